@@ -17,7 +17,10 @@ package client
 
 import (
 	"crypto/tls"
+	"errors"
 	"net"
+	"runtime"
+	"strings"
 )
 
 type Sender interface {
@@ -29,7 +32,27 @@ type NetworkSender struct {
 	connection net.Conn
 }
 
+// Checks that unix domain socket addresses can be used on this platform,
+// abstract ones (with a leading "@") only exist on linux.
+func checkAddr(proto, addr string) error {
+	if !strings.HasPrefix(proto, "unix") {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return errors.New("Can't use Unix domain sockets on Windows.")
+	}
+	if strings.HasPrefix(addr, "@") && runtime.GOOS != "linux" {
+		return errors.New("Abstract sockets are linux-specific.")
+	}
+	return nil
+}
+
+// Creates a sender for any of the networks supported by `net.Dial`, including
+// the "unix", "unixgram" and "unixpacket" domain sockets.
 func NewNetworkSender(proto, addr string) (*NetworkSender, error) {
+	if err := checkAddr(proto, addr); err != nil {
+		return nil, err
+	}
 	var sender *NetworkSender
 	conn, err := net.Dial(proto, addr)
 	if err == nil {
@@ -39,6 +62,9 @@ func NewNetworkSender(proto, addr string) (*NetworkSender, error) {
 }
 
 func NewTlsSender(proto, addr string, config *tls.Config) (*NetworkSender, error) {
+	if err := checkAddr(proto, addr); err != nil {
+		return nil, err
+	}
 	var sender *NetworkSender
 	conn, err := tls.Dial(proto, addr, config)
 	if err == nil {
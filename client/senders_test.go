@@ -0,0 +1,70 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package client
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestUnixDatagramSender(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix sockets on windows")
+	}
+	tmpDir, err := ioutil.TempDir("", "senders-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "heka.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sender, err := NewNetworkSender("unixgram", path)
+	if err != nil {
+		t.Fatalf("NewNetworkSender failed: %s", err)
+	}
+	defer sender.Close()
+	if err = sender.SendMessage([]byte("datagram")); err != nil {
+		t.Fatalf("SendMessage failed: %s", err)
+	}
+	buf := make([]byte, 100)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "datagram" {
+		t.Errorf("expected 'datagram', received '%s'", buf[:n])
+	}
+}
+
+func TestAbstractSocketAddress(t *testing.T) {
+	err := checkAddr("unix", "@heka")
+	if runtime.GOOS == "linux" && err != nil {
+		t.Errorf("abstract address rejected on linux: %s", err)
+	} else if runtime.GOOS != "linux" && err == nil {
+		t.Error("abstract address accepted outside of linux")
+	}
+	if err = checkAddr("tcp", "@heka"); err != nil {
+		t.Errorf("tcp address rejected: %s", err)
+	}
+}
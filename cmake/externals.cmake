@@ -167,6 +167,7 @@ git_clone(https://github.com/eapache/go-resiliency v1.0.0)
 git_clone(https://github.com/eapache/queue v1.0.2)
 git_clone_to_path(https://github.com/rafrombrc/sarama f742e1e20b15b31320e0b6ff2f995bc5f0482fed github.com/Shopify/sarama)
 git_clone(https://github.com/davecgh/go-spew 2df174808ee097f90d259e432cc04442cf60be21)
+git_clone(https://github.com/ulikunitz/xz v0.5.12)
 
 add_dependencies(sarama snappy)
 
@@ -174,6 +175,10 @@ if (INCLUDE_GEOIP)
     add_external_plugin(git https://github.com/abh/geoip da130741c8ed2052f5f455d56e552f2e997e1ce9)
 endif()
 
+if (INCLUDE_ZSTD)
+    git_clone(https://github.com/klauspost/compress v1.15.9)
+endif()
+
 if (INCLUDE_DOCKER_PLUGINS)
     git_clone(https://github.com/fsouza/go-dockerclient 175e1df973274f04e9b459a62cffc49808f1a649)
 endif()
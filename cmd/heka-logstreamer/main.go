@@ -26,7 +26,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/bbangert/toml"
@@ -36,29 +38,69 @@ import (
 
 // Logstreamer config struct
 type LogstreamerConfig struct {
-	LogDirectory   string `toml:"log_directory"`
-	FileMatch      string `toml:"file_match"`
-	Priority       []string
-	Differentiator []string
-	OldestDuration string `toml:"oldest_duration"`
-	Translation    logstreamer.SubmatchTranslationMap
-	InitialTail    bool `toml:"initial_tail"`
+	LogDirectory     string   `toml:"log_directory"`
+	LogDirectories   []string `toml:"log_directories"`
+	JournalDirectory string   `toml:"journal_directory"`
+	FileMatch        string   `toml:"file_match"`
+	FileGlob         []string `toml:"file_glob"`
+	Exclude          []string
+	MaxDepth         int `toml:"max_depth"`
+	Priority         []string
+	Differentiator   []string
+	OldestDuration   string `toml:"oldest_duration"`
+	Translation      logstreamer.SubmatchTranslationMap
+	InitialTail      bool `toml:"initial_tail"`
 }
 
 type Basic struct {
 	PluginType string `toml:"type"`
 }
 
+// The hekad settings the default journal directory depends on.
+type HekadConfig struct {
+	BaseDir string `toml:"base_dir"`
+}
+
 // File's config
 type FileConfig map[string]toml.Primitive
 
+// A LogstreamerInput section of the config file.
+type Input struct {
+	Name   string
+	Config *LogstreamerConfig
+}
+
+const usage = `Usage: heka-logstreamer -config=<config file or dir> [command]
+
+Commands:
+  scan        Show the logstreams and logfiles each LogstreamerInput finds
+              (the default).
+  journals    List the journals with the file, offset and hash status of
+              each logstream's saved position.
+  seek [-offset=<bytes> [-file=<logfile>] | -time=<RFC3339 time>] <logstream>
+              Move a logstream's saved position to an offset into one of its
+              logfiles, by default the one it's in, or to the start of the
+              oldest logfile modified since a time.
+  reset <logstream>
+              Remove a logstream's saved position so it's read from the start
+              again, or from the end with initial_tail.
+
+seek and reset refuse to run while hekad is using the journals.
+
+Options:
+`
+
 func main() {
 	configFile := flag.String("config", "logstreamer.toml", "Heka Logstreamer configuration file")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
 	if flag.NFlag() == 0 {
-		flag.PrintDefaults()
+		flag.Usage()
 		os.Exit(0)
 	}
 
@@ -92,39 +134,94 @@ func main() {
 		}
 	}
 
+	hekad := HekadConfig{BaseDir: filepath.FromSlash("/var/cache/hekad")}
+	if prim, ok := fconfig["hekad"]; ok {
+		if err := toml.PrimitiveDecode(prim, &hekad); err != nil {
+			client.LogError.Fatalf("Error decoding [hekad] config: %s", err)
+		}
+	}
+
 	// Filter out logstream inputs
-	inputs := make(map[string]toml.Primitive)
+	var inputs []*Input
 	for name, prim := range fconfig {
 		basic := new(Basic)
 		if name == "LogstreamerInput" {
-			inputs[name] = prim
+			inputs = append(inputs, decodeInput(name, prim, hekad.BaseDir))
 		} else if err := toml.PrimitiveDecode(prim, &basic); err == nil {
 			if basic.PluginType == "LogstreamerInput" {
-				inputs[name] = prim
+				inputs = append(inputs, decodeInput(name, prim, hekad.BaseDir))
 			}
 		}
 	}
+	sort.Sort(byName(inputs))
 
-	// Go through the logstreams and parse their configs
-	for name, prim := range inputs {
-		parseConfig(name, prim)
+	command, args := "scan", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "scan":
+		// Go through the logstreams and parse their configs
+		for _, input := range inputs {
+			parseConfig(input)
+		}
+	case "journals":
+		listJournals(inputs)
+	case "seek":
+		seekLogstream(inputs, args)
+	case "reset":
+		resetLogstream(inputs, args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
+		flag.Usage()
+		os.Exit(2)
 	}
 }
 
-func parseConfig(name string, prim toml.Primitive) {
-	config := LogstreamerConfig{
-		OldestDuration: "720h",
-		Differentiator: []string{name},
-		LogDirectory:   "/var/log",
+type byName []*Input
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func decodeInput(name string, prim toml.Primitive, baseDir string) *Input {
+	config := &LogstreamerConfig{
+		OldestDuration:   "720h",
+		LogDirectory:     "/var/log",
+		JournalDirectory: filepath.Join(baseDir, "logstreamer"),
 	}
-	if err := toml.PrimitiveDecode(prim, &config); err != nil {
-		client.LogError.Printf("Error decoding config file: %s", err)
-		return
+	if err := toml.PrimitiveDecode(prim, config); err != nil {
+		client.LogError.Fatalf("Error decoding config for [%s]: %s", name, err)
 	}
 
 	if len(config.FileMatch) > 0 && config.FileMatch[len(config.FileMatch)-1:] != "$" {
 		config.FileMatch += "$"
 	}
+	if len(config.Differentiator) == 0 {
+		if config.FileMatch == "" {
+			config.Differentiator = []string{logstreamer.FilePathMatchPart}
+		} else {
+			config.Differentiator = []string{name}
+		}
+	}
+	return &Input{Name: name, Config: config}
+}
+
+// Creates the input's LogstreamSet, loading the positions from the journals
+// in journalRoot unless it's empty.
+func newLogstreamSet(input *Input, journalRoot string) (*logstreamer.LogstreamSet,
+	error) {
+
+	config := input.Config
+	selection := &logstreamer.FileSelection{
+		Roots:    config.LogDirectories,
+		Globs:    config.FileGlob,
+		Exclude:  config.Exclude,
+		MaxDepth: config.MaxDepth,
+	}
+	if len(selection.Roots) == 0 {
+		selection.Roots = []string{config.LogDirectory}
+	}
 
 	sp := &logstreamer.SortPattern{
 		FileMatch:      config.FileMatch,
@@ -133,7 +230,13 @@ func parseConfig(name string, prim toml.Primitive) {
 		Differentiator: config.Differentiator,
 	}
 	oldest, _ := time.ParseDuration(config.OldestDuration)
-	ls, err := logstreamer.NewLogstreamSet(sp, oldest, config.LogDirectory, "", config.InitialTail)
+	return logstreamer.NewLogstreamSetWithSelection(sp, oldest, selection, journalRoot,
+		config.InitialTail)
+}
+
+func parseConfig(input *Input) {
+	name := input.Name
+	ls, err := newLogstreamSet(input, "")
 	if err != nil {
 		client.LogError.Fatalf("Error initializing LogstreamSet: %s\n", err.Error())
 	}
@@ -155,3 +258,174 @@ func parseConfig(name string, prim toml.Primitive) {
 		}
 	}
 }
+
+// Returns the journal directories of the inputs, in order, with the sections
+// using each of them.
+func journalDirectories(inputs []*Input) (dirs []string, sections map[string][]string) {
+	sections = make(map[string][]string)
+	for _, input := range inputs {
+		dir := input.Config.JournalDirectory
+		if _, ok := sections[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		sections[dir] = append(sections[dir], "["+input.Name+"]")
+	}
+	return dirs, sections
+}
+
+// Returns how a saved position relates to the file it's in.
+func hashStatus(location *logstreamer.LogstreamLocation) string {
+	if location.Filename == "" {
+		return "empty"
+	}
+	if _, err := os.Stat(location.Filename); err != nil {
+		return "missing file"
+	}
+	if location.HashMismatch() {
+		return "mismatch"
+	}
+	return "valid"
+}
+
+func listJournals(inputs []*Input) {
+	dirs, sections := journalDirectories(inputs)
+	for i, dir := range dirs {
+		if i > 0 {
+			fmt.Println()
+		}
+		inUse := ""
+		if locked, _ := logstreamer.JournalsLocked(dir); locked {
+			inUse = " (in use by hekad)"
+		}
+		fmt.Printf("Journal directory: %s%s, used by %s\n", dir, inUse,
+			strings.Join(sections[dir], ", "))
+
+		journals, err := logstreamer.LoadJournals(dir)
+		if err != nil {
+			client.LogError.Printf("Error loading journals: %s", err)
+			continue
+		}
+		names := make([]string, 0, len(journals))
+		for name := range journals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "\tLOGSTREAM\tFILE\tOFFSET\tHASH")
+		for _, name := range names {
+			location := journals[name]
+			fmt.Fprintf(w, "\t%s\t%s\t%d\t%s\n", name, location.Filename,
+				location.SeekPosition, hashStatus(location))
+		}
+		w.Flush()
+	}
+}
+
+// Locks the journal directory for changing the journals in it, exits if
+// hekad is using them.
+func lockJournals(dir string) *logstreamer.JournalLock {
+	lock, err := logstreamer.LockJournals(dir, true)
+	if err == logstreamer.ErrJournalsLocked {
+		client.LogError.Fatalf("The journals in %s are in use by hekad, stop it first.",
+			dir)
+	} else if err != nil {
+		client.LogError.Fatalf("Error locking journal directory %s: %s", dir, err)
+	}
+	return lock
+}
+
+// Returns the input that finds the logstream and the logstream itself.
+func findLogstream(inputs []*Input, name string) (*Input, *logstreamer.Logstream) {
+	for _, input := range inputs {
+		ls, err := newLogstreamSet(input, "")
+		if err != nil {
+			client.LogError.Fatalf("Error initializing LogstreamSet for [%s]: %s",
+				input.Name, err)
+		}
+		ls.ScanForLogstreams()
+		if stream, ok := ls.GetLogstream(name); ok {
+			return input, stream
+		}
+	}
+	client.LogError.Fatalf("No LogstreamerInput finds a logstream named '%s'.", name)
+	return nil, nil
+}
+
+func seekLogstream(inputs []*Input, args []string) {
+	flags := flag.NewFlagSet("seek", flag.ExitOnError)
+	offset := flags.Int64("offset", -1, "Offset in bytes into the logfile")
+	file := flags.String("file", "", "Logfile the offset is in, defaults to the current one")
+	at := flags.String("time", "", "Seek to the oldest logfile modified since this RFC3339 time")
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*offset < 0) == (*at == "") {
+		client.LogError.Fatalln("seek needs a logstream and either -offset or -time")
+	}
+	name := flags.Arg(0)
+
+	input, stream := findLogstream(inputs, name)
+	dir := input.Config.JournalDirectory
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		client.LogError.Fatalf("Error creating journal directory %s: %s", dir, err)
+	}
+	lock := lockJournals(dir)
+	defer lock.Unlock()
+	location, err := logstreamer.LogstreamLocationFromFile(filepath.Join(dir, name))
+	if err != nil {
+		client.LogError.Fatalf("Error loading journal: %s", err)
+	}
+	logfiles := stream.GetLogfiles()
+
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			client.LogError.Fatalf("Invalid time: %s", err)
+		}
+		err = location.SeekToTime(logfiles, t)
+	} else {
+		if *file == "" {
+			if location.Filename == "" {
+				client.LogError.Fatalf("Logstream '%s' has no saved position, -file is needed.",
+					name)
+			}
+			*file = location.Filename
+		}
+		if logfiles.IndexOf(*file) == -1 {
+			client.LogError.Fatalf("'%s' isn't one of the logfiles of logstream '%s'.",
+				*file, name)
+		}
+		err = location.SeekTo(*file, *offset)
+	}
+	if err != nil {
+		client.LogError.Fatalf("Error seeking: %s", err)
+	}
+	if err = location.Save(); err != nil {
+		client.LogError.Fatalf("Error saving journal: %s", err)
+	}
+	fmt.Printf("Logstream '%s' moved to %s, offset %d.\n", name, location.Filename,
+		location.SeekPosition)
+}
+
+func resetLogstream(inputs []*Input, args []string) {
+	if len(args) != 1 {
+		client.LogError.Fatalln("reset needs a logstream")
+	}
+	name := args[0]
+
+	// The logstream's logfiles may be gone, so look for its journal.
+	dirs, _ := journalDirectories(inputs)
+	for _, dir := range dirs {
+		journalPath := filepath.Join(dir, name)
+		if _, err := os.Stat(journalPath); err != nil {
+			continue
+		}
+		lock := lockJournals(dir)
+		defer lock.Unlock()
+		if err := os.Remove(journalPath); err != nil {
+			client.LogError.Fatalf("Error removing journal: %s", err)
+		}
+		fmt.Printf("Logstream '%s' reset.\n", name)
+		return
+	}
+	client.LogError.Fatalf("No journal found for logstream '%s'.", name)
+}
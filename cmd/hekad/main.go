@@ -43,14 +43,18 @@ import (
 	_ "github.com/mozilla-services/heka/plugins/graphite"
 	_ "github.com/mozilla-services/heka/plugins/http"
 	_ "github.com/mozilla-services/heka/plugins/irc"
+	_ "github.com/mozilla-services/heka/plugins/journald"
 	_ "github.com/mozilla-services/heka/plugins/kafka"
 	_ "github.com/mozilla-services/heka/plugins/logstreamer"
 	_ "github.com/mozilla-services/heka/plugins/nagios"
 	_ "github.com/mozilla-services/heka/plugins/nfnty"
+	_ "github.com/mozilla-services/heka/plugins/objstore"
+	_ "github.com/mozilla-services/heka/plugins/parquet"
 	_ "github.com/mozilla-services/heka/plugins/payload"
 	_ "github.com/mozilla-services/heka/plugins/process"
 	_ "github.com/mozilla-services/heka/plugins/smtp"
 	_ "github.com/mozilla-services/heka/plugins/statsd"
+	_ "github.com/mozilla-services/heka/plugins/syslog"
 	_ "github.com/mozilla-services/heka/plugins/tcp"
 	_ "github.com/mozilla-services/heka/plugins/udp"
 )
@@ -0,0 +1,31 @@
+// +build !zstd
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"io"
+	"os"
+)
+
+// Whether zstd compressed files can be read, which needs the zstd build tag.
+const zstdSupported = false
+
+func newZstdReader(fd *os.File) (io.Reader, error) {
+	return nil, ErrorZstdNotSupported
+}
+
+func closeZstdReader(reader io.Reader) {}
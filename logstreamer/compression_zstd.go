@@ -0,0 +1,40 @@
+// +build zstd
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Whether zstd compressed files can be read, which needs the zstd build tag.
+const zstdSupported = true
+
+func newZstdReader(fd *os.File) (io.Reader, error) {
+	// A single decoding goroutine keeps the decoder from running ahead of
+	// the reads or holding on to resources once the file is closed.
+	return zstd.NewReader(fd, zstd.WithDecoderConcurrency(1))
+}
+
+// The zstd decoder holds on to its buffers until it is closed.
+func closeZstdReader(reader io.Reader) {
+	if decoder, ok := reader.(*zstd.Decoder); ok && decoder != nil {
+		decoder.Close()
+	}
+}
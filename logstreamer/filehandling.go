@@ -106,6 +106,10 @@ type Logfile struct {
 	// MatchParts maps to integers used for sorting the Logfile within the
 	// Logstream
 	MatchParts map[string]int
+	// The sub-expression names and matches the match parts are populated
+	// from, set when the logfile was found by a LogstreamSet.
+	subexpNames []string
+	matches     []string
 }
 
 // Populate the MatchParts of a Logfile supplied with the sub-expression names,
@@ -371,14 +375,20 @@ type LogfilesMap map[string]Logfiles
 // logfiles into each logstream
 type LogstreamSet struct {
 	logstreams     map[string]*Logstream
-	rescanInterval time.Duration  // Frequency of full rescan
-	oldestDuration time.Duration  // Filter logfiles older than this duration ago
-	sortPattern    *SortPattern   // Used for creating logstreams and updating logfiles
-	logstreamMutex *sync.RWMutex  // Locking for manipulation of logstreams
-	logRoot        string         // Base path to walk for logfiles (ie, /var/log)
-	journalRoot    string         // Base path for journal files (ie, /etc/journals)
-	fileMatch      *regexp.Regexp // File match for regular expression
-	initialTail    bool           // Whether to ignore previous logfiles while initial scan
+	rescanInterval time.Duration // Frequency of full rescan
+	oldestDuration time.Duration // Filter logfiles older than this duration ago
+	sortPattern    *SortPattern  // Used for creating logstreams and updating logfiles
+	logstreamMutex *sync.RWMutex // Locking for manipulation of logstreams
+	selector       *fileSelector // Roots and patterns of the logfiles (ie, /var/log)
+	journalRoot    string        // Base path for journal files (ie, /etc/journals)
+	initialTail    bool          // Whether to ignore previous logfiles while initial scan
+	// Set while watching the log roots, the logfiles known from the watch
+	// events and the logstream each of them belongs to.
+	watchMutex  sync.Mutex
+	watcher     *Watcher
+	knownFiles  map[string]*Logfile
+	fileStreams map[string]string
+	walkNeeded  bool // Whether watch events were lost since the last walk
 }
 
 // append a path separator if needed and escape regexp meta characters
@@ -392,6 +402,18 @@ func fileMatchRegexp(logRoot, fileMatch string) *regexp.Regexp {
 
 func NewLogstreamSet(sortPattern *SortPattern, oldest time.Duration,
 	logRoot, journalRoot string, initialTail bool) (*LogstreamSet, error) {
+
+	selection := &FileSelection{Roots: []string{logRoot}}
+	return NewLogstreamSetWithSelection(sortPattern, oldest, selection, journalRoot,
+		initialTail)
+}
+
+// Creates a LogstreamSet whose logfiles may be under several roots and be
+// selected by glob patterns as well as the sort pattern's FileMatch.
+func NewLogstreamSetWithSelection(sortPattern *SortPattern, oldest time.Duration,
+	selection *FileSelection, journalRoot string, initialTail bool) (*LogstreamSet,
+	error) {
+
 	// Lowercase the actual matching keys.
 	newTranslation := make(SubmatchTranslationMap)
 	for key, val := range sortPattern.Translation {
@@ -403,7 +425,7 @@ func NewLogstreamSet(sortPattern *SortPattern, oldest time.Duration,
 	}
 	sortPattern.Translation = newTranslation
 
-	realLogRoot, err := filepath.EvalSymlinks(logRoot)
+	selector, err := newFileSelector(selection, sortPattern.FileMatch)
 	if err != nil {
 		return nil, err
 	}
@@ -411,10 +433,9 @@ func NewLogstreamSet(sortPattern *SortPattern, oldest time.Duration,
 		logstreams:     make(map[string]*Logstream),
 		oldestDuration: oldest,
 		sortPattern:    sortPattern,
-		logRoot:        realLogRoot,
+		selector:       selector,
 		journalRoot:    journalRoot,
 		logstreamMutex: new(sync.RWMutex),
-		fileMatch:      fileMatchRegexp(realLogRoot, sortPattern.FileMatch),
 		initialTail:    initialTail,
 	}
 	return ls, nil
@@ -451,7 +472,7 @@ func (ls *LogstreamSet) ScanForLogstreams() (result []string, errors *MultipleEr
 	errors = NewMultipleError()
 
 	// Scan for all our logfiles
-	logfiles := ScanDirectoryForLogfiles(ls.logRoot, ls.fileMatch)
+	logfiles := ls.findLogfiles()
 
 	// Filter out old logfiles
 	if ls.oldestDuration != time.Duration(0) {
@@ -459,7 +480,10 @@ func (ls *LogstreamSet) ScanForLogstreams() (result []string, errors *MultipleEr
 	}
 
 	// Setup all the sorting ints in every logfile
-	logfiles.PopulateMatchParts(ls.fileMatch, ls.sortPattern.Translation)
+	for _, logfile := range logfiles {
+		logfile.PopulateMatchParts(logfile.subexpNames, logfile.matches,
+			ls.sortPattern.Translation)
+	}
 
 	// Split up the logfiles into a map
 	mfs := FilterMultipleStreamFiles(logfiles, ls.sortPattern.Differentiator)
@@ -516,6 +540,7 @@ func (ls *LogstreamSet) ScanForLogstreams() (result []string, errors *MultipleEr
 		// Update the logstream with the logfiles
 		logstream.UpdateLogfiles(newLogfiles)
 	}
+	ls.setFileStreams(mfs)
 	return
 }
 
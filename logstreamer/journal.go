@@ -0,0 +1,194 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mozilla-services/heka/ringbuf"
+)
+
+// Returned by LockJournals when the journal directory is locked by another
+// process, e.g. a running hekad when trying to change the journals.
+var ErrJournalsLocked = errors.New("journal directory is locked by another process")
+
+// The file in a journal directory that the journals are locked through.
+const journalLockName = ".lock"
+
+// A lock on a journal directory. A running hekad holds shared locks on the
+// journal directories of its LogstreamerInputs, tools changing the journals
+// hold an exclusive one.
+type JournalLock struct {
+	file *os.File
+}
+
+// Locks the journal directory without waiting, returns ErrJournalsLocked if
+// the lock is held by another process in a conflicting mode.
+func LockJournals(journalRoot string, exclusive bool) (*JournalLock, error) {
+	file, err := os.OpenFile(filepath.Join(journalRoot, journalLockName),
+		os.O_CREATE|os.O_RDONLY, 0660)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &JournalLock{file: file}, nil
+}
+
+// Like LockJournals, but keeps trying for up to `timeout` while the lock is
+// held in a conflicting mode, since heka-logstreamer briefly takes it to
+// check whether the journals are in use.
+func LockJournalsWait(journalRoot string, exclusive bool,
+	timeout time.Duration) (*JournalLock, error) {
+
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := LockJournals(journalRoot, exclusive)
+		if err != ErrJournalsLocked || !time.Now().Before(deadline) {
+			return lock, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Tells whether another process holds a lock on the journal directory,
+// without creating the lock file if there's none.
+func JournalsLocked(journalRoot string) (bool, error) {
+	file, err := os.Open(filepath.Join(journalRoot, journalLockName))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	// Closing the file releases the lock right away.
+	defer file.Close()
+	if err = lockFile(file, true); err == ErrJournalsLocked {
+		return true, nil
+	}
+	return false, err
+}
+
+func (jl *JournalLock) Unlock() error {
+	// Closing the file releases the lock.
+	return jl.file.Close()
+}
+
+// Loads all the journals in a journal directory, keyed by logstream name.
+func LoadJournals(journalRoot string) (map[string]*LogstreamLocation, error) {
+	infos, err := ioutil.ReadDir(journalRoot)
+	if err != nil {
+		return nil, err
+	}
+	journals := make(map[string]*LogstreamLocation)
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		journalPath := filepath.Join(journalRoot, info.Name())
+		location, err := LogstreamLocationFromFile(journalPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", journalPath, err)
+		}
+		journals[info.Name()] = location
+	}
+	return journals, nil
+}
+
+// Returns true if the stored hash concretely doesn't match the contents of
+// the stored file at the stored position, see Logstream.FileHashMismatch.
+func (l *LogstreamLocation) HashMismatch() bool {
+	// We always match our hash if we have no hash
+	if l.Hash == "" {
+		return false
+	}
+	// Checking the hash fills in the last line, which mustn't change here.
+	position := *l
+	position.lastLine = ringbuf.New(LINEBUFFERLEN)
+	fd, reader, err := SeekInFile(l.Filename, &position)
+	if err == ErrorCantSeekPosition {
+		return true
+	} else if err == nil {
+		closeFileReader(fd, reader)
+	}
+	return false
+}
+
+// Moves the location to an offset into a file, with the hash of the data
+// before it, as if the file had been read up to there. Offsets into
+// compressed files are offsets into the decompressed data.
+func (l *LogstreamLocation) SeekTo(filePath string, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("Invalid offset %d", offset)
+	}
+	fd, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	reader, err := createFileReader(filePath, fd)
+	defer closeFileReader(fd, reader)
+	if err != nil {
+		return err
+	}
+
+	lastLineLen := int64(LINEBUFFERLEN)
+	if offset < lastLineLen {
+		lastLineLen = offset
+	}
+	if skip := offset - lastLineLen; skip > 0 {
+		if _, err = io.CopyN(ioutil.Discard, reader, skip); err != nil {
+			return fmt.Errorf("Offset %d is past the end of %s", offset, filePath)
+		}
+	}
+	buf := make([]byte, lastLineLen)
+	if _, err = io.ReadFull(reader, buf); err != nil {
+		return fmt.Errorf("Offset %d is past the end of %s", offset, filePath)
+	}
+
+	l.Filename = filePath
+	l.SeekPosition = offset
+	l.Hash = ""
+	l.lastLine = ringbuf.New(LINEBUFFERLEN)
+	l.lastLine.Write(buf)
+	l.GenerateHash()
+	return nil
+}
+
+// Moves the location to the start of the oldest of the logfiles that was
+// modified at or after the time, or to the end of the newest if they are all
+// older. The logfiles are expected in order from oldest to newest.
+func (l *LogstreamLocation) SeekToTime(logfiles Logfiles, t time.Time) error {
+	if len(logfiles) == 0 {
+		return errors.New("No logfiles to seek in")
+	}
+	for _, logfile := range logfiles {
+		info, err := os.Stat(logfile.FileName)
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(t) {
+			return l.SeekTo(logfile.FileName, 0)
+		}
+	}
+	return l.SetToTail(logfiles[len(logfiles)-1].FileName)
+}
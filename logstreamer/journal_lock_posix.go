@@ -0,0 +1,34 @@
+// +build !windows
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrJournalsLocked
+	}
+	return err
+}
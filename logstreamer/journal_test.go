@@ -0,0 +1,216 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func JournalSpec(c gs.Context) {
+	tmpDir, err := ioutil.TempDir("", "journal-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	logDir := filepath.Join(tmpDir, "logs")
+	journalDir := filepath.Join(tmpDir, "journals")
+	c.Assume(os.MkdirAll(logDir, 0755), gs.IsNil)
+	c.Assume(os.MkdirAll(journalDir, 0755), gs.IsNil)
+
+	line := []byte("a line of the logfile, long enough to need a few of them\n")
+	contents := bytes.Repeat(line, 40)
+	oldLog := filepath.Join(logDir, "app.log.1")
+	newLog := filepath.Join(logDir, "app.log")
+	c.Assume(ioutil.WriteFile(oldLog, contents, 0644), gs.IsNil)
+	c.Assume(ioutil.WriteFile(newLog, contents, 0644), gs.IsNil)
+	now := time.Now()
+	c.Assume(os.Chtimes(oldLog, now.Add(-2*time.Hour), now.Add(-2*time.Hour)), gs.IsNil)
+	c.Assume(os.Chtimes(newLog, now, now), gs.IsNil)
+	logfiles := Logfiles{&Logfile{FileName: oldLog}, &Logfile{FileName: newLog}}
+
+	newLocation := func(name string) *LogstreamLocation {
+		location, err := LogstreamLocationFromFile(filepath.Join(journalDir, name))
+		c.Assume(err, gs.IsNil)
+		return location
+	}
+
+	c.Specify("Seeking to an offset", func() {
+		location := newLocation("app")
+
+		c.Specify("hashes the data before it", func() {
+			offset := int64(len(contents) / 2)
+			err := location.SeekTo(newLog, offset)
+			c.Expect(err, gs.IsNil)
+			c.Expect(location.Filename, gs.Equals, newLog)
+			c.Expect(location.SeekPosition, gs.Equals, offset)
+			c.Expect(location.Hash, gs.Not(gs.Equals), "")
+			c.Expect(location.HashMismatch(), gs.IsFalse)
+
+			// Reading the file up to the offset gives the same hash.
+			read := newLocation("read")
+			fd, reader, err := SeekInFile(newLog, read)
+			c.Assume(err, gs.IsNil)
+			defer fd.Close()
+			buf := make([]byte, offset)
+			_, err = reader.Read(buf)
+			c.Assume(err, gs.IsNil)
+			read.lastLine.Write(buf)
+			read.GenerateHash()
+			c.Expect(read.Hash, gs.Equals, location.Hash)
+		})
+
+		c.Specify("works close to the start of the file", func() {
+			err := location.SeekTo(newLog, int64(len(line)))
+			c.Expect(err, gs.IsNil)
+			c.Expect(location.Hash, gs.Not(gs.Equals), "")
+			c.Expect(location.HashMismatch(), gs.IsFalse)
+		})
+
+		c.Specify("fails past the end of the file", func() {
+			err := location.SeekTo(newLog, int64(len(contents)+1))
+			c.Expect(err, gs.Not(gs.IsNil))
+			c.Expect(location.Filename, gs.Equals, "")
+		})
+
+		c.Specify("is saved to the journal", func() {
+			c.Assume(location.SeekTo(oldLog, int64(len(line)*10)), gs.IsNil)
+			c.Expect(location.Save(), gs.IsNil)
+
+			journals, err := LoadJournals(journalDir)
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(journals), gs.Equals, 1)
+			saved := journals["app"]
+			c.Assume(saved, gs.Not(gs.IsNil))
+			c.Expect(saved.Filename, gs.Equals, oldLog)
+			c.Expect(saved.SeekPosition, gs.Equals, int64(len(line)*10))
+			c.Expect(saved.Hash, gs.Equals, location.Hash)
+			c.Expect(saved.HashMismatch(), gs.IsFalse)
+		})
+	})
+
+	c.Specify("A hash mismatch is reported when the file changed", func() {
+		location := newLocation("app")
+		c.Assume(location.SeekTo(oldLog, int64(len(contents))), gs.IsNil)
+		changed := bytes.Replace(contents, []byte("line"), []byte("LINE"), -1)
+		c.Assume(ioutil.WriteFile(oldLog, changed, 0644), gs.IsNil)
+		c.Expect(location.HashMismatch(), gs.IsTrue)
+		// Checking doesn't move the location.
+		c.Expect(location.Filename, gs.Equals, oldLog)
+		c.Expect(location.SeekPosition, gs.Equals, int64(len(contents)))
+	})
+
+	c.Specify("Seeking to a time", func() {
+		location := newLocation("app")
+
+		c.Specify("moves to the oldest logfile modified since", func() {
+			err := location.SeekToTime(logfiles, now.Add(-3*time.Hour))
+			c.Expect(err, gs.IsNil)
+			c.Expect(location.Filename, gs.Equals, oldLog)
+			c.Expect(location.SeekPosition, gs.Equals, int64(0))
+
+			err = location.SeekToTime(logfiles, now.Add(-time.Hour))
+			c.Expect(err, gs.IsNil)
+			c.Expect(location.Filename, gs.Equals, newLog)
+			c.Expect(location.SeekPosition, gs.Equals, int64(0))
+		})
+
+		c.Specify("moves to the end when nothing is newer", func() {
+			err := location.SeekToTime(logfiles, now.Add(time.Hour))
+			c.Expect(err, gs.IsNil)
+			c.Expect(location.Filename, gs.Equals, newLog)
+			c.Expect(location.SeekPosition, gs.Equals, int64(len(contents)))
+		})
+	})
+
+	c.Specify("Loading journals skips the lock file", func() {
+		lock, err := LockJournals(journalDir, false)
+		c.Assume(err, gs.IsNil)
+		defer lock.Unlock()
+		journals, err := LoadJournals(journalDir)
+		c.Expect(err, gs.IsNil)
+		c.Expect(len(journals), gs.Equals, 0)
+	})
+
+	if runtime.GOOS != "windows" {
+		c.Specify("Journal locks", func() {
+			shared, err := LockJournals(journalDir, false)
+			c.Assume(err, gs.IsNil)
+
+			c.Specify("can be shared", func() {
+				other, err := LockJournals(journalDir, false)
+				c.Expect(err, gs.IsNil)
+				c.Expect(other.Unlock(), gs.IsNil)
+				c.Expect(shared.Unlock(), gs.IsNil)
+			})
+
+			c.Specify("can't be exclusive while shared", func() {
+				_, err := LockJournals(journalDir, true)
+				c.Expect(err, gs.Equals, ErrJournalsLocked)
+				c.Expect(shared.Unlock(), gs.IsNil)
+
+				exclusive, err := LockJournals(journalDir, true)
+				c.Expect(err, gs.IsNil)
+				_, err = LockJournals(journalDir, false)
+				c.Expect(err, gs.Equals, ErrJournalsLocked)
+				c.Expect(exclusive.Unlock(), gs.IsNil)
+			})
+
+			c.Specify("can be waited for", func() {
+				c.Expect(shared.Unlock(), gs.IsNil)
+				exclusive, err := LockJournals(journalDir, true)
+				c.Assume(err, gs.IsNil)
+				_, err = LockJournalsWait(journalDir, false, 10*time.Millisecond)
+				c.Expect(err, gs.Equals, ErrJournalsLocked)
+
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					exclusive.Unlock()
+				}()
+				shared, err = LockJournalsWait(journalDir, false, 5*time.Second)
+				c.Expect(err, gs.IsNil)
+				c.Expect(shared.Unlock(), gs.IsNil)
+			})
+
+			c.Specify("are checked without being held", func() {
+				locked, err := JournalsLocked(journalDir)
+				c.Expect(err, gs.IsNil)
+				c.Expect(locked, gs.IsTrue)
+				other, err := LockJournals(journalDir, false)
+				c.Expect(err, gs.IsNil)
+				c.Expect(other.Unlock(), gs.IsNil)
+				c.Expect(shared.Unlock(), gs.IsNil)
+
+				locked, err = JournalsLocked(journalDir)
+				c.Expect(err, gs.IsNil)
+				c.Expect(locked, gs.IsFalse)
+			})
+		})
+
+		c.Specify("Checking journal locks doesn't create the lock file", func() {
+			emptyDir := filepath.Join(journalDir, "empty")
+			c.Assume(os.Mkdir(emptyDir, 0700), gs.IsNil)
+			locked, err := JournalsLocked(emptyDir)
+			c.Expect(err, gs.IsNil)
+			c.Expect(locked, gs.IsFalse)
+			_, err = os.Stat(filepath.Join(emptyDir, journalLockName))
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+		})
+	}
+}
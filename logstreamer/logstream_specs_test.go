@@ -22,6 +22,9 @@ import (
 func TestAllSpecs(t *testing.T) {
 	r := gospec.NewRunner()
 	r.AddSpec(FilehandlingSpec)
+	r.AddSpec(JournalSpec)
 	r.AddSpec(ReaderSpec)
+	r.AddSpec(SelectorSpec)
+	r.AddSpec(WatcherSpec)
 	gospec.MainGoTest(r, t)
 }
@@ -17,6 +17,7 @@ package logstreamer
 
 import (
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"crypto/sha1"
 	"encoding/json"
@@ -27,6 +28,7 @@ import (
 	"os"
 
 	"github.com/mozilla-services/heka/ringbuf"
+	"github.com/ulikunitz/xz"
 )
 
 // A location in a logstream indicating the farthest that has been read
@@ -240,15 +242,13 @@ func (l *Logstream) NewerFileAvailable() (file string, ok bool) {
 		// reported during the next ticker interval rescan.
 		l.set.ScanForLogstreams()
 
-		fd, _, err := l.LocatePriorLocation(false)
+		fd, reader, err := l.LocatePriorLocation(false)
 
 		if err != nil && IsFileError(err) {
 			return "", false
 		}
 
-		if fd != nil {
-			fd.Close()
-		}
+		closeFileReader(fd, reader)
 
 		if err != nil {
 			// Unable to locate prior position in our file-stream, are there
@@ -307,11 +307,11 @@ func (l *Logstream) FileHashMismatch() bool {
 		return false
 	}
 
-	fd, _, err := SeekInFile(l.position.Filename, l.position)
+	fd, reader, err := SeekInFile(l.position.Filename, l.position)
 	if err == ErrorCantSeekPosition {
 		return true
 	} else if err == nil {
-		fd.Close()
+		closeFileReader(fd, reader)
 	}
 	return false
 }
@@ -354,7 +354,7 @@ func (l *Logstream) LocatePriorLocation(checkFilename bool) (fd *os.File, reader
 		if err != nil {
 			return
 		}
-		if !isGzipFile(logfile.FileName) {
+		if fileCompression(logfile.FileName) == uncompressed {
 			if info.Size() < l.position.SeekPosition {
 				continue
 			}
@@ -371,44 +371,89 @@ func (l *Logstream) LocatePriorLocation(checkFilename bool) (fd *os.File, reader
 			return
 		}
 		err = nil // Reset our error to nil
-		if fd != nil {
-			fd.Close()
-		}
+		closeFileReader(fd, reader)
 	}
 	// Set our default error since we were unable to locate the position
 	err = errors.New("Unable to locate position in the stream")
 	return
 }
 
-// Returns an io.Reader. If file is gzipped, returns a gzip.Reader.
+// The compression formats of rotated logfiles that can be read.
+type compression int
+
+const (
+	uncompressed compression = iota
+	gzipCompressed
+	bzip2Compressed
+	xzCompressed
+	zstdCompressed
+)
+
+// The magic bytes each compression format's files start with.
+var compressionMagic = []struct {
+	format compression
+	magic  []byte
+}{
+	{gzipCompressed, []byte{0x1f, 0x8b}},
+	{bzip2Compressed, []byte("BZh")},
+	{xzCompressed, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{zstdCompressed, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Returns an io.Reader. If file is compressed, returns a reader of the
+// decompressed data.
 func createFileReader(path string, fd *os.File) (reader io.Reader, err error) {
-	if isGzipFile(path) {
+	switch fileCompression(path) {
+	case gzipCompressed:
 		reader, err = gzip.NewReader(fd)
-	} else {
+	case bzip2Compressed:
+		reader = bzip2.NewReader(fd)
+	case xzCompressed:
+		reader, err = xz.NewReader(fd)
+	case zstdCompressed:
+		reader, err = newZstdReader(fd)
+	default:
 		reader = fd
 	}
 	return
 }
 
-// Guesses if the given file is gzipped.
-func isGzipFile(path string) bool {
+// Closes a file opened for createFileReader along with its reader, since the
+// zstd decoder holds on to its buffers until it is closed.
+func closeFileReader(fd *os.File, reader io.Reader) {
+	closeZstdReader(reader)
+	if fd != nil {
+		fd.Close()
+	}
+}
+
+// Guesses the compression format of the given file from its magic bytes.
+func fileCompression(path string) compression {
 	file, err := os.Open(path)
 	if err != nil {
-		return false
+		return uncompressed
 	}
 	defer file.Close()
 
-	magic := make([]byte, 2)
-	numbytes, err := file.Read(magic)
-	if numbytes != 2 || err != nil {
-		return false
+	magic := make([]byte, 6)
+	numbytes, _ := io.ReadFull(file, magic)
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(magic[:numbytes], m.magic) {
+			return m.format
+		}
 	}
-
-	return (magic[0] == 0x1f && magic[1] == 0x8b)
+	return uncompressed
 }
 
 var ErrorCantSeekPosition = errors.New("Unable to locate position")
-var ErrorCantGzipToPosition = errors.New("Couldn't read gzip to seek position")
+var ErrorCantDecompressToPosition = errors.New(
+	"Couldn't read compressed file to seek position")
+var ErrorZstdNotSupported = errors.New(
+	"Can't read zstd compressed files, built without the zstd tag")
+
+// Deprecated: Use ErrorCantDecompressToPosition, which is returned for all
+// the compression formats.
+var ErrorCantGzipToPosition = ErrorCantDecompressToPosition
 
 // SeekInFile opens the file at the given path, seeks to the location
 // specified in the given position, hashes the contents of the file at that
@@ -417,38 +462,49 @@ var ErrorCantGzipToPosition = errors.New("Couldn't read gzip to seek position")
 // the file so there's no hash to compare, then it will return the open file
 // descriptor and related io.Reader. If they do not, or anything goes wrong
 // along the way, then an error will be returned. Note that the fd and the
-// io.Reader will be the same except in cases where the file was gzipped, in
-// which case the io.Reader will be the gzip reader and not the raw file
-// descriptor.
+// io.Reader will be the same except in cases where the file was compressed,
+// in which case the io.Reader will be the decompressing reader and not the
+// raw file descriptor. Seek positions in compressed files are offsets into the
+// decompressed data.
 func SeekInFile(path string, position *LogstreamLocation) (*os.File, io.Reader, error) {
 	fd, err := os.Open(path)
 	if err != nil {
 		return nil, nil, err
 	}
+	reader, err := seekInReader(path, fd, position)
+	if err != nil {
+		closeFileReader(fd, reader)
+		return nil, nil, err
+	}
+	return fd, reader, nil
+}
 
-	var reader io.Reader
+// Does the seeking and hash checking for SeekInFile, returning the reader it
+// created even if it fails, so it can be closed.
+func seekInReader(path string, fd *os.File, position *LogstreamLocation) (
+	reader io.Reader, err error) {
 
 	// Try to get to our seek position, if our seek is 0, then start at the
 	// beginning.
 	if position.SeekPosition == 0 {
-		reader, err = createFileReader(path, fd)
-		return fd, reader, err
+		return createFileReader(path, fd)
 	}
 
 	seekPos := position.SeekPosition - int64(LINEBUFFERLEN)
-	gzipped := isGzipFile(path)
-	if gzipped {
-		reader, err = gzip.NewReader(fd)
+	if fileCompression(path) != uncompressed {
+		// Compressed data can't be seeked into, so decompress up to the
+		// position instead.
+		reader, err = createFileReader(path, fd)
 		if err != nil {
-			return nil, nil, err
+			return reader, err
 		}
 		if seekPos > 0 {
 			n, err := io.CopyN(ioutil.Discard, reader, seekPos)
-			if err != nil {
-				return nil, nil, err
+			if err != nil && err != io.EOF {
+				return reader, err
 			}
 			if n != seekPos {
-				return nil, nil, ErrorCantGzipToPosition
+				return reader, ErrorCantDecompressToPosition
 			}
 		}
 	} else {
@@ -456,7 +512,7 @@ func SeekInFile(path string, position *LogstreamLocation) (*os.File, io.Reader,
 		if seekPos > 0 {
 			_, err = fd.Seek(seekPos, 0)
 			if err != nil {
-				return nil, nil, err
+				return reader, err
 			}
 		}
 	}
@@ -484,10 +540,10 @@ func SeekInFile(path string, position *LogstreamLocation) (*os.File, io.Reader,
 		tmp := fmt.Sprintf("%x", h.Sum(nil))
 		if tmp == position.Hash {
 			position.lastLine.Write(buf)
-			return fd, reader, nil
+			return reader, nil
 		}
 	}
-	return nil, nil, ErrorCantSeekPosition
+	return reader, ErrorCantSeekPosition
 }
 
 // TODO:: Refactor into a different heka package for use by all plugins
@@ -621,7 +677,7 @@ func (l *Logstream) readBytes(p []byte) (n int, err error) {
 		}
 
 		// Some unexpected error, reset everything
-		l.fd.Close()
+		closeFileReader(l.fd, l.reader)
 		l.fd = nil
 		l.reader = nil
 		l.position.Reset()
@@ -663,10 +719,11 @@ func (l *Logstream) readBytes(p []byte) (n int, err error) {
 		return
 	}
 
-	// Create a gzip reader if needed.
+	// Create a decompressing reader if needed.
 	var reader io.Reader
 	reader, err = createFileReader(newerFilename, fd)
 	if err != nil {
+		closeFileReader(fd, reader)
 		return
 	}
 
@@ -676,7 +733,7 @@ func (l *Logstream) readBytes(p []byte) (n int, err error) {
 	// here will be different
 	verifyFilename, vOk := l.NewerFileAvailable()
 	if verifyFilename != newerFilename || !vOk {
-		fd.Close()
+		closeFileReader(fd, reader)
 		// Now try again, hopefully we capture it after rotation this
 		// time, or maybe there's a last batch of data to read
 		return l.Read(p)
@@ -685,7 +742,7 @@ func (l *Logstream) readBytes(p []byte) (n int, err error) {
 	// Ok, we have the handle for the right file, even if it might've
 	// been rotated by now. Commit a flush first.
 	l.FlushBuffer(0)
-	l.fd.Close()
+	closeFileReader(l.fd, l.reader)
 	l.position.Reset()
 
 	l.position.Filename = newerFilename
@@ -18,6 +18,7 @@ package logstreamer
 import (
 	"github.com/mozilla-services/heka/ringbuf"
 	gs "github.com/rafrombrc/gospec/src/gospec"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -193,6 +194,88 @@ func ReaderSpec(c gs.Context) {
 		c.Expect(l.Hash, gs.Equals, "4a9ab34da77c10e87cb6566bbf061d9715985551")
 	})
 
+	c.Specify("Compressed files", func() {
+		compressedPath := filepath.Join(here, "testdir", "compressed")
+		plain, err := ioutil.ReadFile(filepath.Join(compressedPath, "app.log"))
+		c.Assume(err, gs.IsNil)
+		c.Expect(fileCompression(filepath.Join(compressedPath, "app.log")), gs.Equals,
+			uncompressed)
+
+		formats := []struct {
+			ext    string
+			format compression
+		}{
+			{"gz", gzipCompressed},
+			{"bz2", bzip2Compressed},
+			{"xz", xzCompressed},
+		}
+		if zstdSupported {
+			formats = append(formats, struct {
+				ext    string
+				format compression
+			}{"zst", zstdCompressed})
+		} else {
+			c.Specify("are recognized but not read as zstd without the zstd tag", func() {
+				path := filepath.Join(compressedPath, "app.log.zst")
+				c.Expect(fileCompression(path), gs.Equals, zstdCompressed)
+				fd, err := os.Open(path)
+				c.Assume(err, gs.IsNil)
+				reader, err := createFileReader(path, fd)
+				closeFileReader(fd, reader)
+				c.Expect(err, gs.Equals, ErrorZstdNotSupported)
+			})
+		}
+		for _, f := range formats {
+			ext, format := f.ext, f.format
+			path := filepath.Join(compressedPath, "app.log."+ext)
+
+			c.Specify("are recognized and decompressed: "+ext, func() {
+				c.Expect(fileCompression(path), gs.Equals, format)
+				fd, err := os.Open(path)
+				c.Assume(err, gs.IsNil)
+				reader, err := createFileReader(path, fd)
+				c.Expect(err, gs.IsNil)
+				defer closeFileReader(fd, reader)
+				data, err := ioutil.ReadAll(reader)
+				c.Expect(err, gs.IsNil)
+				c.Expect(string(data), gs.Equals, string(plain))
+			})
+
+			c.Specify("are closed along with their reader: "+ext, func() {
+				fd, err := os.Open(path)
+				c.Assume(err, gs.IsNil)
+				reader, err := createFileReader(path, fd)
+				c.Assume(err, gs.IsNil)
+				closeFileReader(fd, reader)
+				_, err = fd.Read(make([]byte, 1))
+				c.Expect(err, gs.Not(gs.IsNil))
+				if format == zstdCompressed {
+					_, err = reader.Read(make([]byte, 1))
+					c.Expect(err, gs.Not(gs.IsNil))
+				}
+			})
+
+			c.Specify("can be resumed by hash: "+ext, func() {
+				position := &LogstreamLocation{
+					SeekPosition: 1000,
+					lastLine:     ringbuf.New(LINEBUFFERLEN),
+				}
+				position.lastLine.Write(plain[1000-LINEBUFFERLEN : 1000])
+				position.GenerateHash()
+				fd, reader, err := SeekInFile(path, position)
+				c.Assume(err, gs.IsNil)
+				defer closeFileReader(fd, reader)
+				data, err := ioutil.ReadAll(reader)
+				c.Expect(err, gs.IsNil)
+				c.Expect(string(data), gs.Equals, string(plain[1000:]))
+
+				position.SeekPosition = int64(len(plain) + 1000)
+				_, _, err = SeekInFile(path, position)
+				c.Expect(err, gs.Equals, ErrorCantDecompressToPosition)
+			})
+		}
+	})
+
 	c.Specify("InitialTail option seeks to the end of a file", func() {
 		regex := `/(?P<Year>\d+)/(?P<Month>\d+)/error\.log(\.(?P<Seq>\d+))?`
 		if runtime.GOOS == "windows" {
@@ -0,0 +1,348 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Where the logfiles of a LogstreamSet are searched for, in addition to the
+// SortPattern's FileMatch regular expression which is matched below each of
+// the roots.
+type FileSelection struct {
+	// Directories to search for logfiles.
+	Roots []string
+	// Glob patterns of logfiles, either absolute or relative to each of the
+	// roots, always using "/" as the separator. Besides "*", "?" and
+	// "[...]", "**" matches any number of directories and "{a,b}" either of
+	// the alternatives. The path of a matched logfile relative to its root,
+	// with separators replaced by underscores, is its "FilePath" match part.
+	Globs []string
+	// Glob patterns of files and directories to leave out, relative to the
+	// roots. Patterns without a "/" are matched against the base names.
+	Exclude []string
+	// How many levels of directories below a root are searched, 0 means no
+	// limit.
+	MaxDepth int
+}
+
+// The name of the match part holding a glob matched logfile's path.
+const FilePathMatchPart = "FilePath"
+
+var filePathReplacer = strings.NewReplacer("/", "_")
+
+// A directory searched for logfiles, with the patterns matched below it.
+type selectorRoot struct {
+	path      string
+	fileMatch *regexp.Regexp // Matched against the full path
+	globs     []*regexp.Regexp
+	// Deepest level the patterns can match, -1 if there's no limit.
+	depth int
+}
+
+// The compiled FileSelection of a LogstreamSet.
+type fileSelector struct {
+	roots []*selectorRoot
+	// Exclude patterns, matched against relative paths or base names.
+	excludePaths []*regexp.Regexp
+	excludeNames []*regexp.Regexp
+	maxDepth     int
+}
+
+func newFileSelector(selection *FileSelection, fileMatch string) (*fileSelector, error) {
+	if selection.MaxDepth < 0 {
+		return nil, errors.New("The maximum depth can't be negative.")
+	}
+	s := &fileSelector{maxDepth: selection.MaxDepth}
+	rootsByPath := make(map[string]*selectorRoot)
+	getRoot := func(path string) (*selectorRoot, error) {
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return nil, err
+		}
+		root, ok := rootsByPath[realPath]
+		if !ok {
+			root = &selectorRoot{path: realPath}
+			rootsByPath[realPath] = root
+			s.roots = append(s.roots, root)
+		}
+		return root, nil
+	}
+
+	var relGlobs []string
+	for _, glob := range selection.Globs {
+		if !filepath.IsAbs(glob) {
+			relGlobs = append(relGlobs, glob)
+			continue
+		}
+		rootPath, pattern := splitGlob(glob)
+		root, err := getRoot(rootPath)
+		if err != nil {
+			return nil, err
+		}
+		if err = root.addGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range selection.Roots {
+		if fileMatch == "" && len(relGlobs) == 0 {
+			break
+		}
+		root, err := getRoot(path)
+		if err != nil {
+			return nil, err
+		}
+		if fileMatch != "" {
+			root.fileMatch = fileMatchRegexp(root.path, fileMatch)
+			root.depth = -1
+		}
+		for _, glob := range relGlobs {
+			if err = root.addGlob(glob); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(s.roots) == 0 {
+		return nil, errors.New("No logfiles are selected, a file match or glob " +
+			"pattern is required.")
+	}
+
+	for _, pattern := range selection.Exclude {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(pattern, "/") {
+			s.excludePaths = append(s.excludePaths, re)
+		} else {
+			s.excludeNames = append(s.excludeNames, re)
+		}
+	}
+	return s, nil
+}
+
+// Splits an absolute glob into the directory before its first wildcard and
+// the pattern after it.
+func splitGlob(glob string) (root, pattern string) {
+	parts := strings.Split(filepath.ToSlash(glob), "/")
+	i := 0
+	for i < len(parts)-1 && !strings.ContainsAny(parts[i], `*?[{\`) {
+		i++
+	}
+	root = strings.Join(parts[:i], "/")
+	if root == "" {
+		root = "/"
+	}
+	return filepath.FromSlash(root), strings.Join(parts[i:], "/")
+}
+
+func (r *selectorRoot) addGlob(glob string) error {
+	re, err := globRegexp(glob)
+	if err != nil {
+		return err
+	}
+	r.globs = append(r.globs, re)
+	if strings.Contains(glob, "**") {
+		r.depth = -1
+	} else if depth := strings.Count(glob, "/"); r.depth != -1 && depth > r.depth {
+		r.depth = depth
+	}
+	return nil
+}
+
+// Translates a glob pattern to an anchored regular expression.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	buf.WriteString("^")
+	alternatives := 0
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					// Any number of directories, including none.
+					buf.WriteString("(?:.*/)?")
+				} else {
+					buf.WriteString(".*")
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated '[' in glob '%s'", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end + 1
+		case '{':
+			alternatives++
+			buf.WriteString("(?:")
+		case '}':
+			if alternatives == 0 {
+				return nil, fmt.Errorf("Unmatched '}' in glob '%s'", glob)
+			}
+			alternatives--
+			buf.WriteString(")")
+		case ',':
+			if alternatives > 0 {
+				buf.WriteString("|")
+			} else {
+				buf.WriteString(",")
+			}
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	if alternatives > 0 {
+		return nil, fmt.Errorf("Unterminated '{' in glob '%s'", glob)
+	}
+	buf.WriteString("$")
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid glob '%s': %s", glob, err)
+	}
+	return re, nil
+}
+
+// Returns the paths of the roots.
+func (s *fileSelector) rootPaths() []string {
+	paths := make([]string, len(s.roots))
+	for i, root := range s.roots {
+		paths[i] = root.path
+	}
+	return paths
+}
+
+// Returns whether a relative path, or one of the directories it's in, is
+// excluded.
+func (s *fileSelector) excluded(relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	for i, name := range parts {
+		for _, re := range s.excludeNames {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, re := range s.excludePaths {
+			if re.MatchString(prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the deepest level below the root that has to be searched, -1 if
+// there's no limit.
+func (s *fileSelector) walkDepth(root *selectorRoot) int {
+	if s.maxDepth > 0 && (root.depth == -1 || root.depth > s.maxDepth) {
+		return s.maxDepth
+	}
+	return root.depth
+}
+
+// Returns the logfile for the path if it's selected, with its match parts
+// ready to be populated.
+func (s *fileSelector) match(path string) (*Logfile, bool) {
+	for _, root := range s.roots {
+		rel, err := filepath.Rel(root.path, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		depth := s.walkDepth(root)
+		if depth != -1 && strings.Count(rel, "/") > depth {
+			continue
+		}
+		if s.excluded(rel) {
+			continue
+		}
+		if root.fileMatch != nil {
+			if matches := root.fileMatch.FindStringSubmatch(path); matches != nil {
+				return &Logfile{
+					FileName:    path,
+					subexpNames: root.fileMatch.SubexpNames(),
+					matches:     matches,
+				}, true
+			}
+		}
+		for _, glob := range root.globs {
+			if glob.MatchString(rel) {
+				return &Logfile{
+					FileName:    path,
+					subexpNames: []string{"", FilePathMatchPart},
+					matches:     []string{path, filePathReplacer.Replace(rel)},
+				}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Walks the roots for the selected logfiles, skipping excluded directories
+// and those deeper than the patterns can match.
+func (s *fileSelector) scan() Logfiles {
+	files := make(Logfiles, 0)
+	seen := make(map[string]bool)
+	for _, root := range s.roots {
+		depth := s.walkDepth(root)
+		filepath.Walk(root.path, func(path string, info os.FileInfo, err error) error {
+			if err != nil || path == root.path {
+				return nil
+			}
+			if info.IsDir() {
+				rel, err := filepath.Rel(root.path, path)
+				if err != nil {
+					return filepath.SkipDir
+				}
+				rel = filepath.ToSlash(rel)
+				if (depth != -1 && strings.Count(rel, "/") >= depth) || s.excluded(rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if seen[path] {
+				return nil
+			}
+			if logfile, ok := s.match(path); ok {
+				seen[path] = true
+				files = append(files, logfile)
+			}
+			return nil
+		})
+	}
+	return files
+}
@@ -0,0 +1,163 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func SelectorSpec(c gs.Context) {
+	tmpDir, err := ioutil.TempDir("", "selector-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	root, err := filepath.EvalSymlinks(tmpDir)
+	c.Assume(err, gs.IsNil)
+
+	for _, name := range []string{
+		"app.log",
+		"app.txt",
+		"web/access.log",
+		"web/old/access.log",
+		"containers/abc/abc-json.log",
+		"containers/def/def-json.log",
+		"srv/api/logs/api.log",
+		"srv/api/tmp/api.log",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		c.Assume(os.MkdirAll(filepath.Dir(path), 0755), gs.IsNil)
+		c.Assume(ioutil.WriteFile(path, nil, 0644), gs.IsNil)
+	}
+
+	// Returns the root relative paths of the selected logfiles.
+	scan := func(selection *FileSelection, fileMatch string) []string {
+		selector, err := newFileSelector(selection, fileMatch)
+		c.Assume(err, gs.IsNil)
+		var names []string
+		for _, logfile := range selector.scan() {
+			rel, _ := filepath.Rel(root, logfile.FileName)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return names
+	}
+
+	c.Specify("A glob", func() {
+		matches := func(glob, path string) bool {
+			re, err := globRegexp(glob)
+			c.Assume(err, gs.IsNil)
+			return re.MatchString(path)
+		}
+
+		c.Specify("matches within a directory", func() {
+			c.Expect(matches("*.log", "app.log"), gs.IsTrue)
+			c.Expect(matches("*.log", "web/app.log"), gs.IsFalse)
+			c.Expect(matches("app.?og", "app.log"), gs.IsTrue)
+			c.Expect(matches("app.[!l]og", "app.log"), gs.IsFalse)
+			c.Expect(matches("app.{log,txt}", "app.txt"), gs.IsTrue)
+			c.Expect(matches(`app\*.log`, "app*.log"), gs.IsTrue)
+			c.Expect(matches(`app\*.log`, "apps.log"), gs.IsFalse)
+		})
+
+		c.Specify("matches any number of directories with **", func() {
+			c.Expect(matches("**/*.log", "app.log"), gs.IsTrue)
+			c.Expect(matches("**/*.log", "web/old/access.log"), gs.IsTrue)
+			c.Expect(matches("web/**", "web/old/access.log"), gs.IsTrue)
+		})
+
+		c.Specify("must be well formed", func() {
+			_, err := globRegexp("app.[log")
+			c.Expect(err, gs.Not(gs.IsNil))
+			_, err = globRegexp("app.{log")
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+	})
+
+	c.Specify("A fileSelector", func() {
+		c.Specify("matches the file match below the root", func() {
+			names := scan(&FileSelection{Roots: []string{root}}, `.*\.log$`)
+			c.Expect(len(names), gs.Equals, 7)
+		})
+
+		c.Specify("matches relative globs below every root", func() {
+			names := scan(&FileSelection{
+				Roots: []string{filepath.Join(root, "web"), filepath.Join(root, "srv")},
+				Globs: []string{"*.log", "*/logs/*.log"},
+			}, "")
+			c.Expect(names, gs.ContainsExactly, []string{"web/access.log",
+				"srv/api/logs/api.log"})
+		})
+
+		c.Specify("matches absolute globs below their own root", func() {
+			names := scan(&FileSelection{
+				Globs: []string{filepath.Join(root, "containers/*/*-json.log")},
+			}, "")
+			c.Expect(names, gs.ContainsExactly, []string{
+				"containers/abc/abc-json.log", "containers/def/def-json.log"})
+		})
+
+		c.Specify("leaves out excluded files and directories", func() {
+			names := scan(&FileSelection{
+				Roots:   []string{root},
+				Globs:   []string{"**/*.log"},
+				Exclude: []string{"old", "srv/*/tmp", "containers"},
+			}, "")
+			c.Expect(names, gs.ContainsExactly, []string{"app.log", "web/access.log",
+				"srv/api/logs/api.log"})
+		})
+
+		c.Specify("stops at the maximum depth", func() {
+			names := scan(&FileSelection{
+				Roots:    []string{root},
+				Globs:    []string{"**/*.log"},
+				MaxDepth: 1,
+			}, "")
+			c.Expect(names, gs.ContainsExactly, []string{"app.log", "web/access.log"})
+		})
+
+		c.Specify("names globbed logfiles by their path", func() {
+			selector, err := newFileSelector(&FileSelection{
+				Roots: []string{root},
+				Globs: []string{"web/**/*.log"},
+			}, "")
+			c.Assume(err, gs.IsNil)
+			logfile, ok := selector.match(filepath.Join(root, "web", "old", "access.log"))
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(logfile.matches[1], gs.Equals, "web_old_access.log")
+			_, ok = selector.match(filepath.Join(root, "app.log"))
+			c.Expect(ok, gs.IsFalse)
+		})
+
+		c.Specify("requires a pattern", func() {
+			_, err := newFileSelector(&FileSelection{Roots: []string{root}}, "")
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+	})
+
+	c.Specify("A LogstreamSet with globs", func() {
+		sp := &SortPattern{Differentiator: []string{FilePathMatchPart}}
+		set, err := NewLogstreamSetWithSelection(sp, 0, &FileSelection{
+			Roots: []string{root},
+			Globs: []string{"containers/*/*.log"},
+		}, filepath.Join(root, "journals"), false)
+		c.Assume(err, gs.IsNil)
+		names, errs := set.ScanForLogstreams()
+		c.Expect(errs.IsError(), gs.IsFalse)
+		c.Expect(names, gs.ContainsExactly, []string{
+			"containers_abc_abc-json.log", "containers_def_def-json.log"})
+	})
+}
@@ -0,0 +1,163 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Returned when the kernel won't allow any more watches, see
+// /proc/sys/fs/inotify/max_user_watches.
+var ErrWatchLimit = errors.New("inotify watch limit reached")
+
+// Returned by NewWatcher where file watching isn't available.
+var ErrWatchUnsupported = errors.New("file watching is only supported on Linux")
+
+// The kind of change reported by a WatchEvent.
+type WatchOp int
+
+const (
+	// Data was written to the file.
+	WatchWrite WatchOp = iota
+	// The file was created or moved into a watched directory.
+	WatchCreate
+	// The file was removed or moved out of a watched directory.
+	WatchRemove
+	// A directory was created, removed or moved.
+	WatchDirChange
+	// The kernel's event queue overflowed and events were lost.
+	WatchOverflow
+)
+
+// A change noticed by a Watcher.
+type WatchEvent struct {
+	// Path of the changed file or directory, empty for WatchOverflow.
+	Path string
+	Op   WatchOp
+}
+
+// Starts watching the set's log roots for changes. Once watching, scans use
+// the files seen in the watch events instead of walking the log roots, which
+// the caller feeds to the set through HandleWatchEvent.
+func (ls *LogstreamSet) Watch() (*Watcher, error) {
+	watcher, err := NewWatcher(ls.selector.rootPaths()...)
+	if err != nil {
+		return nil, err
+	}
+	ls.watchMutex.Lock()
+	ls.watcher = watcher
+	ls.knownFiles = make(map[string]*Logfile)
+	ls.walkNeeded = true
+	ls.watchMutex.Unlock()
+	return watcher, nil
+}
+
+// Stops watching the log roots, scans walk them again.
+func (ls *LogstreamSet) StopWatching() {
+	ls.watchMutex.Lock()
+	defer ls.watchMutex.Unlock()
+	if ls.watcher == nil {
+		return
+	}
+	ls.watcher.Close()
+	ls.watcher = nil
+	ls.knownFiles = nil
+}
+
+// Returns the watcher of the log roots, nil when not watching.
+func (ls *LogstreamSet) Watcher() *Watcher {
+	ls.watchMutex.Lock()
+	defer ls.watchMutex.Unlock()
+	return ls.watcher
+}
+
+// Updates the set's view of the log roots from a watch event. Returns whether
+// logfiles appeared or disappeared so a scan is due, and for writes the name
+// of the logstream the file belongs to, if any.
+func (ls *LogstreamSet) HandleWatchEvent(event WatchEvent) (rescan bool,
+	logstream string) {
+
+	ls.watchMutex.Lock()
+	defer ls.watchMutex.Unlock()
+	if ls.knownFiles == nil {
+		return false, ""
+	}
+	switch event.Op {
+	case WatchWrite:
+		return false, ls.fileStreams[event.Path]
+	case WatchCreate, WatchRemove:
+		logfile, ok := ls.selector.match(event.Path)
+		if !ok {
+			return false, ""
+		}
+		if event.Op == WatchCreate {
+			ls.knownFiles[event.Path] = logfile
+		} else {
+			delete(ls.knownFiles, event.Path)
+		}
+		return true, ""
+	}
+	// Files in new directories may predate their watches and the files of
+	// removed ones aren't reported individually, so walk the tree again.
+	ls.walkNeeded = true
+	return true, ""
+}
+
+// Returns the logfiles in the log roots, walking them unless the watch
+// events since the last walk are enough to know them.
+func (ls *LogstreamSet) findLogfiles() Logfiles {
+	ls.watchMutex.Lock()
+	if ls.knownFiles == nil {
+		ls.watchMutex.Unlock()
+		return ls.selector.scan()
+	}
+	defer ls.watchMutex.Unlock()
+	if ls.walkNeeded {
+		ls.walkNeeded = false
+		ls.knownFiles = make(map[string]*Logfile)
+		for _, logfile := range ls.selector.scan() {
+			ls.knownFiles[logfile.FileName] = logfile
+		}
+	}
+	// Scans populate the match parts of the logfiles they are handed, so
+	// each gets its own copies.
+	logfiles := make(Logfiles, 0, len(ls.knownFiles))
+	for _, logfile := range ls.knownFiles {
+		logfiles = append(logfiles, &Logfile{
+			FileName:    logfile.FileName,
+			subexpNames: logfile.subexpNames,
+			matches:     logfile.matches,
+		})
+	}
+	return logfiles
+}
+
+// Records which logstream each logfile belongs to, so writes can be routed
+// to their logstream.
+func (ls *LogstreamSet) setFileStreams(mfs LogfilesMap) {
+	ls.watchMutex.Lock()
+	defer ls.watchMutex.Unlock()
+	if ls.knownFiles == nil {
+		ls.fileStreams = nil
+		return
+	}
+	ls.fileStreams = make(map[string]string)
+	for name, logfiles := range mfs {
+		for _, logfile := range logfiles {
+			ls.fileStreams[filepath.Clean(logfile.FileName)] = name
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_DELETE | syscall.IN_MODIFY
+
+// Watches directory trees with inotify, using one watch per directory.
+type Watcher struct {
+	file    *os.File
+	fd      int
+	mutex   sync.Mutex
+	watches map[int32]string
+	events  chan WatchEvent
+	errors  chan error
+	done    chan struct{}
+}
+
+// Watches the directory trees under the roots. Returns ErrWatchLimit if
+// there aren't enough inotify watches left for all of their directories.
+func NewWatcher(roots ...string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &Watcher{
+		// A non-blocking fd uses the runtime poller, so Close interrupts
+		// the reading goroutine.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		watches: make(map[int32]string),
+		events:  make(chan WatchEvent, 1024),
+		errors:  make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	for _, root := range roots {
+		if err = w.addTree(root); err != nil {
+			w.file.Close()
+			return nil, err
+		}
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+// Returns the channel of the changes, which is closed when the watcher is.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Returns the channel of errors that stop the watcher from covering the
+// whole tree, e.g. ErrWatchLimit when new directories can't be watched.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	close(w.done)
+	return w.file.Close()
+}
+
+// Adds watches for a directory and all the directories below it.
+func (w *Watcher) addTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask|syscall.IN_ONLYDIR)
+		if err == syscall.ENOSPC {
+			return ErrWatchLimit
+		} else if err != nil {
+			// Unreadable directories are skipped by the scans as well.
+			return nil
+		}
+		w.mutex.Lock()
+		w.watches[int32(wd)] = path
+		w.mutex.Unlock()
+		return nil
+	})
+}
+
+func (w *Watcher) readEvents() {
+	defer close(w.events)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(raw.Len)
+			name := string(bytes.TrimRight(buf[nameStart:offset], "\x00"))
+			if !w.handle(raw.Wd, raw.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// Translates an inotify event, returns false once the watcher is closed.
+func (w *Watcher) handle(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return w.send(WatchEvent{Op: WatchOverflow})
+	}
+	w.mutex.Lock()
+	dir, ok := w.watches[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.watches, wd)
+	}
+	w.mutex.Unlock()
+	if !ok || name == "" {
+		return true
+	}
+	path := filepath.Join(dir, name)
+
+	if mask&syscall.IN_ISDIR != 0 {
+		if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := w.addTree(path); err != nil {
+				select {
+				case w.errors <- err:
+				default:
+				}
+			}
+		}
+		return w.send(WatchEvent{Path: path, Op: WatchDirChange})
+	}
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		return w.send(WatchEvent{Path: path, Op: WatchCreate})
+	case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		return w.send(WatchEvent{Path: path, Op: WatchRemove})
+	case mask&syscall.IN_MODIFY != 0:
+		return w.send(WatchEvent{Path: path, Op: WatchWrite})
+	}
+	return true
+}
+
+func (w *Watcher) send(event WatchEvent) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
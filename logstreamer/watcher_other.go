@@ -0,0 +1,36 @@
+// +build !linux
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+// File watching is only implemented with Linux's inotify.
+type Watcher struct{}
+
+func NewWatcher(roots ...string) (*Watcher, error) {
+	return nil, ErrWatchUnsupported
+}
+
+func (w *Watcher) Events() <-chan WatchEvent {
+	return nil
+}
+
+func (w *Watcher) Errors() <-chan error {
+	return nil
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -0,0 +1,132 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func WatcherSpec(c gs.Context) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	tmpDir, err := ioutil.TempDir("", "watcher-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	logDir, err := filepath.EvalSymlinks(tmpDir)
+	c.Assume(err, gs.IsNil)
+
+	// Returns the next event, or a WatchOverflow on timeout.
+	nextEvent := func(events <-chan WatchEvent) WatchEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			return WatchEvent{Op: WatchOverflow}
+		}
+	}
+
+	c.Specify("A Watcher", func() {
+		watcher, err := NewWatcher(logDir)
+		c.Assume(err, gs.IsNil)
+		defer watcher.Close()
+		events := watcher.Events()
+		path := filepath.Join(logDir, "app.log")
+
+		c.Specify("reports created, written and removed files", func() {
+			err := ioutil.WriteFile(path, []byte("line\n"), 0644)
+			c.Assume(err, gs.IsNil)
+			c.Expect(nextEvent(events), gs.Equals, WatchEvent{path, WatchCreate})
+			c.Expect(nextEvent(events), gs.Equals, WatchEvent{path, WatchWrite})
+			err = os.Remove(path)
+			c.Assume(err, gs.IsNil)
+			c.Expect(nextEvent(events), gs.Equals, WatchEvent{path, WatchRemove})
+		})
+
+		c.Specify("watches new directories", func() {
+			subDir := filepath.Join(logDir, "app")
+			err := os.Mkdir(subDir, 0755)
+			c.Assume(err, gs.IsNil)
+			c.Expect(nextEvent(events), gs.Equals, WatchEvent{subDir, WatchDirChange})
+			subPath := filepath.Join(subDir, "app.log")
+			err = ioutil.WriteFile(subPath, nil, 0644)
+			c.Assume(err, gs.IsNil)
+			c.Expect(nextEvent(events), gs.Equals, WatchEvent{subPath, WatchCreate})
+		})
+
+		c.Specify("closes its events when closed", func() {
+			watcher.Close()
+			for range events {
+			}
+		})
+	})
+
+	c.Specify("A watching LogstreamSet", func() {
+		sp := &SortPattern{
+			FileMatch:      `(?P<Name>\w+)\.log`,
+			Differentiator: []string{"Name"},
+		}
+		set, err := NewLogstreamSet(sp, 0, logDir, filepath.Join(logDir, "journals"),
+			false)
+		c.Assume(err, gs.IsNil)
+		oldPath := filepath.Join(logDir, "old.log")
+		err = ioutil.WriteFile(oldPath, []byte("old\n"), 0644)
+		c.Assume(err, gs.IsNil)
+		watcher, err := set.Watch()
+		c.Assume(err, gs.IsNil)
+		defer set.StopWatching()
+
+		names, errs := set.ScanForLogstreams()
+		c.Expect(errs.IsError(), gs.IsFalse)
+		c.Expect(names, gs.ContainsExactly, []string{"old"})
+
+		c.Specify("finds new logfiles from the events", func() {
+			newPath := filepath.Join(logDir, "new.log")
+			err := ioutil.WriteFile(newPath, nil, 0644)
+			c.Assume(err, gs.IsNil)
+			rescan, _ := set.HandleWatchEvent(nextEvent(watcher.Events()))
+			c.Expect(rescan, gs.IsTrue)
+			names, errs := set.ScanForLogstreams()
+			c.Expect(errs.IsError(), gs.IsFalse)
+			c.Expect(names, gs.ContainsExactly, []string{"new"})
+		})
+
+		c.Specify("ignores files that don't match", func() {
+			rescan, _ := set.HandleWatchEvent(WatchEvent{
+				Path: filepath.Join(logDir, "notes.txt"),
+				Op:   WatchCreate,
+			})
+			c.Expect(rescan, gs.IsFalse)
+		})
+
+		c.Specify("routes writes to their logstream", func() {
+			_, name := set.HandleWatchEvent(WatchEvent{Path: oldPath, Op: WatchWrite})
+			c.Expect(name, gs.Equals, "old")
+		})
+
+		c.Specify("stops watching", func() {
+			set.StopWatching()
+			c.Expect(set.Watcher(), gs.IsNil)
+			_, name := set.HandleWatchEvent(WatchEvent{Path: oldPath, Op: WatchWrite})
+			c.Expect(name, gs.Equals, "")
+		})
+	})
+}
@@ -44,6 +44,7 @@ func TestAllSpecs(t *testing.T) {
 	r.AddSpec(ProtobufDecoderSpec)
 	r.AddSpec(QueueBufferSpec)
 	r.AddSpec(PatternGroupingSpec)
+	r.AddSpec(MultilineSpec)
 	r.AddSpec(RegexSpec)
 	r.AddSpec(ReportSpec)
 	r.AddSpec(SplitterRunnerSpec)
@@ -16,6 +16,8 @@
 
 package pipeline
 
+import "time"
+
 // Interface for Heka plugins that can be wired up to the config system.
 type Plugin interface {
 	// Receives either PluginConfig or custom config struct, populated from
@@ -46,6 +48,17 @@ type UnframingSplitter interface {
 	UnframeRecord(framed []byte, pack *PipelinePack) []byte
 }
 
+// FlushingSplitter is an interface optionally implemented by splitter plugins
+// that hold on to a record until they see where the next one starts, so that
+// the last record of an idle stream still gets delivered.
+type FlushingSplitter interface {
+	// Returns the record held at the start of the buffer once it has been
+	// waiting for longer than the flush timeout.
+	FlushRecord(buf []byte) (bytesRead int, record []byte)
+	// How long a held record waits for more data, 0 if it waits forever.
+	FlushTimeout() time.Duration
+}
+
 // Heka Decoder plugin interface.
 type Decoder interface {
 	// Extract data loaded into the PipelinePack (usually in pack.MsgBytes)
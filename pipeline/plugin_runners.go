@@ -183,6 +183,11 @@ type InputRunner interface {
 	// is no longer in use to ensure that any DecoderRunner goroutines get
 	// cleaned up.
 	NewDeliverer(token string) Deliverer
+	// Like NewDeliverer, but decodes with the named decoder instead of the
+	// one configured for the Input. An empty name means packs are injected
+	// into the router without decoding. Returns an error if no decoder of
+	// that name is registered.
+	NewDecoderDeliverer(token, decoderName string) (Deliverer, error)
 	// Deliver accepts packs from the Input plugin and performs the
 	// appropriate one of three possible next actions. Possible actions are 1)
 	// placing the pack on the Decoder's input channel, if a decoder is
@@ -420,9 +425,10 @@ func (ir *iRunner) LogMessage(msg string) {
 	LogInfo.Printf("Input '%s': %s", ir.name, msg)
 }
 
-func (ir *iRunner) getDeliverFunc(token string) (DeliverFunc, DecoderRunner, Decoder) {
+func (ir *iRunner) getDeliverFunc(token, decoderName string) (DeliverFunc,
+	DecoderRunner, Decoder) {
+
 	var deliver DeliverFunc
-	decoderName := ir.config.Decoder
 	// If no decoder is specified we just inject into the router.
 	if decoderName == "" {
 		deliver = func(pack *PipelinePack) {
@@ -511,7 +517,7 @@ func (ir *iRunner) getDeliverFunc(token string) (DeliverFunc, DecoderRunner, Dec
 }
 
 func (ir *iRunner) NewDeliverer(token string) Deliverer {
-	deliver, dRunner, decoder := ir.getDeliverFunc(token)
+	deliver, dRunner, decoder := ir.getDeliverFunc(token, ir.config.Decoder)
 	d := &deliverer{
 		deliver: deliver,
 		dRunner: dRunner,
@@ -521,13 +527,32 @@ func (ir *iRunner) NewDeliverer(token string) Deliverer {
 	return d
 }
 
+func (ir *iRunner) NewDecoderDeliverer(token, decoderName string) (Deliverer, error) {
+	if decoderName != "" {
+		ir.pConfig.makersLock.RLock()
+		_, ok := ir.pConfig.DecoderMakers[decoderName]
+		ir.pConfig.makersLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("decoder '%s' not registered", decoderName)
+		}
+	}
+	deliver, dRunner, decoder := ir.getDeliverFunc(token, decoderName)
+	d := &deliverer{
+		deliver: deliver,
+		dRunner: dRunner,
+		decoder: decoder,
+		pConfig: ir.pConfig,
+	}
+	return d, nil
+}
+
 func (ir *iRunner) Deliver(pack *PipelinePack) {
 	if ir.deliver == nil {
 		// The lock keeps latecomers from hitting the `deliver` call before the
 		// first `getDeliverFunc` call has returned.
 		ir.delivererLock.Lock()
 		ir.delivererOnce.Do(func() {
-			ir.deliver, _, _ = ir.getDeliverFunc("")
+			ir.deliver, _, _ = ir.getDeliverFunc("", ir.config.Decoder)
 		})
 		ir.delivererLock.Unlock()
 	}
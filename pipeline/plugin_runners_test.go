@@ -231,6 +231,39 @@ func InputRunnerSpec(c gs.Context) {
 				close(d.dRunner.InChan())
 			})
 
+			c.Specify("when the decoder is chosen by name", func() {
+				syncDecode := true
+				commonInput.SyncDecode = &syncDecode
+				runner := NewInputRunner("named", input, commonInput).(*iRunner)
+				runner.pConfig = pConfig
+
+				c.Specify("it decodes with that decoder", func() {
+					mockHelper.EXPECT().PipelineConfig().Return(pConfig)
+					del, err := runner.NewDecoderDeliverer("c1", "FooDecoder")
+					c.Assume(err, gs.IsNil)
+					startRunner(runner)
+
+					del.Deliver(pack)
+					recd := <-pConfig.router.inChan
+					c.Expect(recd, gs.Equals, pack)
+					c.Expect(pack.Message.GetPayload(), gs.Equals, "FOO")
+					c.Expect(pConfig.allSyncDecoders[0].name, gs.Equals,
+						"named-FooDecoder-c1")
+
+					del.Done()
+					c.Expect(len(pConfig.allSyncDecoders), gs.Equals, 0)
+					pack.Recycle(nil)
+					input.Stop()
+					wg.Wait()
+				})
+
+				c.Specify("it fails for unknown decoders", func() {
+					del, err := runner.NewDecoderDeliverer("c1", "BarDecoder")
+					c.Expect(del, gs.IsNil)
+					c.Expect(err.Error(), gs.Equals, "decoder 'BarDecoder' not registered")
+				})
+			})
+
 			c.Specify("when using a decoder", func() {
 				mockHelper.EXPECT().PipelineConfig().Return(pConfig)
 				b := true
@@ -200,7 +200,12 @@ func (sr *sRunner) GetRecordFromStream(r io.Reader) (bytesRead int, record []byt
 		if err == io.EOF {
 			if bytesRead == 0 {
 				// If we didn't read any bytes, we don't need to look for more
-				// records, we can return the EOF.
+				// records, unless the splitter is done waiting for the rest
+				// of the one it's holding on to. Otherwise we can return the
+				// EOF.
+				if bytesRead, record = sr.flushRecord(); len(record) > 0 {
+					return bytesRead, record, nil
+				}
 				return bytesRead, record, err
 			}
 			// We did read some bytes, so clear the EOF for now
@@ -250,6 +255,22 @@ func (sr *sRunner) GetRecordFromStream(r io.Reader) (bytesRead int, record []byt
 	return bytesRead, record, err
 }
 
+// Returns the record a FlushingSplitter is holding on to if its flush timeout
+// has passed.
+func (sr *sRunner) flushRecord() (bytesRead int, record []byte) {
+	flusher, ok := sr.splitter.(FlushingSplitter)
+	if !ok || sr.readPos == sr.scanPos {
+		return 0, nil
+	}
+	bytesRead, record = flusher.FlushRecord(sr.buf[sr.scanPos:sr.readPos])
+	sr.scanPos += bytesRead
+	if sr.readPos == sr.scanPos {
+		sr.readPos = 0
+		sr.scanPos = 0
+	}
+	return bytesRead, record
+}
+
 func (sr *sRunner) DeliverRecord(record []byte, del Deliverer) {
 	unframed := record
 	pack := <-sr.ir.InChan()
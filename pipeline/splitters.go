@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"hash"
 	"regexp"
+	"time"
 
 	"github.com/mozilla-services/heka/message"
 )
@@ -234,6 +235,130 @@ func (r *RegexSplitter) FindRecord(buf []byte) (bytesRead int, record []byte) {
 	return bytesRead, record
 }
 
+type MultilineSplitter struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	maxBytes     int
+	flushTimeout time.Duration
+	// Length of the data held since pendingSince, while waiting for the line
+	// that starts the next record.
+	pendingLen   int
+	pendingSince time.Time
+}
+
+type MultilineSplitterConfig struct {
+	// Lines matching this start a new record.
+	StartPattern string `toml:"start_pattern"`
+	// Lines matching this are appended to the current record.
+	ContinuationPattern string `toml:"continuation_pattern"`
+	MaxLines            int    `toml:"max_lines"`
+	MaxBytes            int    `toml:"max_bytes"`
+	// How long to wait for more lines before delivering the last record.
+	FlushTimeout string `toml:"flush_timeout"`
+}
+
+func (m *MultilineSplitter) ConfigStruct() interface{} {
+	return &MultilineSplitterConfig{
+		MaxLines:     500,
+		MaxBytes:     int(message.MAX_MESSAGE_SIZE),
+		FlushTimeout: "5s",
+	}
+}
+
+func (m *MultilineSplitter) Init(config interface{}) error {
+	conf := config.(*MultilineSplitterConfig)
+	var err error
+	if conf.StartPattern == "" && conf.ContinuationPattern == "" {
+		return errors.New("MultilineSplitter requires a `start_pattern` or a " +
+			"`continuation_pattern`.")
+	}
+	if conf.StartPattern != "" {
+		if m.start, err = regexp.Compile(conf.StartPattern); err != nil {
+			return err
+		}
+	}
+	if conf.ContinuationPattern != "" {
+		if m.continuation, err = regexp.Compile(conf.ContinuationPattern); err != nil {
+			return err
+		}
+	}
+	if conf.MaxLines < 0 || conf.MaxBytes < 0 {
+		return errors.New("MultilineSplitter `max_lines` and `max_bytes` can't " +
+			"be negative.")
+	}
+	m.maxLines = conf.MaxLines
+	m.maxBytes = conf.MaxBytes
+	if m.flushTimeout, err = time.ParseDuration(conf.FlushTimeout); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Returns whether a line, without its line feed, starts a new record.
+func (m *MultilineSplitter) startsRecord(line []byte) bool {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if m.continuation != nil && m.continuation.Match(line) {
+		return false
+	}
+	return m.start == nil || m.start.Match(line)
+}
+
+func (m *MultilineSplitter) FindRecord(buf []byte) (bytesRead int, record []byte) {
+	var lines int
+	for {
+		n := bytes.IndexByte(buf[bytesRead:], '\n')
+		if n == -1 {
+			break
+		}
+		lineEnd := bytesRead + n + 1
+		if lines > 0 && m.startsRecord(buf[bytesRead:lineEnd-1]) {
+			// The next record's first line is there, so this one is complete.
+			m.pendingLen = 0
+			return bytesRead, buf[:bytesRead]
+		}
+		if m.maxBytes > 0 && lineEnd > m.maxBytes {
+			m.pendingLen = 0
+			if lines == 0 {
+				// A single line over the limit is truncated.
+				return lineEnd, buf[:m.maxBytes]
+			}
+			return bytesRead, buf[:bytesRead]
+		}
+		bytesRead = lineEnd
+		lines++
+		if m.maxLines > 0 && lines == m.maxLines {
+			m.pendingLen = 0
+			return bytesRead, buf[:bytesRead]
+		}
+	}
+	// Wait for the next record to start, but note when the data held last
+	// changed so it can be flushed once it has been idle.
+	if len(buf) != m.pendingLen {
+		m.pendingLen = len(buf)
+		m.pendingSince = time.Now()
+	}
+	return 0, nil
+}
+
+func (m *MultilineSplitter) FlushRecord(buf []byte) (bytesRead int, record []byte) {
+	if m.flushTimeout == 0 || len(buf) != m.pendingLen ||
+		time.Since(m.pendingSince) < m.flushTimeout {
+		return 0, nil
+	}
+	// Only complete lines are delivered, a partial one may still be written.
+	bytesRead = bytes.LastIndexByte(buf, '\n') + 1
+	if bytesRead == 0 {
+		return 0, nil
+	}
+	m.pendingLen = 0
+	return bytesRead, buf[:bytesRead]
+}
+
+func (m *MultilineSplitter) FlushTimeout() time.Duration {
+	return m.flushTimeout
+}
+
 // Heka Message signer object.
 type Signer struct {
 	HmacKey string `toml:"hmac_key"`
@@ -368,6 +493,9 @@ func init() {
 	RegisterPlugin("PatternGroupingSplitter", func() interface{} {
 		return &PatternGroupingSplitter{}
 	})
+	RegisterPlugin("MultilineSplitter", func() interface{} {
+		return &MultilineSplitter{}
+	})
 	RegisterPlugin("HekaFramingSplitter", func() interface{} {
 		return &HekaFramingSplitter{}
 	})
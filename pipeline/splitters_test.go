@@ -21,6 +21,7 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"io"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/mozilla-services/heka/message"
@@ -156,6 +157,107 @@ func TokenSpec(c gs.Context) {
 	})
 }
 
+func MultilineSpec(c gs.Context) {
+	c.Specify("A MultilineSplitter", func() {
+		splitter := &MultilineSplitter{}
+		config := splitter.ConfigStruct().(*MultilineSplitterConfig)
+		config.StartPattern = `^\d{4}-\d{2}-\d{2} `
+		config.FlushTimeout = "0"
+		sRunner := makeSplitterRunner("MultilineSplitter", splitter)
+		trace := "2016-01-01 ERROR java.io.FileNotFoundException: fred.txt\n" +
+			"\tat java.io.FileInputStream.<init>(FileInputStream.java)\n" +
+			"\tat ExTest.main(ExTest.java:7)\n"
+		last := "2016-01-01 INFO done\n"
+
+		// Returns the records up to EOF.
+		readRecords := func(reader io.Reader) (records []string) {
+			for {
+				n, record, err := sRunner.GetRecordFromStream(reader)
+				if err == io.EOF {
+					return records
+				}
+				c.Expect(err, gs.IsNil)
+				if len(record) > 0 {
+					c.Expect(n >= len(record), gs.IsTrue)
+					records = append(records, string(record))
+				}
+			}
+		}
+
+		c.Specify("requires a pattern", func() {
+			config.StartPattern = ""
+			err := splitter.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("groups lines up to the next start pattern", func() {
+			err := splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			records := readRecords(bytes.NewReader([]byte(trace + trace + last)))
+			c.Expect(records, gs.ContainsExactly, []string{trace, trace})
+			// The last record is held until the next one starts.
+			records = readRecords(bytes.NewReader([]byte(last)))
+			c.Expect(records, gs.ContainsExactly, []string{last})
+		})
+
+		c.Specify("groups lines matching the continuation pattern", func() {
+			config.StartPattern = ""
+			config.ContinuationPattern = `^\s`
+			err := splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			records := readRecords(bytes.NewReader([]byte(trace + "other\n" + last)))
+			c.Expect(records, gs.ContainsExactly, []string{trace, "other\n"})
+		})
+
+		c.Specify("caps the lines of a record", func() {
+			config.MaxLines = 2
+			err := splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			records := readRecords(bytes.NewReader([]byte(trace + last)))
+			c.Expect(len(records), gs.Equals, 2)
+			c.Expect(records[0]+records[1], gs.Equals, trace)
+		})
+
+		c.Specify("caps the bytes of a record", func() {
+			config.MaxBytes = 100
+			err := splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			records := readRecords(bytes.NewReader([]byte(trace + last)))
+			// Records are cut between lines.
+			c.Expect(records, gs.ContainsExactly, []string{trace[:57], trace[57:]})
+
+			// A line over the limit is truncated.
+			sRunner.GetRemainingData()
+			config.MaxBytes = 40
+			err = splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			records = readRecords(bytes.NewReader([]byte(trace + last)))
+			c.Expect(records, gs.ContainsExactly, []string{trace[:40], trace[57:97],
+				trace[114:]})
+		})
+
+		c.Specify("flushes a held record after the flush timeout", func() {
+			config.FlushTimeout = "10ms"
+			err := splitter.Init(config)
+			c.Assume(err, gs.IsNil)
+			c.Expect(splitter.FlushTimeout(), gs.Equals, 10*time.Millisecond)
+			reader := bytes.NewReader([]byte(trace + "2016-01-01 INFO partial"))
+			records := readRecords(reader)
+			c.Expect(len(records), gs.Equals, 0)
+			time.Sleep(20 * time.Millisecond)
+			records = readRecords(reader)
+			c.Expect(records, gs.ContainsExactly, []string{trace})
+
+			// A partial line is still held.
+			time.Sleep(20 * time.Millisecond)
+			records = readRecords(reader)
+			c.Expect(len(records), gs.Equals, 0)
+			c.Expect(string(sRunner.GetRemainingData()), gs.Equals,
+				"2016-01-01 INFO partial")
+		})
+	})
+}
+
 func PatternGroupingSpec(c gs.Context) {
 	c.Specify("A PatternGroupingSplitter", func() {
 		splitter := &PatternGroupingSplitter{}
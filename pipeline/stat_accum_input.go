@@ -19,9 +19,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"math"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mozilla-services/heka/message"
@@ -34,6 +34,15 @@ type Stat struct {
 	Value    string
 	Modifier string
 	Sampling float32
+	// DogStatsD style tags, each either "name:value" or just "name".
+	Tags []string
+}
+
+// Identifies the series of a bucket with a particular set of tags, which are
+// held sorted and comma separated.
+type statKey struct {
+	bucket string
+	tags   string
 }
 
 // Specialized Input that listens on a provided channel for Stat objects, from
@@ -46,9 +55,10 @@ type StatAccumulator interface {
 
 type StatAccumInput struct {
 	statChan chan Stat
-	counters map[string]int
-	timers   map[string][]float64
-	gauges   map[string]float64
+	// Stats that aren't in any of the configured namespaces.
+	defaultGroup *statGroup
+	// The configured namespaces, each flushed on its own interval.
+	groups   []*statGroup
 	pConfig  *PipelineConfig
 	config   *StatAccumInputConfig
 	ir       InputRunner
@@ -57,6 +67,49 @@ type StatAccumInput struct {
 	stopChan chan bool
 }
 
+// The accumulated stats that are flushed together in one message.
+type statGroup struct {
+	// Bucket prefixes of the group's stats, the default group has none.
+	prefixes       []string
+	tickerInterval uint
+	bucketBounds   []float64
+	counters       map[statKey]int
+	timers         map[statKey]*timingSeries
+	gauges         map[statKey]float64
+	sets           map[statKey]map[string]struct{}
+	histograms     map[statKey]*timingSeries
+	distributions  map[statKey]*timingSeries
+	// Tag sets seen for each bucket, to enforce `max_tag_sets_per_bucket`,
+	// and the number of stats since the last flush whose tags were dropped
+	// because of it.
+	tagSets      map[string]map[string]struct{}
+	tagOverflows int
+}
+
+func newStatGroup(tickerInterval uint, bucketBounds []float64) *statGroup {
+	return &statGroup{
+		tickerInterval: tickerInterval,
+		bucketBounds:   bucketBounds,
+		counters:       make(map[statKey]int),
+		timers:         make(map[statKey]*timingSeries),
+		gauges:         make(map[statKey]float64),
+		sets:           make(map[statKey]map[string]struct{}),
+		histograms:     make(map[statKey]*timingSeries),
+		distributions:  make(map[statKey]*timingSeries),
+		tagSets:        make(map[string]map[string]struct{}),
+	}
+}
+
+// Stats whose buckets start with one of the prefixes are flushed in a
+// separate message on the namespace's own interval.
+type StatNamespaceConfig struct {
+	Prefixes []string `toml:"prefixes"`
+	// Interval at which the namespace's stats are flushed, in seconds.
+	TickerInterval uint `toml:"ticker_interval"`
+	// Overrides the input's `bucket_bounds`.
+	BucketBounds []float64 `toml:"bucket_bounds"`
+}
+
 type StatAccumInputConfig struct {
 	// Specifies whether or not stats data should be written to outgoing
 	// message payloads, in the string format that is expected by a listening
@@ -90,10 +143,37 @@ type StatAccumInputConfig struct {
 	TimerPrefix      string `toml:"timer_prefix"`
 	GaugePrefix      string `toml:"gauge_prefix"`
 	StatsdPrefix     string `toml:"statsd_prefix"`
+	// Namespaces of the set, histogram and distribution stat types.
+	SetPrefix          string `toml:"set_prefix"`
+	HistogramPrefix    string `toml:"histogram_prefix"`
+	DistributionPrefix string `toml:"distribution_prefix"`
+
+	// Maximum number of distinct tag sets tracked for a single bucket. Stats
+	// with further tag sets are accumulated without their tags. 0 means no
+	// limit. Defaults to 1000.
+	MaxTagSetsPerBucket int `toml:"max_tag_sets_per_bucket"`
 
 	// Don't emit values for inactive stats instead of sending 0 or in the case
 	// of gauges, sending the previous value. Defaults to false
 	DeleteIdleStats bool `toml:"delete_idle_stats"`
+
+	// How the values of timers, histograms and distributions are held
+	// between flushes, either "exact", which keeps every value, or "hdr",
+	// which counts them in log-linear buckets so the memory used doesn't
+	// grow with the number of values. Defaults to "exact".
+	TimerSketch string `toml:"timer_sketch"`
+
+	// Number of significant decimal digits the "hdr" sketch keeps for the
+	// percentiles, from 1 to 4. Defaults to 2.
+	SketchSignificantDigits int `toml:"sketch_significant_digits"`
+
+	// Upper bounds of the histogram buckets of timers, histograms and
+	// distributions, for which the cumulative counts of values are emitted.
+	BucketBounds []float64 `toml:"bucket_bounds"`
+
+	// Namespaces whose stats are flushed on their own interval, keyed by
+	// name.
+	Namespaces map[string]StatNamespaceConfig `toml:"namespaces"`
 }
 
 func (sm *StatAccumInput) ConfigStruct() interface{} {
@@ -109,6 +189,14 @@ func (sm *StatAccumInput) ConfigStruct() interface{} {
 		TimerPrefix:      "timers",
 		GaugePrefix:      "gauges",
 		DeleteIdleStats:  false,
+
+		SetPrefix:           "sets",
+		HistogramPrefix:     "histograms",
+		DistributionPrefix:  "distributions",
+		MaxTagSetsPerBucket: 1000,
+
+		TimerSketch:             "exact",
+		SketchSignificantDigits: 2,
 	}
 }
 
@@ -119,9 +207,6 @@ func (sm *StatAccumInput) SetPipelineConfig(pConfig *PipelineConfig) {
 }
 
 func (sm *StatAccumInput) Init(config interface{}) error {
-	sm.counters = make(map[string]int)
-	sm.timers = make(map[string][]float64)
-	sm.gauges = make(map[string]float64)
 	sm.statChan = make(chan Stat, sm.pConfig.Globals.PoolSize)
 	sm.stopChan = make(chan bool, 1)
 
@@ -136,46 +221,254 @@ func (sm *StatAccumInput) Init(config interface{}) error {
 			"One of either `EmitInPayload` or `EmitInFields` must be set to true.",
 		)
 	}
+	if sm.config.MaxTagSetsPerBucket < 0 {
+		return errors.New("`max_tag_sets_per_bucket` can't be negative.")
+	}
+	switch sm.config.TimerSketch {
+	case "", "exact":
+	case "hdr":
+		if sm.config.SketchSignificantDigits < 1 || sm.config.SketchSignificantDigits > 4 {
+			return errors.New("`sketch_significant_digits` must be from 1 to 4.")
+		}
+	default:
+		return fmt.Errorf("Unknown `timer_sketch` '%s', must be 'exact' or 'hdr'.",
+			sm.config.TimerSketch)
+	}
+	if err := checkBucketBounds(sm.config.BucketBounds); err != nil {
+		return err
+	}
+	sm.defaultGroup = newStatGroup(sm.config.TickerInterval, sm.config.BucketBounds)
+
+	names := make([]string, 0, len(sm.config.Namespaces))
+	for name := range sm.config.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sm.groups = make([]*statGroup, 0, len(names))
+	for _, name := range names {
+		nsConfig := sm.config.Namespaces[name]
+		if nsConfig.TickerInterval == 0 {
+			return fmt.Errorf("Namespace '%s': `ticker_interval` must be greater than 0.",
+				name)
+		}
+		if len(nsConfig.Prefixes) == 0 {
+			return fmt.Errorf("Namespace '%s': `prefixes` can't be empty.", name)
+		}
+		bounds := sm.config.BucketBounds
+		if nsConfig.BucketBounds != nil {
+			if err := checkBucketBounds(nsConfig.BucketBounds); err != nil {
+				return fmt.Errorf("Namespace '%s': %s", name, err)
+			}
+			bounds = nsConfig.BucketBounds
+		}
+		group := newStatGroup(nsConfig.TickerInterval, bounds)
+		for _, prefix := range nsConfig.Prefixes {
+			if prefix == "" {
+				return fmt.Errorf("Namespace '%s': prefixes can't be empty.", name)
+			}
+			group.prefixes = append(group.prefixes, prefix)
+		}
+		sm.groups = append(sm.groups, group)
+	}
+	return nil
+}
+
+func checkBucketBounds(bounds []float64) error {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return errors.New("`bucket_bounds` must be in increasing order.")
+		}
+	}
 	return nil
 }
 
+// Returns the group of the namespace with the longest prefix of the bucket,
+// or the default group.
+func (sm *StatAccumInput) groupFor(bucket string) *statGroup {
+	group := sm.defaultGroup
+	longest := 0
+	for _, g := range sm.groups {
+		for _, prefix := range g.prefixes {
+			if len(prefix) > longest && strings.HasPrefix(bucket, prefix) {
+				group = g
+				longest = len(prefix)
+			}
+		}
+	}
+	return group
+}
+
+// Returns a new series for the values of a timer, histogram or
+// distribution.
+func (sm *StatAccumInput) newTimingSeries(g *statGroup) *timingSeries {
+	series := &timingSeries{bounds: g.bucketBounds}
+	if sm.config.TimerSketch == "hdr" {
+		series.values = newHdrSketch(sm.config.SketchSignificantDigits)
+	} else {
+		series.values = new(exactTimings)
+	}
+	if g.bucketBounds != nil {
+		series.bucketCounts = make([]int, len(g.bucketBounds)+1)
+	}
+	return series
+}
+
+// Returns the key of the series the stat belongs to. The stat's tags are
+// dropped if its bucket already has the maximum number of tag sets.
+func (sm *StatAccumInput) statKey(g *statGroup, stat Stat) statKey {
+	key := statKey{bucket: stat.Bucket}
+	if len(stat.Tags) == 0 {
+		return key
+	}
+	tags := make([]string, 0, len(stat.Tags))
+	for _, tag := range stat.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			// Commas separate the tags of a key, semicolons and equal signs
+			// delimit them in the emitted names.
+			tags = append(tags, tagReplacer.Replace(tag))
+		}
+	}
+	if len(tags) == 0 {
+		return key
+	}
+	sort.Strings(tags)
+	key.tags = strings.Join(tags, ",")
+
+	tagSets, ok := g.tagSets[key.bucket]
+	if !ok {
+		tagSets = make(map[string]struct{})
+		g.tagSets[key.bucket] = tagSets
+	}
+	if _, ok = tagSets[key.tags]; !ok {
+		if sm.config.MaxTagSetsPerBucket > 0 &&
+			len(tagSets) >= sm.config.MaxTagSetsPerBucket {
+			g.tagOverflows++
+			return statKey{bucket: stat.Bucket}
+		}
+		tagSets[key.tags] = struct{}{}
+	}
+	return key
+}
+
+var tagReplacer = strings.NewReplacer(",", "_", ";", "_", "=", "_")
+
+// Returns the suffix appended to the names of a tagged series, in the
+// Graphite tag format, e.g. ";canary=true;env=prod". Graphite tags need a
+// value, so tags without one are given "true".
+func (key statKey) suffix() string {
+	if key.tags == "" {
+		return ""
+	}
+	tags := strings.Split(key.tags, ",")
+	for i, tag := range tags {
+		name, value := tag, "true"
+		if sep := strings.Index(tag, ":"); sep >= 0 && sep < len(tag)-1 {
+			name, value = tag[:sep], tag[sep+1:]
+		} else if sep >= 0 {
+			name = tag[:sep]
+		}
+		tags[i] = name + "=" + value
+	}
+	return ";" + strings.Join(tags, ";")
+}
+
+// Adds a stat to the series it belongs to.
+func (sm *StatAccumInput) accumulate(stat Stat) {
+	var floatValue float64
+	g := sm.groupFor(stat.Bucket)
+	key := sm.statKey(g, stat)
+	switch stat.Modifier {
+	case "ms":
+		floatValue, _ = strconv.ParseFloat(stat.Value, 64)
+		sm.addTiming(g, g.timers, key, floatValue)
+	case "h":
+		floatValue, _ = strconv.ParseFloat(stat.Value, 64)
+		sm.addTiming(g, g.histograms, key, floatValue)
+	case "d":
+		floatValue, _ = strconv.ParseFloat(stat.Value, 64)
+		sm.addTiming(g, g.distributions, key, floatValue)
+	case "g":
+		floatValue, _ = strconv.ParseFloat(stat.Value, 64)
+		g.gauges[key] = floatValue
+	case "s":
+		set, ok := g.sets[key]
+		if !ok {
+			set = make(map[string]struct{})
+			g.sets[key] = set
+		}
+		set[stat.Value] = struct{}{}
+	default:
+		floatValue, _ = strconv.ParseFloat(stat.Value, 32)
+		g.counters[key] += int(float32(floatValue) * (1 / stat.Sampling))
+	}
+}
+
+func (sm *StatAccumInput) addTiming(g *statGroup, series map[statKey]*timingSeries,
+	key statKey, value float64) {
+
+	s, ok := series[key]
+	if !ok {
+		s = sm.newTimingSeries(g)
+		series[key] = s
+	}
+	s.add(value)
+}
+
 // Listens on the Stat channel for stats generated internally by Heka.
 func (sm *StatAccumInput) Run(ir InputRunner, h PluginHelper) (err error) {
 	var (
-		stat       Stat
-		floatValue float64
+		stat  Stat
+		group *statGroup
 	)
 
 	sm.ir = ir
 	sm.inChan = ir.InChan()
 	sm.tickChan = ir.Ticker()
+	groupTicks := make(chan *statGroup)
+	for _, g := range sm.groups {
+		go sm.tickGroup(g, groupTicks)
+	}
 	ok := true
 	for ok {
 		select {
 		case <-sm.tickChan:
 			sm.Flush()
+		case group = <-groupTicks:
+			sm.flushGroup(group)
 		case stat, ok = <-sm.statChan:
 			if !ok {
 				sm.Flush()
+				for _, group = range sm.groups {
+					sm.flushGroup(group)
+				}
 				break
 			}
-			switch stat.Modifier {
-			case "ms":
-				floatValue, _ = strconv.ParseFloat(stat.Value, 64)
-				sm.timers[stat.Bucket] = append(sm.timers[stat.Bucket], floatValue)
-			case "g":
-				floatValue, _ = strconv.ParseFloat(stat.Value, 64)
-				sm.gauges[stat.Bucket] = floatValue
-			default:
-				floatValue, _ = strconv.ParseFloat(stat.Value, 32)
-				sm.counters[stat.Bucket] += int(float32(floatValue) * (1 / stat.Sampling))
-			}
+			sm.accumulate(stat)
 		}
 	}
 
 	return
 }
 
+// Sends the group on the channel at the group's interval until the input is
+// stopped.
+func (sm *StatAccumInput) tickGroup(g *statGroup, groupTicks chan<- *statGroup) {
+	ticker := time.NewTicker(time.Duration(g.tickerInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case groupTicks <- g:
+			case <-sm.stopChan:
+				return
+			}
+		case <-sm.stopChan:
+			return
+		}
+	}
+}
+
 func (sm *StatAccumInput) Stop() {
 	// Closing the stopChan first so DropStat won't put any stats on
 	// the statChan after it's closed.
@@ -196,9 +489,15 @@ func (sm *StatAccumInput) DropStat(stat Stat) (sent bool) {
 	return
 }
 
-// Extracts all of the accumulated data and generates and injects a message
-// into the Heka pipeline.
+// Extracts all of the accumulated data of the stats outside of the configured
+// namespaces and generates and injects a message into the Heka pipeline.
 func (sm *StatAccumInput) Flush() {
+	sm.flushGroup(sm.defaultGroup)
+}
+
+// Extracts the accumulated data of a group of stats and generates and
+// injects a message into the Heka pipeline.
+func (sm *StatAccumInput) flushGroup(g *statGroup) {
 	var (
 		field *message.Field
 		err   error
@@ -235,100 +534,60 @@ func (sm *StatAccumInput) Flush() {
 
 	globalNs := rootNs.Namespace(sm.config.GlobalPrefix)
 	counterNs := globalNs.Namespace(sm.config.CounterPrefix)
-	for key, c := range sm.counters {
-		ratePerSecond := float64(c) / float64(sm.config.TickerInterval)
+	for key, c := range g.counters {
+		ratePerSecond := float64(c) / float64(g.tickerInterval)
 		if sm.config.LegacyNamespaces {
-			globalNs.EmitInField(key, int(ratePerSecond))
-			globalNs.EmitInPayload(key, ratePerSecond)
-			rootNs.Namespace("stats_counts").Emit(key, c)
+			globalNs.Tagged(key.suffix()).EmitInField(key.bucket, int(ratePerSecond))
+			globalNs.Tagged(key.suffix()).EmitInPayload(key.bucket, ratePerSecond)
+			rootNs.Namespace("stats_counts").Tagged(key.suffix()).Emit(key.bucket, c)
 		} else {
-			counterKey := counterNs.Namespace(key)
+			counterKey := counterNs.Namespace(key.bucket).Tagged(key.suffix())
 			counterKey.Emit("rate", ratePerSecond)
 			counterKey.Emit("count", c)
 		}
 		if sm.config.DeleteIdleStats {
-			delete(sm.counters, key)
+			delete(g.counters, key)
 		} else {
-			sm.counters[key] = 0
+			g.counters[key] = 0
 		}
 		numStats++
 	}
-	for key, gauge := range sm.gauges {
-		globalNs.Namespace(sm.config.GaugePrefix).Emit(key, float64(gauge))
+	for key, gauge := range g.gauges {
+		globalNs.Namespace(sm.config.GaugePrefix).Tagged(key.suffix()).Emit(key.bucket,
+			float64(gauge))
 		if sm.config.DeleteIdleStats {
-			delete(sm.gauges, key)
+			delete(g.gauges, key)
 		}
 		numStats++
 	}
-	countPercentThreshold := len(sm.config.PercentThreshold)
-	for key, timings := range sm.timers {
-		timerNs := globalNs.Namespace(sm.config.TimerPrefix).Namespace(key)
-		var min, max, sum, mean, rate, thresholdBoundary float64
-		meanPercentile := make([]float64, countPercentThreshold)
-		upperPercentile := make([]float64, countPercentThreshold)
-		count := len(timings)
-		if count > 0 {
-			sort.Float64s(timings)
-
-			cumulativeValues := make([]float64, count)
-			cumulativeValues[0] = timings[0]
-			for i := 1; i < count; i++ {
-				cumulativeValues[i] = timings[i] + cumulativeValues[i-1]
-			}
-
-			rate = float64(count) / float64(sm.config.TickerInterval)
-			min = timings[0]
-			max = timings[count-1]
-			mean = min
-
-			for i := 0; i < countPercentThreshold; i++ {
-				tmp := ((100.0 - float64(sm.config.PercentThreshold[i])) / 100.0) * float64(count)
-				numInThreshold := count - int(math.Floor(tmp+0.5)) // simulate JS Math.round(x)
-
-				if numInThreshold > 0 {
-					mean = cumulativeValues[numInThreshold-1] / float64(numInThreshold)
-					thresholdBoundary = timings[numInThreshold-1]
-				} else {
-					mean = min
-					thresholdBoundary = max
-				}
-				meanPercentile[i] = mean
-				upperPercentile[i] = thresholdBoundary
-			}
-
-			sum = cumulativeValues[len(cumulativeValues)-1]
-			mean = sum / float64(count)
-		} else {
-			rate = 0.
-			min = 0.
-			max = 0.
-			sum = 0.
-			mean = 0.
-		}
-
-		timerNs.Emit("count", count)
-		timerNs.Emit("count_ps", rate)
-		timerNs.Emit("lower", min)
-		timerNs.Emit("upper", max)
-		timerNs.Emit("sum", sum)
-		timerNs.Emit("mean", mean)
-		for i := 0; i < countPercentThreshold; i++ {
-			timerNs.Emit(fmt.Sprintf("mean_%d", sm.config.PercentThreshold[i]), meanPercentile[i])
-			timerNs.Emit(fmt.Sprintf("upper_%d", sm.config.PercentThreshold[i]), upperPercentile[i])
-		}
-
+	for key, set := range g.sets {
+		setNs := globalNs.Namespace(sm.config.SetPrefix).Namespace(key.bucket)
+		setNs.Tagged(key.suffix()).Emit("count", len(set))
 		if sm.config.DeleteIdleStats {
-			delete(sm.timers, key)
+			delete(g.sets, key)
 		} else {
-			sm.timers[key] = timings[:0]
+			g.sets[key] = make(map[string]struct{})
 		}
 		numStats++
 	}
+	numStats += sm.flushTimings(g, g.timers, globalNs.Namespace(sm.config.TimerPrefix))
+	numStats += sm.flushTimings(g, g.histograms,
+		globalNs.Namespace(sm.config.HistogramPrefix))
+	numStats += sm.flushTimings(g, g.distributions,
+		globalNs.Namespace(sm.config.DistributionPrefix))
+	if sm.config.DeleteIdleStats {
+		// The series are gone, so are their tag sets.
+		g.tagSets = make(map[string]map[string]struct{})
+	}
 
+	statsdNs := globalNs.Namespace(sm.config.StatsdPrefix)
 	if sm.config.LegacyNamespaces {
-		rootNs.Namespace(sm.config.StatsdPrefix).Emit("numStats", numStats)
-	} else {
-		globalNs.Namespace(sm.config.StatsdPrefix).Emit("numStats", numStats)
+		statsdNs = rootNs.Namespace(sm.config.StatsdPrefix)
+	}
+	statsdNs.Emit("numStats", numStats)
+	if g.tagOverflows > 0 {
+		statsdNs.Emit("tagOverflows", g.tagOverflows)
+		g.tagOverflows = 0
 	}
 
 	pack.Message.SetLogger(sm.ir.Name())
@@ -341,6 +600,56 @@ func (sm *StatAccumInput) Flush() {
 	sm.ir.Deliver(pack)
 }
 
+// Emits the count, rate, bounds, sum, mean and percentiles of each series of
+// timings, which are used for timers, histograms and distributions, returning
+// the number of series.
+func (sm *StatAccumInput) flushTimings(g *statGroup,
+	series map[statKey]*timingSeries, typeNs *namespaceTree) (numStats int) {
+
+	thresholds := sm.config.PercentThreshold
+	for key, timings := range series {
+		timerNs := typeNs.Namespace(key.bucket).Tagged(key.suffix())
+		s := timings.values.summarize(thresholds)
+		var mean float64
+		if s.count > 0 {
+			mean = s.sum / float64(s.count)
+		}
+
+		timerNs.Emit("count", s.count)
+		timerNs.Emit("count_ps", float64(s.count)/float64(g.tickerInterval))
+		timerNs.Emit("lower", s.min)
+		timerNs.Emit("upper", s.max)
+		timerNs.Emit("sum", s.sum)
+		timerNs.Emit("mean", mean)
+		for i, threshold := range thresholds {
+			timerNs.Emit(fmt.Sprintf("mean_%d", threshold), s.meanPercentile[i])
+			timerNs.Emit(fmt.Sprintf("upper_%d", threshold), s.upperPercentile[i])
+		}
+		cumulative := 0
+		for i, bound := range timings.bounds {
+			cumulative += timings.bucketCounts[i]
+			timerNs.Emit("bucket_le_"+bucketBoundName(bound), cumulative)
+		}
+		if timings.bounds != nil {
+			timerNs.Emit("bucket_le_inf", s.count)
+		}
+
+		if sm.config.DeleteIdleStats {
+			delete(series, key)
+		} else {
+			timings.reset()
+		}
+		numStats++
+	}
+	return numStats
+}
+
+// Formats a bucket bound for use in a stat name, where dots would separate
+// the name's components.
+func bucketBoundName(bound float64) string {
+	return strings.Replace(strconv.FormatFloat(bound, 'f', -1, 64), ".", "_", -1)
+}
+
 type statsEmitters struct {
 	EmitInPayload func(key string, value interface{})
 	EmitInField   func(key string, value interface{})
@@ -350,6 +659,8 @@ type namespaceTree struct {
 	prefix   string
 	Emitters *statsEmitters
 	parent   *namespaceTree
+	// Appended to the emitted names, used for the tags of a series.
+	suffix string
 }
 
 func NewRootNamespace() *namespaceTree {
@@ -370,31 +681,38 @@ func (ns *namespaceTree) setNamespace(namespace string) {
 }
 
 func (ns *namespaceTree) Namespace(namespace string) *namespaceTree {
-	n := namespaceTree{"", ns.Emitters, ns}
+	n := namespaceTree{"", ns.Emitters, ns, ns.suffix}
 	n.setNamespace(namespace)
 	return &n
 }
 
+// Returns a copy of the namespace that appends the suffix to emitted names.
+func (ns *namespaceTree) Tagged(suffix string) *namespaceTree {
+	n := *ns
+	n.suffix = suffix
+	return &n
+}
+
 func (ns *namespaceTree) EmitInField(key string, value interface{}) *namespaceTree {
 	if ns.Emitters.EmitInField != nil {
-		ns.Emitters.EmitInField(ns.prefix+key, value)
+		ns.Emitters.EmitInField(ns.prefix+key+ns.suffix, value)
 	}
 	return ns
 }
 
 func (ns *namespaceTree) EmitInPayload(key string, value interface{}) *namespaceTree {
 	if ns.Emitters.EmitInPayload != nil {
-		ns.Emitters.EmitInPayload(ns.prefix+key, value)
+		ns.Emitters.EmitInPayload(ns.prefix+key+ns.suffix, value)
 	}
 	return ns
 }
 
 func (ns *namespaceTree) Emit(key string, value interface{}) *namespaceTree {
 	if ns.Emitters.EmitInPayload != nil {
-		ns.Emitters.EmitInPayload(ns.prefix+key, value)
+		ns.Emitters.EmitInPayload(ns.prefix+key+ns.suffix, value)
 	}
 	if ns.Emitters.EmitInField != nil {
-		ns.Emitters.EmitInField(ns.prefix+key, value)
+		ns.Emitters.EmitInField(ns.prefix+key+ns.suffix, value)
 	}
 	return ns
 }
@@ -16,6 +16,7 @@ package pipeline
 
 import (
 	"log"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -66,6 +67,45 @@ func StatAccumInputSpec(c gs.Context) {
 			c.Expect(err.Error(), gs.Equals, expected)
 		})
 
+		c.Specify("validates the timer settings", func() {
+			config.TimerSketch = "tdigest"
+			err := statAccumInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+
+			config.TimerSketch = "hdr"
+			config.SketchSignificantDigits = 5
+			err = statAccumInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+
+			config.SketchSignificantDigits = 2
+			config.BucketBounds = []float64{10, 5}
+			err = statAccumInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("validates the namespaces", func() {
+			config.Namespaces = map[string]StatNamespaceConfig{
+				"slow": {TickerInterval: 60},
+			}
+			err := statAccumInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+
+			config.Namespaces["slow"] = StatNamespaceConfig{Prefixes: []string{"slow."}}
+			err = statAccumInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+
+			config.Namespaces["slow"] = StatNamespaceConfig{
+				Prefixes:       []string{"slow."},
+				TickerInterval: 60,
+			}
+			err = statAccumInput.Init(config)
+			c.Expect(err, gs.IsNil)
+			c.Expect(statAccumInput.groupFor("slow.cnt"), gs.Equals,
+				statAccumInput.groups[0])
+			c.Expect(statAccumInput.groupFor("fast.cnt"), gs.Equals,
+				statAccumInput.defaultGroup)
+		})
+
 		c.Specify("that is started", func() {
 			ith := new(InputTestHelper)
 			ith.MockHelper = NewMockPluginHelper(ctrl)
@@ -119,17 +159,17 @@ func StatAccumInputSpec(c gs.Context) {
 
 				sendTimer := func(key string, vals ...int) {
 					for _, v := range vals {
-						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "ms", float32(1)}
+						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "ms", float32(1), nil}
 					}
 				}
 				sendCounter := func(key string, vals ...int) {
 					for _, v := range vals {
-						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "c", float32(1)}
+						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "c", float32(1), nil}
 					}
 				}
 				sendGauge := func(key string, vals ...int) {
 					for _, v := range vals {
-						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "g", float32(1)}
+						statAccumInput.statChan <- Stat{key, strconv.Itoa(v), "g", float32(1), nil}
 					}
 				}
 
@@ -268,6 +308,158 @@ func StatAccumInputSpec(c gs.Context) {
 					validateValueAtKey(msg, "stats.statsd.numStats", int64(1))
 				})
 
+				sendStat := func(key, modifier string, tags []string, vals ...string) {
+					for _, v := range vals {
+						statAccumInput.statChan <- Stat{key, v, modifier, float32(1), tags}
+					}
+				}
+
+				c.Specify("emits sets, histograms and distributions", func() {
+					startInput()
+					sendStat("sample.set", "s", nil, "a", "b", "a", "c")
+					sendStat("sample.hist", "h", nil, "1", "3")
+					sendStat("sample.dist", "d", nil, "2.5")
+					msg, err := finalizeSendingStats()
+					c.Assume(err, gs.IsNil)
+					validateValueAtKey(msg, "stats.sets.sample.set.count", int64(3))
+					validateValueAtKey(msg, "stats.histograms.sample.hist.count", int64(2))
+					validateValueAtKey(msg, "stats.histograms.sample.hist.mean", 2.0)
+					validateValueAtKey(msg, "stats.distributions.sample.dist.upper", 2.5)
+					validateValueAtKey(msg, "stats.statsd.numStats", int64(3))
+				})
+
+				c.Specify("aggregates each tag set separately", func() {
+					startInput()
+					sendStat("sample.cnt", "c", []string{"env:prod", "canary"}, "1", "2")
+					sendStat("sample.cnt", "c", []string{"canary", "env:prod"}, "3")
+					sendStat("sample.cnt", "c", []string{"env:dev"}, "4")
+					sendStat("sample.cnt", "c", []string{"env:"}, "7")
+					sendStat("sample.cnt", "c", nil, "5")
+					sendStat("sample.timer", "ms", []string{"route:/a;b=c"}, "10")
+					msg, err := finalizeSendingStats()
+					c.Assume(err, gs.IsNil)
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count;canary=true;env=prod",
+						int64(6))
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count;env=dev", int64(4))
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count;env=true", int64(7))
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count", int64(5))
+					validateValueAtKey(msg, "stats.timers.sample.timer.upper;route=/a_b_c",
+						10.0)
+					validateValueAtKey(msg, "stats.statsd.numStats", int64(5))
+				})
+
+				c.Specify("limits the tag sets of a bucket", func() {
+					config.MaxTagSetsPerBucket = 2
+					err := statAccumInput.Init(config)
+					c.Assume(err, gs.IsNil)
+					startInput()
+					sendStat("sample.cnt", "c", []string{"host:a"}, "1")
+					sendStat("sample.cnt", "c", []string{"host:b"}, "1")
+					sendStat("sample.cnt", "c", []string{"host:c"}, "1")
+					sendStat("sample.cnt", "c", []string{"host:a"}, "1")
+					sendStat("other.cnt", "c", []string{"host:c"}, "1")
+					msg, err := finalizeSendingStats()
+					c.Assume(err, gs.IsNil)
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count;host=a", int64(2))
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count;host=b", int64(1))
+					validateValueAtKey(msg, "stats.counters.sample.cnt.count", int64(1))
+					validateValueAtKey(msg, "stats.counters.other.cnt.count;host=c", int64(1))
+					validateValueAtKey(msg, "stats.statsd.tagOverflows", int64(1))
+				})
+				c.Specify("emits histogram bucket counts", func() {
+					config.BucketBounds = []float64{0.5, 10, 100}
+					err := statAccumInput.Init(config)
+					c.Assume(err, gs.IsNil)
+					startInput()
+					sendStat("sample.timer", "ms", nil, "0.25", "5", "10", "50", "500")
+					sendStat("sample.hist", "h", nil, "1")
+					msg, err := finalizeSendingStats()
+					c.Assume(err, gs.IsNil)
+					validateValueAtKey(msg, "stats.timers.sample.timer.bucket_le_0_5", int64(1))
+					validateValueAtKey(msg, "stats.timers.sample.timer.bucket_le_10", int64(3))
+					validateValueAtKey(msg, "stats.timers.sample.timer.bucket_le_100", int64(4))
+					validateValueAtKey(msg, "stats.timers.sample.timer.bucket_le_inf", int64(5))
+					validateValueAtKey(msg, "stats.histograms.sample.hist.bucket_le_0_5",
+						int64(0))
+					validateValueAtKey(msg, "stats.histograms.sample.hist.bucket_le_inf",
+						int64(1))
+				})
+
+				c.Specify("summarizes timers with an hdr sketch", func() {
+					config.TimerSketch = "hdr"
+					err := statAccumInput.Init(config)
+					c.Assume(err, gs.IsNil)
+					startInput()
+					for i := 1; i <= 100; i++ {
+						sendStat("sample.timer", "ms", nil, strconv.Itoa(i))
+					}
+					msg, err := finalizeSendingStats()
+					c.Assume(err, gs.IsNil)
+					validateValueAtKey(msg, "stats.timers.sample.timer.count", int64(100))
+					validateValueAtKey(msg, "stats.timers.sample.timer.lower", 1.0)
+					validateValueAtKey(msg, "stats.timers.sample.timer.upper", 100.0)
+					validateValueAtKey(msg, "stats.timers.sample.timer.sum", 5050.0)
+					validateValueAtKey(msg, "stats.timers.sample.timer.mean", 50.5)
+					upper90, _ := msg.GetFieldValue("stats.timers.sample.timer.upper_90")
+					c.Expect(math.Abs(upper90.(float64)-90) <= 0.9, gs.IsTrue)
+					mean90, _ := msg.GetFieldValue("stats.timers.sample.timer.mean_90")
+					c.Expect(math.Abs(mean90.(float64)-45.5) <= 0.5, gs.IsTrue)
+				})
+
+				c.Specify("flushes namespaces on their own interval", func() {
+					config.Namespaces = map[string]StatNamespaceConfig{
+						"fast": {Prefixes: []string{"fast."}, TickerInterval: 1},
+					}
+					err := statAccumInput.Init(config)
+					c.Assume(err, gs.IsNil)
+
+					// Every flush gets a fresh pack.
+					<-ith.PackSupply
+					supplyDone := make(chan struct{})
+					defer close(supplyDone)
+					go func() {
+						for {
+							select {
+							case ith.PackSupply <- NewPipelinePack(pConfig.inputRecycleChan):
+							case <-supplyDone:
+								return
+							}
+						}
+					}()
+					delivered := make(chan *message.Message, 10)
+					ith.MockInputRunner.EXPECT().Name().Return("StatAccumInput").AnyTimes()
+					ith.MockInputRunner.EXPECT().Deliver(gomock.Any()).Do(
+						func(pack *PipelinePack) {
+							delivered <- pack.Message
+						}).AnyTimes()
+
+					startInput()
+					sendStat("fast.cnt", "c", nil, "1")
+					sendStat("sample.cnt", "c", nil, "2")
+
+					var msg *message.Message
+					select {
+					case msg = <-delivered:
+					case <-time.After(5 * time.Second):
+					}
+					c.Assume(msg, gs.Not(gs.IsNil))
+					validateValueAtKey(msg, "stats.counters.fast.cnt.count", int64(1))
+					validateValueAtKey(msg, "stats.counters.fast.cnt.rate", 1.0)
+					_, ok := msg.GetFieldValue("stats.counters.sample.cnt.count")
+					c.Expect(ok, gs.IsFalse)
+
+					statAccumInput.Stop()
+					c.Expect(<-runErrChan, gs.IsNil)
+					close(delivered)
+					var sampleCount interface{}
+					for msg = range delivered {
+						if value, ok := msg.GetFieldValue(
+							"stats.counters.sample.cnt.count"); ok {
+							sampleCount = value
+						}
+					}
+					c.Expect(sampleCount, gs.Equals, int64(2))
+				})
 			})
 
 			c.Specify("using legacy namespaces", func() {
@@ -275,7 +467,7 @@ func StatAccumInputSpec(c gs.Context) {
 
 				statName := "sample.stat"
 				statVal := int64(303)
-				testStat := Stat{statName, strconv.Itoa(int(statVal)), "c", float32(1)}
+				testStat := Stat{statName, strconv.Itoa(int(statVal)), "c", float32(1), nil}
 
 				validateMsgFields := func(msg *message.Message) {
 					c.Expect(len(msg.Fields), gs.Equals, 4)
@@ -415,7 +607,7 @@ func StatAccumInputSpec(c gs.Context) {
 					sendTimer := func(vals ...int) {
 						for _, v := range vals {
 							statAccumInput.statChan <- Stat{"sample.timer", strconv.Itoa(int(v)),
-								"ms", float32(1)}
+								"ms", float32(1), nil}
 						}
 					}
 					config.EmitInFields = true
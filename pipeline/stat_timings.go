@@ -0,0 +1,235 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package pipeline
+
+import (
+	"math"
+	"sort"
+)
+
+// Statistics of the values of a timer, histogram or distribution series
+// since the last flush.
+type timingSummary struct {
+	count           int
+	min, max, sum   float64
+	meanPercentile  []float64
+	upperPercentile []float64
+}
+
+// Holds the values of a timing series, either all of them or a sketch.
+type timingValues interface {
+	add(value float64)
+	summarize(thresholds []int) *timingSummary
+	reset()
+}
+
+// Returns the number of the lowest values that fall within a percent
+// threshold.
+func numInThreshold(count, threshold int) int {
+	tmp := ((100.0 - float64(threshold)) / 100.0) * float64(count)
+	return count - int(math.Floor(tmp+0.5)) // simulate JS Math.round(x)
+}
+
+// Keeps every value, so the summary is exact but the memory used grows with
+// the number of values per flush interval.
+type exactTimings struct {
+	values []float64
+}
+
+func (t *exactTimings) add(value float64) {
+	t.values = append(t.values, value)
+}
+
+func (t *exactTimings) summarize(thresholds []int) *timingSummary {
+	s := &timingSummary{
+		count:           len(t.values),
+		meanPercentile:  make([]float64, len(thresholds)),
+		upperPercentile: make([]float64, len(thresholds)),
+	}
+	if s.count == 0 {
+		return s
+	}
+	timings := t.values
+	sort.Float64s(timings)
+
+	cumulativeValues := make([]float64, s.count)
+	cumulativeValues[0] = timings[0]
+	for i := 1; i < s.count; i++ {
+		cumulativeValues[i] = timings[i] + cumulativeValues[i-1]
+	}
+	s.min = timings[0]
+	s.max = timings[s.count-1]
+	s.sum = cumulativeValues[s.count-1]
+
+	for i, threshold := range thresholds {
+		if n := numInThreshold(s.count, threshold); n > 0 {
+			s.meanPercentile[i] = cumulativeValues[n-1] / float64(n)
+			s.upperPercentile[i] = timings[n-1]
+		} else {
+			s.meanPercentile[i] = s.min
+			s.upperPercentile[i] = s.max
+		}
+	}
+	return s
+}
+
+func (t *exactTimings) reset() {
+	t.values = t.values[:0]
+}
+
+// An HDR histogram style sketch that counts values in log-linear buckets,
+// each power of two being split in `subBuckets` linear buckets, so the
+// memory used only depends on the range of the values. Count, sum, lower
+// and upper are exact, the percentiles are within the relative error of a
+// bucket's width. Values <= 0 are counted together as 0.
+type hdrSketch struct {
+	subBuckets    int
+	counts        map[int]int
+	zeroCount     int
+	count         int
+	min, max, sum float64
+}
+
+// Returns a sketch whose percentiles keep the given number of significant
+// decimal digits.
+func newHdrSketch(significantDigits int) *hdrSketch {
+	subBuckets := 1
+	for float64(subBuckets) < math.Pow10(significantDigits) {
+		subBuckets <<= 1
+	}
+	return &hdrSketch{
+		subBuckets: subBuckets,
+		counts:     make(map[int]int),
+	}
+}
+
+func (h *hdrSketch) bucketIndex(value float64) int {
+	// value = frac * 2^exp, with frac in [0.5, 1).
+	frac, exp := math.Frexp(value)
+	sub := int((frac - 0.5) * 2 * float64(h.subBuckets))
+	return exp*h.subBuckets + sub
+}
+
+// Returns the midpoint of a bucket.
+func (h *hdrSketch) bucketValue(index int) float64 {
+	exp := index / h.subBuckets
+	sub := index % h.subBuckets
+	if sub < 0 {
+		exp--
+		sub += h.subBuckets
+	}
+	frac := 0.5 + (float64(sub)+0.5)/float64(2*h.subBuckets)
+	return math.Ldexp(frac, exp)
+}
+
+func (h *hdrSketch) add(value float64) {
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.count++
+	h.sum += value
+	if value <= 0 {
+		h.zeroCount++
+		return
+	}
+	h.counts[h.bucketIndex(value)]++
+}
+
+func (h *hdrSketch) summarize(thresholds []int) *timingSummary {
+	s := &timingSummary{
+		count:           h.count,
+		min:             h.min,
+		max:             h.max,
+		sum:             h.sum,
+		meanPercentile:  make([]float64, len(thresholds)),
+		upperPercentile: make([]float64, len(thresholds)),
+	}
+	if h.count == 0 {
+		return s
+	}
+	indexes := make([]int, 0, len(h.counts))
+	for index := range h.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for i, threshold := range thresholds {
+		n := numInThreshold(h.count, threshold)
+		if n <= 0 {
+			s.meanPercentile[i] = s.min
+			s.upperPercentile[i] = s.max
+			continue
+		}
+		// Walk the buckets from the lowest up until n values are covered.
+		var sum, upper float64
+		remaining := n
+		take := func(count int, value float64) {
+			if count > remaining {
+				count = remaining
+			}
+			// Buckets can be wider than the range of their values.
+			value = math.Max(s.min, math.Min(s.max, value))
+			sum += float64(count) * value
+			remaining -= count
+			upper = value
+		}
+		if h.zeroCount > 0 {
+			take(h.zeroCount, 0)
+		}
+		for _, index := range indexes {
+			if remaining == 0 {
+				break
+			}
+			take(h.counts[index], h.bucketValue(index))
+		}
+		s.meanPercentile[i] = sum / float64(n)
+		s.upperPercentile[i] = upper
+	}
+	return s
+}
+
+func (h *hdrSketch) reset() {
+	h.counts = make(map[int]int)
+	h.zeroCount = 0
+	h.count = 0
+	h.min, h.max, h.sum = 0, 0, 0
+}
+
+// The values of a timing series, along with the number of values that fell
+// in each of the configured histogram buckets.
+type timingSeries struct {
+	values timingValues
+	bounds []float64
+	// Non-cumulative, the last one counts the values above the highest
+	// bound.
+	bucketCounts []int
+}
+
+func (s *timingSeries) add(value float64) {
+	s.values.add(value)
+	if s.bounds != nil {
+		s.bucketCounts[sort.SearchFloat64s(s.bounds, value)]++
+	}
+}
+
+func (s *timingSeries) reset() {
+	s.values.reset()
+	for i := range s.bucketCounts {
+		s.bucketCounts[i] = 0
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
@@ -50,13 +51,30 @@ type AttachManager struct {
 	fieldsFromLabels        []string
 	sinces                  *SinceTracker
 	newContainersReplayLogs bool
+	decoderFromLabel        string
+	maxLineSize             int
+	attachedLock            sync.Mutex
+	attached                map[string]*attachment
+}
+
+// The log streams of a container we're attached to.
+type attachment struct {
+	stdout *lineJoiner
+	stderr *lineJoiner
+}
+
+// Pass on the unfinished lines held for both streams.
+func (a *attachment) flush() {
+	a.stdout.Flush()
+	a.stderr.Flush()
 }
 
 // Construct an AttachManager and set up the Docker Client
 func NewAttachManager(endpoint string, certPath string, nameFromEnv string,
 	fieldsFromEnv []string, fieldsFromLabels []string,
 	sincePath string, sinceInterval time.Duration, containerExpiryDays int,
-	newContainersReplayLogs bool) (*AttachManager, error) {
+	newContainersReplayLogs bool, decoderFromLabel string,
+	maxLineSize int) (*AttachManager, error) {
 
 	client, err := newDockerClient(certPath, endpoint)
 	if err != nil {
@@ -76,6 +94,9 @@ func NewAttachManager(endpoint string, certPath string, nameFromEnv string,
 		fieldsFromLabels:        fieldsFromLabels,
 		sinces:			 sinceTracker,
 		newContainersReplayLogs: newContainersReplayLogs,
+		decoderFromLabel:        decoderFromLabel,
+		maxLineSize:             maxLineSize,
+		attached:                make(map[string]*attachment),
 	}
 
 	return m, nil
@@ -199,8 +220,14 @@ func (m *AttachManager) handleDockerEvents(stopChan chan error) {
 				continue
 			}
 
-			if msg.Status == "start" {
+			switch msg.Status {
+			case "start":
+				// Lines a previous run of the container left unfinished
+				// won't be continued.
+				m.flush(msg.ID[:12])
 				go m.attach(msg.ID[:12], m.client)
+			case "die", "stop":
+				m.flush(msg.ID[:12])
 			}
 		case <-stopChan:
 			return
@@ -208,17 +235,47 @@ func (m *AttachManager) handleDockerEvents(stopChan chan error) {
 	}
 }
 
+// Pass on the unfinished lines held for a container, if we're attached to it.
+func (m *AttachManager) flush(id string) {
+	m.attachedLock.Lock()
+	a, ok := m.attached[id]
+	m.attachedLock.Unlock()
+	if ok {
+		a.flush()
+	}
+}
+
+// Returns the decoder named by the container's label and whether there is
+// one. An empty label value turns decoding off for the container.
+func (m *AttachManager) labelDecoder(container *docker.Container) (string, bool) {
+	if m.decoderFromLabel == "" {
+		return "", false
+	}
+	decoder, ok := container.Config.Labels[m.decoderFromLabel]
+	return decoder, ok
+}
+
 // Attach to the log output of a single running container.
 func (m *AttachManager) attach(id string, client DockerClient) error {
 	m.ir.LogMessage(fmt.Sprintf("Attaching container: %s", id))
 
-	fields, err := extractFields(id, client, m.fieldsFromLabels, m.fieldsFromEnv, m.nameFromEnv)
+	container, err := client.InspectContainer(id)
 	if err != nil {
 		return err
 	}
+	fields := containerFields(id, container, m.fieldsFromLabels, m.fieldsFromEnv, m.nameFromEnv)
+
+	decoder, useLabel := m.labelDecoder(container)
 
 	outrd, outwr := io.Pipe()
 	errrd, errwr := io.Pipe()
+	a := &attachment{
+		stdout: newLineJoiner(outwr, m.maxLineSize),
+		stderr: newLineJoiner(errwr, m.maxLineSize),
+	}
+	m.attachedLock.Lock()
+	m.attached[id] = a
+	m.attachedLock.Unlock()
 
 	// Spin up one of these for each container we're watching.
 	go func() {
@@ -248,8 +305,8 @@ func (m *AttachManager) attach(id string, client DockerClient) error {
 		// This will block until the container exits.
 		err := client.Logs(docker.LogsOptions{
 			Container:    id,
-			OutputStream: outwr,
-			ErrorStream:  errwr,
+			OutputStream: a.stdout,
+			ErrorStream:  a.stderr,
 			Follow:       true,
 			Stdout:       true,
 			Stderr:       true,
@@ -259,8 +316,14 @@ func (m *AttachManager) attach(id string, client DockerClient) error {
 			RawTerminal:  false,
 		})
 
-		// Once it has exited, close our pipes, set the since time to now, and
-		// (if necessary) log the error.
+		// Once it has exited, pass on unfinished lines, close our pipes, set
+		// the since time to now, and (if necessary) log the error.
+		m.attachedLock.Lock()
+		if m.attached[id] == a {
+			delete(m.attached, id)
+		}
+		m.attachedLock.Unlock()
+		a.flush()
 		outwr.Close()
 		errwr.Close()
 		m.sinces.Lock()
@@ -273,8 +336,8 @@ func (m *AttachManager) attach(id string, client DockerClient) error {
 	}()
 
 	// Wait for success from the attachment
-	go m.handleOneStream("stdout", outrd, fields, id)
-	go m.handleOneStream("stderr", errrd, fields, id)
+	go m.handleOneStream("stdout", outrd, fields, id, decoder, useLabel)
+	go m.handleOneStream("stderr", errrd, fields, id, decoder, useLabel)
 	return nil
 }
 
@@ -303,9 +366,11 @@ func (m *AttachManager) makePackDecorator(logger string,
 	}
 }
 
-// Sets up the Heka pipeline for a single IO stream (either stdout or stderr)
+// Sets up the Heka pipeline for a single IO stream (either stdout or stderr).
+// If useLabel is set the stream is decoded with the decoder named by the
+// container's label instead of the input's one.
 func (m *AttachManager) handleOneStream(name string, in io.Reader, fields map[string]string,
-	containerId string) {
+	containerId string, decoder string, useLabel bool) {
 
 	id := fmt.Sprintf("%s-%s", fields["ContainerName"], name)
 
@@ -314,7 +379,17 @@ func (m *AttachManager) handleOneStream(name string, in io.Reader, fields map[st
 		sRunner.SetPackDecorator(m.makePackDecorator(name, fields))
 	}
 
-	deliverer := m.ir.NewDeliverer(id)
+	var deliverer Deliverer
+	if useLabel {
+		var err error
+		if deliverer, err = m.ir.NewDecoderDeliverer(id, decoder); err != nil {
+			m.ir.LogError(fmt.Errorf("container %s: %s, using the default decoder",
+				containerId, err.Error()))
+		}
+	}
+	if deliverer == nil {
+		deliverer = m.ir.NewDeliverer(id)
+	}
 
 	var err error
 	for err == nil {
@@ -324,6 +399,7 @@ func (m *AttachManager) handleOneStream(name string, in io.Reader, fields map[st
 		}
 	}
 	sRunner.Done()
+	deliverer.Done()
 
 	m.ir.LogMessage(fmt.Sprintf("Disconnecting %s stream from %s", name, containerId))
 }
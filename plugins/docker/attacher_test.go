@@ -0,0 +1,101 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func AttachManagerSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+	mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+
+	c.Specify("An AttachManager's label decoder", func() {
+		m := &AttachManager{decoderFromLabel: "heka.decoder"}
+		container := &docker.Container{Config: &docker.Config{
+			Labels: map[string]string{"heka.decoder": "JsonDecoder"},
+		}}
+
+		c.Specify("is named by the container's label", func() {
+			decoder, ok := m.labelDecoder(container)
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(decoder, gs.Equals, "JsonDecoder")
+		})
+
+		c.Specify("is empty for an empty label", func() {
+			container.Config.Labels["heka.decoder"] = ""
+			decoder, ok := m.labelDecoder(container)
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(decoder, gs.Equals, "")
+		})
+
+		c.Specify("isn't used without the label", func() {
+			delete(container.Config.Labels, "heka.decoder")
+			_, ok := m.labelDecoder(container)
+			c.Expect(ok, gs.IsFalse)
+		})
+
+		c.Specify("isn't used without decoder_from_label", func() {
+			m.decoderFromLabel = ""
+			_, ok := m.labelDecoder(container)
+			c.Expect(ok, gs.IsFalse)
+		})
+	})
+
+	c.Specify("An AttachManager's stream", func() {
+		m := &AttachManager{ir: mockIR}
+		fields := map[string]string{"ContainerName": "web"}
+		in := strings.NewReader("")
+
+		mockIR.EXPECT().NewSplitterRunner("web-stdout").Return(mockSR)
+		mockSR.EXPECT().UseMsgBytes().Return(true)
+		mockSR.EXPECT().SplitStream(in, mockDeliverer).Return(io.EOF)
+		mockSR.EXPECT().Done()
+		mockDeliverer.EXPECT().Done()
+		mockIR.EXPECT().LogMessage(gomock.Any())
+
+		c.Specify("uses the input's decoder without a label", func() {
+			mockIR.EXPECT().NewDeliverer("web-stdout").Return(mockDeliverer)
+			m.handleOneStream("stdout", in, fields, "0123456789ab", "", false)
+		})
+
+		c.Specify("uses the decoder named by the container's label", func() {
+			mockIR.EXPECT().NewDecoderDeliverer("web-stdout", "JsonDecoder").Return(
+				mockDeliverer, nil)
+			m.handleOneStream("stdout", in, fields, "0123456789ab", "JsonDecoder", true)
+		})
+
+		c.Specify("falls back to the input's decoder for an unknown one", func() {
+			mockIR.EXPECT().NewDecoderDeliverer("web-stdout", "NoDecoder").Return(
+				nil, errors.New("no decoder"))
+			mockIR.EXPECT().LogError(gomock.Any())
+			mockIR.EXPECT().NewDeliverer("web-stdout").Return(mockDeliverer)
+			m.handleOneStream("stdout", in, fields, "0123456789ab", "NoDecoder", true)
+		})
+	})
+}
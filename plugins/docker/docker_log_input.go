@@ -17,10 +17,12 @@
 package docker
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
 
+	"github.com/mozilla-services/heka/message"
 	"github.com/mozilla-services/heka/pipeline"
 )
 
@@ -35,6 +37,10 @@ type DockerLogInputConfig struct {
 	FieldsFromLabels        []string `toml:"fields_from_labels"`
 	ContainerExpiryDays     int      `toml:"container_expiry_days"`
 	NewContainersReplayLogs bool     `toml:"new_containers_replay_logs"`
+	// Name of the container label naming the decoder for its logs.
+	DecoderFromLabel string `toml:"decoder_from_label"`
+	// Lines longer than this are split into several messages.
+	MaxLineSize uint32 `toml:"max_line_size"`
 }
 
 type DockerLogInput struct {
@@ -56,6 +62,7 @@ func (di *DockerLogInput) ConfigStruct() interface{} {
 		SinceInterval:           "5s",
 		ContainerExpiryDays:     30,
 		NewContainersReplayLogs: true,
+		MaxLineSize:             message.MAX_MESSAGE_SIZE,
 	}
 }
 
@@ -71,6 +78,10 @@ func (di *DockerLogInput) Init(config interface{}) error {
 			err.Error())
 	}
 
+	if conf.MaxLineSize == 0 {
+		return errors.New("max_line_size must be greater than zero")
+	}
+
 	// Make sure we have a sinces File.
 	err = EnsureSincesFile(conf, sincePath)
 	if err != nil {
@@ -90,6 +101,8 @@ func (di *DockerLogInput) Init(config interface{}) error {
 		sinceInterval,
 		conf.ContainerExpiryDays,
 		conf.NewContainersReplayLogs,
+		conf.DecoderFromLabel,
+		int(conf.MaxLineSize),
 	)
 	if err != nil {
 		return fmt.Errorf("DockerLogInput: failed to attach: %s", err.Error())
@@ -33,6 +33,17 @@ type DockerStatsInputConfig struct {
 	NameFromEnv      string   `toml:"name_from_env_var"`
 	FieldsFromEnv    []string `toml:"fields_from_env"`
 	FieldsFromLabels []string `toml:"fields_from_labels"`
+	// Where the stats are read from, "docker" for the Docker daemon or
+	// "cgroup" for the cgroup v2 hierarchy.
+	Source string `toml:"source"`
+	// Root of the cgroup v2 hierarchy.
+	CgroupRoot string `toml:"cgroup_root"`
+	// Globs matching the containers' cgroups, relative to cgroup_root.
+	CgroupGlobs []string `toml:"cgroup_globs"`
+	// Where the proc filesystem is mounted, for network stats.
+	ProcRoot string `toml:"proc_root"`
+	// How often the cgroups are read, in seconds.
+	TickerInterval uint `toml:"ticker_interval"`
 }
 
 type DockerStatsInput struct {
@@ -42,12 +53,18 @@ type DockerStatsInput struct {
 	statsstream  chan *DockerStat
 	attachErrors chan error
 	statsMgr     *StatsManager
+	cgroups      *cgroupStatsReader
 }
 
 func (di *DockerStatsInput) ConfigStruct() interface{} {
 	return &DockerStatsInputConfig{
-		Endpoint: "unix:///var/run/docker.sock",
-		CertPath: "",
+		Endpoint:       "unix:///var/run/docker.sock",
+		CertPath:       "",
+		Source:         "docker",
+		CgroupRoot:     "/sys/fs/cgroup",
+		CgroupGlobs:    []string{"system.slice/docker-*.scope", "docker/*"},
+		ProcRoot:       "/proc",
+		TickerInterval: uint(10),
 	}
 }
 
@@ -59,6 +76,20 @@ func (di *DockerStatsInput) Init(config interface{}) error {
 	di.statsstream = make(chan *DockerStat)
 	di.attachErrors = make(chan error)
 
+	switch di.conf.Source {
+	case "docker":
+	case "cgroup":
+		var err error
+		di.cgroups, err = newCgroupStatsReader(di.conf.CgroupRoot, di.conf.CgroupGlobs,
+			di.conf.ProcRoot)
+		if err != nil {
+			return fmt.Errorf("DockerStatsInput: %s", err.Error())
+		}
+		return nil
+	default:
+		return fmt.Errorf("DockerStatsInput: unknown source '%s'", di.conf.Source)
+	}
+
 	m, err := NewStatsManager(di.conf.Endpoint, di.conf.CertPath, di.attachErrors,
 		di.conf.NameFromEnv, di.conf.FieldsFromEnv, di.conf.FieldsFromLabels)
 	if err != nil {
@@ -70,12 +101,12 @@ func (di *DockerStatsInput) Init(config interface{}) error {
 }
 
 func (di *DockerStatsInput) Run(ir pipeline.InputRunner, h pipeline.PluginHelper) error {
-	var (
-		ok   bool
-		pack *pipeline.PipelinePack
-	)
-	di.statsMgr.ir = ir
+	var ok bool
 	hostname := h.Hostname()
+	if di.cgroups != nil {
+		return di.runCgroups(ir, hostname)
+	}
+	di.statsMgr.ir = ir
 
 	go di.statsMgr.Run(di.statsstream, di.closer, di.stopChan)
 
@@ -89,27 +120,7 @@ func (di *DockerStatsInput) Run(ir pipeline.InputRunner, h pipeline.PluginHelper
 	for ok {
 		select {
 		case statsline := <-di.statsstream:
-			pack = <-packSupply
-
-			pack.Message.SetType("DockerStats")
-			pack.Message.SetLogger(statsline.Container)
-			pack.Message.SetHostname(hostname) // Use the host's hosntame
-			pack.Message.SetPayload(statsline.StatsString)
-			pack.Message.SetTimestamp(statsline.Time.UnixNano())
-			pack.Message.SetUuid(uuid.NewRandom())
-
-			for name, value := range statsline.Fields {
-				field, err := message.NewField(name, value, "")
-				if err != nil {
-					ir.LogError(
-						fmt.Errorf("can't add '%s' field: %s", name, err.Error()),
-					)
-					continue
-				}
-
-				pack.Message.AddField(field)
-			}
-			ir.Deliver(pack)
+			di.deliver(ir, <-packSupply, hostname, statsline)
 
 		case err, ok = <-di.attachErrors:
 			if !ok {
@@ -125,6 +136,61 @@ func (di *DockerStatsInput) Run(ir pipeline.InputRunner, h pipeline.PluginHelper
 	return nil
 }
 
+// Reads the stats from the cgroup hierarchy at every tick, until stopped.
+func (di *DockerStatsInput) runCgroups(ir pipeline.InputRunner, hostname string) error {
+	packSupply := ir.InChan()
+	ticker := ir.Ticker()
+	for {
+		select {
+		case <-ticker:
+			stats, errs := di.cgroups.ReadAll()
+			for _, err := range errs {
+				ir.LogError(err)
+			}
+			for _, statsline := range stats {
+				di.deliver(ir, <-packSupply, hostname, statsline)
+			}
+		case <-di.stopChan:
+			return nil
+		}
+	}
+}
+
+func (di *DockerStatsInput) deliver(ir pipeline.InputRunner, pack *pipeline.PipelinePack,
+	hostname string, statsline *DockerStat) {
+
+	pack.Message.SetType("DockerStats")
+	pack.Message.SetLogger(statsline.Container)
+	pack.Message.SetHostname(hostname) // Use the host's hosntame
+	pack.Message.SetPayload(statsline.StatsString)
+	pack.Message.SetTimestamp(statsline.Time.UnixNano())
+	pack.Message.SetUuid(uuid.NewRandom())
+
+	for name, value := range statsline.Fields {
+		field, err := message.NewField(name, value, "")
+		if err != nil {
+			ir.LogError(
+				fmt.Errorf("can't add '%s' field: %s", name, err.Error()),
+			)
+			continue
+		}
+
+		pack.Message.AddField(field)
+	}
+	for _, value := range statsline.Values {
+		field, err := message.NewField(value.Name, value.Value, value.Representation)
+		if err != nil {
+			ir.LogError(
+				fmt.Errorf("can't add '%s' field: %s", value.Name, err.Error()),
+			)
+			continue
+		}
+
+		pack.Message.AddField(field)
+	}
+	ir.Deliver(pack)
+}
+
 func (di *DockerStatsInput) CleanupForRestart() {
 	// Intentionally left empty. Cleanup happens in Run()
 }
@@ -0,0 +1,102 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Passes a container's log output on one newline terminated line per Write.
+// Docker splits lines longer than 16KB into several log messages, which
+// without timestamps arrive one after the other and already make up the
+// whole line for the splitter. What the lineJoiner adds is that lines longer
+// than maxSize are passed on in pieces of maxSize bytes instead of
+// overflowing the splitter's buffer, and that Flush ends an unfinished line,
+// which the splitter would otherwise drop when the stream ends.
+type lineJoiner struct {
+	lock    sync.Mutex
+	w       io.Writer
+	line    []byte
+	maxSize int
+}
+
+func newLineJoiner(w io.Writer, maxSize int) *lineJoiner {
+	return &lineJoiner{
+		w:       w,
+		maxSize: maxSize,
+	}
+}
+
+func (j *lineJoiner) Write(p []byte) (n int, err error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			j.hold(p)
+			return n + len(p), nil
+		}
+		if len(j.line) == 0 && i <= j.maxSize {
+			// A complete line, no need to copy it.
+			_, err = j.w.Write(p[:i+1])
+		} else {
+			j.hold(p[:i])
+			err = j.writeLine()
+		}
+		if err != nil {
+			return n, err
+		}
+		n += i + 1
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Adds a chunk of a line, passing on the line in pieces as it grows past
+// maxSize.
+func (j *lineJoiner) hold(chunk []byte) {
+	for len(chunk) > 0 {
+		room := j.maxSize - len(j.line)
+		if len(chunk) <= room {
+			j.line = append(j.line, chunk...)
+			return
+		}
+		j.line = append(j.line, chunk[:room]...)
+		chunk = chunk[room:]
+		// Errors will show up again with the next Write.
+		j.writeLine()
+	}
+}
+
+func (j *lineJoiner) writeLine() error {
+	j.line = append(j.line, '\n')
+	_, err := j.w.Write(j.line)
+	j.line = j.line[:0]
+	return err
+}
+
+// Passes on the chunks of an unfinished line as a line of their own, e.g.
+// when the container stopped before writing the rest of it.
+func (j *lineJoiner) Flush() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if len(j.line) == 0 {
+		return nil
+	}
+	return j.writeLine()
+}
@@ -0,0 +1,89 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"errors"
+	"strings"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+// Keeps each Write it gets as a string of its own.
+type writeRecorder struct {
+	writes []string
+	err    error
+}
+
+func (r *writeRecorder) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+func LineJoinerSpec(c gs.Context) {
+	c.Specify("A lineJoiner", func() {
+		recorder := new(writeRecorder)
+		joiner := newLineJoiner(recorder, 8)
+
+		// The writes in order, separated by '|'.
+		written := func() string {
+			return strings.Join(recorder.writes, "|")
+		}
+		write := func(data string) {
+			n, err := joiner.Write([]byte(data))
+			c.Expect(err, gs.IsNil)
+			c.Expect(n, gs.Equals, len(data))
+		}
+
+		c.Specify("passes on complete lines one per write", func() {
+			write("one\ntwo\n")
+			c.Expect(written(), gs.Equals, "one\n|two\n")
+		})
+
+		c.Specify("joins the chunks of a line", func() {
+			write("on")
+			write("e")
+			c.Expect(len(recorder.writes), gs.Equals, 0)
+			write("\ntw")
+			write("o\n")
+			c.Expect(written(), gs.Equals, "one\n|two\n")
+		})
+
+		c.Specify("cuts lines longer than the max size", func() {
+			write("0123456789abcdef")
+			write("ghi\n")
+			c.Expect(written(), gs.Equals, "01234567\n|89abcdef\n|ghi\n")
+		})
+
+		c.Specify("ends an unfinished line when flushed", func() {
+			c.Expect(joiner.Flush(), gs.IsNil)
+			c.Expect(len(recorder.writes), gs.Equals, 0)
+			write("last words")
+			c.Expect(joiner.Flush(), gs.IsNil)
+			c.Expect(written(), gs.Equals, "last wor\n|ds\n")
+			c.Expect(joiner.Flush(), gs.IsNil)
+			c.Expect(len(recorder.writes), gs.Equals, 2)
+		})
+
+		c.Specify("returns write errors", func() {
+			recorder.err = errors.New("closed pipe")
+			_, err := joiner.Write([]byte("one\n"))
+			c.Expect(err, gs.Equals, recorder.err)
+		})
+	})
+}
@@ -0,0 +1,298 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reads container stats straight from the cgroup v2 hierarchy, for hosts on
+// which no Docker daemon can be reached. Containers are the cgroups matching
+// the globs, relative to the hierarchy's root.
+type cgroupStatsReader struct {
+	root     string
+	globs    []string
+	procRoot string
+	rates    map[string]*rateCalculator
+}
+
+func newCgroupStatsReader(root string, globs []string,
+	procRoot string) (*cgroupStatsReader, error) {
+
+	// Only the unified hierarchy has this file in its root.
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 hierarchy: %s", root, err)
+	}
+	for _, glob := range globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid cgroup glob '%s': %s", glob, err)
+		}
+	}
+	return &cgroupStatsReader{
+		root:     root,
+		globs:    globs,
+		procRoot: procRoot,
+		rates:    make(map[string]*rateCalculator),
+	}, nil
+}
+
+// Returns the container ID for a cgroup directory, e.g. "docker-<id>.scope"
+// with the systemd cgroup driver or just "<id>" with the cgroupfs one.
+func cgroupContainerID(dir string) string {
+	id := strings.TrimSuffix(filepath.Base(dir), ".scope")
+	if i := strings.LastIndex(id, "-"); i >= 0 {
+		id = id[i+1:]
+	}
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+// Reads the stats of all containers. Errors reading a container's stats
+// don't keep the other containers from being read.
+func (c *cgroupStatsReader) ReadAll() (stats []*DockerStat, errs []error) {
+	var dirs []string
+	for _, glob := range c.globs {
+		matches, _ := filepath.Glob(filepath.Join(c.root, glob))
+		dirs = append(dirs, matches...)
+	}
+	sort.Strings(dirs)
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		id := cgroupContainerID(dir)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		calc, ok := c.rates[id]
+		if !ok {
+			calc = new(rateCalculator)
+			c.rates[id] = calc
+		}
+		sample, err := c.readSample(dir, calc.last)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading cgroup %s: %s", dir, err))
+			continue
+		}
+		payload, _ := json.Marshal(sample)
+		stats = append(stats, &DockerStat{
+			Container:   id,
+			Time:        sample.Time,
+			StatsString: string(payload),
+			Fields:      map[string]string{"ContainerID": id, "ContainerName": id},
+			Values:      calc.Values(sample),
+		})
+	}
+
+	// Forget the containers that are gone.
+	for id := range c.rates {
+		if !seen[id] {
+			delete(c.rates, id)
+		}
+	}
+	return stats, errs
+}
+
+// Reads a container's counters from the files of its cgroup. Files of
+// controllers that aren't enabled for the cgroup are skipped. The network
+// counters of the last sample are kept when there's no process to read them
+// from.
+func (c *cgroupStatsReader) readSample(dir string, last *statsSample) (*statsSample,
+	error) {
+
+	s := &statsSample{Time: time.Now()}
+
+	cpu, err := readKeyedFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	s.CPUUsage = cpu["usage_usec"] * 1000
+	s.Periods = cpu["nr_periods"]
+	s.ThrottledPeriods = cpu["nr_throttled"]
+	s.ThrottledTime = cpu["throttled_usec"] * 1000
+
+	if s.MemoryUsage, err = readValueFile(filepath.Join(dir, "memory.current")); err != nil {
+		return nil, err
+	}
+	// Unlimited cgroups have "max" here, which reads as zero.
+	if s.MemoryLimit, err = readValueFile(filepath.Join(dir, "memory.max")); err != nil {
+		return nil, err
+	}
+	memory, err := readKeyedFile(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	s.MemoryInactiveFile = memory["inactive_file"]
+
+	if s.BlkReadBytes, s.BlkWriteBytes, err = readIOStat(filepath.Join(dir, "io.stat")); err != nil {
+		return nil, err
+	}
+
+	// Network interfaces belong to namespaces rather than cgroups, so the
+	// counters are read from the network namespace of one of the
+	// container's processes.
+	pid, err := firstPid(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	netRead := false
+	if pid != "" {
+		devPath := filepath.Join(c.procRoot, pid, "net", "dev")
+		s.NetRxBytes, s.NetTxBytes, err = readNetDev(devPath)
+		if err == nil {
+			netRead = true
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	// Zero counters would make the whole traffic so far show up as the
+	// next rate.
+	if !netRead {
+		if last != nil && !last.NoNetwork {
+			s.NetRxBytes, s.NetTxBytes = last.NetRxBytes, last.NetTxBytes
+		} else {
+			s.NoNetwork = true
+		}
+	}
+	return s, nil
+}
+
+// Reads a file with a single value, treating a missing file as zero.
+func readValueFile(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// Reads a file of "key value" lines, treating a missing file as empty.
+func readKeyedFile(path string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			values[parts[0]] = value
+		}
+	}
+	return values, scanner.Err()
+}
+
+// Sums the bytes read and written over all devices in io.stat, which has a
+// line of "key=value" pairs per device, e.g.
+// "8:0 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0".
+func readIOStat(path string) (read, written uint64, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, pair := range strings.Fields(line) {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch parts[0] {
+			case "rbytes":
+				read += value
+			case "wbytes":
+				written += value
+			}
+		}
+	}
+	return read, written, nil
+}
+
+// Returns the first process in the cgroup, or an empty string if there's
+// none.
+func firstPid(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// Sums the bytes received and sent over all interfaces but the loopback one
+// in a /proc/<pid>/net/dev file.
+func readNetDev(path string) (rx, tx uint64, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || strings.TrimSpace(line[:i]) == "lo" {
+			continue
+		}
+		// Eight receive columns followed by eight transmit ones.
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 16 {
+			continue
+		}
+		r, err1 := strconv.ParseUint(fields[0], 10, 64)
+		t, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rx += r
+		tx += t
+	}
+	return rx, tx, nil
+}
@@ -0,0 +1,131 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func CgroupStatsSpec(c gs.Context) {
+	root := filepath.Join("testsupport", "cgroup")
+	procRoot := filepath.Join("testsupport", "proc")
+	scope := filepath.Join(root, "system.slice", "docker-0123456789abcdef0123.scope")
+
+	c.Specify("Container IDs are taken from cgroup directory names", func() {
+		ids := map[string]string{
+			"docker-0123456789abcdef0123.scope":          "0123456789ab",
+			"/sys/fs/cgroup/docker/0123456789abcdef0123": "0123456789ab",
+			"cri-containerd-fedcba9876543210.scope":      "fedcba987654",
+			"abcdef":                                     "abcdef",
+		}
+		for dir, id := range ids {
+			c.Expect(cgroupContainerID(dir), gs.Equals, id)
+		}
+	})
+
+	c.Specify("Keyed files", func() {
+		c.Specify("are read into a map", func() {
+			values, err := readKeyedFile(filepath.Join(scope, "cpu.stat"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(values), gs.Equals, 6)
+			c.Expect(values["usage_usec"], gs.Equals, uint64(2500000))
+			c.Expect(values["nr_throttled"], gs.Equals, uint64(25))
+		})
+
+		c.Specify("are empty when missing", func() {
+			values, err := readKeyedFile(filepath.Join(scope, "missing.stat"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(values), gs.Equals, 0)
+		})
+	})
+
+	c.Specify("io.stat is summed over all devices", func() {
+		read, written, err := readIOStat(filepath.Join(scope, "io.stat"))
+		c.Expect(err, gs.IsNil)
+		c.Expect(read, gs.Equals, uint64(1049600))
+		c.Expect(written, gs.Equals, uint64(6144))
+	})
+
+	c.Specify("net/dev is summed over all interfaces but loopback", func() {
+		rx, tx, err := readNetDev(filepath.Join(procRoot, "4242", "net", "dev"))
+		c.Expect(err, gs.IsNil)
+		c.Expect(rx, gs.Equals, uint64(2000))
+		c.Expect(tx, gs.Equals, uint64(1000))
+	})
+
+	c.Specify("A cgroupStatsReader", func() {
+		reader, err := newCgroupStatsReader(root, []string{"system.slice/docker-*.scope"},
+			procRoot)
+		c.Expect(err, gs.IsNil)
+
+		c.Specify("requires a cgroup v2 hierarchy", func() {
+			_, err := newCgroupStatsReader(procRoot, nil, procRoot)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("reads the stats of matching containers", func() {
+			stats, errs := reader.ReadAll()
+			c.Expect(len(errs), gs.Equals, 0)
+			c.Expect(len(stats), gs.Equals, 1)
+			stat := stats[0]
+			c.Expect(stat.Container, gs.Equals, "0123456789ab")
+			c.Expect(stat.Fields["ContainerID"], gs.Equals, "0123456789ab")
+			c.Expect(strings.Contains(stat.StatsString, `"cpu_usage":2500000000`), gs.IsTrue)
+			c.Expect(strings.Contains(stat.StatsString, `"net_rx_bytes":2000`), gs.IsTrue)
+
+			values := valuesByName(stat.Values)
+			c.Expect(len(values), gs.Equals, 2)
+			c.Expect(values["MemoryUsage"], gs.Equals, float64(104857600))
+			c.Expect(values["MemoryWorkingSet"], gs.Equals, float64(62914560))
+		})
+
+		c.Specify("without a readable process", func() {
+			dir, err := ioutil.TempDir("", "cgroup")
+			c.Expect(err, gs.IsNil)
+			defer os.RemoveAll(dir)
+			procs := filepath.Join(dir, "cgroup.procs")
+			last := &statsSample{NetRxBytes: 100, NetTxBytes: 50}
+
+			check := func() {
+				sample, err := reader.readSample(dir, last)
+				c.Expect(err, gs.IsNil)
+				c.Expect(sample.NoNetwork, gs.IsFalse)
+				c.Expect(sample.NetRxBytes, gs.Equals, uint64(100))
+				c.Expect(sample.NetTxBytes, gs.Equals, uint64(50))
+
+				sample, err = reader.readSample(dir, nil)
+				c.Expect(err, gs.IsNil)
+				c.Expect(sample.NoNetwork, gs.IsTrue)
+			}
+
+			c.Specify("keeps the network counters of an empty cgroup", func() {
+				err = ioutil.WriteFile(procs, nil, 0644)
+				c.Expect(err, gs.IsNil)
+				check()
+			})
+
+			c.Specify("keeps the network counters after the process exited", func() {
+				err = ioutil.WriteFile(procs, []byte("999999\n"), 0644)
+				c.Expect(err, gs.IsNil)
+				check()
+			})
+		})
+	})
+}
@@ -46,6 +46,7 @@ type DockerStat struct {
 	Time        time.Time
 	StatsString string
 	Fields      map[string]string
+	Values      []statValue
 }
 
 type StatsAttachEvent struct {
@@ -323,6 +324,7 @@ func NewStatsPump(statsChan chan *docker.Stats, name string, fields map[string]s
 	// Does the work of actually pumping out Stats structs coming in
 	// from the channel
 	pump := func(sourceChan chan *docker.Stats, fields map[string]string) {
+		rates := new(rateCalculator)
 		for {
 			source, ok := <-sourceChan
 			if !ok{
@@ -335,6 +337,7 @@ func NewStatsPump(statsChan chan *docker.Stats, name string, fields map[string]s
 				Time:        source.Read,
 				StatsString: string(json_ver),
 				Fields:      fields,
+				Values:      rates.Values(sampleFromDocker(source)),
 			})
 		}
 	}
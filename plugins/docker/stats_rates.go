@@ -0,0 +1,158 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// The counters and gauges of a container the derived stats are computed
+// from, independent of where they were read. Times are in nanoseconds, sizes
+// in bytes.
+type statsSample struct {
+	Time               time.Time `json:"time"`
+	CPUUsage           uint64    `json:"cpu_usage"`
+	Periods            uint64    `json:"periods"`
+	ThrottledPeriods   uint64    `json:"throttled_periods"`
+	ThrottledTime      uint64    `json:"throttled_time"`
+	MemoryUsage        uint64    `json:"memory_usage"`
+	MemoryInactiveFile uint64    `json:"memory_inactive_file"`
+	MemoryLimit        uint64    `json:"memory_limit"`
+	NetRxBytes         uint64    `json:"net_rx_bytes"`
+	NetTxBytes         uint64    `json:"net_tx_bytes"`
+	BlkReadBytes       uint64    `json:"blk_read_bytes"`
+	BlkWriteBytes      uint64    `json:"blk_write_bytes"`
+	// Set when the network counters couldn't be read.
+	NoNetwork bool `json:"-"`
+}
+
+// A derived stat, added to the message as a field.
+type statValue struct {
+	Name           string
+	Value          float64
+	Representation string
+}
+
+func sampleFromDocker(stats *docker.Stats) *statsSample {
+	s := &statsSample{
+		Time:             stats.Read,
+		CPUUsage:         stats.CPUStats.CPUUsage.TotalUsage,
+		Periods:          stats.CPUStats.ThrottlingData.Periods,
+		ThrottledPeriods: stats.CPUStats.ThrottlingData.ThrottledPeriods,
+		ThrottledTime:    stats.CPUStats.ThrottlingData.ThrottledTime,
+		MemoryUsage:      stats.MemoryStats.Usage,
+		MemoryLimit:      stats.MemoryStats.Limit,
+	}
+	// cgroup v1 reports the hierarchical total, cgroup v2 only has the
+	// container's own value.
+	s.MemoryInactiveFile = stats.MemoryStats.Stats.TotalInactiveFile
+	if s.MemoryInactiveFile == 0 {
+		s.MemoryInactiveFile = stats.MemoryStats.Stats.InactiveFile
+	}
+
+	if len(stats.Networks) > 0 {
+		for _, network := range stats.Networks {
+			s.NetRxBytes += network.RxBytes
+			s.NetTxBytes += network.TxBytes
+		}
+	} else {
+		s.NetRxBytes = stats.Network.RxBytes
+		s.NetTxBytes = stats.Network.TxBytes
+	}
+
+	// The operations are capitalized on cgroup v1 only.
+	for _, entry := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			s.BlkReadBytes += entry.Value
+		case "write":
+			s.BlkWriteBytes += entry.Value
+		}
+	}
+	return s
+}
+
+// Computes the derived stats of a container from consecutive samples.
+type rateCalculator struct {
+	last *statsSample
+}
+
+// Returns the stats derived from the sample. Rates need a previous sample, so
+// the first call only returns the memory gauges. A counter that went down
+// was reset, e.g. because the container restarted, and is counted from zero.
+func (r *rateCalculator) Values(s *statsSample) []statValue {
+	values := []statValue{
+		{"MemoryUsage", float64(s.MemoryUsage), "B"},
+		{"MemoryWorkingSet", float64(workingSet(s)), "B"},
+	}
+	if s.MemoryLimit > 0 {
+		values = append(values, statValue{"MemoryLimit", float64(s.MemoryLimit), "B"})
+	}
+
+	last := r.last
+	r.last = s
+	if last == nil {
+		return values
+	}
+	elapsed := s.Time.Sub(last.Time).Seconds()
+	if elapsed <= 0 {
+		return values
+	}
+
+	perSecond := func(name string, cur, prev uint64) statValue {
+		return statValue{name, float64(delta(cur, prev)) / elapsed, "B/s"}
+	}
+	values = append(values,
+		statValue{"CPUPercent",
+			float64(delta(s.CPUUsage, last.CPUUsage)) / 1e9 / elapsed * 100, "%"},
+		statValue{"ThrottledTime",
+			float64(delta(s.ThrottledTime, last.ThrottledTime)) / 1e9, "s"},
+	)
+	if !s.NoNetwork && !last.NoNetwork {
+		values = append(values,
+			perSecond("NetRxBytesPerSec", s.NetRxBytes, last.NetRxBytes),
+			perSecond("NetTxBytesPerSec", s.NetTxBytes, last.NetTxBytes),
+		)
+	}
+	values = append(values,
+		perSecond("BlkReadBytesPerSec", s.BlkReadBytes, last.BlkReadBytes),
+		perSecond("BlkWriteBytesPerSec", s.BlkWriteBytes, last.BlkWriteBytes),
+	)
+	if periods := delta(s.Periods, last.Periods); periods > 0 {
+		throttled := delta(s.ThrottledPeriods, last.ThrottledPeriods)
+		values = append(values, statValue{"ThrottledPercent",
+			float64(throttled) / float64(periods) * 100, "%"})
+	}
+	return values
+}
+
+// Memory in use that can't be reclaimed easily, i.e. without the inactive
+// page cache. This is what the kernel looks at before OOM-killing.
+func workingSet(s *statsSample) uint64 {
+	if s.MemoryInactiveFile > s.MemoryUsage {
+		return 0
+	}
+	return s.MemoryUsage - s.MemoryInactiveFile
+}
+
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
@@ -0,0 +1,162 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package docker
+
+import (
+	"time"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+// Turns stat values into a map, for comparing them regardless of order.
+func valuesByName(values []statValue) map[string]float64 {
+	byName := make(map[string]float64, len(values))
+	for _, value := range values {
+		byName[value.Name] = value.Value
+	}
+	return byName
+}
+
+func RateCalculatorSpec(c gs.Context) {
+	t0 := time.Unix(1462104000, 0)
+	first := statsSample{
+		Time:               t0,
+		CPUUsage:           1e9,
+		Periods:            10,
+		ThrottledPeriods:   1,
+		ThrottledTime:      1e8,
+		MemoryUsage:        1000,
+		MemoryInactiveFile: 300,
+		MemoryLimit:        4000,
+		NetRxBytes:         1000,
+		NetTxBytes:         500,
+		BlkReadBytes:       2000,
+		BlkWriteBytes:      100,
+	}
+	gauges := map[string]float64{
+		"MemoryUsage":      1000,
+		"MemoryWorkingSet": 700,
+		"MemoryLimit":      4000,
+	}
+	// Adds the rates to the gauges.
+	withRates := func(rates map[string]float64) map[string]float64 {
+		values := make(map[string]float64)
+		for name, value := range gauges {
+			values[name] = value
+		}
+		for name, value := range rates {
+			values[name] = value
+		}
+		return values
+	}
+
+	cases := []struct {
+		name     string
+		next     func(s *statsSample)
+		expected map[string]float64
+	}{
+		{
+			name:     "only has gauges for the first sample",
+			expected: gauges,
+		},
+		{
+			name: "computes rates between samples",
+			next: func(s *statsSample) {
+				s.Time = t0.Add(2 * time.Second)
+				s.CPUUsage = 2e9
+				s.Periods, s.ThrottledPeriods, s.ThrottledTime = 20, 6, 6e8
+				s.NetRxBytes, s.NetTxBytes = 3000, 900
+				s.BlkWriteBytes = 4100
+			},
+			expected: withRates(map[string]float64{
+				"CPUPercent":          50,
+				"ThrottledTime":       0.5,
+				"ThrottledPercent":    50,
+				"NetRxBytesPerSec":    1000,
+				"NetTxBytesPerSec":    200,
+				"BlkReadBytesPerSec":  0,
+				"BlkWriteBytesPerSec": 2000,
+			}),
+		},
+		{
+			name: "counts reset counters from zero",
+			next: func(s *statsSample) {
+				s.Time = t0.Add(2 * time.Second)
+				s.CPUUsage = 5e8
+				s.Periods, s.ThrottledPeriods, s.ThrottledTime = 4, 2, 0
+				s.NetRxBytes = 400
+			},
+			expected: withRates(map[string]float64{
+				"CPUPercent":          25,
+				"ThrottledTime":       0,
+				"ThrottledPercent":    25,
+				"NetRxBytesPerSec":    200,
+				"NetTxBytesPerSec":    0,
+				"BlkReadBytesPerSec":  0,
+				"BlkWriteBytesPerSec": 0,
+			}),
+		},
+		{
+			name: "has no rates when no time elapsed",
+			next: func(s *statsSample) {
+				s.CPUUsage = 2e9
+			},
+			expected: gauges,
+		},
+		{
+			name: "leaves out network rates without network counters",
+			next: func(s *statsSample) {
+				s.Time = t0.Add(time.Second)
+				s.NetRxBytes, s.NetTxBytes, s.NoNetwork = 0, 0, true
+			},
+			expected: withRates(map[string]float64{
+				"CPUPercent":          0,
+				"ThrottledTime":       0,
+				"BlkReadBytesPerSec":  0,
+				"BlkWriteBytesPerSec": 0,
+			}),
+		},
+	}
+
+	c.Specify("A rateCalculator", func() {
+		for _, tc := range cases {
+			tc := tc
+			c.Specify(tc.name, func() {
+				calc := new(rateCalculator)
+				sample := first
+				values := calc.Values(&sample)
+				if tc.next != nil {
+					next := first
+					tc.next(&next)
+					values = calc.Values(&next)
+				}
+				byName := valuesByName(values)
+				c.Expect(len(byName), gs.Equals, len(tc.expected))
+				for name, value := range tc.expected {
+					actual, ok := byName[name]
+					c.Expect(ok, gs.IsTrue)
+					c.Expect(actual, gs.Equals, value)
+				}
+			})
+		}
+
+		c.Specify("leaves out the memory limit if there's none", func() {
+			sample := first
+			sample.MemoryLimit = 0
+			_, ok := valuesByName(new(rateCalculator).Values(&sample))["MemoryLimit"]
+			c.Expect(ok, gs.IsFalse)
+		})
+	})
+}
@@ -91,6 +91,11 @@ func extractFields(id string, client DockerClient, fieldsFromLabels []string, fi
 	if err != nil {
 		return nil, err
 	}
+	return containerFields(id, container, fieldsFromLabels, fieldsFromEnv, nameFromEnv), nil
+}
+
+// Extract the env vars/labels we were told to keep from an inspected container
+func containerFields(id string, container *docker.Container, fieldsFromLabels []string, fieldsFromEnv []string, nameFromEnv string) map[string]string {
 	name := container.Name[1:] // Strip the leading slas
 	image := container.Config.Image
 
@@ -112,7 +117,7 @@ func extractFields(id string, client DockerClient, fieldsFromLabels []string, fi
 		}
 	}
 
-	return fields, nil
+	return fields
 }
 
 // Process the env vars and capture the ones we want
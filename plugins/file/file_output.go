@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cactus/gostrftime"
@@ -31,29 +32,85 @@ import (
 )
 
 type outBatch struct {
-	data   []byte
+	data []byte
+	// Used instead of `data` when the output path is interpolated from
+	// message data, keyed by the interpolated path.
+	byPath map[string][]byte
 	cursor string
 }
 
 func newOutBatch() *outBatch {
 	return &outBatch{
-		data: make([]byte, 0, 10000),
+		data:   make([]byte, 0, 10000),
+		byPath: make(map[string][]byte),
 	}
 }
 
+// Returns the total number of bytes held by the batch.
+func (b *outBatch) size() int {
+	n := len(b.data)
+	for _, data := range b.byPath {
+		n += len(data)
+	}
+	return n
+}
+
+// Empties the batch for reuse. Per-path buffers that weren't used in the
+// last batch are dropped so they don't accumulate forever.
+func (b *outBatch) reset() {
+	b.data = b.data[:0]
+	for key, data := range b.byPath {
+		if len(data) == 0 {
+			delete(b.byPath, key)
+		} else {
+			b.byPath[key] = data[:0]
+		}
+	}
+}
+
+// Used to neutralize message data before it's interpolated into an output
+// path, so it can't escape the configured directory or inject strftime
+// directives.
+var pathValueEscaper = strings.NewReplacer("/", "_", "\\", "_", "%", "_", "..", "_")
+
+// Supported `fsync` modes.
+const (
+	fsyncNone = iota
+	fsyncBatch
+	fsyncInterval
+)
+
 // Output plugin that writes message contents to a file on the file system.
 type FileOutput struct {
 	*FileOutputConfig
-	path       string
-	perm       os.FileMode
-	flushOpAnd bool
-	file       *os.File
-	batchChan  chan *outBatch
-	backChan   chan *outBatch
-	folderPerm os.FileMode
-	timerChan  <-chan time.Time
-	rotateChan chan time.Time
-	closing    chan struct{}
+	path        string
+	perm        os.FileMode
+	flushOpAnd  bool
+	file        *os.File
+	fileSize    int64
+	fileDirty   bool
+	batchChan   chan *outBatch
+	backChan    chan *outBatch
+	folderPerm  os.FileMode
+	timerChan   <-chan time.Time
+	rotateChan  chan time.Time
+	rotateTime  time.Time
+	closing     chan struct{}
+	maxAge      time.Duration
+	templated   bool
+	files       *fileCache
+	rotatedChan chan rotatedFile
+	fsyncMode   int
+	fsyncChan   <-chan time.Time
+	// Cursor of the most recently written batch that hasn't yet been
+	// fsynced, when fsyncing by interval.
+	pendingCursor string
+	// Data for interpolated paths that couldn't be written, keyed by path.
+	// It's retried with every batch, and the cursor isn't advanced while
+	// there is any. Each path's data is capped at `max_held_size`.
+	failed map[string][]byte
+	// Cursor of the most recent batch held back because of failed data.
+	failedCursor string
 }
 
 // ConfigStruct for FileOutput plugin.
@@ -62,6 +119,9 @@ type FileOutputConfig struct {
 	// If date rotation is in use, then the output file name can support
 	// Go's time.Format syntax to embed timestamps in the filename:
 	// http://golang.org/pkg/time/#Time.Format
+	// The path may also contain `%{name}` references to message header or
+	// field values, in which case one output file is used per distinct
+	// interpolated path.
 	Path string
 
 	// Output file permissions (default "644").
@@ -73,6 +133,44 @@ type FileOutputConfig struct {
 	// (default 0, i.e. disabled). Set to 0 to disable.
 	RotationInterval uint32 `toml:"rotation_interval"`
 
+	// Size in bytes at which the output file will be rotated, i.e. renamed
+	// with a timestamp suffix and replaced with a new, empty file (default 0,
+	// i.e. disabled).
+	MaxFileSize uint64 `toml:"max_file_size"`
+
+	// Maximum number of rotated files to keep for each output path, older
+	// ones are deleted (default 0, i.e. keep all).
+	MaxFiles uint32 `toml:"max_files"`
+
+	// Maximum age of rotated files to keep, as a duration string (e.g.
+	// "168h"), older ones are deleted (default "", i.e. keep all).
+	MaxAge string `toml:"max_age"`
+
+	// Whether or not rotated files should be gzip compressed once they've
+	// been closed (default false).
+	CompressRotated bool `toml:"compress_rotated"`
+
+	// Maximum number of files that will be held open at once when the path
+	// is interpolated from message data (default 32).
+	MaxOpenFiles uint32 `toml:"max_open_files"`
+
+	// Maximum number of bytes held for retrying for each interpolated path
+	// that can't be written to. Once it would be exceeded the held data for
+	// that path is dropped, so the cursor can advance again (default
+	// 16777216, i.e. 16MiB). Set to 0 to never drop held data.
+	MaxHeldSize uint64 `toml:"max_held_size"`
+
+	// Specifies when written data is flushed to stable storage using fsync,
+	// which is also when the queue cursor is advanced. Allowed values are
+	// "batch" (after every batch is written), "interval" (every
+	// `fsync_interval` milliseconds) and "none" (leave it up to the OS, the
+	// cursor is advanced as soon as a batch is written). Default is "batch".
+	Fsync string `toml:"fsync"`
+
+	// Interval at which written data is fsynced when `fsync` is set to
+	// "interval", in milliseconds (default 1000).
+	FsyncInterval uint32 `toml:"fsync_interval"`
+
 	// Interval at which accumulated file data should be written to disk, in
 	// milliseconds (default 1000, i.e. 1 second). Set to 0 to disable.
 	FlushInterval uint32 `toml:"flush_interval"`
@@ -107,6 +205,10 @@ func (o *FileOutput) ConfigStruct() interface{} {
 	return &FileOutputConfig{
 		Perm:             "644",
 		RotationInterval: 0,
+		MaxOpenFiles:     32,
+		MaxHeldSize:      16 * 1024 * 1024,
+		Fsync:            "batch",
+		FsyncInterval:    1000,
 		FlushInterval:    1000,
 		FlushCount:       1,
 		FlushOperator:    "AND",
@@ -149,6 +251,40 @@ func (o *FileOutput) Init(config interface{}) (err error) {
 		return err
 	}
 
+	switch conf.Fsync {
+	case "none":
+		o.fsyncMode = fsyncNone
+	case "batch":
+		o.fsyncMode = fsyncBatch
+	case "interval":
+		if conf.FsyncInterval == 0 {
+			err = errors.New("Parameter 'fsync_interval' needs to be greater than 0.")
+			return err
+		}
+		o.fsyncMode = fsyncInterval
+	default:
+		err = fmt.Errorf("Parameter 'fsync' needs to be one of 'batch', 'interval' or 'none', is currently: '%s'",
+			conf.Fsync)
+		return err
+	}
+
+	if conf.MaxAge != "" {
+		if o.maxAge, err = time.ParseDuration(conf.MaxAge); err != nil {
+			err = fmt.Errorf("FileOutput '%s' can't parse `max_age`: %s", o.Path, err)
+			return err
+		}
+	}
+
+	o.templated = plugins.IsMessageTemplate(conf.Path)
+	if o.templated {
+		if conf.MaxOpenFiles < 1 {
+			err = errors.New("Parameter 'max_open_files' needs to be at least 1.")
+			return err
+		}
+		o.files = newFileCache(int(conf.MaxOpenFiles))
+		o.failed = make(map[string][]byte)
+	}
+
 	o.closing = make(chan struct{})
 	switch conf.RotationInterval {
 	case 0:
@@ -161,15 +297,18 @@ func (o *FileOutput) Init(config interface{}) (err error) {
 		err = fmt.Errorf("Parameter 'rotation_interval' must be one of: 0, 1, 4, 12, 24.")
 		return err
 	}
-	if err = o.openFile(); err != nil {
-		err = fmt.Errorf("FileOutput '%s' error opening file: %s", o.path, err)
-		close(o.closing)
-		return err
+	if !o.templated {
+		if err = o.openFile(); err != nil {
+			err = fmt.Errorf("FileOutput '%s' error opening file: %s", o.path, err)
+			close(o.closing)
+			return err
+		}
 	}
 
 	o.batchChan = make(chan *outBatch)
 	o.backChan = make(chan *outBatch, 2) // Never block on the hand-back
 	o.rotateChan = make(chan time.Time)
+	o.rotatedChan = make(chan rotatedFile, 32)
 	return nil
 }
 
@@ -181,7 +320,8 @@ func (o *FileOutput) startRotateNotifier() {
 	until := next.Sub(now)
 	after := time.After(until)
 
-	o.path = gostrftime.Strftime(o.FileOutputConfig.Path, now)
+	o.rotateTime = now
+	o.path = o.resolvePath(o.FileOutputConfig.Path)
 
 	go func() {
 		ok := true
@@ -200,18 +340,172 @@ func (o *FileOutput) startRotateNotifier() {
 	}()
 }
 
-func (o *FileOutput) openFile() (err error) {
-	basePath := filepath.Dir(o.path)
+// Applies the current rotation time to the provided path template, if date
+// rotation is in use.
+func (o *FileOutput) resolvePath(tmpl string) string {
+	if o.RotationInterval == 0 {
+		return tmpl
+	}
+	return gostrftime.Strftime(tmpl, o.rotateTime)
+}
+
+// Creates (if necessary) and opens the file at the provided path for
+// appending, returning the file and its current size.
+func (o *FileOutput) createFile(path string) (file *os.File, size int64, err error) {
+	basePath := filepath.Dir(path)
 	if err = os.MkdirAll(basePath, o.folderPerm); err != nil {
-		return fmt.Errorf("Can't create the basepath for the FileOutput plugin: %s", err.Error())
+		err = fmt.Errorf("Can't create the basepath for the FileOutput plugin: %s", err.Error())
+		return
 	}
 	if err = plugins.CheckWritePermission(basePath); err != nil {
 		return
 	}
-	o.file, err = os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, o.perm)
+	if file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, o.perm); err != nil {
+		return
+	}
+	var fi os.FileInfo
+	if fi, err = file.Stat(); err != nil {
+		file.Close()
+		file = nil
+		return
+	}
+	size = fi.Size()
+	if size == 0 && o.fsyncMode != fsyncNone {
+		// Make sure a newly created file's directory entry is durable.
+		syncDir(basePath)
+	}
 	return
 }
 
+func (o *FileOutput) openFile() (err error) {
+	o.file, o.fileSize, err = o.createFile(o.path)
+	o.fileDirty = false
+	return
+}
+
+// Closes the current output file, first flushing it to stable storage if it
+// has unsynced writes.
+func (o *FileOutput) closeFile() {
+	if o.fileDirty {
+		o.file.Sync()
+		o.fileDirty = false
+	}
+	o.file.Close()
+}
+
+// Called after a batch has been written, either flushes the written data to
+// stable storage and advances the queue cursor or, if fsyncing by interval,
+// holds on to the cursor until the next fsync.
+func (o *FileOutput) commitCursor(or OutputRunner, cursor string) {
+	switch o.fsyncMode {
+	case fsyncNone:
+		or.UpdateCursor(cursor)
+	case fsyncBatch:
+		if err := o.syncFiles(); err != nil {
+			or.LogError(fmt.Errorf("can't fsync: %s", err))
+			return
+		}
+		or.UpdateCursor(cursor)
+	case fsyncInterval:
+		o.pendingCursor = cursor
+	}
+}
+
+// Flushes all open files to stable storage and advances the queue cursor to
+// that of the last written batch, if there is one.
+func (o *FileOutput) syncPending(or OutputRunner) {
+	if err := o.syncFiles(); err != nil {
+		or.LogError(fmt.Errorf("can't fsync: %s", err))
+		return
+	}
+	if o.pendingCursor != "" {
+		or.UpdateCursor(o.pendingCursor)
+		o.pendingCursor = ""
+	}
+}
+
+// Flushes any open files with unsynced writes to stable storage.
+func (o *FileOutput) syncFiles() error {
+	if o.templated {
+		return o.files.SyncAll()
+	}
+	if !o.fileDirty || o.file == nil {
+		return nil
+	}
+	o.fileDirty = false
+	return o.file.Sync()
+}
+
+// Returns the open file for the provided interpolated path template, opening
+// it if necessary.
+func (o *FileOutput) templatedFile(key string) (*outFile, error) {
+	if f, ok := o.files.Get(key); ok {
+		return f, nil
+	}
+	path := o.resolvePath(key)
+	file, size, err := o.createFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &outFile{key: key, path: path, file: file, size: size}
+	o.files.Add(key, f)
+	return f, nil
+}
+
+// Returns true if writing `n` more bytes to a file that's already `size`
+// bytes long should cause the file to be rotated first.
+func (o *FileOutput) needsSizeRotation(size int64, n int) bool {
+	return o.MaxFileSize > 0 && size > 0 && uint64(size)+uint64(n) > o.MaxFileSize
+}
+
+// Moves a closed, full file out of the way and hands it to the housekeeper.
+func (o *FileOutput) rotateClosedFile(path, tmpl string) error {
+	rotated := sizeRotatedPath(path, time.Now())
+	if err := os.Rename(path, rotated); err != nil {
+		return err
+	}
+	if o.fsyncMode != fsyncNone {
+		syncDir(filepath.Dir(path))
+	}
+	o.housekeep(rotated, tmpl)
+	return nil
+}
+
+// Queues a closed file up for compression and retention processing, if
+// either is in use.
+func (o *FileOutput) housekeep(path, tmpl string) {
+	if !o.CompressRotated && o.MaxFiles == 0 && o.maxAge == 0 {
+		return
+	}
+	var open map[string]bool
+	if o.templated {
+		open = o.files.Paths()
+	} else {
+		open = map[string]bool{o.path: true}
+	}
+	o.rotatedChan <- rotatedFile{path: path, tmpl: tmpl, open: open}
+}
+
+// Runs in a separate goroutine, compressing rotated files and deleting the
+// ones that exceed the retention settings.
+func (o *FileOutput) housekeeper(or OutputRunner, done chan struct{}) {
+	for rf := range o.rotatedChan {
+		if o.CompressRotated {
+			// Files that were rotated while an earlier one was being
+			// compressed may have been pruned already.
+			_, err := compressFile(rf.path, o.perm)
+			if err != nil && !os.IsNotExist(err) {
+				or.LogError(fmt.Errorf("can't compress rotated file '%s': %s", rf.path, err))
+			}
+		}
+		_, err := pruneRotated(rf.tmpl, rf.open, o.MaxFiles, o.maxAge, time.Now())
+		if err != nil {
+			or.LogError(err)
+		}
+	}
+	close(done)
+}
+
 func (o *FileOutput) Run(or OutputRunner, h PluginHelper) error {
 	enc := or.Encoder()
 	if enc == nil {
@@ -239,6 +533,7 @@ func (o *FileOutput) receiver(or OutputRunner, errChan chan error) (err error) {
 		msgCounter      uint32
 		intervalElapsed bool
 		outBytes        []byte
+		key             string
 	)
 	ok := true
 	out := newOutBatch()
@@ -257,19 +552,32 @@ func (o *FileOutput) receiver(or OutputRunner, errChan chan error) (err error) {
 		case pack, ok = <-inChan:
 			if !ok {
 				// Closed inChan => we're shutting down, flush data
-				if len(out.data) > 0 {
+				if out.size() > 0 {
 					o.batchChan <- out
 				}
 				close(o.batchChan)
 				break
 			}
+			if o.templated {
+				msgTime := time.Unix(0, pack.Message.GetTimestamp())
+				key, e = plugins.InterpolateMessage(o.Path, pack.Message, msgTime,
+					pathValueEscaper.Replace)
+				if e != nil {
+					pack.Recycle(e) // Don't try to resend.
+					continue
+				}
+			}
 			if outBytes, e = or.Encode(pack); e != nil {
 				e = fmt.Errorf("can't encode: %s", e)
 				pack.Recycle(e) // Don't try to resend.
 				continue
 			}
 			if outBytes != nil {
-				out.data = append(out.data, outBytes...)
+				if o.templated {
+					out.byPath[key] = append(out.byPath[key], outBytes...)
+				} else {
+					out.data = append(out.data, outBytes...)
+				}
 				out.cursor = pack.QueueCursor
 				msgCounter++
 			}
@@ -314,6 +622,106 @@ func (o *FileOutput) receiver(or OutputRunner, errChan chan error) (err error) {
 	return err
 }
 
+// Writes the batch data for a single interpolated path template out to the
+// corresponding file, rotating it first if it has grown too large. Returns
+// the number of bytes written.
+func (o *FileOutput) writeTemplated(key string, data []byte) (int, error) {
+	f, err := o.templatedFile(key)
+	if err != nil {
+		return 0, err
+	}
+	if o.needsSizeRotation(f.size, len(data)) {
+		o.files.Remove(key)
+		f.Close()
+		if err = o.rotateClosedFile(f.path, key); err != nil {
+			return 0, fmt.Errorf("can't rotate '%s': %s", f.path, err)
+		}
+		if f, err = o.templatedFile(key); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if n > 0 && o.fsyncMode != fsyncNone {
+		f.dirty = true
+	}
+	if err == nil && n != len(data) {
+		err = fmt.Errorf("truncated output for %s", f.path)
+	}
+	return n, err
+}
+
+// Retries writing the data of earlier failed writes. Returns true if there's
+// none left.
+func (o *FileOutput) retryFailed(or OutputRunner) bool {
+	for key, data := range o.failed {
+		n, err := o.writeTemplated(key, data)
+		if err != nil {
+			or.LogError(fmt.Errorf("Still can't write to %s: %s", key, err))
+			o.failed[key] = data[n:]
+			continue
+		}
+		delete(o.failed, key)
+	}
+	return len(o.failed) == 0
+}
+
+// Adds data to what's held for retrying for a path. If that would take it
+// over `max_held_size` everything held for the path is dropped instead, so a
+// path that keeps failing doesn't hold back the cursor for good.
+func (o *FileOutput) hold(or OutputRunner, key string, data []byte) {
+	held := o.failed[key]
+	if max := o.MaxHeldSize; max > 0 && uint64(len(held)+len(data)) > max {
+		or.LogError(fmt.Errorf("Dropping %d bytes that couldn't be written to %s",
+			len(held)+len(data), key))
+		delete(o.failed, key)
+		return
+	}
+	// The batch's buffers are reused, so the data has to be copied.
+	o.failed[key] = append(held, data...)
+}
+
+// Writes out a batch when the output path is interpolated from message data.
+// Data that can't be written is held on to and retried with the next batch,
+// ahead of that batch's data for the same path so each file's records stay
+// in order. The cursor is only advanced once all data up to it is written,
+// otherwise the held data would be lost on restart.
+func (o *FileOutput) commitTemplated(or OutputRunner, out *outBatch) {
+	o.retryFailed(or)
+	for key, data := range out.byPath {
+		if len(data) == 0 {
+			continue
+		}
+		if _, ok := o.failed[key]; ok {
+			o.hold(or, key, data)
+			continue
+		}
+		if n, err := o.writeTemplated(key, data); err != nil {
+			or.LogError(fmt.Errorf("Can't write to %s: %s", key, err))
+			o.hold(or, key, data[n:])
+		}
+	}
+	if len(o.failed) > 0 {
+		o.failedCursor = out.cursor
+		return
+	}
+	o.failedCursor = ""
+	o.commitCursor(or, out.cursor)
+}
+
+// Closes the current output file, moves it out of the way, and opens a new
+// one in its place.
+func (o *FileOutput) rotateBySize() error {
+	o.closeFile()
+	if err := o.rotateClosedFile(o.path, o.FileOutputConfig.Path); err != nil {
+		if e := o.openFile(); e != nil {
+			return e
+		}
+		return fmt.Errorf("can't rotate '%s': %s", o.path, err)
+	}
+	return o.openFile()
+}
+
 // Runs in a separate goroutine, waits for buffered data on the committer
 // channel, writes it out to the filesystem, and puts the now empty buffer on
 // the return channel for reuse.
@@ -323,6 +731,32 @@ func (o *FileOutput) committer(or OutputRunner, errChan chan error) {
 	var out *outBatch
 	var err error
 
+	if o.fsyncMode == fsyncInterval && o.fsyncChan == nil { // Tests might have set this already.
+		ticker := time.NewTicker(time.Duration(o.FsyncInterval) * time.Millisecond)
+		defer ticker.Stop()
+		o.fsyncChan = ticker.C
+	}
+
+	housekeeperDone := make(chan struct{})
+	go o.housekeeper(or, housekeeperDone)
+	// Closes everything down once we're exiting.
+	shutdown := func() {
+		if o.templated && o.failedCursor != "" && o.retryFailed(or) {
+			o.commitCursor(or, o.failedCursor)
+		}
+		if o.fsyncMode == fsyncInterval {
+			o.syncPending(or)
+		}
+		if o.templated {
+			o.files.CloseAll()
+		} else {
+			o.closeFile()
+		}
+		close(o.rotatedChan)
+		<-housekeeperDone
+		close(o.closing)
+	}
+
 	ok := true
 	hupChan := make(chan interface{})
 	notify.Start(RELOAD, hupChan)
@@ -332,41 +766,81 @@ func (o *FileOutput) committer(or OutputRunner, errChan chan error) {
 		case out, ok = <-o.batchChan:
 			if !ok {
 				// Channel is closed => we're shutting down, exit cleanly.
-				o.file.Close()
-				close(o.closing)
+				shutdown()
 				break
 			}
+			if o.templated {
+				o.commitTemplated(or, out)
+				out.reset()
+				o.backChan <- out
+				continue
+			}
+			if o.needsSizeRotation(o.fileSize, len(out.data)) {
+				if err = o.rotateBySize(); err != nil && o.file == nil {
+					shutdown()
+					errChan <- fmt.Errorf("unable to reopen file '%s': %s", o.path, err)
+					ok = false
+					break
+				} else if err != nil {
+					or.LogError(err)
+				}
+			}
 			n, err := o.file.Write(out.data)
+			o.fileSize += int64(n)
+			if n > 0 && o.fsyncMode != fsyncNone {
+				o.fileDirty = true
+			}
 			if err != nil {
 				or.LogError(fmt.Errorf("Can't write to %s: %s", o.path, err))
 			} else if n != len(out.data) {
 				or.LogError(fmt.Errorf("data loss - truncated output for %s", o.path))
-				or.UpdateCursor(out.cursor)
+				o.commitCursor(or, out.cursor)
 			} else {
-				o.file.Sync()
-				or.UpdateCursor(out.cursor)
+				o.commitCursor(or, out.cursor)
 			}
-			out.data = out.data[:0]
+			out.reset()
 			o.backChan <- out
 		case <-hupChan:
-			o.file.Close()
+			if o.templated {
+				// Files will be lazily reopened on the next write.
+				o.files.CloseAll()
+				continue
+			}
+			o.closeFile()
 			if err = o.openFile(); err != nil {
-				close(o.closing)
+				o.file = nil
+				shutdown()
 				err = fmt.Errorf("unable to reopen file '%s': %s", o.path, err)
 				errChan <- err
 				ok = false
 				break
 			}
+		case <-o.fsyncChan:
+			o.syncPending(or)
 		case rotateTime := <-o.rotateChan:
-			o.file.Close()
-			o.path = gostrftime.Strftime(o.FileOutputConfig.Path, rotateTime)
+			o.rotateTime = rotateTime
+			if o.templated {
+				for _, f := range o.files.CloseAll() {
+					if f.path != o.resolvePath(f.key) {
+						o.housekeep(f.path, f.key)
+					}
+				}
+				continue
+			}
+			o.closeFile()
+			oldPath := o.path
+			o.path = o.resolvePath(o.FileOutputConfig.Path)
 			if err = o.openFile(); err != nil {
-				close(o.closing)
+				o.file = nil
+				shutdown()
 				err = fmt.Errorf("unable to open rotated file '%s': %s", o.path, err)
 				errChan <- err
 				ok = false
 				break
 			}
+			if oldPath != o.path {
+				o.housekeep(oldPath, o.FileOutputConfig.Path)
+			}
 		}
 	}
 }
@@ -228,6 +228,250 @@ func FileOutputSpec(c gs.Context) {
 			})
 		})
 
+		c.Specify("fsyncs by interval", func() {
+			config.Fsync = "interval"
+			err := fileOutput.Init(config)
+			c.Assume(err, gs.IsNil)
+			fsyncChan := make(chan time.Time)
+			fileOutput.fsyncChan = fsyncChan
+
+			go fileOutput.committer(oth.MockOutputRunner, errChan)
+			fileOutput.batchChan <- &outBatch{
+				data:   []byte("Write me out to the log file"),
+				cursor: pack.QueueCursor,
+			}
+			<-fileOutput.backChan // clear backChan to prevent blocking.
+			<-fileOutput.backChan
+
+			// Cursor is held back until the data has been fsynced.
+			c.Expect(fileOutput.pendingCursor, gs.Equals, pack.QueueCursor)
+			c.Expect(fileOutput.fileDirty, gs.IsTrue)
+
+			oth.MockOutputRunner.EXPECT().UpdateCursor(pack.QueueCursor)
+			fsyncChan <- time.Now()
+			close(fileOutput.batchChan)
+			<-fileOutput.closing
+			c.Expect(fileOutput.pendingCursor, gs.Equals, "")
+			c.Expect(fileOutput.fileDirty, gs.IsFalse)
+		})
+
+		c.Specify("rejects an unknown fsync mode", func() {
+			config.Fsync = "sometimes"
+			err := fileOutput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("rotates by size", func() {
+			tmpDir, err := ioutil.TempDir("", "fileoutput-rotate")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			config.Path = filepath.Join(tmpDir, "out.log")
+			config.MaxFileSize = 10
+			oth.MockOutputRunner.EXPECT().UpdateCursor(pack.QueueCursor).Times(3)
+
+			commit := func() {
+				go fileOutput.committer(oth.MockOutputRunner, errChan)
+				go func() {
+					for i := 0; i < 3; i++ {
+						fileOutput.batchChan <- &outBatch{
+							data:   []byte("0123456789"),
+							cursor: pack.QueueCursor,
+						}
+						<-fileOutput.backChan // clear backChan to prevent blocking.
+					}
+					close(fileOutput.batchChan)
+				}()
+				<-fileOutput.closing
+			}
+
+			c.Specify("keeping every file", func() {
+				err = fileOutput.Init(config)
+				c.Assume(err, gs.IsNil)
+				commit()
+				matches, err := filepath.Glob(config.Path + "*")
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(matches), gs.Equals, 3)
+				contents, err := ioutil.ReadFile(config.Path)
+				c.Expect(err, gs.IsNil)
+				c.Expect(string(contents), gs.Equals, "0123456789")
+			})
+
+			c.Specify("honoring max_files and compress_rotated", func() {
+				config.MaxFiles = 1
+				config.CompressRotated = true
+				err = fileOutput.Init(config)
+				c.Assume(err, gs.IsNil)
+				commit()
+				matches, err := filepath.Glob(config.Path + ".*")
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(matches), gs.Equals, 1)
+				c.Expect(filepath.Ext(matches[0]), gs.Equals, ".gz")
+			})
+		})
+
+		c.Specify("prunes rotated files", func() {
+			tmpDir, err := ioutil.TempDir("", "fileoutput-prune")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			tmpl := filepath.Join(tmpDir, "out-%Y%m%d.log")
+			now := time.Now()
+			for i := 0; i < 4; i++ {
+				t := now.Add(time.Duration(-i) * 24 * time.Hour)
+				path := filepath.Join(tmpDir, t.Format("out-20060102.log"))
+				err = ioutil.WriteFile(path, []byte("data"), 0644)
+				c.Assume(err, gs.IsNil)
+				err = os.Chtimes(path, t, t)
+				c.Assume(err, gs.IsNil)
+			}
+			current := filepath.Join(tmpDir, now.Format("out-20060102.log"))
+			open := map[string]bool{current: true}
+
+			c.Specify("by count", func() {
+				removed, err := pruneRotated(tmpl, open, 2, 0, now)
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(removed), gs.Equals, 1)
+				_, err = os.Stat(current)
+				c.Expect(err, gs.IsNil)
+			})
+
+			c.Specify("by age", func() {
+				removed, err := pruneRotated(tmpl, open, 0, 36*time.Hour, now)
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(removed), gs.Equals, 2)
+			})
+		})
+
+		c.Specify("interpolates message fields into the path", func() {
+			tmpDir, err := ioutil.TempDir("", "fileoutput-tmpl")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			config.Path = filepath.Join(tmpDir, "%{Logger}", "%{foo}.log")
+			config.MaxOpenFiles = 1
+			err = fileOutput.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			msg2 := pipeline_ts.GetTestMessage()
+			msg2.SetLogger("../other")
+			pack2 := NewPipelinePack(pConfig.InputRecycleChan())
+			pack2.Message = msg2
+			pack2.QueueCursor = "queuecursor2"
+
+			oth.MockOutputRunner.EXPECT().InChan().Return(inChan)
+			oth.MockOutputRunner.EXPECT().Encode(pack).Return(encoder.Encode(pack))
+			oth.MockOutputRunner.EXPECT().Encode(pack2).Return(encoder.Encode(pack2))
+			oth.MockOutputRunner.EXPECT().UpdateCursor(pack2.QueueCursor)
+
+			go fileOutput.committer(oth.MockOutputRunner, errChan)
+			go fileOutput.receiver(oth.MockOutputRunner, errChan)
+			inChan <- pack
+			inChan <- pack2
+			close(inChan)
+			<-fileOutput.closing
+
+			payload := fmt.Sprintf("%s\n", msg.GetPayload())
+			contents, err := ioutil.ReadFile(filepath.Join(tmpDir, "GoSpec", "bar.log"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(contents), gs.Equals, payload)
+			contents, err = ioutil.ReadFile(filepath.Join(tmpDir, "__other", "bar.log"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(contents), gs.Equals, payload)
+		})
+
+		c.Specify("holds on to data it can't write until it can", func() {
+			tmpDir, err := ioutil.TempDir("", "fileoutput-tmpl")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			config.Path = filepath.Join(tmpDir, "%{Logger}", "out.log")
+			err = fileOutput.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			// A file in place of the directory keeps the output file from
+			// being created.
+			blocker := filepath.Join(tmpDir, "blocked")
+			err = ioutil.WriteFile(blocker, nil, 0644)
+			c.Assume(err, gs.IsNil)
+			okPath := filepath.Join(tmpDir, "ok", "out.log")
+			blockedPath := filepath.Join(blocker, "out.log")
+			oth.MockOutputRunner.EXPECT().LogError(gomock.Any()).AnyTimes()
+
+			go fileOutput.committer(oth.MockOutputRunner, errChan)
+			<-fileOutput.backChan
+			fileOutput.batchChan <- &outBatch{
+				byPath: map[string][]byte{
+					okPath:      []byte("one\n"),
+					blockedPath: []byte("two\n"),
+				},
+				cursor: "cursor1",
+			}
+			<-fileOutput.backChan
+			c.Expect(fileOutput.failedCursor, gs.Equals, "cursor1")
+
+			err = os.Remove(blocker)
+			c.Assume(err, gs.IsNil)
+			oth.MockOutputRunner.EXPECT().UpdateCursor("cursor2")
+			fileOutput.batchChan <- &outBatch{
+				byPath: map[string][]byte{blockedPath: []byte("three\n")},
+				cursor: "cursor2",
+			}
+			<-fileOutput.backChan
+			close(fileOutput.batchChan)
+			<-fileOutput.closing
+
+			contents, err := ioutil.ReadFile(okPath)
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(contents), gs.Equals, "one\n")
+			contents, err = ioutil.ReadFile(blockedPath)
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(contents), gs.Equals, "two\nthree\n")
+			c.Expect(len(fileOutput.failed), gs.Equals, 0)
+		})
+
+		c.Specify("drops held data once it exceeds max_held_size", func() {
+			tmpDir, err := ioutil.TempDir("", "fileoutput-tmpl")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			config.Path = filepath.Join(tmpDir, "%{Logger}", "out.log")
+			config.MaxHeldSize = 8
+			err = fileOutput.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			blocker := filepath.Join(tmpDir, "blocked")
+			err = ioutil.WriteFile(blocker, nil, 0644)
+			c.Assume(err, gs.IsNil)
+			okPath := filepath.Join(tmpDir, "ok", "out.log")
+			blockedPath := filepath.Join(blocker, "out.log")
+			oth.MockOutputRunner.EXPECT().LogError(gomock.Any()).AnyTimes()
+
+			go fileOutput.committer(oth.MockOutputRunner, errChan)
+			<-fileOutput.backChan
+			fileOutput.batchChan <- &outBatch{
+				byPath: map[string][]byte{blockedPath: []byte("one\n")},
+				cursor: "cursor1",
+			}
+			<-fileOutput.backChan
+			c.Expect(fileOutput.failedCursor, gs.Equals, "cursor1")
+
+			// The blocked path is still failing, but its held data is dropped
+			// so the other path's data can be committed.
+			oth.MockOutputRunner.EXPECT().UpdateCursor("cursor2")
+			fileOutput.batchChan <- &outBatch{
+				byPath: map[string][]byte{
+					okPath:      []byte("two\n"),
+					blockedPath: []byte("three\n"),
+				},
+				cursor: "cursor2",
+			}
+			<-fileOutput.backChan
+			close(fileOutput.batchChan)
+			<-fileOutput.closing
+
+			c.Expect(len(fileOutput.failed), gs.Equals, 0)
+			c.Expect(fileOutput.failedCursor, gs.Equals, "")
+			contents, err := ioutil.ReadFile(okPath)
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(contents), gs.Equals, "two\n")
+		})
+
 		if runtime.GOOS != "windows" {
 			if u, err := user.Current(); err != nil && u.Uid != "0" {
 				c.Specify("Init halts if basedirectory is not writable", func() {
@@ -0,0 +1,295 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package file
+
+import (
+	"compress/gzip"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timestamp format appended to the name of files that are rotated out of
+// the way because they've reached `max_file_size`.
+const rotatedSuffixFormat = "20060102T150405.000000000"
+
+// An open output file and the number of bytes it currently holds.
+type outFile struct {
+	key  string
+	path string
+	file *os.File
+	size int64
+	// Whether data has been written since the file was last fsynced.
+	dirty bool
+}
+
+// Closes the file, first flushing it to stable storage if it has unsynced
+// writes.
+func (f *outFile) Close() error {
+	if f.dirty {
+		f.file.Sync()
+		f.dirty = false
+	}
+	return f.file.Close()
+}
+
+// Flushes the file to stable storage if it has unsynced writes.
+func (f *outFile) Sync() error {
+	if !f.dirty {
+		return nil
+	}
+	f.dirty = false
+	return f.file.Sync()
+}
+
+// Small LRU cache of open output files, used when the output path is
+// interpolated from message data and we might be writing to any number of
+// files.
+type fileCache struct {
+	max   int
+	ll    *list.List
+	files map[string]*list.Element
+}
+
+func newFileCache(max int) *fileCache {
+	return &fileCache{
+		max:   max,
+		ll:    list.New(),
+		files: make(map[string]*list.Element),
+	}
+}
+
+// Returns the cached file for the provided key, if it exists, marking it as
+// most recently used.
+func (fc *fileCache) Get(key string) (*outFile, bool) {
+	if elem, ok := fc.files[key]; ok {
+		fc.ll.MoveToFront(elem)
+		return elem.Value.(*outFile), true
+	}
+	return nil, false
+}
+
+// Adds a file to the cache, closing and returning the least recently used
+// file if the cache is full.
+func (fc *fileCache) Add(key string, f *outFile) (evicted *outFile) {
+	fc.files[key] = fc.ll.PushFront(f)
+	if fc.ll.Len() > fc.max {
+		oldest := fc.ll.Back()
+		evicted = oldest.Value.(*outFile)
+		fc.ll.Remove(oldest)
+		for k, elem := range fc.files {
+			if elem == oldest {
+				delete(fc.files, k)
+				break
+			}
+		}
+		evicted.Close()
+	}
+	return
+}
+
+// Removes a file from the cache without closing it.
+func (fc *fileCache) Remove(key string) {
+	if elem, ok := fc.files[key]; ok {
+		fc.ll.Remove(elem)
+		delete(fc.files, key)
+	}
+}
+
+// Closes all cached files and empties the cache, returning the files that
+// were closed.
+func (fc *fileCache) CloseAll() []*outFile {
+	closed := make([]*outFile, 0, fc.ll.Len())
+	for elem := fc.ll.Front(); elem != nil; elem = elem.Next() {
+		f := elem.Value.(*outFile)
+		f.Close()
+		closed = append(closed, f)
+	}
+	fc.ll.Init()
+	fc.files = make(map[string]*list.Element)
+	return closed
+}
+
+// Flushes all of the cached files to stable storage, returning the first
+// error encountered, if any.
+func (fc *fileCache) SyncAll() (err error) {
+	for elem := fc.ll.Front(); elem != nil; elem = elem.Next() {
+		if e := elem.Value.(*outFile).Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Returns the paths of all of the currently open files.
+func (fc *fileCache) Paths() map[string]bool {
+	paths := make(map[string]bool, fc.ll.Len())
+	for elem := fc.ll.Front(); elem != nil; elem = elem.Next() {
+		paths[elem.Value.(*outFile).path] = true
+	}
+	return paths
+}
+
+// A closed output file that needs compression and / or retention processing.
+type rotatedFile struct {
+	// Path of the closed file.
+	path string
+	// Output path template (after any message field interpolation) that
+	// produced the file, used to find sibling files for retention.
+	tmpl string
+	// Files that are still open and must not be touched.
+	open map[string]bool
+}
+
+// Converts an output path template to a glob pattern matching every file
+// the template might produce, by replacing the strftime directives with
+// wildcards.
+func templateGlob(tmpl string) string {
+	var buf []byte
+	for i := 0; i < len(tmpl); i++ {
+		switch {
+		case tmpl[i] == '%' && i+1 < len(tmpl):
+			i++
+			if tmpl[i] == '%' {
+				buf = append(buf, '%')
+			} else {
+				buf = append(buf, '*')
+			}
+		case tmpl[i] == '*' || tmpl[i] == '?' || tmpl[i] == '[' || tmpl[i] == '\\':
+			buf = append(buf, '\\', tmpl[i])
+		default:
+			buf = append(buf, tmpl[i])
+		}
+	}
+	return string(buf)
+}
+
+// Returns the name a file should be moved to when it's being rotated out of
+// the way because it's grown too large.
+func sizeRotatedPath(path string, t time.Time) string {
+	return fmt.Sprintf("%s.%s", path, t.Format(rotatedSuffixFormat))
+}
+
+// Flushes a directory's entries to stable storage, so that newly created or
+// renamed files survive a power loss.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	d.Close()
+	return err
+}
+
+// Gzips the file at the provided path, removing the original once the
+// compressed version has been completely written. Returns the path to the
+// compressed file.
+func compressFile(path string, perm os.FileMode) (string, error) {
+	gzPath := path + ".gz"
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(gzPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(dst)
+	gz.Name = filepath.Base(path)
+	if _, err = io.Copy(gz, src); err == nil {
+		if err = gz.Close(); err == nil {
+			err = dst.Sync()
+		}
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return "", err
+	}
+	return gzPath, os.Remove(path)
+}
+
+type fileInfos []os.FileInfo
+
+func (fis fileInfos) Len() int           { return len(fis) }
+func (fis fileInfos) Swap(i, j int)      { fis[i], fis[j] = fis[j], fis[i] }
+func (fis fileInfos) Less(i, j int) bool { return fis[i].ModTime().After(fis[j].ModTime()) }
+
+// Deletes the rotated files generated by the provided output path template
+// that are older than `maxAge` or that exceed the `maxFiles` count, newest
+// files first. Zero values disable the respective check. Files in the `open`
+// set are never deleted, nor counted.
+func pruneRotated(tmpl string, open map[string]bool, maxFiles uint32,
+	maxAge time.Duration, now time.Time) (removed []string, err error) {
+
+	if maxFiles == 0 && maxAge == 0 {
+		return
+	}
+	pattern := templateGlob(tmpl)
+	var matches []string
+	for _, p := range []string{pattern, pattern + ".*"} {
+		m, e := filepath.Glob(p)
+		if e != nil {
+			return nil, e
+		}
+		matches = append(matches, m...)
+	}
+
+	seen := make(map[string]bool, len(matches))
+	infos := make(fileInfos, 0, len(matches))
+	paths := make(map[os.FileInfo]string, len(matches))
+	for _, m := range matches {
+		if seen[m] || open[m] {
+			continue
+		}
+		seen[m] = true
+		fi, e := os.Stat(m)
+		if e != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		infos = append(infos, fi)
+		paths[fi] = m
+	}
+	sort.Sort(infos)
+
+	var errMsgs []string
+	for i, fi := range infos {
+		expired := maxAge > 0 && now.Sub(fi.ModTime()) > maxAge
+		excess := maxFiles > 0 && uint32(i) >= maxFiles
+		if !expired && !excess {
+			continue
+		}
+		if e := os.Remove(paths[fi]); e != nil {
+			errMsgs = append(errMsgs, e.Error())
+			continue
+		}
+		removed = append(removed, paths[fi])
+	}
+	if len(errMsgs) > 0 {
+		err = fmt.Errorf("error removing rotated files: %s",
+			strings.Join(errMsgs, ", "))
+	}
+	return
+}
@@ -24,26 +24,26 @@ import (
 	"strings"
 
 	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins/tcp"
 )
 
 // Output plugin that sends statmetric messages via TCP
 type CarbonOutput struct {
 	bufSplitSize int
 	*CarbonOutputConfig
-	*net.UDPAddr
-	*net.TCPAddr
-	*net.TCPConn
-	send func(or OutputRunner, data []byte)
+	network string
+	conn    net.Conn
+	send    func(or OutputRunner, data []byte)
 }
 
 // ConfigStruct for CarbonOutput plugin.
 type CarbonOutputConfig struct {
 	// String representation of the TCP address to which this output should be
-	// sending data.
+	// sending data, or the socket path for the unix protocols.
 	Address string
 	// Keep the TCP connection open
 	TCPKeepAlive bool `toml:"tcp_keep_alive"`
-	// If true, use UDP rather than TCP (default) to send the data
+	// One of "tcp" (default), "udp", "unix" or "unixgram".
 	Protocol string `toml:"protocol"`
 }
 
@@ -56,14 +56,27 @@ func (t *CarbonOutput) Init(config interface{}) (err error) {
 
 	switch t.Protocol {
 	case "", "tcp":
-		t.send = t.sendTCP
-		t.TCPAddr, err = net.ResolveTCPAddr("tcp", t.Address)
+		t.network = "tcp"
+		t.send = t.sendStream
+		_, err = net.ResolveTCPAddr("tcp", t.Address)
 	case "udp":
-		t.send = t.sendUDP
-		t.UDPAddr, err = net.ResolveUDPAddr("udp", t.Address)
+		t.network = "udp"
+		t.send = t.sendDatagram
+		_, err = net.ResolveUDPAddr("udp", t.Address)
 		t.bufSplitSize = 63488 // 62KiB
+	case "unix":
+		t.network = "unix"
+		t.send = t.sendStream
+		err = tcp.CheckUnixAddress(t.Address)
+	case "unixgram":
+		t.network = "unixgram"
+		t.send = t.sendDatagram
+		err = tcp.CheckUnixAddress(t.Address)
+		// Unix datagrams are limited by the socket buffer size, which tends
+		// to be smaller than the UDP limit.
+		t.bufSplitSize = 8192
 	default:
-		err = fmt.Errorf(`CarbonOutput: "%s" is not a supported protocol, must be "tcp" or "udp"`, t.Protocol)
+		err = fmt.Errorf(`CarbonOutput: "%s" is not a supported protocol, must be "tcp", "udp", "unix" or "unixgram"`, t.Protocol)
 	}
 
 	return
@@ -115,16 +128,16 @@ func (t *CarbonOutput) ProcessPack(pack *PipelinePack, or OutputRunner) {
 	t.send(or, buffer.Bytes())
 }
 
-func (t *CarbonOutput) sendTCP(or OutputRunner, data []byte) {
+func (t *CarbonOutput) sendStream(or OutputRunner, data []byte) {
 	write := func() (err error) {
-		if t.TCPConn == nil {
-			t.TCPConn, err = net.DialTCP("tcp", nil, t.TCPAddr)
+		if t.conn == nil {
+			t.conn, err = net.Dial(t.network, t.Address)
 			if err != nil {
 				or.LogError(fmt.Errorf("Dial failed: %s", err.Error()))
 				return
 			}
 		}
-		_, err = t.TCPConn.Write(data)
+		_, err = t.conn.Write(data)
 		if err != nil {
 			or.LogError(fmt.Errorf("Write to server failed: %s", err.Error()))
 			return
@@ -133,11 +146,11 @@ func (t *CarbonOutput) sendTCP(or OutputRunner, data []byte) {
 	}
 
 	disconnect := func() {
-		if t.TCPConn == nil {
+		if t.conn == nil {
 			return
 		}
-		t.TCPConn.Close()
-		t.TCPConn = nil
+		t.conn.Close()
+		t.conn = nil
 	}
 
 	if !t.TCPKeepAlive {
@@ -157,12 +170,13 @@ func (t *CarbonOutput) sendTCP(or OutputRunner, data []byte) {
 	}
 }
 
-func (t *CarbonOutput) sendUDP(or OutputRunner, data []byte) {
-	conn, err := net.DialUDP("udp", nil, t.UDPAddr)
+func (t *CarbonOutput) sendDatagram(or OutputRunner, data []byte) {
+	conn, err := net.Dial(t.network, t.Address)
 	if err != nil {
 		or.LogError(fmt.Errorf("Dial failed: %s", err.Error()))
 		return
 	}
+	defer conn.Close()
 
 	_, err = conn.Write(data)
 	if err != nil {
@@ -18,7 +18,11 @@ package graphite
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -265,5 +269,60 @@ func CarbonOutputSpec(c gs.Context) {
 				c.Expect(err, gs.IsNil)
 			})
 		})
+
+		if runtime.GOOS != "windows" {
+			c.Specify("using unix sockets", func() {
+				tmpDir, err := ioutil.TempDir("", "carbon-tests")
+				c.Assume(err, gs.IsNil)
+				defer os.RemoveAll(tmpDir)
+				config.Address = filepath.Join(tmpDir, "carbon.sock")
+
+				c.Specify("writes to a unix stream socket", func() {
+					config.Protocol = "unix"
+					listener, err := net.Listen("unix", config.Address)
+					c.Assume(err, gs.IsNil)
+					defer listener.Close()
+					go func() {
+						conn, err := listener.Accept()
+						if err != nil {
+							errChan <- err
+							return
+						}
+						connChan <- conn
+					}()
+
+					err = output.Init(config)
+					c.Assume(err, gs.IsNil)
+					inChan <- pack
+					go startOutput(output, oth)
+					conn = <-connChan
+					defer conn.Close()
+					go collectData(conn)
+					c.Expect(<-dataChan, gs.Equals, expected_data)
+
+					close(inChan)
+					err = <-errChan
+					c.Expect(err, gs.IsNil)
+				})
+
+				c.Specify("writes to a unix datagram socket", func() {
+					config.Protocol = "unixgram"
+					conn, err := net.ListenPacket("unixgram", config.Address)
+					c.Assume(err, gs.IsNil)
+					defer conn.Close()
+
+					err = output.Init(config)
+					c.Assume(err, gs.IsNil)
+					inChan <- pack
+					go startOutput(output, oth)
+					go collectData(conn.(net.Conn))
+					c.Expect(<-dataChan, gs.Equals, expected_data)
+
+					close(inChan)
+					err = <-errChan
+					c.Expect(err, gs.IsNil)
+				})
+			})
+		}
 	})
 }
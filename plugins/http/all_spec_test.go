@@ -24,7 +24,10 @@ func TestAllSpecs(t *testing.T) {
 	r.Parallel = false
 
 	r.AddSpec(HttpInputSpec)
+	r.AddSpec(HttpInputPagesSpec)
 	r.AddSpec(HttpListenInputSpec)
+	r.AddSpec(HttpListenInputBodySpec)
+	r.AddSpec(HttpListenAuthSpec)
 	r.AddSpec(HttpOutputSpec)
 
 	gospec.MainGoTest(r, t)
@@ -18,9 +18,13 @@
 package http
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -49,6 +53,9 @@ type HttpInput struct {
 	hostname        string
 	packSupply      chan *PipelinePack
 	customUserAgent bool
+	pConfig         *PipelineConfig
+	states          map[string]*urlState
+	statePath       string
 }
 
 // Http Input config struct
@@ -72,18 +79,43 @@ type HttpInputConfig struct {
 	SuccessSeverity int32 `toml:"success_severity"`
 	// Severity level of errors and unsuccessful requests. Default is 1 (alert)
 	ErrorSeverity int32 `toml:"error_severity"`
+	// How to find the next page of a response, either "link" to follow the
+	// Link header's rel="next" URL or "cursor" to pass on a cursor found in
+	// the JSON response body. Default is no pagination.
+	Pagination string
+	// Maximum number of pages requested from a URL per tick. Default is 100.
+	MaxPages int `toml:"max_pages"`
+	// Path of the cursor within the response body, and the query parameter
+	// it is sent back in.
+	CursorField string `toml:"cursor_field"`
+	CursorParam string `toml:"cursor_param"`
+	// Resume each URL from the last cursor received, across restarts.
+	PersistCursor bool `toml:"persist_cursor"`
+	// Send If-None-Match and If-Modified-Since headers, skipping responses
+	// which haven't changed since they were last received.
+	ConditionalRequests bool `toml:"conditional_requests"`
+	// Deliver the elements of a JSON array response as separate records, the
+	// array being found at `json_array_path` in the response body.
+	SplitJsonArray bool   `toml:"split_json_array"`
+	JsonArrayPath  string `toml:"json_array_path"`
 }
 
 func (hi *HttpInput) SetName(name string) {
 	hi.name = name
 }
 
+func (hi *HttpInput) SetPipelineConfig(pConfig *PipelineConfig) {
+	hi.pConfig = pConfig
+}
+
 func (hi *HttpInput) ConfigStruct() interface{} {
 	return &HttpInputConfig{
 		Method:          "GET",
 		TickerInterval:  uint(10),
 		SuccessSeverity: int32(6),
 		ErrorSeverity:   int32(1),
+		MaxPages:        100,
+		CursorParam:     "cursor",
 	}
 }
 
@@ -99,6 +131,37 @@ func (hi *HttpInput) Init(config interface{}) error {
 	}
 	hi.stopChan = make(chan bool)
 
+	switch hi.conf.Pagination {
+	case "", "link":
+	case "cursor":
+		if hi.conf.CursorField == "" || hi.conf.CursorParam == "" {
+			return errors.New("cursor pagination requires cursor_field and cursor_param")
+		}
+	default:
+		return fmt.Errorf("unknown pagination '%s'", hi.conf.Pagination)
+	}
+	if hi.conf.MaxPages < 1 {
+		return errors.New("max_pages must be at least 1")
+	}
+	if hi.conf.PersistCursor && hi.conf.Pagination != "cursor" {
+		return errors.New("persist_cursor requires cursor pagination")
+	}
+	hi.states = make(map[string]*urlState)
+	if hi.conf.PersistCursor {
+		hi.statePath = hi.pConfig.Globals.PrependBaseDir(filepath.Join("http_input",
+			hi.name+".json"))
+		states, err := loadUrlStates(hi.statePath)
+		if err != nil {
+			return fmt.Errorf("loading URL states: %s", err)
+		}
+		hi.states = states
+	}
+	for _, url := range hi.urls {
+		if hi.states[url] == nil {
+			hi.states[url] = new(urlState)
+		}
+	}
+
 	// Check to see if a custom user-agent is in use.
 	h := make(http.Header)
 	for key, value := range hi.conf.Headers {
@@ -140,12 +203,48 @@ func (hi *HttpInput) makePackDecorator(respData ResponseData) func(*PipelinePack
 	return packDecorator
 }
 
+// Requests all pages of a URL, starting from its last cursor if cursors are
+// persisted.
 func (hi *HttpInput) fetchUrl(url string, sRunner SplitterRunner) {
+	state := hi.states[url]
+	pageUrl := url
+	skip := 0
+	if hi.conf.PersistCursor {
+		skip = state.Delivered
+	}
+	if hi.conf.PersistCursor && state.Cursor != "" {
+		var err error
+		if pageUrl, err = withQueryParam(url, hi.conf.CursorParam, state.Cursor); err != nil {
+			hi.ir.LogError(fmt.Errorf("can't resume %s: %s", url, err.Error()))
+			return
+		}
+	}
+	for page := 0; page < hi.conf.MaxPages; page++ {
+		next := hi.fetchPage(url, pageUrl, page == 0, skip, sRunner)
+		skip = 0
+		if next == "" || next == pageUrl {
+			break
+		}
+		pageUrl = next
+	}
+	if hi.conf.PersistCursor {
+		if err := saveUrlStates(hi.statePath, hi.states); err != nil {
+			hi.ir.LogError(fmt.Errorf("saving URL states: %s", err.Error()))
+		}
+	}
+}
+
+// Requests a single page of a URL's response, skipping its first `skip` JSON
+// records, and returns the URL of the next page, if there is one.
+func (hi *HttpInput) fetchPage(url, pageUrl string, first bool, skip int,
+	sRunner SplitterRunner) (next string) {
+
+	state := hi.states[url]
 	responseTimeStart := time.Now()
 	httpClient := &http.Client{}
-	req, err := http.NewRequest(hi.conf.Method, url, strings.NewReader(hi.conf.Body))
+	req, err := http.NewRequest(hi.conf.Method, pageUrl, strings.NewReader(hi.conf.Body))
 	if err != nil {
-		hi.ir.LogError(fmt.Errorf("can't create HTTP request for %s: %s", url, err.Error()))
+		hi.ir.LogError(fmt.Errorf("can't create HTTP request for %s: %s", pageUrl, err.Error()))
 		return
 	}
 	// HTTP Basic Auth
@@ -159,6 +258,16 @@ func (hi *HttpInput) fetchUrl(url string, sRunner SplitterRunner) {
 	if !hi.customUserAgent {
 		req.Header.Add("User-Agent", "Heka")
 	}
+	// Only the first page is checked for changes, later pages depend on it.
+	conditional := hi.conf.ConditionalRequests && first
+	if conditional {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
 	resp, err := httpClient.Do(req)
 	responseTime := time.Since(responseTimeStart)
 	if err != nil {
@@ -168,10 +277,20 @@ func (hi *HttpInput) fetchUrl(url string, sRunner SplitterRunner) {
 		pack.Message.SetType("heka.httpinput.error")
 		pack.Message.SetPayload(err.Error())
 		pack.Message.SetSeverity(hi.conf.ErrorSeverity)
-		pack.Message.SetLogger(url)
+		pack.Message.SetLogger(pageUrl)
 		hi.ir.Deliver(pack)
 		return
 	}
+	defer resp.Body.Close()
+	if conditional {
+		if resp.StatusCode == http.StatusNotModified {
+			return
+		}
+		if resp.StatusCode == http.StatusOK {
+			state.ETag = resp.Header.Get("ETag")
+			state.LastModified = resp.Header.Get("Last-Modified")
+		}
+	}
 	contentLength, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
 	respData := ResponseData{
 		Size:       contentLength,
@@ -179,18 +298,93 @@ func (hi *HttpInput) fetchUrl(url string, sRunner SplitterRunner) {
 		StatusCode: resp.StatusCode,
 		Status:     resp.Status,
 		Proto:      resp.Proto,
-		Url:        url,
+		Url:        pageUrl,
 	}
 
 	if !sRunner.UseMsgBytes() {
 		sRunner.SetPackDecorator(hi.makePackDecorator(respData))
 	}
 
-	err = sRunner.SplitStreamNullSplitterToEOF(resp.Body, nil)
-	if err != nil && err != io.EOF {
-		hi.ir.LogError(fmt.Errorf("fetching %s response input: %s", url, err.Error()))
+	if resp.StatusCode != http.StatusOK ||
+		(!hi.conf.SplitJsonArray && hi.conf.Pagination != "cursor") {
+
+		err = sRunner.SplitStreamNullSplitterToEOF(resp.Body, nil)
+		if err != nil && err != io.EOF {
+			hi.ir.LogError(fmt.Errorf("fetching %s response input: %s", pageUrl, err.Error()))
+		}
+		if resp.StatusCode == http.StatusOK && hi.conf.Pagination == "link" {
+			next = nextLink(resp)
+		}
+		return next
+	}
+
+	// The whole body is needed to look into the JSON.
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		hi.ir.LogError(fmt.Errorf("fetching %s response input: %s", pageUrl, err.Error()))
+		return
+	}
+	records := hi.deliverJson(pageUrl, body, skip, sRunner)
+
+	switch hi.conf.Pagination {
+	case "link":
+		next = nextLink(resp)
+	case "cursor":
+		cursor, err := jsonCursor(body, hi.conf.CursorField)
+		if err != nil {
+			hi.ir.LogError(fmt.Errorf("reading cursor of %s: %s", pageUrl, err.Error()))
+		}
+		if cursor != "" {
+			if next, err = withQueryParam(url, hi.conf.CursorParam, cursor); err != nil {
+				hi.ir.LogError(fmt.Errorf("can't paginate %s: %s", url, err.Error()))
+			}
+		}
+		if next == "" || next == pageUrl {
+			// Caught up. The page is requested again next time for the
+			// records added to it meanwhile, so remember what was delivered.
+			state.Delivered = records
+			return ""
+		}
+		// Only the cursor of a page that's yet to be delivered is kept.
+		state.Cursor = cursor
+		state.Delivered = 0
+	}
+	return next
+}
+
+// Delivers the body as a single record through the splitter, or each of its
+// array's elements if split_json_array is set, leaving out the first `skip`
+// records. Returns the number of records in the body.
+func (hi *HttpInput) deliverJson(pageUrl string, body []byte, skip int,
+	sRunner SplitterRunner) int {
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return 0
+	}
+	if !hi.conf.SplitJsonArray {
+		if skip == 0 {
+			err := sRunner.SplitStreamNullSplitterToEOF(bytes.NewReader(body), nil)
+			if err != nil && err != io.EOF {
+				hi.ir.LogError(fmt.Errorf("fetching %s response input: %s", pageUrl,
+					err.Error()))
+			}
+		}
+		return 1
+	}
+	elements, err := jsonArray(body, hi.conf.JsonArrayPath)
+	if err != nil {
+		hi.ir.LogError(fmt.Errorf("splitting %s response: %s", pageUrl, err.Error()))
+		if skip == 0 {
+			sRunner.DeliverRecord(body, nil)
+		}
+		return 1
+	}
+	for i, element := range elements {
+		if i >= skip {
+			sRunner.DeliverRecord(element, nil)
+		}
 	}
-	resp.Body.Close()
+	return len(elements)
 }
 
 func (hi *HttpInput) Run(ir InputRunner, h PluginHelper) (err error) {
@@ -0,0 +1,178 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// What is remembered between requests for each of the configured URLs.
+type urlState struct {
+	Cursor       string `json:"cursor,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Number of records of the page at Cursor that were already delivered.
+	Delivered int `json:"delivered,omitempty"`
+}
+
+// Loads the URL states written by a previous run, if there are any.
+func loadUrlStates(path string) (states map[string]*urlState, err error) {
+	states = make(map[string]*urlState)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return states, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return states, nil
+}
+
+// Writes the URL states to a temporary file first, so a crash can't leave a
+// truncated state file behind.
+func saveUrlStates(path string, states map[string]*urlState) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Returns the URL of the `rel="next"` entry of the response's Link headers,
+// resolved against the request URL, or an empty string if there is none.
+func nextLink(resp *http.Response) string {
+	for _, header := range resp.Header["Link"] {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if len(target) < 2 || target[0] != '<' || target[len(target)-1] != '>' {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "rel=") {
+					continue
+				}
+				rels := strings.Fields(strings.Trim(param[4:], `"`))
+				for _, rel := range rels {
+					if rel != "next" {
+						continue
+					}
+					next, err := resp.Request.URL.Parse(target[1 : len(target)-1])
+					if err != nil {
+						return ""
+					}
+					return next.String()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Sets a query parameter of the URL, replacing any existing value.
+func withQueryParam(rawUrl, name, value string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Returns the JSON value found at the dot separated path of object keys and
+// array indexes, e.g. "data.items" or "results.0.id". An empty path returns
+// the whole document.
+func jsonPath(data []byte, path string) (json.RawMessage, error) {
+	value := json.RawMessage(bytes.TrimSpace(data))
+	if path == "" {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		if len(value) > 0 && value[0] == '[' {
+			var array []json.RawMessage
+			if err := json.Unmarshal(value, &array); err != nil {
+				return nil, err
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(array) {
+				return nil, fmt.Errorf("no index '%s' in JSON array", key)
+			}
+			value = array[i]
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return nil, fmt.Errorf("can't look up '%s': %s", key, err)
+		}
+		var ok bool
+		if value, ok = object[key]; !ok {
+			return nil, fmt.Errorf("no key '%s' in JSON object", key)
+		}
+	}
+	return value, nil
+}
+
+// Returns the elements of the JSON array at the given path.
+func jsonArray(data []byte, path string) ([]json.RawMessage, error) {
+	value, err := jsonPath(data, path)
+	if err != nil {
+		return nil, err
+	}
+	var array []json.RawMessage
+	if err = json.Unmarshal(value, &array); err != nil {
+		return nil, fmt.Errorf("not a JSON array: %s", err)
+	}
+	return array, nil
+}
+
+// Returns the string or number at the given path, or an empty string if it is
+// missing or null.
+func jsonCursor(data []byte, path string) (string, error) {
+	value, err := jsonPath(data, path)
+	if err != nil || string(value) == "null" {
+		return "", nil
+	}
+	if len(value) > 0 && value[0] == '"' {
+		var cursor string
+		err = json.Unmarshal(value, &cursor)
+		return cursor, err
+	}
+	var number json.Number
+	if err = json.Unmarshal(value, &number); err != nil {
+		return "", fmt.Errorf("cursor is neither a string nor a number: %s", value)
+	}
+	return number.String(), nil
+}
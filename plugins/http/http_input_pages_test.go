@@ -0,0 +1,273 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func HttpInputPagesSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "http-input-pages-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	pConfig := NewPipelineConfig(nil)
+	pConfig.Globals.BaseDir = tmpDir
+
+	c.Specify("A HttpInput", func() {
+		input := new(HttpInput)
+		input.SetName("scraper")
+		input.SetPipelineConfig(pConfig)
+		config := input.ConfigStruct().(*HttpInputConfig)
+
+		mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+		mockIR := pipelinemock.NewMockInputRunner(ctrl)
+		mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+
+		// Each handled request is reported on the channel.
+		requests := make(chan *http.Request, 10)
+		var handler func(w http.ResponseWriter, req *http.Request)
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				handler(w, req)
+				requests <- req
+			}))
+		defer server.Close()
+		config.Url = server.URL + "/items"
+
+		records := make(chan string, 10)
+		// Records that went through the splitter.
+		streamed := make(chan string, 10)
+		tickChan := make(chan time.Time)
+		runErr := make(chan error, 1)
+		startInput := func() {
+			mockIR.EXPECT().Ticker().Return(tickChan)
+			mockHelper.EXPECT().Hostname().Return("hekatests.example.com")
+			mockIR.EXPECT().NewSplitterRunner("0").Return(mockSR)
+			mockSR.EXPECT().UseMsgBytes().Return(false).AnyTimes()
+			mockSR.EXPECT().SetPackDecorator(gomock.Any()).AnyTimes()
+			mockSR.EXPECT().SplitStreamNullSplitterToEOF(gomock.Any(), nil).Do(
+				func(r io.Reader, del Deliverer) {
+					body, _ := ioutil.ReadAll(r)
+					records <- string(body)
+					streamed <- string(body)
+				}).Return(io.EOF).AnyTimes()
+			mockSR.EXPECT().DeliverRecord(gomock.Any(), nil).Do(
+				func(record []byte, del Deliverer) {
+					records <- string(record)
+				}).AnyTimes()
+			mockSR.EXPECT().Done()
+			go func() {
+				runErr <- input.Run(mockIR, mockHelper)
+			}()
+		}
+		stopInput := func() {
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+		}
+
+		c.Specify("rejects unknown pagination types", func() {
+			config.Pagination = "scroll"
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("requires a cursor field for cursor pagination", func() {
+			config.Pagination = "cursor"
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("follows Link headers", func() {
+			handler = func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("page") == "" {
+					w.Header().Add("Link", `</items?page=0>; rel="prev", `+
+						`</items?page=2>; rel="next"`)
+					fmt.Fprint(w, "page 1")
+				} else {
+					fmt.Fprint(w, "page "+req.URL.Query().Get("page"))
+				}
+			}
+			config.Pagination = "link"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput()
+			tickChan <- time.Now()
+
+			c.Expect(<-records, gs.Equals, "page 1")
+			c.Expect(<-records, gs.Equals, "page 2")
+			<-requests
+			req := <-requests
+			c.Expect(req.URL.String(), gs.Equals, "/items?page=2")
+			stopInput()
+			c.Expect(len(requests), gs.Equals, 0)
+		})
+
+		c.Specify("pages through cursors, splitting JSON arrays", func() {
+			handler = func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Query().Get("after") {
+				case "":
+					fmt.Fprint(w, `{"data": {"items": [{"id": 1}, {"id": 2}]}, "next": "c2"}`)
+				case "c2":
+					fmt.Fprint(w, `{"data": {"items": [{"id": 3}]}, "next": "c3"}`)
+				default:
+					fmt.Fprint(w, `{"data": {"items": []}, "next": null}`)
+				}
+			}
+			config.Pagination = "cursor"
+			config.CursorField = "next"
+			config.CursorParam = "after"
+			config.SplitJsonArray = true
+			config.JsonArrayPath = "data.items"
+
+			c.Specify("starting over on every tick", func() {
+				err := input.Init(config)
+				c.Assume(err, gs.IsNil)
+				startInput()
+				tickChan <- time.Now()
+
+				c.Expect(<-records, gs.Equals, `{"id": 1}`)
+				c.Expect(<-records, gs.Equals, `{"id": 2}`)
+				c.Expect(<-records, gs.Equals, `{"id": 3}`)
+				for i := 0; i < 3; i++ {
+					<-requests
+				}
+				tickChan <- time.Now()
+				c.Expect(<-records, gs.Equals, `{"id": 1}`)
+				stopInput()
+			})
+
+			c.Specify("resuming from persisted cursors", func() {
+				config.PersistCursor = true
+				err := input.Init(config)
+				c.Assume(err, gs.IsNil)
+				startInput()
+				tickChan <- time.Now()
+				for i := 0; i < 3; i++ {
+					<-records
+					<-requests
+				}
+				tickChan <- time.Now()
+				req := <-requests
+				c.Expect(req.URL.Query().Get("after"), gs.Equals, "c3")
+				stopInput()
+
+				statePath := filepath.Join(tmpDir, "http_input", "scraper.json")
+				states, err := loadUrlStates(statePath)
+				c.Expect(err, gs.IsNil)
+				c.Expect(states[config.Url].Cursor, gs.Equals, "c3")
+
+				restarted := new(HttpInput)
+				restarted.SetName("scraper")
+				restarted.SetPipelineConfig(pConfig)
+				err = restarted.Init(config)
+				c.Expect(err, gs.IsNil)
+				c.Expect(restarted.states[config.Url].Cursor, gs.Equals, "c3")
+			})
+		})
+
+		c.Specify("only delivers the records added to a persisted last page", func() {
+			fetches := 0
+			handler = func(w http.ResponseWriter, req *http.Request) {
+				fetches++
+				if fetches == 1 {
+					fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+				} else {
+					fmt.Fprint(w, `{"items": [{"id": 1}, {"id": 2}]}`)
+				}
+			}
+			config.Pagination = "cursor"
+			config.CursorField = "next"
+			config.PersistCursor = true
+			config.SplitJsonArray = true
+			config.JsonArrayPath = "items"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput()
+
+			tickChan <- time.Now()
+			<-requests
+			c.Expect(<-records, gs.Equals, `{"id": 1}`)
+			tickChan <- time.Now()
+			<-requests
+			c.Expect(<-records, gs.Equals, `{"id": 2}`)
+			tickChan <- time.Now()
+			<-requests
+			stopInput()
+			c.Expect(len(records), gs.Equals, 0)
+			c.Expect(input.states[config.Url].Delivered, gs.Equals, 2)
+		})
+
+		c.Specify("uses the splitter for unsplit cursor pages", func() {
+			handler = func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("cursor") == "" {
+					fmt.Fprint(w, `{"next": "c2"}`)
+				} else {
+					fmt.Fprint(w, `{"next": null}`)
+				}
+			}
+			config.Pagination = "cursor"
+			config.CursorField = "next"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput()
+
+			tickChan <- time.Now()
+			c.Expect(<-streamed, gs.Equals, `{"next": "c2"}`)
+			c.Expect(<-streamed, gs.Equals, `{"next": null}`)
+			stopInput()
+		})
+
+		c.Specify("skips unchanged responses", func() {
+			handler = func(w http.ResponseWriter, req *http.Request) {
+				if req.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, "content")
+			}
+			config.ConditionalRequests = true
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput()
+
+			tickChan <- time.Now()
+			<-requests
+			c.Expect(<-records, gs.Equals, "content")
+			tickChan <- time.Now()
+			req := <-requests
+			c.Expect(req.Header.Get("If-None-Match"), gs.Equals, `"v1"`)
+			stopInput()
+			c.Expect(len(records), gs.Equals, 0)
+		})
+	})
+}
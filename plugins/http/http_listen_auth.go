@@ -0,0 +1,387 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package http
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often a token file is checked for changes, at most.
+const tokenFileCheckInterval = time.Second
+
+var (
+	errNoCredentials = errors.New("no credentials provided")
+	errUnknownToken  = errors.New("unknown token")
+	errNoClientCert  = errors.New("no verified client certificate")
+)
+
+// Returns the token from a "Bearer" Authorization header, falling back to
+// the X-API-Key header.
+func bearerToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return req.Header.Get("X-API-Key")
+}
+
+// Named tokens read from a file holding one `<name> <token>` pair per line.
+// The file is reread whenever its modification time changes.
+type tokenFile struct {
+	path      string
+	lock      sync.Mutex
+	tokens    map[string]string // Name, keyed by token.
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newTokenFile(path string) (tf *tokenFile, err error) {
+	tf = &tokenFile{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = tf.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	tf.lastCheck = time.Now()
+	return tf, nil
+}
+
+func (tf *tokenFile) load(modTime time.Time) error {
+	file, err := os.Open(tf.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s:%d: expected '<name> <token>'", tf.path, lineNum)
+		}
+		if _, ok := tokens[parts[1]]; ok {
+			return fmt.Errorf("%s:%d: duplicate token", tf.path, lineNum)
+		}
+		tokens[parts[1]] = parts[0]
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	tf.tokens = tokens
+	tf.modTime = modTime
+	return nil
+}
+
+// Rereads the file if it changed since it was last loaded. The previously
+// loaded tokens stay in use if the new contents can't be read.
+func (tf *tokenFile) reload() error {
+	info, err := os.Stat(tf.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(tf.modTime) {
+		return nil
+	}
+	return tf.load(info.ModTime())
+}
+
+// Returns the name of the given token. The returned error is non-nil if
+// reloading the file failed, even when the token is known.
+func (tf *tokenFile) lookup(token string) (name string, reloadErr error) {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+	if now := time.Now(); now.Sub(tf.lastCheck) >= tokenFileCheckInterval {
+		tf.lastCheck = now
+		if err := tf.reload(); err != nil {
+			reloadErr = fmt.Errorf("reloading token file: %s", err)
+		}
+	}
+	for candidate, candidateName := range tf.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			name = candidateName
+		}
+	}
+	return name, reloadErr
+}
+
+// A symmetric key from a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	K   string `json:"k"`
+	key []byte
+}
+
+var jwtHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// Verifies HMAC signed JSON Web Tokens against the "oct" keys of a local
+// JWKS file.
+type jwtVerifier struct {
+	keys     []*jwk
+	issuer   string
+	audience string
+	claim    string
+	now      func() time.Time
+}
+
+func newJwtVerifier(jwksPath, issuer, audience, claim string) (*jwtVerifier, error) {
+	data, err := ioutil.ReadFile(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []*jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("parsing JWKS file: %s", err)
+	}
+	v := &jwtVerifier{
+		issuer:   issuer,
+		audience: audience,
+		claim:    claim,
+		now:      time.Now,
+	}
+	for _, key := range jwks.Keys {
+		if key.Kty != "oct" {
+			// Only HMAC keys are supported, other key types may be in the
+			// same set for other consumers.
+			continue
+		}
+		if key.key, err = base64.RawURLEncoding.DecodeString(
+			strings.TrimRight(key.K, "=")); err != nil {
+			return nil, fmt.Errorf("decoding JWKS key '%s': %s", key.Kid, err)
+		}
+		v.keys = append(v.keys, key)
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("JWKS file contains no 'oct' keys")
+	}
+	return v, nil
+}
+
+func (v *jwtVerifier) findKey(kid, alg string) *jwk {
+	for _, key := range v.keys {
+		if (key.Kid == kid || (kid == "" && len(v.keys) == 1)) &&
+			(key.Alg == "" || key.Alg == alg) {
+			return key
+		}
+	}
+	return nil
+}
+
+// Verifies the token's signature and claims, returning the value of the
+// identity claim.
+func (v *jwtVerifier) verify(token string) (identity string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = decodeJwtPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	newHash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported JWT algorithm '%s'", header.Alg)
+	}
+	key := v.findKey(header.Kid, header.Alg)
+	if key == nil {
+		return "", fmt.Errorf("no key for JWT key id '%s'", header.Kid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decoding JWT signature: %s", err)
+	}
+	mac := hmac.New(newHash, key.key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errors.New("invalid JWT signature")
+	}
+
+	var claims map[string]interface{}
+	if err = decodeJwtPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	now := float64(v.now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return "", errors.New("JWT expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return "", errors.New("JWT not yet valid")
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return "", fmt.Errorf("unexpected JWT issuer '%v'", claims["iss"])
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return "", errors.New("JWT not issued for this audience")
+	}
+	identity, _ = claims[v.claim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("JWT is missing the '%s' claim", v.claim)
+	}
+	return identity, nil
+}
+
+func decodeJwtPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("decoding JWT: %s", err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding JWT: %s", err)
+	}
+	return nil
+}
+
+// The "aud" claim can either be a single string or a list of them.
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checks the request's credentials according to the configured auth_type,
+// returning the authenticated identity, if there is one.
+func (hli *HttpListenInput) authenticate(req *http.Request) (identity string,
+	err error) {
+
+	switch hli.conf.AuthType {
+	case "Basic":
+		if hli.conf.Username != "" && hli.conf.Password != "" {
+			user, pass, ok := req.BasicAuth()
+			if !ok || user != hli.conf.Username || pass != hli.conf.Password {
+				return "", errors.New("Basic Auth Failed")
+			}
+			identity = user
+		}
+	case "API":
+		if hli.conf.Key != "" {
+			api_key := req.Header.Get("X-API-Key")
+			if api_key != hli.conf.Key {
+				return "", errors.New("API Auth Failed")
+			}
+		}
+	case "Token":
+		token := bearerToken(req)
+		if token == "" {
+			return "", errNoCredentials
+		}
+		identity, err = hli.tokens.lookup(token)
+		if err != nil {
+			hli.ir.LogError(err)
+		}
+		if identity == "" {
+			return "", errUnknownToken
+		}
+	case "JWT":
+		token := bearerToken(req)
+		if token == "" {
+			return "", errNoCredentials
+		}
+		if identity, err = hli.jwt.verify(token); err != nil {
+			return "", err
+		}
+	case "Cert":
+		if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+			return "", errNoClientCert
+		}
+		identity = req.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	return identity, nil
+}
+
+// Sets up the token file, JWKS and TLS settings the auth_type needs.
+func (hli *HttpListenInput) setupAuth() (err error) {
+	conf := hli.conf
+	switch conf.AuthType {
+	case "", "Basic", "API":
+	case "Token":
+		if conf.TokenFile == "" {
+			return errors.New("auth_type 'Token' requires a token_file")
+		}
+		if hli.tokens, err = newTokenFile(conf.TokenFile); err != nil {
+			return fmt.Errorf("loading token file: %s", err)
+		}
+	case "JWT":
+		if conf.JwksFile == "" {
+			return errors.New("auth_type 'JWT' requires a jwks_file")
+		}
+		if hli.jwt, err = newJwtVerifier(conf.JwksFile, conf.JwtIssuer,
+			conf.JwtAudience, conf.JwtIdentityClaim); err != nil {
+			return fmt.Errorf("loading JWKS file: %s", err)
+		}
+	case "Cert":
+		if !conf.UseTls || conf.Tls.ClientCAs == "" {
+			return errors.New("auth_type 'Cert' requires use_tls and a tls client_cafile")
+		}
+		if conf.Tls.ClientAuth == "" {
+			conf.Tls.ClientAuth = "RequireAndVerifyClientCert"
+		}
+	default:
+		return fmt.Errorf("unknown auth_type '%s'", conf.AuthType)
+	}
+	return nil
+}
+
+// Value of the WWW-Authenticate header sent along with a 401.
+func (hli *HttpListenInput) authChallenge() string {
+	switch hli.conf.AuthType {
+	case "Basic":
+		return `Basic realm="heka"`
+	case "Token", "JWT":
+		return `Bearer realm="heka"`
+	}
+	return ""
+}
@@ -0,0 +1,240 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+// Builds an HS256 signed JWT with the given key id and claims.
+func signJwt(kid string, key []byte, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func HttpListenAuthSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "http-listen-auth-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+
+	c.Specify("A HttpListenInput", func() {
+		input := HttpListenInput{}
+		config := input.ConfigStruct().(*HttpListenInputConfig)
+		mockIR := pipelinemock.NewMockInputRunner(ctrl)
+		input.ir = mockIR
+
+		request := func(header, value string) *http.Request {
+			req, _ := http.NewRequest("POST", "http://localhost/", nil)
+			if header != "" {
+				req.Header.Set(header, value)
+			}
+			return req
+		}
+
+		c.Specify("rejects unknown auth types", func() {
+			config.AuthType = "Magic"
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("with token auth", func() {
+			config.AuthType = "Token"
+			config.TokenFile = filepath.Join(tmpDir, "tokens")
+			err := ioutil.WriteFile(config.TokenFile, []byte(
+				"# tenant token\nalpha s3cr3t\nbeta  0ther\n"), 0600)
+			c.Assume(err, gs.IsNil)
+			err = input.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			c.Specify("identifies bearer tokens by name", func() {
+				identity, err := input.authenticate(request("Authorization", "Bearer s3cr3t"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "alpha")
+				identity, err = input.authenticate(request("X-API-Key", "0ther"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "beta")
+			})
+
+			c.Specify("rejects unknown or missing tokens", func() {
+				_, err := input.authenticate(request("Authorization", "Bearer nope"))
+				c.Expect(err, gs.Equals, errUnknownToken)
+				_, err = input.authenticate(request("", ""))
+				c.Expect(err, gs.Equals, errNoCredentials)
+			})
+
+			c.Specify("reloads the token file when it changes", func() {
+				err := ioutil.WriteFile(config.TokenFile, []byte("gamma n3w\n"), 0600)
+				c.Assume(err, gs.IsNil)
+				later := time.Now().Add(time.Minute)
+				c.Assume(os.Chtimes(config.TokenFile, later, later), gs.IsNil)
+				input.tokens.lastCheck = time.Time{}
+
+				identity, err := input.authenticate(request("X-API-Key", "n3w"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "gamma")
+				_, err = input.authenticate(request("X-API-Key", "s3cr3t"))
+				c.Expect(err, gs.Equals, errUnknownToken)
+			})
+
+			c.Specify("keeps the old tokens if the file becomes invalid", func() {
+				err := ioutil.WriteFile(config.TokenFile, []byte("broken\n"), 0600)
+				c.Assume(err, gs.IsNil)
+				later := time.Now().Add(time.Minute)
+				c.Assume(os.Chtimes(config.TokenFile, later, later), gs.IsNil)
+				input.tokens.lastCheck = time.Time{}
+
+				mockIR.EXPECT().LogError(gomock.Any())
+				identity, err := input.authenticate(request("X-API-Key", "s3cr3t"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "alpha")
+			})
+		})
+
+		c.Specify("with JWT auth", func() {
+			key := []byte("0123456789abcdef0123456789abcdef")
+			jwks := `{"keys": [
+				{"kty": "RSA", "kid": "other", "n": "AQAB", "e": "AQAB"},
+				{"kty": "oct", "kid": "k1", "alg": "HS256", "k": "` +
+				base64.RawURLEncoding.EncodeToString(key) + `"}]}`
+			config.AuthType = "JWT"
+			config.JwksFile = filepath.Join(tmpDir, "jwks.json")
+			config.JwtAudience = "heka"
+			err := ioutil.WriteFile(config.JwksFile, []byte(jwks), 0600)
+			c.Assume(err, gs.IsNil)
+			err = input.Init(config)
+			c.Assume(err, gs.IsNil)
+			now := time.Now()
+			claims := map[string]interface{}{
+				"sub": "tenant-42",
+				"aud": []string{"heka", "other"},
+				"exp": now.Add(time.Hour).Unix(),
+			}
+
+			c.Specify("uses the subject as identity", func() {
+				token := signJwt("k1", key, claims)
+				identity, err := input.authenticate(request("Authorization", "Bearer "+token))
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "tenant-42")
+			})
+
+			c.Specify("rejects invalid signatures", func() {
+				token := signJwt("k1", []byte("wrong key"), claims)
+				_, err := input.authenticate(request("Authorization", "Bearer "+token))
+				c.Expect(err, gs.Not(gs.IsNil))
+				token = signJwt("unknown", key, claims)
+				_, err = input.authenticate(request("Authorization", "Bearer "+token))
+				c.Expect(err, gs.Not(gs.IsNil))
+			})
+
+			c.Specify("rejects expired tokens", func() {
+				claims["exp"] = now.Add(-time.Minute).Unix()
+				token := signJwt("k1", key, claims)
+				_, err := input.authenticate(request("Authorization", "Bearer "+token))
+				c.Expect(err, gs.Not(gs.IsNil))
+			})
+
+			c.Specify("rejects tokens for other audiences", func() {
+				claims["aud"] = "elsewhere"
+				token := signJwt("k1", key, claims)
+				_, err := input.authenticate(request("Authorization", "Bearer "+token))
+				c.Expect(err, gs.Not(gs.IsNil))
+			})
+		})
+
+		c.Specify("with client certificate auth", func() {
+			config.AuthType = "Cert"
+
+			c.Specify("requires TLS with client CAs", func() {
+				err := input.Init(config)
+				c.Expect(err, gs.Not(gs.IsNil))
+			})
+
+			c.Specify("uses the certificate's common name as identity", func() {
+				config.UseTls = true
+				config.Tls.ClientCAs = "ca.pem"
+				err := input.Init(config)
+				c.Assume(err, gs.IsNil)
+				c.Expect(config.Tls.ClientAuth, gs.Equals, "RequireAndVerifyClientCert")
+
+				req := request("", "")
+				_, err = input.authenticate(req)
+				c.Expect(err, gs.Equals, errNoClientCert)
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: "collector-1"}}
+				req.TLS = &tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{cert}},
+				}
+				identity, err := input.authenticate(req)
+				c.Expect(err, gs.IsNil)
+				c.Expect(identity, gs.Equals, "collector-1")
+			})
+		})
+
+		c.Specify("answers failed authentication with a 401", func() {
+			config.AuthType = "Basic"
+			config.Username = "foo"
+			config.Password = "bar"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			mockIR.EXPECT().LogError(gomock.Any())
+			req := request("", "")
+			req.SetBasicAuth("foo", "baz")
+			recorder := httptest.NewRecorder()
+			input.RequestHandler(recorder, req)
+			c.Expect(recorder.Code, gs.Equals, http.StatusUnauthorized)
+			c.Expect(recorder.Header().Get("WWW-Authenticate"), gs.Equals,
+				`Basic realm="heka"`)
+		})
+
+		c.Specify("adds the identity to messages", func() {
+			config.IdentityField = "Tenant"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+
+			pack := NewPipelinePack(make(chan *PipelinePack, 1))
+			input.makePackDecorator(request("", ""), "tenant-42")(pack)
+			tenant, ok := pack.Message.GetFieldValue("Tenant")
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(tenant, gs.Equals, "tenant-42")
+		})
+	})
+}
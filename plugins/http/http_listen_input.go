@@ -17,28 +17,49 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
 	. "github.com/mozilla-services/heka/plugins/tcp"
 )
 
+var errBodyTooLarge = errors.New("request body exceeds max_body_size")
+
 type HttpListenInput struct {
 	conf        *HttpListenInputConfig
 	listener    net.Listener
 	stopChan    chan bool
 	ir          InputRunner
+	pConfig     *PipelineConfig
 	server      *http.Server
 	starterFunc func(hli *HttpListenInput) error
 	hekaPid     int32
 	hostname    string
+	// Route path patterns, longest first, and their deliverers.
+	routePaths      []string
+	routes          map[string]Deliverer
+	routeDeliverers []Deliverer
+	tokens          *tokenFile
+	jwt             *jwtVerifier
+	// Requests in flight, which have to finish before the route deliverers
+	// can be released.
+	requests     sync.WaitGroup
+	requestsLock sync.Mutex
+	stopping     bool
 }
 
 // HTTP Listen Input config struct
@@ -52,18 +73,62 @@ type HttpListenInputConfig struct {
 	Username       string   `toml:"username"`
 	Password       string   `toml:"password"`
 	Key            string   `toml:"api_key"`
+	// File of named bearer tokens accepted if auth_type is "Token".
+	TokenFile string `toml:"token_file"`
+	// JWKS file holding the HMAC keys used to verify bearer JWTs if
+	// auth_type is "JWT", along with the claims to check.
+	JwksFile         string `toml:"jwks_file"`
+	JwtIssuer        string `toml:"jwt_issuer"`
+	JwtAudience      string `toml:"jwt_audience"`
+	JwtIdentityClaim string `toml:"jwt_identity_claim"`
+	// Message field the authenticated identity is stored in.
+	IdentityField string `toml:"identity_field"`
 	// Set to true if the TCP connection should be tunneled through TLS.
 	// Requires additional Tls config section.
 	UseTls bool `toml:"use_tls"`
 	// Subsection for TLS configuration.
 	Tls TlsConfig
+	// Maximum size of a (decompressed) request body in bytes, larger
+	// requests are answered with a 413. 0 means no limit.
+	MaxBodySize int64 `toml:"max_body_size"`
+	// Maps request paths to the decoder used for their bodies, paths ending
+	// in a slash match all paths below them. Requests to paths without a
+	// route use the input's decoder.
+	Routes map[string]string `toml:"routes"`
+	// Status code (429 or 503) used to turn requests away while the pack
+	// pool or the router are saturated. 0 means requests wait until the
+	// pipeline catches up.
+	BusyStatusCode int `toml:"busy_status_code"`
+}
+
+// Reader that fails once more than `remaining` bytes are read.
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (n int, err error) {
+	if l.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err = l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
 }
 
 func (hli *HttpListenInput) ConfigStruct() interface{} {
 	config := &HttpListenInputConfig{
-		Address:        "127.0.0.1:8325",
-		Headers:        make(http.Header),
-		RequestHeaders: []string{},
+		Address:          "127.0.0.1:8325",
+		Headers:          make(http.Header),
+		RequestHeaders:   []string{},
+		JwtIdentityClaim: "sub",
+		IdentityField:    "Identity",
 	}
 	config.Tls = TlsConfig{PreferServerCiphers: true}
 	return config
@@ -86,6 +151,12 @@ func defaultStarter(hli *HttpListenInput) (err error) {
 	}
 
 	err = hli.server.Serve(hli.listener)
+	select {
+	case <-hli.stopChan:
+		// Stop closed the listener, which is how Serve ends.
+		return nil
+	default:
+	}
 	if err != nil {
 		return fmt.Errorf("Serve fail: %s", err.Error())
 	}
@@ -112,7 +183,9 @@ func (hli *HttpListenInput) makeField(name string, value string) (field *message
 	return
 }
 
-func (hli *HttpListenInput) makePackDecorator(req *http.Request) func(*PipelinePack) {
+func (hli *HttpListenInput) makePackDecorator(req *http.Request,
+	identity string) func(*PipelinePack) {
+
 	packDecorator := func(pack *PipelinePack) {
 		pack.Message.SetType("heka.httpdata.request")
 		pack.Message.SetPid(hli.hekaPid)
@@ -152,6 +225,11 @@ func (hli *HttpListenInput) makePackDecorator(req *http.Request) func(*PipelineP
 		if field, err := hli.makeField("RemoteAddr", host); err == nil {
 			pack.Message.AddField(field)
 		}
+		if identity != "" {
+			if field, err := hli.makeField(hli.conf.IdentityField, identity); err == nil {
+				pack.Message.AddField(field)
+			}
+		}
 		for _, key := range hli.conf.RequestHeaders {
 			value := req.Header.Get(key)
 			if len(value) == 0 {
@@ -172,47 +250,168 @@ func (hli *HttpListenInput) makePackDecorator(req *http.Request) func(*PipelineP
 	return packDecorator
 }
 
+// Registers a request as being in flight, unless the input is stopping.
+func (hli *HttpListenInput) startRequest() bool {
+	hli.requestsLock.Lock()
+	defer hli.requestsLock.Unlock()
+	if hli.stopping {
+		return false
+	}
+	hli.requests.Add(1)
+	return true
+}
+
 func (hli *HttpListenInput) RequestHandler(w http.ResponseWriter, req *http.Request) {
-	var err error
-
-	if hli.conf.AuthType == "Basic" {
-		if hli.conf.Username != "" && hli.conf.Password != "" {
-			user, pass, ok := req.BasicAuth()
-			if !ok || user != hli.conf.Username || pass != hli.conf.Password {
-				err = fmt.Errorf("Basic Auth Failed")
-				hli.ir.LogError(err)
-			}
-		}
+	if !hli.startRequest() {
+		// Kept alive connections can still send requests after the listener
+		// is closed.
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
 	}
-	if hli.conf.AuthType == "API" {
-		if hli.conf.Key != "" {
-			api_key := req.Header.Get("X-API-Key")
-			if api_key != hli.conf.Key {
-				err = fmt.Errorf("API Auth Failed")
-				hli.ir.LogError(err)
-			}
+	defer hli.requests.Done()
+
+	identity, err := hli.authenticate(req)
+	if err != nil {
+		hli.ir.LogError(fmt.Errorf("authenticating %s: %s", req.RemoteAddr, err))
+		if challenge := hli.authChallenge(); challenge != "" {
+			w.Header().Set("WWW-Authenticate", challenge)
 		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if hli.conf.BusyStatusCode != 0 && hli.busy() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy", hli.conf.BusyStatusCode)
+		return
+	}
+	if hli.conf.MaxBodySize > 0 && req.ContentLength > hli.conf.MaxBodySize {
+		http.Error(w, errBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
 	}
-	if err == nil {
-		sRunner := hli.ir.NewSplitterRunner(req.RemoteAddr)
-		if !sRunner.UseMsgBytes() {
-			sRunner.SetPackDecorator(hli.makePackDecorator(req))
+	defer req.Body.Close()
+
+	var body io.Reader = req.Body
+	switch strings.ToLower(req.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gzReader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid gzip body: %s", err), http.StatusBadRequest)
+			return
+		}
+		defer gzReader.Close()
+		body = gzReader
+	case "deflate":
+		zReader, err := zlib.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid deflate body: %s", err), http.StatusBadRequest)
+			return
 		}
-		err = sRunner.SplitStreamNullSplitterToEOF(req.Body, nil)
-		if err != nil && err != io.EOF {
+		defer zReader.Close()
+		body = zReader
+	default:
+		http.Error(w, "unsupported Content-Encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+	if hli.conf.MaxBodySize > 0 {
+		// The whole body is read before anything is delivered, so a body
+		// that turns out to be too large once decompressed is rejected as a
+		// whole.
+		data, err := ioutil.ReadAll(&limitedBody{r: body, remaining: hli.conf.MaxBodySize})
+		if err == errBodyTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
 			hli.ir.LogError(fmt.Errorf("receiving request body: %s", err.Error()))
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		body = bytes.NewReader(data)
+	}
+
+	sRunner := hli.ir.NewSplitterRunner(req.RemoteAddr)
+	if !sRunner.UseMsgBytes() {
+		sRunner.SetPackDecorator(hli.makePackDecorator(req, identity))
+	}
+	err = sRunner.SplitStreamNullSplitterToEOF(body, hli.route(req.URL.Path))
+	sRunner.Done()
+	if err != nil && err != io.EOF {
+		hli.ir.LogError(fmt.Errorf("receiving request body: %s", err.Error()))
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+	}
+}
+
+// Returns the deliverer for the longest route matching the path, or nil to
+// use the input's own.
+func (hli *HttpListenInput) route(path string) Deliverer {
+	for _, routePath := range hli.routePaths {
+		if path == routePath ||
+			(strings.HasSuffix(routePath, "/") && strings.HasPrefix(path, routePath)) {
+			return hli.routes[routePath]
+		}
+	}
+	return nil
+}
+
+// Checks if there are no packs to be had, or if the router isn't keeping up.
+func (hli *HttpListenInput) busy() bool {
+	if len(hli.ir.InChan()) == 0 {
+		return true
+	}
+	routerChan := hli.pConfig.Router().InChan()
+	return len(routerChan) == cap(routerChan)
+}
+
+// Sets up a Deliverer for each decoder used in the routes.
+func (hli *HttpListenInput) setupRoutes() error {
+	hli.routes = make(map[string]Deliverer)
+	deliverers := make(map[string]Deliverer)
+	for path, decoderName := range hli.conf.Routes {
+		deliverer, ok := deliverers[decoderName]
+		if !ok {
+			var err error
+			if deliverer, err = hli.ir.NewDecoderDeliverer("route", decoderName); err != nil {
+				hli.releaseRoutes()
+				return fmt.Errorf("route '%s': %s", path, err)
+			}
+			deliverers[decoderName] = deliverer
+			hli.routeDeliverers = append(hli.routeDeliverers, deliverer)
 		}
-		req.Body.Close()
-		sRunner.Done()
+		hli.routes[path] = deliverer
 	}
+	return nil
+}
+
+func (hli *HttpListenInput) releaseRoutes() {
+	for _, deliverer := range hli.routeDeliverers {
+		deliverer.Done()
+	}
+	hli.routeDeliverers = nil
 }
 
 func (hli *HttpListenInput) Init(config interface{}) (err error) {
 	hli.conf = config.(*HttpListenInputConfig)
+	if hli.conf.BusyStatusCode != 0 && hli.conf.BusyStatusCode != 429 &&
+		hli.conf.BusyStatusCode != 503 {
+		return errors.New("busy_status_code must be 429 or 503")
+	}
+	hli.routePaths = make([]string, 0, len(hli.conf.Routes))
+	for path := range hli.conf.Routes {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("route path '%s' must start with a slash", path)
+		}
+		hli.routePaths = append(hli.routePaths, path)
+	}
+	sort.Sort(sort.Reverse(byLength(hli.routePaths)))
+	if err = hli.setupAuth(); err != nil {
+		return err
+	}
 	if hli.starterFunc == nil {
 		hli.starterFunc = defaultStarter
 	}
 	hli.stopChan = make(chan bool, 1)
+	hli.stopping = false
 
 	handler := http.HandlerFunc(hli.RequestHandler)
 	hli.server = &http.Server{
@@ -224,25 +423,40 @@ func (hli *HttpListenInput) Init(config interface{}) (err error) {
 
 func (hli *HttpListenInput) Run(ir InputRunner, h PluginHelper) (err error) {
 	hli.ir = ir
+	hli.pConfig = h.PipelineConfig()
 	var hostname, _ = os.Hostname()
 	hli.hostname = hostname
-	err = hli.starterFunc(hli)
-	if err != nil {
+	if err = hli.setupRoutes(); err != nil {
 		return err
 	}
+	defer hli.releaseRoutes()
+	if err = hli.starterFunc(hli); err == nil {
+		<-hli.stopChan
+	}
 
-	<-hli.stopChan
-
-	return nil
+	// Requests still in flight deliver to the routes, so they have to finish
+	// before the routes are released.
+	hli.requestsLock.Lock()
+	hli.stopping = true
+	hli.requestsLock.Unlock()
+	hli.requests.Wait()
+	return err
 }
 
 func (hli *HttpListenInput) Stop() {
+	// Closed first, so the starter knows the listener was closed on purpose.
+	close(hli.stopChan)
 	if hli.listener != nil {
 		hli.listener.Close()
 	}
-	close(hli.stopChan)
 }
 
+type byLength []string
+
+func (b byLength) Len() int           { return len(b) }
+func (b byLength) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byLength) Less(i, j int) bool { return len(b[i]) < len(b[j]) }
+
 func init() {
 	RegisterPlugin("HttpListenInput", func() interface{} {
 		return new(HttpListenInput)
@@ -17,7 +17,10 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/tls"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net"
@@ -26,6 +29,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	. "github.com/mozilla-services/heka/pipeline"
 	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
@@ -76,6 +80,8 @@ func HttpListenInputSpec(c gs.Context) {
 			return nil
 		}
 
+		ith.MockHelper.EXPECT().PipelineConfig().Return(pConfig).AnyTimes()
+
 		// These EXPECTs imply that every spec below will send exactly one
 		// HTTP request to the input.
 		ith.MockInputRunner.EXPECT().NewSplitterRunner(gomock.Any()).Return(
@@ -326,3 +332,260 @@ func HttpListenInputSpec(c gs.Context) {
 
 	})
 }
+
+func HttpListenInputBodySpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	pConfig := NewPipelineConfig(nil)
+
+	mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+
+	c.Specify("A HttpListenInput", func() {
+		input := HttpListenInput{}
+		config := input.ConfigStruct().(*HttpListenInputConfig)
+		config.Address = "127.0.0.1:0"
+
+		ts := httptest.NewUnstartedServer(nil)
+		startedChan := make(chan bool, 1)
+		input.starterFunc = func(hli *HttpListenInput) error {
+			ts.Start()
+			startedChan <- true
+			return nil
+		}
+
+		packSupply := make(chan *PipelinePack, 1)
+		packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+		mockHelper.EXPECT().PipelineConfig().Return(pConfig).AnyTimes()
+		mockIR.EXPECT().InChan().Return(packSupply).AnyTimes()
+		mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+
+		errChan := make(chan error, 1)
+		startInput := func() {
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			ts.Config = input.server
+			go func() {
+				errChan <- input.Run(mockIR, mockHelper)
+			}()
+			<-startedChan
+		}
+
+		expectSplit := func(split func(r io.Reader, del Deliverer)) *gomock.Call {
+			mockIR.EXPECT().NewSplitterRunner(gomock.Any()).Return(mockSR)
+			mockSR.EXPECT().UseMsgBytes().Return(true)
+			mockSR.EXPECT().Done()
+			return mockSR.EXPECT().SplitStreamNullSplitterToEOF(gomock.Any(),
+				gomock.Any()).Do(split)
+		}
+
+		bodyChan := make(chan []byte, 1)
+		readBody := func(r io.Reader, del Deliverer) {
+			body, _ := ioutil.ReadAll(r)
+			bodyChan <- body
+		}
+
+		c.Specify("decompresses gzipped bodies", func() {
+			expectSplit(readBody).Return(io.EOF)
+			startInput()
+
+			buf := new(bytes.Buffer)
+			gzWriter := gzip.NewWriter(buf)
+			gzWriter.Write([]byte("compressed body"))
+			gzWriter.Close()
+			req, err := http.NewRequest("POST", ts.URL, buf)
+			c.Assume(err, gs.IsNil)
+			req.Header.Set("Content-Encoding", "gzip")
+			resp, err := http.DefaultClient.Do(req)
+			c.Assume(err, gs.IsNil)
+			resp.Body.Close()
+			c.Expect(resp.StatusCode, gs.Equals, 200)
+			c.Expect(string(<-bodyChan), gs.Equals, "compressed body")
+		})
+
+		c.Specify("rejects unknown content encodings", func() {
+			startInput()
+			req, err := http.NewRequest("POST", ts.URL, strings.NewReader("data"))
+			c.Assume(err, gs.IsNil)
+			req.Header.Set("Content-Encoding", "br")
+			resp, err := http.DefaultClient.Do(req)
+			c.Assume(err, gs.IsNil)
+			resp.Body.Close()
+			c.Expect(resp.StatusCode, gs.Equals, http.StatusUnsupportedMediaType)
+		})
+
+		c.Specify("rejects bodies over max_body_size", func() {
+			config.MaxBodySize = 40
+
+			c.Specify("by their Content-Length", func() {
+				startInput()
+				resp, err := http.Post(ts.URL, "text/plain", strings.NewReader(strings.Repeat("a", 41)))
+				c.Assume(err, gs.IsNil)
+				resp.Body.Close()
+				c.Expect(resp.StatusCode, gs.Equals, http.StatusRequestEntityTooLarge)
+			})
+
+			c.Specify("once decompressed, without delivering any of it", func() {
+				// No splitter is expected to be used.
+				startInput()
+
+				buf := new(bytes.Buffer)
+				gzWriter := gzip.NewWriter(buf)
+				// Compresses to well below the limit.
+				gzWriter.Write([]byte(strings.Repeat("a", 1000)))
+				gzWriter.Close()
+				req, err := http.NewRequest("POST", ts.URL, buf)
+				c.Assume(err, gs.IsNil)
+				req.Header.Set("Content-Encoding", "gzip")
+				resp, err := http.DefaultClient.Do(req)
+				c.Assume(err, gs.IsNil)
+				resp.Body.Close()
+				c.Expect(resp.StatusCode, gs.Equals, http.StatusRequestEntityTooLarge)
+			})
+
+			c.Specify("but delivers smaller ones", func() {
+				expectSplit(readBody).Return(io.EOF)
+				startInput()
+				resp, err := http.Post(ts.URL, "text/plain", strings.NewReader("data"))
+				c.Assume(err, gs.IsNil)
+				resp.Body.Close()
+				c.Expect(resp.StatusCode, gs.Equals, 200)
+				c.Expect(string(<-bodyChan), gs.Equals, "data")
+			})
+		})
+
+		c.Specify("routes paths to their decoders", func() {
+			config.Routes = map[string]string{
+				"/json/":     "JsonDecoder",
+				"/json/raw":  "RawDecoder",
+				"/ingest/v2": "JsonDecoder",
+			}
+			jsonDel := pipelinemock.NewMockDeliverer(ctrl)
+			mockIR.EXPECT().NewDecoderDeliverer("route", "JsonDecoder").Return(
+				jsonDel, nil)
+			rawDel := pipelinemock.NewMockDeliverer(ctrl)
+			mockIR.EXPECT().NewDecoderDeliverer("route", "RawDecoder").Return(
+				rawDel, nil)
+
+			delChan := make(chan Deliverer, 1)
+			expectSplit(func(r io.Reader, del Deliverer) {
+				delChan <- del
+			}).Return(io.EOF)
+			startInput()
+
+			c.Expect(input.route("/json/raw"), gs.Equals, input.routes["/json/raw"])
+			c.Expect(input.route("/ingest/v2/x"), gs.IsNil)
+			c.Expect(input.route("/other"), gs.IsNil)
+
+			resp, err := http.Post(ts.URL+"/json/events", "application/json",
+				strings.NewReader("{}"))
+			c.Assume(err, gs.IsNil)
+			resp.Body.Close()
+			c.Expect(resp.StatusCode, gs.Equals, 200)
+			c.Expect(<-delChan, gs.Equals, jsonDel)
+
+			// The deliverers are released once the input has stopped.
+			jsonDel.EXPECT().Done()
+			rawDel.EXPECT().Done()
+		})
+
+		c.Specify("fails to start with unknown route decoders", func() {
+			config.Routes = map[string]string{"/json/": "JsonDecoder"}
+			mockIR.EXPECT().NewDecoderDeliverer("route", "JsonDecoder").Return(
+				nil, errors.New("decoder 'JsonDecoder' not registered"))
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			err = input.Run(mockIR, mockHelper)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("with the default starter", func() {
+			// Find a free port for the input to listen on.
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			c.Assume(err, gs.IsNil)
+			config.Address = listener.Addr().String()
+			listener.Close()
+			config.Routes = map[string]string{"/json/": "JsonDecoder"}
+			input.starterFunc = nil
+			mockIR.EXPECT().LogMessage(gomock.Any())
+
+			jsonDel := pipelinemock.NewMockDeliverer(ctrl)
+			mockIR.EXPECT().NewDecoderDeliverer("route", "JsonDecoder").Return(
+				jsonDel, nil)
+			released := make(chan bool, 1)
+			jsonDel.EXPECT().Done().Do(func() {
+				released <- true
+			})
+
+			splitting := make(chan bool, 1)
+			finishSplit := make(chan bool)
+			expectSplit(func(r io.Reader, del Deliverer) {
+				splitting <- true
+				<-finishSplit
+			}).Return(io.EOF)
+
+			err = input.Init(config)
+			c.Assume(err, gs.IsNil)
+			go func() {
+				errChan <- input.Run(mockIR, mockHelper)
+			}()
+
+			c.Specify("waits for requests in flight before releasing the routes", func() {
+				respChan := make(chan int, 1)
+				go func() {
+					url := "http://" + config.Address + "/json/events"
+					for i := 0; i < 100; i++ {
+						resp, err := http.Post(url, "application/json", strings.NewReader("{}"))
+						if err == nil {
+							resp.Body.Close()
+							respChan <- resp.StatusCode
+							return
+						}
+						time.Sleep(20 * time.Millisecond)
+					}
+					respChan <- 0
+				}()
+				<-splitting
+
+				input.Stop()
+				select {
+				case err = <-errChan:
+					c.Expect(err, gs.Equals, "stopped before the request finished")
+				case <-released:
+					c.Expect("released", gs.Equals, "kept until the request finished")
+				case <-time.After(100 * time.Millisecond):
+				}
+
+				close(finishSplit)
+				c.Expect(<-respChan, gs.Equals, 200)
+				c.Expect(<-errChan, gs.IsNil)
+				c.Expect(<-released, gs.IsTrue)
+			})
+		})
+
+		c.Specify("turns requests away when busy", func() {
+			config.BusyStatusCode = 429
+			<-packSupply
+			startInput()
+			resp, err := http.Post(ts.URL, "text/plain", strings.NewReader("data"))
+			c.Assume(err, gs.IsNil)
+			resp.Body.Close()
+			c.Expect(resp.StatusCode, gs.Equals, 429)
+			c.Expect(resp.Header.Get("Retry-After"), gs.Equals, "1")
+		})
+
+		c.Specify("rejects other busy status codes", func() {
+			config.BusyStatusCode = 500
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		if input.server != nil && ts.Config == input.server {
+			ts.Close()
+			input.Stop()
+			c.Expect(<-errChan, gs.IsNil)
+		}
+	})
+}
@@ -16,25 +16,57 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/mozilla-services/heka/message"
 	"github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins"
 	"github.com/mozilla-services/heka/plugins/tcp"
 )
 
+// Matches the `%{name}` references in a templated address.
+var addressVarRegex = regexp.MustCompile(`%\{[^}]*\}`)
+
+// Pending request data for a single URL.
+type httpBatch struct {
+	url   *url.URL
+	body  bytes.Buffer
+	count int64
+}
+
 type HttpOutput struct {
 	*HttpOutputConfig
 	url          *url.URL
 	client       *http.Client
 	useBasicAuth bool
 	sendBody     bool
+	templated    bool
+	// Pending batches keyed by URL, and the order in which they were
+	// started.
+	batches     map[string]*httpBatch
+	batchOrder  []string
+	batchCount  uint32
+	batchCursor string
+	tickChan    <-chan time.Time
+	// Set while batches that failed to be sent are waiting to be retried.
+	retryPending bool
+
+	sentMessageCount    int64
+	dropMessageCount    int64
+	requestCount        int64
+	requestFailureCount int64
+	retryCount          int64
 }
 
 type HttpOutputConfig struct {
@@ -45,19 +77,45 @@ type HttpOutputConfig struct {
 	Username    string `toml:"username"`
 	Password    string `toml:"password"`
 	Tls         tcp.TlsConfig
+	// Number of messages to send in each request.
+	BatchSize uint32 `toml:"batch_size"`
+	// Maximum time (in milliseconds) a partial batch waits before it's sent.
+	BatchTimeout uint32 `toml:"batch_timeout"`
+	// How batched messages are combined, either "delimited" or "json_array".
+	BatchFormat string `toml:"batch_format"`
+	// Appended to each message in a "delimited" batch.
+	BatchDelimiter string `toml:"batch_delimiter"`
+	// Whether or not to gzip request bodies.
+	Gzip bool
+	// Number of times a failed request is retried.
+	MaxRetries uint32 `toml:"max_retries"`
+	// Initial delay (in milliseconds) before retrying a failed request,
+	// doubled after each attempt.
+	RetryInterval uint32 `toml:"retry_interval"`
+	// Upper limit (in milliseconds) on the delay between retries.
+	MaxRetryInterval uint32 `toml:"max_retry_interval"`
 }
 
 func (o *HttpOutput) ConfigStruct() interface{} {
 	return &HttpOutputConfig{
-		HttpTimeout: 0,
-		Headers:     make(http.Header),
-		Method:      "POST",
+		HttpTimeout:      0,
+		Headers:          make(http.Header),
+		Method:           "POST",
+		BatchSize:        1,
+		BatchTimeout:     1000,
+		BatchFormat:      "delimited",
+		BatchDelimiter:   "\n",
+		RetryInterval:    1000,
+		MaxRetryInterval: 30000,
 	}
 }
 
 func (o *HttpOutput) Init(config interface{}) (err error) {
 	o.HttpOutputConfig = config.(*HttpOutputConfig)
-	if o.url, err = url.Parse(o.Address); err != nil {
+	o.templated = plugins.IsMessageTemplate(o.Address)
+	// Templated addresses are checked with the references replaced by a
+	// placeholder, since they're not valid URLs as is.
+	if o.url, err = url.Parse(addressVarRegex.ReplaceAllString(o.Address, "x")); err != nil {
 		return fmt.Errorf("Can't parse URL '%s': %s", o.Address, err.Error())
 	}
 	if o.url.Scheme != "http" && o.url.Scheme != "https" {
@@ -70,6 +128,16 @@ func (o *HttpOutput) Init(config interface{}) (err error) {
 	if o.Method != "GET" {
 		o.sendBody = true
 	}
+	if o.BatchSize == 0 {
+		o.BatchSize = 1
+	}
+	if o.BatchSize > 1 && !o.sendBody {
+		return errors.New("`batch_size` can't be greater than 1 for GET requests.")
+	}
+	if o.BatchFormat != "delimited" && o.BatchFormat != "json_array" {
+		return fmt.Errorf("`batch_format` must be 'delimited' or 'json_array', is currently: '%s'",
+			o.BatchFormat)
+	}
 	o.client = new(http.Client)
 	if o.HttpTimeout > 0 {
 		o.client.Timeout = time.Duration(o.HttpTimeout) * time.Millisecond
@@ -84,6 +152,7 @@ func (o *HttpOutput) Init(config interface{}) (err error) {
 		}
 		o.client.Transport = transport
 	}
+	o.batches = make(map[string]*httpBatch)
 	return
 }
 
@@ -91,14 +160,23 @@ func (o *HttpOutput) Run(or pipeline.OutputRunner, h pipeline.PluginHelper) (err
 	if or.Encoder() == nil {
 		return errors.New("Encoder must be specified.")
 	}
+	if o.BatchSize > 1 {
+		return o.runBatched(or)
+	}
 
 	var (
 		e        error
 		outBytes []byte
+		u        *url.URL
 	)
 	inChan := or.InChan()
 
 	for pack := range inChan {
+		if u, e = o.resolveURL(pack); e != nil {
+			or.UpdateCursor(pack.QueueCursor)
+			pack.Recycle(e)
+			continue
+		}
 		outBytes, e = or.Encode(pack)
 		if e != nil {
 			or.UpdateCursor(pack.QueueCursor)
@@ -110,10 +188,11 @@ func (o *HttpOutput) Run(or pipeline.OutputRunner, h pipeline.PluginHelper) (err
 			pack.Recycle(nil)
 			continue
 		}
-		if e = o.request(or, outBytes); e != nil {
+		if _, e = o.send(u, outBytes); e != nil {
 			e = pipeline.NewRetryMessageError(e.Error())
 			pack.Recycle(e)
 		} else {
+			atomic.AddInt64(&o.sentMessageCount, 1)
 			or.UpdateCursor(pack.QueueCursor)
 			pack.Recycle(nil)
 		}
@@ -122,38 +201,263 @@ func (o *HttpOutput) Run(or pipeline.OutputRunner, h pipeline.PluginHelper) (err
 	return
 }
 
-func (o *HttpOutput) request(or pipeline.OutputRunner, outBytes []byte) (err error) {
+// Accumulates encoded messages into batches, sending them when `batch_size`
+// messages have been received or `batch_timeout` has elapsed. While batches
+// that failed are waiting to be retried no new messages are accepted.
+func (o *HttpOutput) runBatched(or pipeline.OutputRunner) error {
+	if o.tickChan == nil && o.BatchTimeout > 0 { // Tests might have set this.
+		ticker := time.NewTicker(time.Duration(o.BatchTimeout) * time.Millisecond)
+		defer ticker.Stop()
+		o.tickChan = ticker.C
+	}
+
+	var (
+		pack *pipeline.PipelinePack
+		e    error
+	)
+	inChan := or.InChan()
+	ok := true
+	for ok {
+		select {
+		case pack, ok = <-inChan:
+			if !ok {
+				break
+			}
+			if o.retryPending && !o.flush(or) {
+				// The queue buffer hands the message back after a delay.
+				pack.Recycle(pipeline.NewRetryMessageError(
+					"batches waiting to be retried weren't delivered"))
+				continue
+			}
+			e = o.addToBatch(or, pack)
+			o.batchCursor = pack.QueueCursor
+			pack.Recycle(e)
+			if o.batchCount >= o.BatchSize {
+				o.flush(or)
+			}
+		case <-o.tickChan:
+			o.flush(or)
+		}
+	}
+	o.flush(or)
+	return nil
+}
+
+func (o *HttpOutput) addToBatch(or pipeline.OutputRunner, pack *pipeline.PipelinePack) error {
+	u, err := o.resolveURL(pack)
+	if err != nil {
+		return err
+	}
+	outBytes, err := or.Encode(pack)
+	if err != nil {
+		return fmt.Errorf("can't encode: %s", err)
+	}
+	if outBytes == nil {
+		return nil
+	}
+
+	key := u.String()
+	b, ok := o.batches[key]
+	if !ok {
+		b = &httpBatch{url: u}
+		o.batches[key] = b
+		o.batchOrder = append(o.batchOrder, key)
+	}
+	if o.BatchFormat == "json_array" {
+		if b.count == 0 {
+			b.body.WriteByte('[')
+		} else {
+			b.body.WriteByte(',')
+		}
+		b.body.Write(bytes.TrimRight(outBytes, "\r\n"))
+	} else {
+		// The delimiter takes the place of the encoder's trailing newline.
+		b.body.Write(bytes.TrimRight(outBytes, "\r\n"))
+		b.body.WriteString(o.BatchDelimiter)
+	}
+	b.count++
+	o.batchCount++
+	return nil
+}
+
+// Sends every pending batch, in the order they were started, and then
+// advances the queue cursor past all of the batched messages. Batches that
+// fail with a retryable error are kept, and the cursor isn't advanced, until
+// a later flush delivers them. Returns false if any batches were kept.
+func (o *HttpOutput) flush(or pipeline.OutputRunner) bool {
+	if len(o.batchOrder) == 0 {
+		if o.batchCursor != "" {
+			or.UpdateCursor(o.batchCursor)
+			o.batchCursor = ""
+		}
+		return true
+	}
+	kept := o.batchOrder[:0]
+	for _, key := range o.batchOrder {
+		b := o.batches[key]
+		body := b.body.Bytes()
+		if o.BatchFormat == "json_array" {
+			// Copied, so the batch can be sent again.
+			body = append(body[:len(body):len(body)], ']')
+		}
+		retry, err := o.send(b.url, body)
+		switch {
+		case err == nil:
+			atomic.AddInt64(&o.sentMessageCount, b.count)
+		case retry:
+			or.LogError(fmt.Errorf("keeping batch of %d messages to retry: %s", b.count, err))
+			kept = append(kept, key)
+			continue
+		default:
+			atomic.AddInt64(&o.dropMessageCount, b.count)
+			or.LogError(fmt.Errorf("dropping batch of %d messages: %s", b.count, err))
+		}
+		o.batchCount -= uint32(b.count)
+		delete(o.batches, key)
+	}
+	o.batchOrder = kept
+	if o.retryPending = len(kept) > 0; o.retryPending {
+		return false
+	}
+	or.UpdateCursor(o.batchCursor)
+	o.batchCursor = ""
+	return true
+}
+
+// Returns the URL to which the provided message should be sent, interpolating
+// message data into the address if necessary.
+func (o *HttpOutput) resolveURL(pack *pipeline.PipelinePack) (*url.URL, error) {
+	if !o.templated {
+		return o.url, nil
+	}
+	msgTime := time.Unix(0, pack.Message.GetTimestamp())
+	address, err := plugins.InterpolateMessage(o.Address, pack.Message, msgTime,
+		escapeURLValue)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("Can't parse URL '%s': %s", address, err.Error())
+	}
+	return u, nil
+}
+
+// Escapes an interpolated value so it's safe to use in a URL path or query.
+func escapeURLValue(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// Sends the request body to the provided URL, retrying retryable failures up
+// to `max_retries` times. Returns whether the final failure was retryable.
+func (o *HttpOutput) send(u *url.URL, body []byte) (retry bool, err error) {
+	if o.sendBody && o.Gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		gz.Close()
+		body = buf.Bytes()
+	}
+
+	var wait time.Duration
+	for attempt := uint32(0); ; attempt++ {
+		atomic.AddInt64(&o.requestCount, 1)
+		if retry, wait, err = o.request(u, body); err == nil {
+			return false, nil
+		}
+		atomic.AddInt64(&o.requestFailureCount, 1)
+		if !retry || attempt >= o.MaxRetries {
+			return retry, err
+		}
+		atomic.AddInt64(&o.retryCount, 1)
+		time.Sleep(o.retryDelay(attempt, wait))
+	}
+}
+
+// Returns how long to wait before making another attempt. The server's
+// requested delay (via `Retry-After`) is used if provided, otherwise the
+// delay increases exponentially. Either way it's capped by
+// `max_retry_interval`.
+func (o *HttpOutput) retryDelay(attempt uint32, retryAfter time.Duration) time.Duration {
+	max := time.Duration(o.MaxRetryInterval) * time.Millisecond
+	delay := retryAfter
+	if delay <= 0 {
+		delay = time.Duration(o.RetryInterval) * time.Millisecond
+		for i := uint32(0); i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Parses a `Retry-After` header value, which is either a number of seconds or
+// an HTTP date. Returns 0 if the value is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Makes a single request, returning whether a failed request should be
+// retried and how long the server asked us to wait before doing so.
+func (o *HttpOutput) request(u *url.URL, outBytes []byte) (retry bool,
+	wait time.Duration, err error) {
+
 	var (
 		resp       *http.Response
 		reader     io.Reader
 		readCloser io.ReadCloser
 	)
 
+	header := make(http.Header, len(o.Headers)+1)
+	for k, v := range o.Headers {
+		header[k] = v
+	}
 	req := &http.Request{
 		Method: o.Method,
-		URL:    o.url,
-		Header: o.Headers,
+		URL:    u,
+		Header: header,
 	}
 	if o.useBasicAuth {
 		req.SetBasicAuth(o.Username, o.Password)
 	}
 
 	if o.sendBody {
+		if o.Gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
 		req.ContentLength = int64(len(outBytes))
 		reader = bytes.NewReader(outBytes)
 		readCloser = ioutil.NopCloser(reader)
 		req.Body = readCloser
 	}
 	if resp, err = o.client.Do(req); err != nil {
-		return fmt.Errorf("Error making HTTP request: %s", err.Error())
+		return true, 0, fmt.Errorf("Error making HTTP request: %s", err.Error())
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("Error reading HTTP response: %s", err.Error())
+			return true, 0, fmt.Errorf("Error reading HTTP response: %s", err.Error())
+		}
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry {
+			wait = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 		}
-		return fmt.Errorf("HTTP Error code returned: %d %s - %s",
+		return retry, wait, fmt.Errorf("HTTP Error code returned: %d %s - %s",
 			resp.StatusCode, resp.Status, string(body))
 	} else {
 		io.Copy(ioutil.Discard, resp.Body)
@@ -161,6 +465,22 @@ func (o *HttpOutput) request(or pipeline.OutputRunner, outBytes []byte) (err err
 	return
 }
 
+// Satisfies the `pipeline.ReportingPlugin` interface to provide plugin state
+// information to the Heka report and dashboard.
+func (o *HttpOutput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "SentMessageCount",
+		atomic.LoadInt64(&o.sentMessageCount), "count")
+	message.NewInt64Field(msg, "DropMessageCount",
+		atomic.LoadInt64(&o.dropMessageCount), "count")
+	message.NewInt64Field(msg, "RequestCount",
+		atomic.LoadInt64(&o.requestCount), "count")
+	message.NewInt64Field(msg, "RequestFailureCount",
+		atomic.LoadInt64(&o.requestFailureCount), "count")
+	message.NewInt64Field(msg, "RetryCount",
+		atomic.LoadInt64(&o.retryCount), "count")
+	return nil
+}
+
 func init() {
 	pipeline.RegisterPlugin("HttpOutput", func() interface{} {
 		return new(HttpOutput)
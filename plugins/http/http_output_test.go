@@ -15,13 +15,16 @@
 package http
 
 import (
+	"compress/gzip"
 	"encoding/base64"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/mozilla-services/heka/message"
 	"github.com/mozilla-services/heka/pipeline"
 	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
 	"github.com/mozilla-services/heka/plugins"
@@ -238,6 +241,209 @@ func HttpOutputSpec(c gs.Context) {
 				c.Expect(strings.Contains(e.Error(), "use of closed network connection"),
 					gs.IsTrue)
 			})
+
+			c.Specify("retries throttled requests", func() {
+				config.MaxRetries = 2
+				config.RetryInterval = 1
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+
+				var attempts int
+				handler.serveHttp = func(rw http.ResponseWriter, req *http.Request) {
+					defer handleWg.Done()
+					body, _ := ioutil.ReadAll(req.Body)
+					reqBody = string(body)
+					if attempts++; attempts == 1 {
+						rw.Header().Set("Retry-After", "0")
+						rw.WriteHeader(http.StatusTooManyRequests)
+					}
+				}
+				runWg.Add(1)
+				go runOutput()
+				handleWg.Add(2)
+				inChan <- pack
+				close(inChan)
+				handleWg.Wait()
+				runWg.Wait()
+				c.Expect(attempts, gs.Equals, 2)
+				c.Expect(reqBody, gs.Equals, payload)
+
+				msg := new(message.Message)
+				err = httpOutput.ReportMsg(msg)
+				c.Expect(err, gs.IsNil)
+				retries, _ := msg.GetFieldValue("RetryCount")
+				c.Expect(retries, gs.Equals, int64(1))
+				sent, _ := msg.GetFieldValue("SentMessageCount")
+				c.Expect(sent, gs.Equals, int64(1))
+			})
+
+			c.Specify("doesn't retry client errors", func() {
+				config.MaxRetries = 2
+				config.RetryInterval = 1
+				handler.respBody = ""
+				handler.respCode = 400
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+
+				pack.BufferedPack = true
+				pack.DelivErrChan = make(chan error, 1)
+				runWg.Add(1)
+				go runOutput()
+				handleWg.Add(1)
+				inChan <- pack
+				close(inChan)
+				handleWg.Wait()
+				runWg.Wait()
+				e := <-pack.DelivErrChan
+				c.Expect(strings.HasPrefix(e.Error(),
+					"HTTP Error code returned: 400"), gs.IsTrue)
+			})
+		})
+
+		c.Specify("that batches requests", func() {
+			var (
+				reqs     []*http.Request
+				bods     []string
+				failures int
+			)
+			server := httptest.NewServer(http.HandlerFunc(
+				func(rw http.ResponseWriter, req *http.Request) {
+					var body []byte
+					if req.Header.Get("Content-Encoding") == "gzip" {
+						gz, err := gzip.NewReader(req.Body)
+						c.Assume(err, gs.IsNil)
+						body, _ = ioutil.ReadAll(gz)
+					} else {
+						body, _ = ioutil.ReadAll(req.Body)
+					}
+					reqs = append(reqs, req)
+					bods = append(bods, string(body))
+					if failures > 0 {
+						failures--
+						rw.WriteHeader(http.StatusServiceUnavailable)
+					}
+				}))
+			defer server.Close()
+
+			config.Address = server.URL + "/logs"
+			config.BatchSize = 3
+			tickChan := make(chan time.Time)
+			httpOutput.tickChan = tickChan
+			inChan := make(chan *pipeline.PipelinePack)
+			oth.MockOutputRunner.EXPECT().Encoder().Return(encoder)
+			oth.MockOutputRunner.EXPECT().InChan().Return(inChan)
+			oth.MockOutputRunner.EXPECT().Encode(gomock.Any()).Return(
+				[]byte("{\"a\":1}\n"), nil).AnyTimes()
+
+			newPack := func(cursor string) *pipeline.PipelinePack {
+				pack := pipeline.NewPipelinePack(recycleChan)
+				pack.Message = pipeline_ts.GetTestMessage()
+				pack.QueueCursor = cursor
+				return pack
+			}
+			sendPacks := func(cursors ...string) {
+				for _, cursor := range cursors {
+					inChan <- newPack(cursor)
+					<-recycleChan
+				}
+			}
+			runOutput := func() {
+				httpOutput.Run(oth.MockOutputRunner, oth.MockHelper)
+				runWg.Done()
+			}
+
+			c.Specify("when the batch size is reached", func() {
+				config.Gzip = true
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("c3")
+				oth.MockOutputRunner.EXPECT().UpdateCursor("c4")
+				runWg.Add(1)
+				go runOutput()
+				sendPacks("c1", "c2", "c3", "c4")
+				close(inChan)
+				runWg.Wait()
+				c.Expect(len(reqs), gs.Equals, 2)
+				c.Expect(bods[0], gs.Equals, "{\"a\":1}\n{\"a\":1}\n{\"a\":1}\n")
+				c.Expect(bods[1], gs.Equals, "{\"a\":1}\n")
+			})
+
+			c.Specify("as a JSON array when the batch times out", func() {
+				config.BatchFormat = "json_array"
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("c2")
+				runWg.Add(1)
+				go runOutput()
+				sendPacks("c1", "c2")
+				tickChan <- time.Now()
+				close(inChan)
+				runWg.Wait()
+				c.Expect(len(reqs), gs.Equals, 1)
+				c.Expect(bods[0], gs.Equals, `[{"a":1},{"a":1}]`)
+			})
+
+			c.Specify("keeps failed batches until they're delivered", func() {
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+				oth.MockOutputRunner.EXPECT().LogError(gomock.Any()).AnyTimes()
+				start := func(fails int) {
+					failures = fails
+					runWg.Add(1)
+					go runOutput()
+					sendPacks("c1", "c2", "c3")
+				}
+
+				c.Specify("sending them before accepting more messages", func() {
+					start(1)
+					oth.MockOutputRunner.EXPECT().UpdateCursor("c3")
+					oth.MockOutputRunner.EXPECT().UpdateCursor("c4")
+					sendPacks("c4")
+					close(inChan)
+					runWg.Wait()
+					c.Expect(len(reqs), gs.Equals, 3)
+					c.Expect(bods[1], gs.Equals, bods[0])
+					c.Expect(bods[2], gs.Equals, "{\"a\":1}\n")
+				})
+
+				c.Specify("handing messages back while they still fail", func() {
+					start(2)
+					pack := newPack("c4")
+					pack.BufferedPack = true
+					pack.DelivErrChan = make(chan error, 1)
+					inChan <- pack
+					e := <-pack.DelivErrChan
+					_, isRetry := e.(pipeline.RetryMessageError)
+					c.Expect(isRetry, gs.IsTrue)
+
+					oth.MockOutputRunner.EXPECT().UpdateCursor("c3")
+					tickChan <- time.Now()
+					close(inChan)
+					runWg.Wait()
+					c.Expect(len(reqs), gs.Equals, 3)
+					c.Expect(bods[2], gs.Equals, bods[0])
+				})
+			})
+
+			c.Specify("to URLs interpolated from message data", func() {
+				config.Address = server.URL + "/logs/%{Logger}?host=%{Hostname}"
+				err := httpOutput.Init(config)
+				c.Expect(err, gs.IsNil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("c2")
+				runWg.Add(1)
+				go runOutput()
+				inChan <- newPack("c1")
+				<-recycleChan
+				pack := newPack("c2")
+				pack.Message.SetLogger("other logger")
+				inChan <- pack
+				<-recycleChan
+				close(inChan)
+				runWg.Wait()
+				c.Expect(len(reqs), gs.Equals, 2)
+				c.Expect(reqs[0].URL.String(), gs.Equals, "/logs/GoSpec?host=my.host.name")
+				c.Expect(reqs[1].URL.String(), gs.Equals, "/logs/other%20logger?host=my.host.name")
+			})
 		})
 	})
 }
@@ -0,0 +1,118 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cactus/gostrftime"
+	"github.com/mozilla-services/heka/message"
+)
+
+// Returns true if the provided template contains any `%{name}` references
+// that need to be interpolated from message data.
+func IsMessageTemplate(tmpl string) bool {
+	return strings.Contains(tmpl, "%{")
+}
+
+// Replaces every `%{name}` reference in the template with the corresponding
+// value from the provided message. The message header names (Type, Logger,
+// Hostname, Pid, Uuid, EnvVersion, Severity and Payload) are supported, as
+// are any dynamic field names. A reference that starts with a `%` (e.g.
+// `%{%Y.%m.%d}`) is treated as a strftime format and is rendered using the
+// provided time. If `escape` is not nil, every interpolated value is passed
+// through it before being inserted. References to fields that don't exist on
+// the message are left in place and an error is returned.
+func InterpolateMessage(tmpl string, m *message.Message, t time.Time,
+	escape func(string) string) (string, error) {
+
+	var (
+		buf     bytes.Buffer
+		missing []string
+	)
+	rest := tmpl
+	for {
+		start := strings.Index(rest, "%{")
+		if start == -1 {
+			buf.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			buf.WriteString(rest)
+			break
+		}
+		end += start
+		buf.WriteString(rest[:start])
+		name := rest[start+2 : end]
+		val, ok := messageValue(m, name, t)
+		if !ok {
+			missing = append(missing, name)
+			buf.WriteString(rest[start : end+1])
+		} else {
+			if escape != nil {
+				val = escape(val)
+			}
+			buf.WriteString(val)
+		}
+		rest = rest[end+1:]
+	}
+
+	if len(missing) > 0 {
+		return buf.String(), fmt.Errorf("can't interpolate '%s', missing: %s",
+			tmpl, strings.Join(missing, ", "))
+	}
+	return buf.String(), nil
+}
+
+func messageValue(m *message.Message, name string, t time.Time) (string, bool) {
+	if strings.HasPrefix(name, "%") {
+		return gostrftime.Strftime(name, t), true
+	}
+	switch name {
+	case "Type":
+		return m.GetType(), true
+	case "Logger":
+		return m.GetLogger(), true
+	case "Hostname":
+		return m.GetHostname(), true
+	case "Pid":
+		return strconv.Itoa(int(m.GetPid())), true
+	case "Uuid", "UUID":
+		return m.GetUuidString(), true
+	case "EnvVersion":
+		return m.GetEnvVersion(), true
+	case "Severity":
+		return strconv.Itoa(int(m.GetSeverity())), true
+	case "Payload":
+		return m.GetPayload(), true
+	}
+	val, ok := m.GetFieldValue(name)
+	if !ok {
+		return "", false
+	}
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
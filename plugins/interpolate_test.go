@@ -0,0 +1,64 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package plugins
+
+import (
+	"strings"
+	"time"
+
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func InterpolateMessageSpec(c gs.Context) {
+	c.Specify("Message interpolation", func() {
+		msg := pipeline_ts.GetTestMessage()
+		t := time.Date(2016, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+		c.Specify("detects templates", func() {
+			c.Expect(IsMessageTemplate("/var/log/%{Logger}.log"), gs.IsTrue)
+			c.Expect(IsMessageTemplate("/var/log/%Y-%m-%d.log"), gs.IsFalse)
+		})
+
+		c.Specify("replaces header and dynamic fields", func() {
+			out, err := InterpolateMessage("%{Hostname}/%{Logger}-%{foo}-%{Pid}",
+				msg, t, nil)
+			c.Expect(err, gs.IsNil)
+			c.Expect(out, gs.Equals, "my.host.name/GoSpec-bar-43")
+		})
+
+		c.Specify("renders strftime references", func() {
+			out, err := InterpolateMessage("logs-%{%Y.%m.%d}", msg, t, nil)
+			c.Expect(err, gs.IsNil)
+			c.Expect(out, gs.Equals, "logs-2016.03.04")
+		})
+
+		c.Specify("escapes values", func() {
+			msg.SetLogger("a/b")
+			escape := func(s string) string {
+				return strings.Replace(s, "/", "_", -1)
+			}
+			out, err := InterpolateMessage("/tmp/%{Logger}", msg, t, escape)
+			c.Expect(err, gs.IsNil)
+			c.Expect(out, gs.Equals, "/tmp/a_b")
+		})
+
+		c.Specify("reports missing fields", func() {
+			out, err := InterpolateMessage("x-%{missing}-%{Type}", msg, t, nil)
+			c.Expect(err, gs.Not(gs.IsNil))
+			c.Expect(out, gs.Equals, "x-%{missing}-TEST")
+		})
+	})
+}
@@ -0,0 +1,154 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var errEntryTooLarge = errors.New("journal entry too large")
+
+// A field of a journal entry. Binary is set for fields that were serialized
+// with an explicit length because their value isn't plain text.
+type entryField struct {
+	Name   string
+	Value  []byte
+	Binary bool
+}
+
+// A journal entry, with its fields in the order they were exported.
+type entry []entryField
+
+// Returns the value of the first field with the name.
+func (e entry) Get(name string) (value []byte, ok bool) {
+	for _, field := range e {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Reads journal entries serialized in the journal export format, see
+// https://www.freedesktop.org/wiki/Software/systemd/export/. Each field is
+// either a `NAME=value` line or, for values that aren't plain text, the name
+// on its own line followed by the value's size as a little endian 64 bit
+// integer, the value and a newline. Entries are separated by empty lines.
+type exportReader struct {
+	reader       *bufio.Reader
+	maxEntrySize int
+}
+
+func newExportReader(r io.Reader, maxEntrySize int) *exportReader {
+	return &exportReader{
+		reader:       bufio.NewReader(r),
+		maxEntrySize: maxEntrySize,
+	}
+}
+
+// Returns the next entry. Entries larger than maxEntrySize are skipped and
+// errEntryTooLarge is returned, after which reading can continue. io.EOF is
+// only returned between entries, an incomplete last entry results in
+// io.ErrUnexpectedEOF.
+func (r *exportReader) ReadEntry() (entry, error) {
+	var (
+		e        entry
+		size     int
+		tooLarge bool
+	)
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(line) == 0 && len(e) == 0 && !tooLarge {
+				return nil, io.EOF
+			}
+			return nil, unexpected(err)
+		}
+		line = line[:len(line)-1]
+		if len(line) == 0 {
+			if tooLarge {
+				return nil, errEntryTooLarge
+			}
+			if len(e) == 0 {
+				// Tolerate extra separators.
+				continue
+			}
+			return e, nil
+		}
+
+		var field entryField
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			field.Name = string(line[:i])
+			field.Value = line[i+1:]
+		} else {
+			field.Name = string(line)
+			field.Binary = true
+			if field.Value, err = r.readBinary(r.maxEntrySize - size); err != nil {
+				if err != errEntryTooLarge {
+					return nil, err
+				}
+				tooLarge = true
+			}
+		}
+		size += len(field.Name) + len(field.Value)
+		if size > r.maxEntrySize {
+			tooLarge = true
+		}
+		if !tooLarge {
+			e = append(e, field)
+		}
+	}
+}
+
+// Reads a length prefixed value, discarding it if it's larger than max.
+func (r *exportReader) readBinary(max int) ([]byte, error) {
+	var size uint64
+	if err := binary.Read(r.reader, binary.LittleEndian, &size); err != nil {
+		return nil, unexpected(err)
+	}
+	var value []byte
+	if max < 0 || size > uint64(max) {
+		if _, err := io.CopyN(ioutil.Discard, r.reader, int64(size)); err != nil {
+			return nil, unexpected(err)
+		}
+	} else {
+		value = make([]byte, size)
+		if _, err := io.ReadFull(r.reader, value); err != nil {
+			return nil, unexpected(err)
+		}
+	}
+	if b, err := r.reader.ReadByte(); err != nil {
+		return nil, unexpected(err)
+	} else if b != '\n' {
+		return nil, fmt.Errorf("missing newline after binary field value")
+	}
+	if value == nil && size > 0 {
+		return nil, errEntryTooLarge
+	}
+	return value, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
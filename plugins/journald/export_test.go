@@ -0,0 +1,105 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+// Serializes a binary field in the export format.
+func binaryField(name string, value []byte) string {
+	buf := bytes.NewBufferString(name + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.Write(value)
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+func ExportReaderSpec(c gs.Context) {
+	c.Specify("An export reader", func() {
+		read := func(data string, maxEntrySize int) (entries []entry, err error) {
+			r := newExportReader(bytes.NewBufferString(data), maxEntrySize)
+			for {
+				var e entry
+				if e, err = r.ReadEntry(); err == errEntryTooLarge {
+					entries = append(entries, nil)
+					continue
+				} else if err != nil {
+					return entries, err
+				}
+				entries = append(entries, e)
+			}
+		}
+
+		c.Specify("reads text and binary fields", func() {
+			data := "__CURSOR=s=1;i=1\nMESSAGE=first\n\n" +
+				"__CURSOR=s=1;i=2\n" + binaryField("MESSAGE", []byte("two\nlines")) +
+				"_PID=42\n\n"
+			entries, err := read(data, 1024)
+			c.Expect(err, gs.Equals, io.EOF)
+			c.Assume(len(entries), gs.Equals, 2)
+
+			c.Expect(len(entries[0]), gs.Equals, 2)
+			value, ok := entries[0].Get("MESSAGE")
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(string(value), gs.Equals, "first")
+			c.Expect(entries[0][1].Binary, gs.IsFalse)
+
+			c.Expect(len(entries[1]), gs.Equals, 3)
+			c.Expect(entries[1][1].Name, gs.Equals, "MESSAGE")
+			c.Expect(string(entries[1][1].Value), gs.Equals, "two\nlines")
+			c.Expect(entries[1][1].Binary, gs.IsTrue)
+			value, _ = entries[1].Get("_PID")
+			c.Expect(string(value), gs.Equals, "42")
+			_, ok = entries[1].Get("_HOSTNAME")
+			c.Expect(ok, gs.IsFalse)
+		})
+
+		c.Specify("keeps '=' in values", func() {
+			entries, err := read("MESSAGE=a=b\n\n", 1024)
+			c.Expect(err, gs.Equals, io.EOF)
+			c.Assume(len(entries), gs.Equals, 1)
+			value, _ := entries[0].Get("MESSAGE")
+			c.Expect(string(value), gs.Equals, "a=b")
+		})
+
+		c.Specify("skips entries that are too large", func() {
+			data := "MESSAGE=small\n\n" +
+				"MESSAGE=" + string(bytes.Repeat([]byte("x"), 100)) + "\n\n" +
+				binaryField("MESSAGE", bytes.Repeat([]byte("y"), 100)) + "\n" +
+				"MESSAGE=small again\n\n"
+			entries, err := read(data, 50)
+			c.Expect(err, gs.Equals, io.EOF)
+			c.Assume(len(entries), gs.Equals, 4)
+			c.Expect(entries[1], gs.IsNil)
+			c.Expect(entries[2], gs.IsNil)
+			value, _ := entries[3].Get("MESSAGE")
+			c.Expect(string(value), gs.Equals, "small again")
+		})
+
+		c.Specify("fails on incomplete entries", func() {
+			_, err := read("MESSAGE=first\n\nMESSAGE=cut", 1024)
+			c.Expect(err, gs.Equals, io.ErrUnexpectedEOF)
+
+			data := binaryField("MESSAGE", []byte("value"))
+			_, err = read(data[:len(data)-3], 1024)
+			c.Expect(err, gs.Equals, io.ErrUnexpectedEOF)
+		})
+	})
+}
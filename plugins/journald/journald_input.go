@@ -0,0 +1,353 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package journald
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/pborman/uuid"
+)
+
+// Journal fields that are mapped onto message headers rather than stored as
+// message fields.
+const (
+	fieldMessage    = "MESSAGE"
+	fieldHostname   = "_HOSTNAME"
+	fieldPriority   = "PRIORITY"
+	fieldIdentifier = "SYSLOG_IDENTIFIER"
+	fieldPid        = "_PID"
+	fieldTimestamp  = "__REALTIME_TIMESTAMP"
+	fieldCursor     = "__CURSOR"
+)
+
+var priorityNames = map[string]bool{
+	"emerg": true, "alert": true, "crit": true, "err": true,
+	"warning": true, "notice": true, "info": true, "debug": true,
+}
+
+// Input plugin that follows the systemd journal using journalctl's export
+// format and turns each journal entry into a Heka message. The cursor of the
+// last delivered entry is saved in Heka's base directory, so reading resumes
+// where it left off after a restart.
+type JournaldInput struct {
+	*JournaldInputConfig
+	name          string
+	pConfig       *PipelineConfig
+	hostname      string
+	cursorPath    string
+	cursor        string
+	savedCursor   string
+	lastSave      time.Time
+	stopChan      chan struct{}
+	processCount  int64
+	tooLargeCount int64
+}
+
+type JournaldInputConfig struct {
+	// Path to the journalctl binary. Defaults to "journalctl".
+	JournalctlPath string `toml:"journalctl_path"`
+	// Directory to read the journal files from instead of the system
+	// journal, e.g. one mounted from the host into a container.
+	JournalDirectory string `toml:"journal_directory"`
+	// Only read the entries of these systemd units.
+	Units []string
+	// Only read entries of this priority or more important ones, or of a
+	// range of priorities, e.g. "warning", "3" or "err..alert".
+	Priority string
+	// Start at the end of the journal instead of the beginning when there's
+	// no saved cursor.
+	InitialTail bool `toml:"initial_tail"`
+	// Entries larger than this are skipped. Defaults to the maximum message
+	// size.
+	MaxEntrySize uint32 `toml:"max_entry_size"`
+	// How often the cursor is saved while entries are read, in milliseconds.
+	// Defaults to 1000.
+	CursorSaveInterval uint32 `toml:"cursor_save_interval"`
+}
+
+func (j *JournaldInput) SetName(name string) {
+	j.name = name
+}
+
+func (j *JournaldInput) SetPipelineConfig(pConfig *PipelineConfig) {
+	j.pConfig = pConfig
+}
+
+func (j *JournaldInput) ConfigStruct() interface{} {
+	return &JournaldInputConfig{
+		JournalctlPath:     "journalctl",
+		MaxEntrySize:       message.MAX_MESSAGE_SIZE,
+		CursorSaveInterval: 1000,
+	}
+}
+
+func (j *JournaldInput) Init(config interface{}) (err error) {
+	j.JournaldInputConfig = config.(*JournaldInputConfig)
+	if j.MaxEntrySize == 0 {
+		return errors.New("max_entry_size must be greater than zero")
+	}
+	if j.Priority != "" && !validPriority(j.Priority) {
+		return fmt.Errorf("invalid priority '%s'", j.Priority)
+	}
+	for _, unit := range j.Units {
+		if unit == "" {
+			return errors.New("units can't be empty")
+		}
+	}
+
+	j.cursorPath = j.pConfig.Globals.PrependBaseDir(filepath.Join("journald",
+		j.name+".cursor"))
+	if j.cursor, err = loadCursor(j.cursorPath); err != nil {
+		return fmt.Errorf("loading cursor: %s", err)
+	}
+	j.savedCursor = j.cursor
+	j.stopChan = make(chan struct{})
+	return nil
+}
+
+// Returns true if the priority is a priority name or number, or a range of
+// them, as accepted by journalctl.
+func validPriority(priority string) bool {
+	for _, p := range strings.SplitN(priority, "..", 2) {
+		if n, err := strconv.Atoi(p); err == nil {
+			if n < 0 || n > 7 {
+				return false
+			}
+		} else if !priorityNames[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func loadCursor(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Writes the cursor to a temporary file first, so a crash can't leave a
+// truncated cursor file behind.
+func saveCursor(path, cursor string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, []byte(cursor+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Returns the journalctl arguments for following the journal from the saved
+// cursor.
+func (j *JournaldInput) arguments() []string {
+	args := []string{"--output=export", "--follow", "--no-pager"}
+	if j.JournalDirectory != "" {
+		args = append(args, "--directory="+j.JournalDirectory)
+	}
+	if j.cursor != "" {
+		args = append(args, "--after-cursor="+j.cursor)
+	} else if j.InitialTail {
+		args = append(args, "--lines=0")
+	} else {
+		args = append(args, "--no-tail")
+	}
+	for _, unit := range j.Units {
+		args = append(args, "--unit="+unit)
+	}
+	if j.Priority != "" {
+		args = append(args, "--priority="+j.Priority)
+	}
+	return args
+}
+
+func (j *JournaldInput) Run(ir InputRunner, h PluginHelper) error {
+	j.hostname = h.Hostname()
+
+	cmd := exec.Command(j.JournalctlPath, j.arguments()...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting %s: %s", j.JournalctlPath, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-j.stopChan:
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	deliverer := ir.NewDeliverer("")
+	readErr := j.readEntries(ir, deliverer, stdout)
+	close(done)
+	waitErr := cmd.Wait()
+	deliverer.Done()
+	j.saveCursor(ir)
+
+	if j.stopping() {
+		return nil
+	}
+	if readErr != nil && readErr != io.EOF {
+		return fmt.Errorf("Error reading journal: %s", readErr)
+	}
+	if waitErr == nil {
+		waitErr = errors.New("exited")
+	}
+	return fmt.Errorf("%s: %s %s", j.JournalctlPath, waitErr,
+		strings.TrimSpace(stderr.String()))
+}
+
+// Delivers the entries journalctl exports until its output ends.
+func (j *JournaldInput) readEntries(ir InputRunner, deliverer Deliverer,
+	r io.Reader) error {
+
+	entries := newExportReader(r, int(j.MaxEntrySize))
+	saveInterval := time.Duration(j.CursorSaveInterval) * time.Millisecond
+	for {
+		e, err := entries.ReadEntry()
+		if err == errEntryTooLarge {
+			atomic.AddInt64(&j.tooLargeCount, 1)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		pack := <-ir.InChan()
+		j.populateMessage(pack.Message, e)
+		atomic.AddInt64(&j.processCount, 1)
+		deliverer.Deliver(pack)
+
+		if cursor, ok := e.Get(fieldCursor); ok {
+			j.cursor = string(cursor)
+		}
+		if time.Since(j.lastSave) >= saveInterval {
+			j.saveCursor(ir)
+		}
+	}
+}
+
+// Maps the entry's fields onto the message. Header fields with values that
+// can't be parsed are stored as message fields instead.
+func (j *JournaldInput) populateMessage(msg *message.Message, e entry) {
+	msg.SetUuid(uuid.NewRandom())
+	msg.SetTimestamp(time.Now().UnixNano())
+	msg.SetType(j.name)
+	msg.SetHostname(j.hostname)
+
+	for _, field := range e {
+		value := string(field.Value)
+		switch field.Name {
+		case fieldMessage:
+			msg.SetPayload(value)
+			continue
+		case fieldHostname:
+			msg.SetHostname(value)
+			continue
+		case fieldIdentifier:
+			msg.SetLogger(value)
+			continue
+		case fieldPriority:
+			if n, err := strconv.ParseInt(value, 10, 32); err == nil {
+				msg.SetSeverity(int32(n))
+				continue
+			}
+		case fieldPid:
+			if n, err := strconv.ParseInt(value, 10, 32); err == nil {
+				msg.SetPid(int32(n))
+				continue
+			}
+		case fieldTimestamp:
+			// Microseconds since the epoch.
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				msg.SetTimestamp(n * 1000)
+				continue
+			}
+		}
+
+		var f *message.Field
+		if field.Binary && !utf8.Valid(field.Value) {
+			f, _ = message.NewField(field.Name, field.Value, "")
+		} else {
+			f, _ = message.NewField(field.Name, value, "")
+		}
+		msg.AddField(f)
+	}
+}
+
+func (j *JournaldInput) saveCursor(ir InputRunner) {
+	j.lastSave = time.Now()
+	if j.cursor == j.savedCursor {
+		return
+	}
+	if err := saveCursor(j.cursorPath, j.cursor); err != nil {
+		ir.LogError(fmt.Errorf("Error saving cursor: %s", err))
+		return
+	}
+	j.savedCursor = j.cursor
+}
+
+func (j *JournaldInput) stopping() bool {
+	select {
+	case <-j.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *JournaldInput) Stop() {
+	close(j.stopChan)
+}
+
+func (j *JournaldInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "ProcessMessageCount",
+		atomic.LoadInt64(&j.processCount), "count")
+	message.NewInt64Field(msg, "TooLargeCount",
+		atomic.LoadInt64(&j.tooLargeCount), "count")
+	return nil
+}
+
+func init() {
+	RegisterPlugin("JournaldInput", func() interface{} {
+		return new(JournaldInput)
+	})
+}
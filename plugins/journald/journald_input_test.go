@@ -0,0 +1,206 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package journald
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func JournaldInputSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "journald-input-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	pConfig := NewPipelineConfig(nil)
+	pConfig.Globals.BaseDir = tmpDir
+
+	mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+
+	newInput := func() (*JournaldInput, *JournaldInputConfig) {
+		input := new(JournaldInput)
+		input.SetName("journal")
+		input.SetPipelineConfig(pConfig)
+		return input, input.ConfigStruct().(*JournaldInputConfig)
+	}
+
+	c.Specify("A JournaldInput", func() {
+		input, config := newInput()
+
+		c.Specify("rejects invalid priorities", func() {
+			for _, priority := range []string{"8", "error", "err..", "-1"} {
+				config.Priority = priority
+				c.Expect(input.Init(config), gs.Not(gs.IsNil))
+			}
+			for _, priority := range []string{"0", "warning", "err..alert", "3..7"} {
+				config.Priority = priority
+				c.Expect(input.Init(config), gs.IsNil)
+			}
+		})
+
+		c.Specify("passes the filters to journalctl", func() {
+			config.JournalDirectory = "/var/log/journal"
+			config.Units = []string{"nginx.service", "sshd.service"}
+			config.Priority = "err"
+			c.Assume(input.Init(config), gs.IsNil)
+			c.Expect(strings.Join(input.arguments(), " "), gs.Equals,
+				"--output=export --follow --no-pager --directory=/var/log/journal "+
+					"--no-tail --unit=nginx.service --unit=sshd.service --priority=err")
+		})
+
+		c.Specify("starts at the end with initial_tail", func() {
+			config.InitialTail = true
+			c.Assume(input.Init(config), gs.IsNil)
+			c.Expect(strings.Join(input.arguments(), " "), gs.Equals,
+				"--output=export --follow --no-pager --lines=0")
+		})
+	})
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	c.Specify("A JournaldInput running journalctl", func() {
+		// A fake journalctl that records its arguments, prints the export
+		// data and then keeps running like `journalctl --follow` does.
+		argsPath := filepath.Join(tmpDir, "args")
+		dataPath := filepath.Join(tmpDir, "export")
+		scriptPath := filepath.Join(tmpDir, "journalctl")
+		writeScript := func(follow bool) {
+			script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\ncat %s\n", argsPath,
+				dataPath)
+			if follow {
+				script += "exec sleep 60\n"
+			} else {
+				script += "echo 'Failed to follow' >&2\nexit 1\n"
+			}
+			c.Assume(ioutil.WriteFile(scriptPath, []byte(script), 0755), gs.IsNil)
+		}
+		data := "__CURSOR=s=abc;i=1\n__REALTIME_TIMESTAMP=1462435200123456\n" +
+			"_HOSTNAME=web1\nPRIORITY=3\nSYSLOG_IDENTIFIER=nginx\n_PID=1234\n" +
+			"_SYSTEMD_UNIT=nginx.service\nMESSAGE=upstream timed out\n\n" +
+			"__CURSOR=s=abc;i=2\nPRIORITY=high\n" +
+			binaryField("MESSAGE", []byte("two\nlines")) +
+			binaryField("BLOB", []byte{0xff, 0xfe}) + "\n"
+		c.Assume(ioutil.WriteFile(dataPath, []byte(data), 0644), gs.IsNil)
+
+		input, config := newInput()
+		config.JournalctlPath = scriptPath
+
+		packSupply := make(chan *PipelinePack, 2)
+		for i := 0; i < 2; i++ {
+			packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+		}
+		delivered := make(chan *PipelinePack, 2)
+		runErr := make(chan error, 1)
+		startInput := func() {
+			mockHelper.EXPECT().Hostname().Return("localhost.localdomain")
+			mockIR.EXPECT().NewDeliverer("").Return(mockDeliverer)
+			mockIR.EXPECT().InChan().Return(packSupply).AnyTimes()
+			mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+			mockDeliverer.EXPECT().Deliver(gomock.Any()).Do(func(pack *PipelinePack) {
+				delivered <- pack
+			}).AnyTimes()
+			mockDeliverer.EXPECT().Done()
+			go func() {
+				runErr <- input.Run(mockIR, mockHelper)
+			}()
+		}
+
+		c.Specify("maps journal entries onto messages", func() {
+			writeScript(true)
+			c.Assume(input.Init(config), gs.IsNil)
+			startInput()
+
+			msg := (<-delivered).Message
+			c.Expect(msg.GetType(), gs.Equals, "journal")
+			c.Expect(msg.GetPayload(), gs.Equals, "upstream timed out")
+			c.Expect(msg.GetHostname(), gs.Equals, "web1")
+			c.Expect(msg.GetSeverity(), gs.Equals, int32(3))
+			c.Expect(msg.GetLogger(), gs.Equals, "nginx")
+			c.Expect(msg.GetPid(), gs.Equals, int32(1234))
+			c.Expect(msg.GetTimestamp(), gs.Equals, int64(1462435200123456000))
+			unit, _ := msg.GetFieldValue("_SYSTEMD_UNIT")
+			c.Expect(unit, gs.Equals, "nginx.service")
+			cursor, _ := msg.GetFieldValue("__CURSOR")
+			c.Expect(cursor, gs.Equals, "s=abc;i=1")
+			_, ok := msg.GetFieldValue("MESSAGE")
+			c.Expect(ok, gs.IsFalse)
+
+			msg = (<-delivered).Message
+			c.Expect(msg.GetPayload(), gs.Equals, "two\nlines")
+			c.Expect(msg.GetHostname(), gs.Equals, "localhost.localdomain")
+			priority, _ := msg.GetFieldValue("PRIORITY")
+			c.Expect(priority, gs.Equals, "high")
+			blob, _ := msg.GetFieldValue("BLOB")
+			c.Expect(string(blob.([]byte)), gs.Equals, "\xff\xfe")
+
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+			args, err := ioutil.ReadFile(argsPath)
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(args), gs.Equals, "--output=export --follow --no-pager --no-tail\n")
+
+			c.Specify("and resumes after the saved cursor", func() {
+				cursor, err := ioutil.ReadFile(filepath.Join(tmpDir, "journald",
+					"journal.cursor"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(string(cursor), gs.Equals, "s=abc;i=2\n")
+
+				for i := 0; i < 2; i++ {
+					packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+				}
+				input, config = newInput()
+				config.JournalctlPath = scriptPath
+				c.Assume(input.Init(config), gs.IsNil)
+				startInput()
+				<-delivered
+				<-delivered
+				input.Stop()
+				c.Expect(<-runErr, gs.IsNil)
+				args, err := ioutil.ReadFile(argsPath)
+				c.Expect(err, gs.IsNil)
+				c.Expect(string(args), gs.Equals,
+					"--output=export --follow --no-pager --after-cursor=s=abc;i=2\n")
+			})
+		})
+
+		c.Specify("fails when journalctl exits", func() {
+			writeScript(false)
+			c.Assume(input.Init(config), gs.IsNil)
+			startInput()
+			<-delivered
+			<-delivered
+			err := <-runErr
+			c.Assume(err, gs.Not(gs.IsNil))
+			c.Expect(strings.Contains(err.Error(), "Failed to follow"), gs.IsTrue)
+		})
+	})
+}
@@ -0,0 +1,415 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ls "github.com/mozilla-services/heka/logstreamer"
+	"github.com/mozilla-services/heka/message"
+	p "github.com/mozilla-services/heka/pipeline"
+	"github.com/pborman/uuid"
+)
+
+// Matches the logfiles kubelet keeps under /var/log/pods, i.e.
+// `<namespace>_<pod name>_<pod uid>/<container name>/<restart count>.log` and
+// their rotated, possibly compressed, versions with a `.YYYYMMDD-hhmmss`
+// suffix.
+const criFileMatch = `(?P<Namespace>[^/_]+)_(?P<PodName>[^/_]+)_(?P<PodUid>[^/]+)/` +
+	`(?P<ContainerName>[^/]+)/(?P<RestartCount>\d+)\.log` +
+	`(\.(?P<RotationDate>\d{8})-(?P<RotationTime>\d{6}))?(\.(gz|zst|xz|bz2))?`
+
+// The match parts of the logfile paths that are added as message fields.
+var criPathFields = []string{"Namespace", "PodName", "PodUid", "ContainerName"}
+
+type CRILogInputConfig struct {
+	// Hostname to use for the generated messages.
+	Hostname string
+	// Directory kubelet keeps the pod logs in.
+	LogDirectory string `toml:"log_directory"`
+	// Journal base directory for saving journal files
+	JournalDirectory string `toml:"journal_directory"`
+	// Glob patterns of files and directories to leave out
+	Exclude []string
+	// Oldest logfiles to parse, as a duration parseable
+	OldestDuration string `toml:"oldest_duration"`
+	// How often to scan for new logfiles.
+	RescanInterval string `toml:"rescan_interval"`
+	// How often to check the logfiles for data.
+	CheckDataInterval string `toml:"check_data_interval"`
+	// So we can default to TokenSplitter.
+	Splitter string
+	// Whether to ignore previous logfiles while initial scan
+	InitialTail bool `toml:"initial_tail"`
+	// Use inotify to find new logfiles and learn about new data, Linux only.
+	UseInotify bool `toml:"use_inotify"`
+	// How long to wait for the rest of a partial line before delivering
+	// what there is of it.
+	PartialTimeout string `toml:"partial_timeout"`
+	// Reassembled lines are delivered in pieces of at most this many bytes.
+	MaxLineSize uint32 `toml:"max_line_size"`
+	// File holding the pods on the node as a Kubernetes PodList in JSON.
+	MetadataFile string `toml:"metadata_file"`
+	// Pod labels to add as message fields.
+	FieldsFromLabels []string `toml:"fields_from_labels"`
+	// Pod annotations to add as message fields.
+	FieldsFromAnnotations []string `toml:"fields_from_annotations"`
+}
+
+// Input plugin that tails the container logfiles written in the CRI logging
+// format by container runtimes such as containerd, reassembling the lines
+// they split up and adding the namespace, pod and container they belong to.
+type CRILogInput struct {
+	*LogstreamerInput
+	conf           *CRILogInputConfig
+	partialTimeout time.Duration
+	metadata       *podMetadata
+	labelFields    map[string]bool
+	parseFailures  int64
+	partialCount   int64
+}
+
+func (ci *CRILogInput) ConfigStruct() interface{} {
+	baseDir := ci.pConfig.Globals.BaseDir
+	return &CRILogInputConfig{
+		LogDirectory:      "/var/log/pods",
+		JournalDirectory:  filepath.Join(baseDir, "logstreamer"),
+		OldestDuration:    "720h",
+		RescanInterval:    "1m",
+		CheckDataInterval: "250ms",
+		Splitter:          "TokenSplitter",
+		PartialTimeout:    "5s",
+		MaxLineSize:       message.MAX_MESSAGE_SIZE,
+	}
+}
+
+func (ci *CRILogInput) Init(config interface{}) (err error) {
+	conf := config.(*CRILogInputConfig)
+	ci.conf = conf
+	if ci.partialTimeout, err = time.ParseDuration(conf.PartialTimeout); err != nil {
+		return fmt.Errorf("invalid partial_timeout: %s", err)
+	}
+	if conf.MaxLineSize == 0 {
+		return errors.New("max_line_size must be greater than zero")
+	}
+	rescanInterval, err := time.ParseDuration(conf.RescanInterval)
+	if err != nil {
+		return err
+	}
+	if conf.MetadataFile != "" {
+		ci.metadata = &podMetadata{path: conf.MetadataFile, checkInterval: rescanInterval}
+		if err = ci.metadata.load(); err != nil {
+			return fmt.Errorf("loading pod metadata: %s", err)
+		}
+		ci.labelFields = make(map[string]bool)
+		for _, name := range conf.FieldsFromLabels {
+			ci.labelFields[name] = true
+		}
+	}
+
+	ci.newHandler = ci.newCRIHandler
+	return ci.LogstreamerInput.Init(&LogstreamerInputConfig{
+		Hostname:          conf.Hostname,
+		LogDirectory:      conf.LogDirectory,
+		JournalDirectory:  conf.JournalDirectory,
+		FileMatch:         criFileMatch,
+		Exclude:           conf.Exclude,
+		Priority:          []string{"RestartCount", "RotationDate", "RotationTime"},
+		Differentiator:    []string{ci.pluginName, "-", "PodUid", "-", "ContainerName"},
+		OldestDuration:    conf.OldestDuration,
+		Translation:       criTranslation(),
+		RescanInterval:    conf.RescanInterval,
+		CheckDataInterval: conf.CheckDataInterval,
+		Splitter:          conf.Splitter,
+		InitialTail:       conf.InitialTail,
+		UseInotify:        conf.UseInotify,
+	})
+}
+
+// Sorts the current logfile of a container after the rotated ones.
+func criTranslation() ls.SubmatchTranslationMap {
+	return ls.SubmatchTranslationMap{
+		"RotationDate": ls.MatchTranslationMap{"missing": 99999999},
+		"RotationTime": ls.MatchTranslationMap{"missing": 999999},
+	}
+}
+
+func (ci *CRILogInput) newCRIHandler(name string, stream *ls.Logstream) recordHandler {
+	h := &criHandler{
+		input:    ci,
+		partials: make(map[string]*criPartial),
+	}
+	if logfiles := stream.GetLogfiles(); len(logfiles) > 0 {
+		parts := logfiles[0].StringMatchParts
+		for _, name := range criPathFields {
+			h.pathValues = append(h.pathValues, parts[name])
+		}
+		h.podUid = parts["PodUid"]
+	}
+	return h
+}
+
+func (ci *CRILogInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "ParseFailureCount",
+		atomic.LoadInt64(&ci.parseFailures), "count")
+	message.NewInt64Field(msg, "IncompleteLineCount",
+		atomic.LoadInt64(&ci.partialCount), "count")
+	return ci.LogstreamerInput.ReportMsg(msg)
+}
+
+// A line that's being reassembled from its parts.
+type criPartial struct {
+	timestamp int64
+	content   []byte
+	since     time.Time
+	// Where the first record of the line starts in the logstream.
+	offset int64
+}
+
+// Parses the CRI lines of a logstream into messages. Lines the container
+// runtime split into parts are held on to until their last part is read, per
+// output stream, since the parts of stdout and stderr lines can interleave.
+type criHandler struct {
+	input      *CRILogInput
+	pathValues []string
+	podUid     string
+	partials   map[string]*criPartial
+}
+
+// Parses a line of a CRI logfile, i.e. `<RFC3339 time> <stream> <tags>
+// <content>`. The first tag is "P" for partial lines and "F" for full ones,
+// any further tags are separated by ':'.
+func parseCRILine(line []byte) (timestamp int64, stream string, partial bool,
+	content []byte, err error) {
+
+	parts := bytes.SplitN(line, []byte{' '}, 4)
+	if len(parts) < 3 {
+		return 0, "", false, nil, errors.New("missing fields")
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(parts[0]))
+	if err != nil {
+		return 0, "", false, nil, err
+	}
+	stream = string(parts[1])
+	if stream != "stdout" && stream != "stderr" {
+		return 0, "", false, nil, fmt.Errorf("invalid stream '%s'", stream)
+	}
+	switch tags := strings.SplitN(string(parts[2]), ":", 2); tags[0] {
+	case "P":
+		partial = true
+	case "F":
+	default:
+		return 0, "", false, nil, fmt.Errorf("invalid tag '%s'", tags[0])
+	}
+	if len(parts) == 4 {
+		content = parts[3]
+	}
+	return t.UnixNano(), stream, partial, content, nil
+}
+
+func (h *criHandler) HandleRecord(record []byte, offset int64, ir p.InputRunner,
+	deliverer p.Deliverer) {
+
+	line := bytes.TrimSuffix(record, []byte{'\n'})
+	timestamp, stream, partial, content, err := parseCRILine(line)
+	if err != nil {
+		atomic.AddInt64(&h.input.parseFailures, 1)
+		h.deliver(ir, deliverer, time.Now().UnixNano(), "", line, false)
+		return
+	}
+
+	pending := h.partials[stream]
+	if pending == nil {
+		if !partial {
+			h.deliver(ir, deliverer, timestamp, stream, content, false)
+			return
+		}
+		pending = &criPartial{timestamp: timestamp, since: time.Now(), offset: offset}
+		h.partials[stream] = pending
+	}
+	if len(pending.content)+len(content) > int(h.input.conf.MaxLineSize) {
+		h.deliver(ir, deliverer, pending.timestamp, stream, pending.content, true)
+		pending.timestamp, pending.content, pending.offset = timestamp, nil, offset
+	}
+	pending.content = append(pending.content, content...)
+	if !partial {
+		delete(h.partials, stream)
+		h.deliver(ir, deliverer, pending.timestamp, stream, pending.content, false)
+	}
+}
+
+// Partial lines are read again after a restart, as long as the logstream's
+// position is kept at the start of the oldest one.
+func (h *criHandler) HeldFrom() (offset int64, held bool) {
+	for _, pending := range h.partials {
+		if !held || pending.offset < offset {
+			offset, held = pending.offset, true
+		}
+	}
+	return offset, held
+}
+
+// Has the logstream checked often enough to deliver partial lines once their
+// partial_timeout has passed, even when it's only checked at the
+// rescan_interval because inotify is in use.
+func (h *criHandler) FlushTimeout() time.Duration {
+	return h.input.partialTimeout
+}
+
+func (h *criHandler) Flush(ir p.InputRunner, deliverer p.Deliverer, final bool) {
+	streams := make([]string, 0, len(h.partials))
+	for stream := range h.partials {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	for _, stream := range streams {
+		pending := h.partials[stream]
+		if final || time.Since(pending.since) >= h.input.partialTimeout {
+			delete(h.partials, stream)
+			h.deliver(ir, deliverer, pending.timestamp, stream, pending.content, true)
+		}
+	}
+}
+
+// Delivers a line, incomplete is set for partial lines delivered before their
+// last part was read.
+func (h *criHandler) deliver(ir p.InputRunner, deliverer p.Deliverer,
+	timestamp int64, stream string, content []byte, incomplete bool) {
+
+	pack := <-ir.InChan()
+	msg := pack.Message
+	msg.SetUuid(uuid.NewRandom())
+	msg.SetTimestamp(timestamp)
+	msg.SetType("CRILog")
+	msg.SetLogger(stream) // stdout or stderr
+	msg.SetHostname(h.input.hostName)
+	msg.SetPayload(string(content))
+	for i, value := range h.pathValues {
+		message.NewStringField(msg, criPathFields[i], value)
+	}
+	if incomplete {
+		atomic.AddInt64(&h.input.partialCount, 1)
+		field, _ := message.NewField("Incomplete", true, "")
+		msg.AddField(field)
+	}
+	if h.input.metadata != nil {
+		h.addMetadataFields(ir, msg)
+	}
+	deliverer.Deliver(pack)
+}
+
+func (h *criHandler) addMetadataFields(ir p.InputRunner, msg *message.Message) {
+	pod, err := h.input.metadata.lookup(h.podUid)
+	if err != nil {
+		ir.LogError(fmt.Errorf("Error reloading pod metadata: %s", err))
+	}
+	if pod == nil {
+		return
+	}
+	// Labels override annotations of the same name.
+	conf := h.input.conf
+	for _, name := range conf.FieldsFromAnnotations {
+		if _, ok := pod.Metadata.Labels[name]; ok && h.input.labelFields[name] {
+			continue
+		}
+		if value, ok := pod.Metadata.Annotations[name]; ok {
+			message.NewStringField(msg, name, value)
+		}
+	}
+	for _, name := range conf.FieldsFromLabels {
+		if value, ok := pod.Metadata.Labels[name]; ok {
+			message.NewStringField(msg, name, value)
+		}
+	}
+}
+
+// A pod of a Kubernetes PodList, with the metadata we use.
+type podInfo struct {
+	Metadata struct {
+		Uid         string            `json:"uid"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// The pods of the metadata file, reloaded when it changes.
+type podMetadata struct {
+	path          string
+	checkInterval time.Duration
+	lock          sync.Mutex
+	pods          map[string]*podInfo
+	modTime       time.Time
+	lastCheck     time.Time
+}
+
+// Reads the pods from the metadata file if it was modified since the last
+// time. A missing file leaves no pods.
+func (m *podMetadata) load() error {
+	m.lastCheck = time.Now()
+	info, err := os.Stat(m.path)
+	if os.IsNotExist(err) {
+		m.pods, m.modTime = nil, time.Time{}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(m.modTime) && m.pods != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	var list struct {
+		Items []*podInfo `json:"items"`
+	}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("parsing %s: %s", m.path, err)
+	}
+	m.pods = make(map[string]*podInfo, len(list.Items))
+	for _, pod := range list.Items {
+		m.pods[pod.Metadata.Uid] = pod
+	}
+	m.modTime = info.ModTime()
+	return nil
+}
+
+// Returns the pod with the uid, reloading the file if it's time to check
+// for changes. The pods loaded before are kept if reloading fails.
+func (m *podMetadata) lookup(uid string) (pod *podInfo, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if time.Since(m.lastCheck) >= m.checkInterval {
+		err = m.load()
+	}
+	return m.pods[uid], err
+}
+
+func init() {
+	p.RegisterPlugin("CRILogInput", func() interface{} {
+		return &CRILogInput{LogstreamerInput: new(LogstreamerInput)}
+	})
+}
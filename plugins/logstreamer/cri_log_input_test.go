@@ -0,0 +1,375 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package logstreamer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	ls "github.com/mozilla-services/heka/logstreamer"
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func CRILogInputSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "cri-log-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+
+	globals := DefaultGlobals()
+	globals.BaseDir = tmpDir
+	pConfig := NewPipelineConfig(globals)
+
+	podDir := "default_web-6d4cf56db6-x8zvq_0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10"
+	podsDir := filepath.Join(tmpDir, "pods")
+	for _, name := range []string{
+		"nginx/0.log.20160501-120000.gz",
+		"nginx/0.log.20160502-120000",
+		"nginx/0.log.20160503-120000.zst",
+		"nginx/0.log",
+		"nginx/1.log",
+		"sidecar/0.log",
+	} {
+		path := filepath.Join(podsDir, podDir, filepath.FromSlash(name))
+		c.Assume(os.MkdirAll(filepath.Dir(path), 0755), gs.IsNil)
+		c.Assume(ioutil.WriteFile(path, nil, 0644), gs.IsNil)
+	}
+
+	newInput := func() (*CRILogInput, *CRILogInputConfig) {
+		input := &CRILogInput{LogstreamerInput: &LogstreamerInput{pConfig: pConfig}}
+		input.SetName("pods")
+		config := input.ConfigStruct().(*CRILogInputConfig)
+		config.LogDirectory = podsDir
+		config.Hostname = "node1"
+		return input, config
+	}
+
+	c.Specify("A CRI log line", func() {
+		c.Specify("is parsed", func() {
+			ts, stream, partial, content, err := parseCRILine(
+				[]byte("2016-05-01T12:00:00.123456789Z stderr F oops: it failed"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(ts, gs.Equals, int64(1462104000123456789))
+			c.Expect(stream, gs.Equals, "stderr")
+			c.Expect(partial, gs.IsFalse)
+			c.Expect(string(content), gs.Equals, "oops: it failed")
+
+			_, stream, partial, content, err = parseCRILine(
+				[]byte("2016-05-01T14:00:00.5+02:00 stdout P:x "))
+			c.Expect(err, gs.IsNil)
+			c.Expect(stream, gs.Equals, "stdout")
+			c.Expect(partial, gs.IsTrue)
+			c.Expect(len(content), gs.Equals, 0)
+		})
+
+		c.Specify("is rejected when malformed", func() {
+			for _, line := range []string{
+				"hello world",
+				"2016-05-01 stdout F hi",
+				"2016-05-01T12:00:00Z stdin F hi",
+				"2016-05-01T12:00:00Z stdout X hi",
+			} {
+				_, _, _, _, err := parseCRILine([]byte(line))
+				c.Expect(err, gs.Not(gs.IsNil))
+			}
+		})
+	})
+
+	c.Specify("A CRILogInput", func() {
+		input, config := newInput()
+
+		c.Specify("makes a logstream per container", func() {
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			c.Expect(len(input.plugins), gs.Equals, 2)
+			lsi, ok := input.plugins["pods-0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10-nginx"]
+			c.Assume(ok, gs.IsTrue)
+
+			var names []string
+			for _, logfile := range lsi.stream.GetLogfiles() {
+				names = append(names, filepath.Base(logfile.FileName))
+			}
+			c.Expect(names, gs.ContainsExactly, []string{"0.log.20160501-120000.gz",
+				"0.log.20160502-120000", "0.log.20160503-120000.zst", "0.log", "1.log"})
+
+			h := lsi.handler.(*criHandler)
+			c.Expect(h.pathValues, gs.ContainsExactly, []string{"default",
+				"web-6d4cf56db6-x8zvq", "0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10", "nginx"})
+		})
+
+		c.Specify("rejects an invalid partial timeout", func() {
+			config.PartialTimeout = "soon"
+			c.Expect(input.Init(config), gs.Not(gs.IsNil))
+		})
+
+		mockIR := pipelinemock.NewMockInputRunner(ctrl)
+		mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+		packSupply := make(chan *PipelinePack, 10)
+		for i := 0; i < 10; i++ {
+			packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+		}
+		delivered := make(chan *PipelinePack, 10)
+		mockIR.EXPECT().InChan().Return(packSupply).AnyTimes()
+		mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+		mockDeliverer.EXPECT().Deliver(gomock.Any()).Do(func(pack *PipelinePack) {
+			delivered <- pack
+		}).AnyTimes()
+
+		c.Specify("handling records", func() {
+			metadataPath := filepath.Join(tmpDir, "pods.json")
+			podList := `{"kind": "PodList", "items": [{"metadata": {
+				"name": "web-6d4cf56db6-x8zvq", "namespace": "default",
+				"uid": "0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10",
+				"labels": {"app": "web", "tier": "frontend"},
+				"annotations": {"team": "payments", "app": "shop"}}}]}`
+			c.Assume(ioutil.WriteFile(metadataPath, []byte(podList), 0644), gs.IsNil)
+			config.MetadataFile = metadataPath
+			config.FieldsFromLabels = []string{"app", "release"}
+			config.FieldsFromAnnotations = []string{"team", "app"}
+			config.MaxLineSize = 16
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			h := input.plugins["pods-0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10-nginx"].handler
+			var offset int64
+			handle := func(line string) {
+				h.HandleRecord([]byte(line+"\n"), offset, mockIR, mockDeliverer)
+				offset += int64(len(line) + 1)
+			}
+
+			c.Specify("delivers full lines with the pod's fields", func() {
+				handle("2016-05-01T12:00:00Z stdout F GET /index.html")
+				c.Assume(len(delivered), gs.Equals, 1)
+				msg := (<-delivered).Message
+				c.Expect(msg.GetType(), gs.Equals, "CRILog")
+				c.Expect(msg.GetLogger(), gs.Equals, "stdout")
+				c.Expect(msg.GetHostname(), gs.Equals, "node1")
+				c.Expect(msg.GetPayload(), gs.Equals, "GET /index.html")
+				c.Expect(msg.GetTimestamp(), gs.Equals, int64(1462104000000000000))
+				for name, expected := range map[string]string{
+					"Namespace":     "default",
+					"PodName":       "web-6d4cf56db6-x8zvq",
+					"PodUid":        "0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10",
+					"ContainerName": "nginx",
+					"app":           "web",
+					"team":          "payments",
+				} {
+					value, _ := msg.GetFieldValue(name)
+					c.Expect(value, gs.Equals, expected)
+				}
+				c.Expect(len(msg.FindAllFields("app")), gs.Equals, 1)
+				_, ok := msg.GetFieldValue("tier")
+				c.Expect(ok, gs.IsFalse)
+				_, ok = msg.GetFieldValue("Incomplete")
+				c.Expect(ok, gs.IsFalse)
+			})
+
+			c.Specify("reassembles partial lines per stream", func() {
+				handle("2016-05-01T12:00:00Z stdout P one ")
+				handle("2016-05-01T12:00:01Z stderr P uh ")
+				handle("2016-05-01T12:00:02Z stdout P two ")
+				handle("2016-05-01T12:00:03Z stderr F oh")
+				c.Assume(len(delivered), gs.Equals, 1)
+				msg := (<-delivered).Message
+				c.Expect(msg.GetLogger(), gs.Equals, "stderr")
+				c.Expect(msg.GetPayload(), gs.Equals, "uh oh")
+				c.Expect(msg.GetTimestamp(), gs.Equals, int64(1462104001000000000))
+
+				handle("2016-05-01T12:00:04Z stdout F three")
+				c.Assume(len(delivered), gs.Equals, 1)
+				msg = (<-delivered).Message
+				c.Expect(msg.GetLogger(), gs.Equals, "stdout")
+				c.Expect(msg.GetPayload(), gs.Equals, "one two three")
+				c.Expect(msg.GetTimestamp(), gs.Equals, int64(1462104000000000000))
+			})
+
+			c.Specify("reports where the oldest held line starts", func() {
+				_, held := h.HeldFrom()
+				c.Expect(held, gs.IsFalse)
+				handle("2016-05-01T12:00:00Z stdout F ready")
+				handle("2016-05-01T12:00:01Z stdout P one ")
+				handle("2016-05-01T12:00:02Z stderr P uh ")
+				from, held := h.HeldFrom()
+				c.Expect(held, gs.IsTrue)
+				c.Expect(from, gs.Equals, int64(36))
+				handle("2016-05-01T12:00:03Z stdout F two")
+				from, _ = h.HeldFrom()
+				c.Expect(from, gs.Equals, int64(71))
+				handle("2016-05-01T12:00:04Z stderr F oh")
+				_, held = h.HeldFrom()
+				c.Expect(held, gs.IsFalse)
+			})
+
+			c.Specify("delivers what there is of long lines", func() {
+				handle("2016-05-01T12:00:00Z stdout P 0123456789")
+				handle("2016-05-01T12:00:01Z stdout P abcdefghij")
+				handle("2016-05-01T12:00:02Z stdout F !")
+				c.Assume(len(delivered), gs.Equals, 2)
+				msg := (<-delivered).Message
+				c.Expect(msg.GetPayload(), gs.Equals, "0123456789")
+				incomplete, _ := msg.GetFieldValue("Incomplete")
+				c.Expect(incomplete, gs.Equals, true)
+				msg = (<-delivered).Message
+				c.Expect(msg.GetPayload(), gs.Equals, "abcdefghij!")
+			})
+
+			c.Specify("flushes partial lines", func() {
+				handle("2016-05-01T12:00:00Z stdout P cut")
+				h.Flush(mockIR, mockDeliverer, false)
+				c.Expect(len(delivered), gs.Equals, 0)
+
+				c.Specify("after the partial timeout", func() {
+					input.partialTimeout = time.Millisecond
+					time.Sleep(2 * time.Millisecond)
+					h.Flush(mockIR, mockDeliverer, false)
+				})
+
+				c.Specify("when stopping", func() {
+					h.Flush(mockIR, mockDeliverer, true)
+				})
+
+				c.Assume(len(delivered), gs.Equals, 1)
+				msg := (<-delivered).Message
+				c.Expect(msg.GetPayload(), gs.Equals, "cut")
+				incomplete, _ := msg.GetFieldValue("Incomplete")
+				c.Expect(incomplete, gs.Equals, true)
+			})
+
+			c.Specify("delivers malformed lines as they are", func() {
+				handle("not a CRI line")
+				c.Assume(len(delivered), gs.Equals, 1)
+				msg := (<-delivered).Message
+				c.Expect(msg.GetPayload(), gs.Equals, "not a CRI line")
+				c.Expect(input.parseFailures, gs.Equals, int64(1))
+			})
+		})
+
+		c.Specify("checks for data often enough to flush partial lines", func() {
+			if runtime.GOOS != "linux" {
+				return
+			}
+			config.UseInotify = true
+			config.RescanInterval = "1h"
+			config.PartialTimeout = "2s"
+			c.Assume(input.Init(config), gs.IsNil)
+			defer input.logstreamSet.StopWatching()
+
+			mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+			mockSR.EXPECT().Splitter().Return(nil)
+			lsi := input.plugins["pods-0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10-sidecar"]
+			lsi.sRunner = mockSR
+			c.Expect(lsi.checkDataInterval, gs.Equals, time.Hour)
+			c.Expect(lsi.tickInterval(), gs.Equals, 2*time.Second)
+		})
+
+		c.Specify("runs its logstreams through the handler", func() {
+			config.Exclude = []string{"*/nginx"}
+			c.Assume(input.Init(config), gs.IsNil)
+			c.Assume(len(input.plugins), gs.Equals, 1)
+
+			mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+			mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+			mockIR.EXPECT().NewDeliverer("1").Return(mockDeliverer)
+			mockIR.EXPECT().NewSplitterRunner("1").Return(mockSR)
+			mockSR.EXPECT().UseMsgBytes().Return(false)
+			mockSR.EXPECT().SetPackDecorator(gomock.Any())
+			mockSR.EXPECT().Splitter().Return(nil)
+			mockSR.EXPECT().IncompleteFinal().Return(false).AnyTimes()
+			lines := []string{
+				"2016-05-01T12:00:00Z stdout F ready\n",
+				"2016-05-01T12:00:01Z stdout P waiting for",
+			}
+			for _, line := range lines {
+				mockSR.EXPECT().GetRecordFromStream(gomock.Any()).Return(len(line),
+					[]byte(line), nil)
+			}
+			mockSR.EXPECT().GetRecordFromStream(gomock.Any()).Return(0, nil,
+				io.EOF).AnyTimes()
+			mockSR.EXPECT().Done()
+			mockDeliverer.EXPECT().Done()
+
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- input.Run(mockIR, mockHelper)
+			}()
+			msg := (<-delivered).Message
+			c.Expect(msg.GetPayload(), gs.Equals, "ready")
+			value, _ := msg.GetFieldValue("ContainerName")
+			c.Expect(value, gs.Equals, "sidecar")
+
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+			c.Assume(len(delivered), gs.Equals, 1)
+			msg = (<-delivered).Message
+			c.Expect(msg.GetPayload(), gs.Equals, "waiting for")
+			incomplete, _ := msg.GetFieldValue("Incomplete")
+			c.Expect(incomplete, gs.Equals, true)
+		})
+
+		c.Specify("keeps the journal at the start of held partial lines", func() {
+			lines := []string{
+				"2016-05-01T12:00:00Z stdout F ready\n",
+				"2016-05-01T12:00:01Z stdout P waiting for\n",
+			}
+			logPath := filepath.Join(podsDir, podDir, "sidecar", "0.log")
+			err := ioutil.WriteFile(logPath, []byte(strings.Join(lines, "")), 0644)
+			c.Assume(err, gs.IsNil)
+			config.Exclude = []string{"*/nginx"}
+			c.Assume(input.Init(config), gs.IsNil)
+			lsi := input.plugins["pods-0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10-sidecar"]
+			c.Assume(lsi, gs.Not(gs.IsNil))
+
+			mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+			mockSR.EXPECT().IncompleteFinal().Return(false).AnyTimes()
+			for _, line := range lines {
+				line := line
+				mockSR.EXPECT().GetRecordFromStream(gomock.Any()).Do(func(r io.Reader) {
+					io.ReadFull(r, make([]byte, len(line)))
+				}).Return(len(line), []byte(line), nil)
+			}
+			mockSR.EXPECT().GetRecordFromStream(gomock.Any()).Return(0, nil, io.EOF)
+			lsi.ir, lsi.deliverer, lsi.sRunner = mockIR, mockDeliverer, mockSR
+			lsi.stopChan = make(chan chan bool)
+
+			savedPosition := func() int64 {
+				lsi.stream.SavePosition()
+				journals, err := ls.LoadJournals(config.JournalDirectory)
+				c.Assume(err, gs.IsNil)
+				location := journals["pods-0c9e2b1a-7f1e-4d2b-9a61-3f1f3b6c2d10-sidecar"]
+				c.Assume(location, gs.Not(gs.IsNil))
+				return location.SeekPosition
+			}
+			c.Expect(lsi.deliverRecords(), gs.Equals, io.EOF)
+			c.Expect(len(delivered), gs.Equals, 1)
+			c.Expect(savedPosition(), gs.Equals, int64(len(lines[0])))
+
+			lsi.handler.Flush(mockIR, mockDeliverer, true)
+			lsi.flushPosition()
+			c.Expect(len(delivered), gs.Equals, 2)
+			c.Expect(savedPosition(), gs.Equals, int64(len(lines[0])+len(lines[1])))
+		})
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,15 +30,27 @@ import (
 	p "github.com/mozilla-services/heka/pipeline"
 )
 
+// How long Init waits for a journal directory that heka-logstreamer has
+// locked.
+const journalLockTimeout = 5 * time.Second
+
 type LogstreamerInputConfig struct {
 	// Hostname to use for the generated logfile message objects.
 	Hostname string
 	// Log base directory to run log regex under
 	LogDirectory string `toml:"log_directory"`
+	// Several log base directories, replacing `log_directory` when set
+	LogDirectories []string `toml:"log_directories"`
 	// Journal base directory for saving journal files
 	JournalDirectory string `toml:"journal_directory"`
 	// File match for regular expression
 	FileMatch string `toml:"file_match"`
+	// Glob patterns of logfiles, absolute or relative to the log directories
+	FileGlob []string `toml:"file_glob"`
+	// Glob patterns of files and directories to leave out
+	Exclude []string
+	// How many levels of directories below the log directories to search
+	MaxDepth int `toml:"max_depth"`
 	// Priority to sort in
 	Priority []string
 	// Differentiator for splitting out logstreams if applicable
@@ -56,6 +69,9 @@ type LogstreamerInputConfig struct {
 	Splitter string
 	// Whether to ignore previous logfiles while initial scan
 	InitialTail bool `toml:"initial_tail"`
+	// Use inotify to find new logfiles and learn about new data instead of
+	// rescanning and polling, Linux only.
+	UseInotify bool `toml:"use_inotify"`
 }
 
 type LogstreamerInput struct {
@@ -72,6 +88,14 @@ type LogstreamerInput struct {
 	delimiterLocation  string
 	hostName           string
 	pluginName         string
+	numStreams         int
+	// Keeps heka-logstreamer from changing the journals while we use them.
+	journalLock *ls.JournalLock
+	// Set if `use_inotify` is on but watching failed.
+	watchErr error
+	// Creates the record handlers of new logstreams, if records aren't
+	// simply delivered to the splitter runner.
+	newHandler func(name string, stream *ls.Logstream) recordHandler
 }
 
 // Heka will call this before calling any other methods to give us access to
@@ -109,9 +133,20 @@ func (li *LogstreamerInput) Init(config interface{}) (err error) {
 	if err = os.MkdirAll(conf.JournalDirectory, 0744); err != nil {
 		return err
 	}
+	li.journalLock, err = ls.LockJournalsWait(conf.JournalDirectory, false,
+		journalLockTimeout)
+	if err != nil {
+		return fmt.Errorf("Can't lock journal directory '%s': %s",
+			conf.JournalDirectory, err)
+	}
+	defer func() {
+		if err != nil {
+			li.journalLock.Unlock()
+		}
+	}()
 
-	if conf.FileMatch == "" {
-		return errors.New("`file_match` setting is required.")
+	if conf.FileMatch == "" && len(conf.FileGlob) == 0 {
+		return errors.New("`file_match` or `file_glob` setting is required.")
 	}
 	if len(conf.FileMatch) > 0 && conf.FileMatch[len(conf.FileMatch)-1:] != "$" {
 		conf.FileMatch += "$"
@@ -131,9 +166,23 @@ func (li *LogstreamerInput) Init(config interface{}) (err error) {
 	if oldest, err = time.ParseDuration(conf.OldestDuration); err != nil {
 		return
 	}
-	// If no differentiator is present then we use the plugin name.
+	// If no differentiator is present then we use the plugin name, or each
+	// globbed logfile's path.
 	if len(conf.Differentiator) == 0 {
-		conf.Differentiator = []string{li.pluginName}
+		if conf.FileMatch == "" {
+			conf.Differentiator = []string{ls.FilePathMatchPart}
+		} else {
+			conf.Differentiator = []string{li.pluginName}
+		}
+	}
+	selection := &ls.FileSelection{
+		Roots:    conf.LogDirectories,
+		Globs:    conf.FileGlob,
+		Exclude:  conf.Exclude,
+		MaxDepth: conf.MaxDepth,
+	}
+	if len(selection.Roots) == 0 {
+		selection.Roots = []string{conf.LogDirectory}
 	}
 
 	for name, submap := range conf.Translation {
@@ -157,14 +206,22 @@ func (li *LogstreamerInput) Init(config interface{}) (err error) {
 	// Create the main logstream set
 	li.logstreamSetLock.Lock()
 	defer li.logstreamSetLock.Unlock()
-	li.logstreamSet, err = ls.NewLogstreamSet(sp, oldest, conf.LogDirectory,
+	li.logstreamSet, err = ls.NewLogstreamSetWithSelection(sp, oldest, selection,
 		conf.JournalDirectory, conf.InitialTail)
 	if err != nil {
 		return
 	}
+	if conf.UseInotify {
+		// Watch before the initial scan so no new logfiles are missed.
+		if _, li.watchErr = li.logstreamSet.Watch(); li.watchErr != nil {
+			li.watchErr = fmt.Errorf("Can't watch '%s', polling instead: %s",
+				strings.Join(selection.Roots, "', '"), li.watchErr)
+		}
+	}
 	// Initial scan for logstreams
 	plugins, errs = li.logstreamSet.ScanForLogstreams()
 	if errs.IsError() {
+		li.logstreamSet.StopWatching()
 		return errs
 	}
 	// Declare our hostname
@@ -180,40 +237,113 @@ func (li *LogstreamerInput) Init(config interface{}) (err error) {
 		if !ok {
 			continue
 		}
-		li.plugins[name] = NewLogstreamInput(stream, name, li.hostName, li.checkDataInterval)
+		li.plugins[name] = li.newLogstreamInput(stream, name)
 	}
 	li.stopLogstreamChans = make([]chan chan bool, 0, len(plugins))
 	li.stopChan = make(chan bool)
 	return
 }
 
+func (li *LogstreamerInput) newLogstreamInput(stream *ls.Logstream,
+	name string) *LogstreamInput {
+
+	lsi := NewLogstreamInput(stream, name, li.hostName, li.streamCheckInterval())
+	if li.newHandler != nil {
+		lsi.handler = li.newHandler(name, stream)
+	}
+	return lsi
+}
+
+// Returns how often the logstreams check for data on their own. When watching
+// they are woken up when their files are written to, checking at the rescan
+// interval only catches what the watch might have missed.
+func (li *LogstreamerInput) streamCheckInterval() time.Duration {
+	if li.watcher() != nil {
+		return li.rescanInterval
+	}
+	return li.checkDataInterval
+}
+
+func (li *LogstreamerInput) watcher() *ls.Watcher {
+	return li.logstreamSet.Watcher()
+}
+
 // Creates deliverer and stop channel and starts the provided LogstreamInput.
-func (li *LogstreamerInput) startLogstreamInput(logstream *LogstreamInput, i int,
+func (li *LogstreamerInput) startLogstreamInput(logstream *LogstreamInput,
 	ir p.InputRunner, h p.PluginHelper) {
 
+	li.numStreams++
 	stop := make(chan chan bool, 1)
-	token := strconv.Itoa(i)
+	token := strconv.Itoa(li.numStreams)
 	deliverer := ir.NewDeliverer(token)
 	sRunner := ir.NewSplitterRunner(token)
 	li.stopLogstreamChans = append(li.stopLogstreamChans, stop)
 	go logstream.Run(ir, h, stop, deliverer, sRunner)
 }
 
+// Scans for new logstreams and starts inputs for them.
+func (li *LogstreamerInput) rescan(ir p.InputRunner, h p.PluginHelper) {
+	li.logstreamSetLock.Lock()
+	defer li.logstreamSetLock.Unlock()
+	newstreams, errs := li.logstreamSet.ScanForLogstreams()
+	if errs.IsError() {
+		ir.LogError(errs)
+	}
+	for _, name := range newstreams {
+		stream, ok := li.logstreamSet.GetLogstream(name)
+		if !ok {
+			ir.LogError(fmt.Errorf("Found new logstream: %s, but couldn't fetch it.",
+				name))
+			continue
+		}
+
+		lsi := li.newLogstreamInput(stream, name)
+		li.plugins[name] = lsi
+		li.startLogstreamInput(lsi, ir, h)
+	}
+}
+
+// Wakes up all the logstream inputs to check for data.
+func (li *LogstreamerInput) wakeAll() {
+	for _, lsi := range li.plugins {
+		lsi.wakeUp()
+	}
+}
+
 // Main Logstreamer Input runner. This runner kicks off all the other
 // logstream inputs, and handles rescanning for updates to the filesystem that
 // might affect file visibility for the logstream inputs.
 func (li *LogstreamerInput) Run(ir p.InputRunner, h p.PluginHelper) (err error) {
 	var (
-		ok         bool
-		errs       *ls.MultipleError
-		newstreams []string
+		ok          bool
+		watchEvents <-chan ls.WatchEvent
+		watchErrors <-chan error
+		// Set when the watch fails and the logstream inputs have to be
+		// woken up at the check data interval instead.
+		pollTick <-chan time.Time
+		// Delays the scans triggered by watch events so bursts of them,
+		// e.g. from log rotation, cause a single scan.
+		rescanTimer <-chan time.Time
 	)
 
+	if li.watchErr != nil {
+		ir.LogError(li.watchErr)
+	}
+	if watcher := li.watcher(); watcher != nil {
+		watchEvents = watcher.Events()
+		watchErrors = watcher.Errors()
+	}
+	stopWatching := func(err error) {
+		ir.LogError(fmt.Errorf("Watching for changes failed, polling instead: %s",
+			err))
+		li.logstreamSet.StopWatching()
+		watchEvents, watchErrors = nil, nil
+		pollTick = time.Tick(li.checkDataInterval)
+	}
+
 	// Kick off all the current logstreams we know of
-	i := 0
 	for _, logstream := range li.plugins {
-		i++
-		li.startLogstreamInput(logstream, i, ir, h)
+		li.startLogstreamInput(logstream, ir, h)
 	}
 
 	ok = true
@@ -223,6 +353,7 @@ func (li *LogstreamerInput) Run(ir p.InputRunner, h p.PluginHelper) (err error)
 		select {
 		case <-li.stopChan:
 			ok = false
+			li.logstreamSet.StopWatching()
 			returnChans := make([]chan bool, len(li.stopLogstreamChans))
 			// Send out all the stop signals
 			for i, ch := range li.stopLogstreamChans {
@@ -235,29 +366,35 @@ func (li *LogstreamerInput) Run(ir p.InputRunner, h p.PluginHelper) (err error)
 			for _, ch := range returnChans {
 				<-ch
 			}
+			li.journalLock.Unlock()
 
 			// Close our own stopChan to indicate we shut down
 			close(li.stopChan)
 		case <-rescan:
-			li.logstreamSetLock.Lock()
-			newstreams, errs = li.logstreamSet.ScanForLogstreams()
-			if errs.IsError() {
-				ir.LogError(errs)
+			li.rescan(ir, h)
+		case event, eventOk := <-watchEvents:
+			if !eventOk {
+				stopWatching(errors.New("inotify stopped"))
+				continue
 			}
-			for _, name := range newstreams {
-				stream, ok := li.logstreamSet.GetLogstream(name)
-				if !ok {
-					ir.LogError(fmt.Errorf("Found new logstream: %s, but couldn't fetch it.",
-						name))
-					continue
-				}
-
-				lsi := NewLogstreamInput(stream, name, li.hostName, li.checkDataInterval)
-				li.plugins[name] = lsi
-				i++
-				li.startLogstreamInput(lsi, i, ir, h)
+			needsScan, name := li.logstreamSet.HandleWatchEvent(event)
+			if needsScan && rescanTimer == nil {
+				rescanTimer = time.After(li.checkDataInterval)
 			}
-			li.logstreamSetLock.Unlock()
+			if lsi, found := li.plugins[name]; found {
+				lsi.wakeUp()
+			}
+		case err = <-watchErrors:
+			stopWatching(err)
+			err = nil
+		case <-rescanTimer:
+			rescanTimer = nil
+			li.rescan(ir, h)
+			// Streams with a new logfile move on to it once at the end of
+			// their current one.
+			li.wakeAll()
+		case <-pollTick:
+			li.wakeAll()
 		}
 	}
 	return nil
@@ -268,11 +405,35 @@ func (li *LogstreamerInput) Stop() {
 	<-li.stopChan
 }
 
+// Turns the records of a logstream into messages in place of the splitter
+// runner, for inputs that understand the format of their logfiles.
+type recordHandler interface {
+	// Delivers the messages of a record, possibly holding on to it until
+	// later records complete them. The offset is where the record starts in
+	// the data read from the logstream.
+	HandleRecord(record []byte, offset int64, ir p.InputRunner,
+		deliverer p.Deliverer)
+	// Returns the offset of the oldest record that is held, if any, so the
+	// logstream's position isn't moved past it.
+	HeldFrom() (offset int64, held bool)
+	// Delivers the held data that has waited too long, or all of it if
+	// final is set.
+	Flush(ir p.InputRunner, deliverer p.Deliverer, final bool)
+}
+
+// Implemented by record handlers that flush held data after a timeout, so the
+// logstream is checked often enough to do so on time.
+type flushingHandler interface {
+	FlushTimeout() time.Duration
+}
+
 type LogstreamInput struct {
-	stream              *ls.Logstream
-	loggerIdent         string
-	hostName            string
-	checkDataInterval   time.Duration
+	stream            *ls.Logstream
+	loggerIdent       string
+	hostName          string
+	checkDataInterval time.Duration
+	// Wakes the input up to check for data before its next interval.
+	wake                chan bool
 	recordCount         int
 	stopped             chan bool
 	prevMsgWasTruncated bool
@@ -280,6 +441,11 @@ type LogstreamInput struct {
 	stopChan            chan chan bool
 	deliverer           p.Deliverer
 	sRunner             p.SplitterRunner
+	handler             recordHandler
+	// Bytes read from the stream, and how many of them its position was
+	// moved past.
+	readOffset    int64
+	flushedOffset int64
 }
 
 func NewLogstreamInput(stream *ls.Logstream, loggerIdent,
@@ -290,10 +456,20 @@ func NewLogstreamInput(stream *ls.Logstream, loggerIdent,
 		loggerIdent:         loggerIdent,
 		hostName:            hostName,
 		checkDataInterval:   checkDataInterval,
+		wake:                make(chan bool, 1),
 		prevMsgWasTruncated: false,
 	}
 }
 
+// Asks the input to check for data, without waiting if it has already been
+// asked.
+func (lsi *LogstreamInput) wakeUp() {
+	select {
+	case lsi.wake <- true:
+	default:
+	}
+}
+
 func (lsi *LogstreamInput) Run(ir p.InputRunner, h p.PluginHelper, stopChan chan chan bool,
 	deliverer p.Deliverer, sRunner p.SplitterRunner) {
 
@@ -307,8 +483,7 @@ func (lsi *LogstreamInput) Run(ir p.InputRunner, h p.PluginHelper, stopChan chan
 	lsi.sRunner = sRunner
 	var err error
 
-	// Check for more data interval
-	tick := time.Tick(lsi.checkDataInterval)
+	tick := time.Tick(lsi.tickInterval())
 
 	ok := true
 	for ok {
@@ -326,6 +501,10 @@ func (lsi *LogstreamInput) Run(ir p.InputRunner, h p.PluginHelper, stopChan chan
 		if err != nil && err != io.EOF {
 			ir.LogError(err)
 		}
+		if lsi.handler != nil {
+			lsi.handler.Flush(ir, deliverer, false)
+			lsi.flushPosition()
+		}
 
 		// Did our parser func get stopped?
 		if lsi.stopped != nil {
@@ -339,13 +518,39 @@ func (lsi *LogstreamInput) Run(ir p.InputRunner, h p.PluginHelper, stopChan chan
 			ok = false
 		case <-tick:
 			continue
+		case <-lsi.wake:
+			continue
 		}
 	}
+	if lsi.handler != nil {
+		lsi.handler.Flush(ir, deliverer, true)
+		lsi.flushPosition()
+		lsi.stream.SavePosition()
+	}
 	close(lsi.stopped)
 	deliverer.Done()
 	sRunner.Done()
 }
 
+// Returns the interval to check for more data at, often enough to deliver
+// what the splitter or the record handler holds on to once its flush timeout
+// has passed.
+func (lsi *LogstreamInput) tickInterval() time.Duration {
+	interval := lsi.checkDataInterval
+	shorten := func(timeout time.Duration) {
+		if timeout > 0 && timeout < interval {
+			interval = timeout
+		}
+	}
+	if flusher, ok := lsi.sRunner.Splitter().(p.FlushingSplitter); ok {
+		shorten(flusher.FlushTimeout())
+	}
+	if flusher, ok := lsi.handler.(flushingHandler); ok {
+		shorten(flusher.FlushTimeout())
+	}
+	return interval
+}
+
 func (lsi *LogstreamInput) deliverRecords() (err error) {
 	var (
 		record []byte
@@ -371,29 +576,51 @@ func (lsi *LogstreamInput) deliverRecords() (err error) {
 			err = nil // non-fatal, keep going
 			isMessageTruncated = true
 		}
-		if n > 0 {
-			lsi.stream.FlushBuffer(n)
-		}
+		offset := lsi.readOffset
+		lsi.readOffset += int64(n)
 		if len(record) > 0 {
 			if lsi.prevMsgWasTruncated == false {
 				// Send the message only if previous record had normal size.
 				if !isMessageTruncated || lsi.sRunner.KeepTruncated() {
-					lsi.sRunner.DeliverRecord(record, lsi.deliverer)
-					lsi.countRecord()
+					lsi.deliverRecord(record, offset)
 				}
 			}
 		} else if err == io.EOF && lsi.sRunner.IncompleteFinal() {
 			record = lsi.sRunner.GetRemainingData()
 			if len(record) > 0 {
-				lsi.sRunner.DeliverRecord(record, lsi.deliverer)
-				lsi.countRecord()
+				lsi.deliverRecord(record, offset)
 			}
 		}
+		lsi.flushPosition()
 		lsi.prevMsgWasTruncated = isMessageTruncated
 	}
 	return err
 }
 
+// Moves the stream's position past the records that were read, but not past
+// those the handler holds on to, so they're read again after a restart.
+func (lsi *LogstreamInput) flushPosition() {
+	offset := lsi.readOffset
+	if lsi.handler != nil {
+		if held, ok := lsi.handler.HeldFrom(); ok && held < offset {
+			offset = held
+		}
+	}
+	if n := offset - lsi.flushedOffset; n > 0 {
+		lsi.stream.FlushBuffer(int(n))
+		lsi.flushedOffset = offset
+	}
+}
+
+func (lsi *LogstreamInput) deliverRecord(record []byte, offset int64) {
+	if lsi.handler != nil {
+		lsi.handler.HandleRecord(record, offset, lsi.ir, lsi.deliverer)
+	} else {
+		lsi.sRunner.DeliverRecord(record, lsi.deliverer)
+	}
+	lsi.countRecord()
+}
+
 func (lsi *LogstreamInput) packDecorator(pack *p.PipelinePack) {
 	pack.Message.SetType("logfile")
 	pack.Message.SetHostname(lsi.hostName)
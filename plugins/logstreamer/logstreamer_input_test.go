@@ -38,6 +38,7 @@ func TestAllSpecs(t *testing.T) {
 	r.Parallel = false
 
 	r.AddSpec(LogstreamerInputSpec)
+	r.AddSpec(CRILogInputSpec)
 
 	gs.MainGoTest(r, t)
 }
@@ -122,6 +123,7 @@ func LogstreamerInputSpec(c gs.Context) {
 				ith.MockSplitterRunner.EXPECT().UseMsgBytes().Return(false)
 				ith.MockSplitterRunner.EXPECT().IncompleteFinal().Return(false)
 				ith.MockSplitterRunner.EXPECT().SetPackDecorator(gomock.Any())
+				ith.MockSplitterRunner.EXPECT().Splitter().Return(nil)
 				ith.MockSplitterRunner.EXPECT().Done()
 
 				getRecCall := ith.MockSplitterRunner.EXPECT().GetRecordFromStream(
@@ -167,6 +169,109 @@ func LogstreamerInputSpec(c gs.Context) {
 			})
 		})
 
+		c.Specify("w/ inotify", func() {
+			if runtime.GOOS != "linux" {
+				return
+			}
+			logDir := filepath.Join(tmpDir, "logs")
+			err := os.Mkdir(logDir, 0755)
+			c.Assume(err, gs.IsNil)
+			logPath := filepath.Join(logDir, "file.log")
+			err = ioutil.WriteFile(logPath, nil, 0644)
+			c.Assume(err, gs.IsNil)
+			lsiConfig.LogDirectory = logDir
+			lsiConfig.UseInotify = true
+			lsiConfig.RescanInterval = "1h"
+			err = lsInput.Init(lsiConfig)
+			c.Assume(err, gs.IsNil)
+			c.Assume(len(lsInput.plugins), gs.Equals, 1)
+
+			c.Specify("only polls at the rescan interval", func() {
+				c.Expect(lsInput.plugins["logfile"].checkDataInterval, gs.Equals,
+					time.Hour)
+				lsInput.logstreamSet.StopWatching()
+			})
+
+			c.Specify("reads data as soon as it's written", func() {
+				ith.MockInputRunner.EXPECT().LogError(gomock.Any()).AnyTimes()
+				ith.MockInputRunner.EXPECT().NewDeliverer("1").Return(ith.MockDeliverer)
+				ith.MockInputRunner.EXPECT().NewSplitterRunner("1").Return(
+					ith.MockSplitterRunner)
+				ith.MockSplitterRunner.EXPECT().UseMsgBytes().Return(false)
+				ith.MockSplitterRunner.EXPECT().IncompleteFinal().Return(false).AnyTimes()
+				ith.MockSplitterRunner.EXPECT().SetPackDecorator(gomock.Any())
+				ith.MockSplitterRunner.EXPECT().Splitter().Return(nil)
+				ith.MockSplitterRunner.EXPECT().Done()
+				ith.MockDeliverer.EXPECT().Done()
+
+				firstRead := make(chan bool, 1)
+				ith.MockSplitterRunner.EXPECT().GetRecordFromStream(gomock.Any()).Do(
+					func(r io.Reader) {
+						firstRead <- true
+					}).Return(0, []byte{}, io.EOF)
+				line := "written later"
+				ith.MockSplitterRunner.EXPECT().GetRecordFromStream(gomock.Any()).Return(
+					len(line), []byte(line), nil)
+				ith.MockSplitterRunner.EXPECT().GetRecordFromStream(gomock.Any()).Return(
+					0, []byte{}, io.EOF).AnyTimes()
+				deliverChan := make(chan []byte, 1)
+				ith.MockSplitterRunner.EXPECT().DeliverRecord(gomock.Any(),
+					ith.MockDeliverer).Do(func(record []byte, del Deliverer) {
+					deliverChan <- record
+				})
+
+				runOutChan := make(chan error, 1)
+				go func() {
+					runOutChan <- lsInput.Run(ith.MockInputRunner, ith.MockHelper)
+				}()
+
+				<-firstRead
+				f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+				c.Assume(err, gs.IsNil)
+				_, err = f.WriteString(line + "\n")
+				f.Close()
+				c.Assume(err, gs.IsNil)
+
+				select {
+				case record := <-deliverChan:
+					c.Expect(string(record), gs.Equals, line)
+				case <-time.After(5 * time.Second):
+					c.Expect("timed out", gs.Equals, "")
+				}
+				lsInput.Stop()
+				c.Expect(<-runOutChan, gs.IsNil)
+				c.Expect(lsInput.logstreamSet.Watcher(), gs.IsNil)
+			})
+		})
+
+		c.Specify("w/ file globs", func() {
+			lsiConfig.FileMatch = ""
+			lsiConfig.Differentiator = nil
+			lsiConfig.Priority = nil
+			lsiConfig.OldestDuration = "0"
+
+			c.Specify("makes a logstream per logfile", func() {
+				lsiConfig.LogDirectories = []string{filepath.Dir(dirPath)}
+				lsiConfig.FileGlob = []string{"sub*/file.log{,.1}"}
+				err := lsInput.Init(lsiConfig)
+				c.Expect(err, gs.IsNil)
+				c.Expect(lsiConfig.Differentiator, gs.ContainsExactly,
+					[]string{ls.FilePathMatchPart})
+				c.Expect(len(lsInput.plugins), gs.Equals, 2)
+				_, ok := lsInput.plugins["subdir_file.log"]
+				c.Expect(ok, gs.IsTrue)
+				_, ok = lsInput.plugins["subdir_file.log.1"]
+				c.Expect(ok, gs.IsTrue)
+			})
+
+			c.Specify("requires a file match or glob", func() {
+				err := lsInput.Init(lsiConfig)
+				c.Expect(err, gs.Not(gs.IsNil))
+				c.Expect(err.Error(), gs.Equals,
+					"`file_match` or `file_glob` setting is required.")
+			})
+		})
+
 		c.Specify("with a translation map", func() {
 			lsiConfig.Translation = make(ls.SubmatchTranslationMap)
 			lsiConfig.Translation["Seq"] = make(ls.MatchTranslationMap)
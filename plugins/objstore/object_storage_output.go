@@ -0,0 +1,696 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package objstore
+
+import (
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins"
+	"github.com/mozilla-services/heka/plugins/tcp"
+)
+
+const (
+	// S3 rejects multipart upload parts, other than the last, that are
+	// smaller than this.
+	minPartSize = 5 * 1024 * 1024
+	tmpSuffix   = ".tmp"
+	gzSuffix    = ".gz"
+	keySuffix   = ".key"
+	maxBackoff  = time.Minute
+)
+
+// A staging file that is still accepting messages for a single object key.
+type stagedObject struct {
+	key    string
+	path   string
+	file   *os.File
+	size   uint64
+	opened time.Time
+	// Sequence number of the first message in this object.
+	firstSeq uint64
+	// Queue cursor of the last message received before this object was
+	// opened.
+	prevCursor string
+}
+
+// Output plugin that stages encoded messages in local files, grouped by an
+// object key generated from the message data, and uploads them to an S3
+// compatible object store.
+type ObjectStorageOutput struct {
+	*ObjectStorageOutputConfig
+	name          string
+	pConfig       *PipelineConfig
+	client        *s3Client
+	openDir       string
+	readyDir      string
+	flushInterval time.Duration
+	objects       map[string]*stagedObject
+	seq           uint64
+	lastCursor    string
+	tickChan      <-chan time.Time
+	readyChan     chan struct{}
+	stopChan      chan struct{}
+	uploaderWg    sync.WaitGroup
+	// Test hook, called with the error (if any) after every upload attempt.
+	uploaded           func(err error)
+	uploadCount        int64
+	uploadFailureCount int64
+}
+
+type ObjectStorageOutputConfig struct {
+	// URL of the object store, e.g. "https://s3.amazonaws.com".
+	Endpoint string
+	Bucket   string
+	Region   string
+	// Credentials used to sign requests. Requests are sent unsigned if no
+	// access key is provided.
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+	// Template for the object keys, supports message field and strftime
+	// interpolation.
+	KeyTemplate string `toml:"key_template"`
+	// Directory in which objects are staged before upload, relative paths
+	// are relative to the base_dir.
+	StagingDir string `toml:"staging_dir"`
+	// Size at which a staged object is sealed and queued for upload.
+	MaxObjectSize uint64 `toml:"max_object_size"`
+	// Maximum time (in milliseconds) a staged object stays open.
+	FlushInterval uint32 `toml:"flush_interval"`
+	// Size of each multipart upload part.
+	PartSize uint64 `toml:"part_size"`
+	// Either "gzip" or "none".
+	Compression string
+	// Maximum number of staged objects that can be open at once.
+	MaxOpenObjects int `toml:"max_open_objects"`
+	// Timeout (in milliseconds) for each HTTP request.
+	HttpTimeout uint32 `toml:"http_timeout"`
+	Tls         tcp.TlsConfig
+	// Defaults to true for ObjectStorageOutput.
+	UseBuffering *bool `toml:"use_buffering"`
+	Buffering    QueueBufferConfig
+}
+
+func (o *ObjectStorageOutput) SetName(name string) {
+	o.name = name
+}
+
+func (o *ObjectStorageOutput) SetPipelineConfig(pConfig *PipelineConfig) {
+	o.pConfig = pConfig
+}
+
+func (o *ObjectStorageOutput) ConfigStruct() interface{} {
+	b := true
+	return &ObjectStorageOutputConfig{
+		Region:         "us-east-1",
+		KeyTemplate:    "%{%Y}/%{%m}/%{%d}/%{Logger}",
+		StagingDir:     filepath.Join("object_storage", o.name),
+		MaxObjectSize:  64 * 1024 * 1024,
+		FlushInterval:  300000,
+		PartSize:       8 * 1024 * 1024,
+		Compression:    "gzip",
+		MaxOpenObjects: 64,
+		UseBuffering:   &b,
+		Buffering: QueueBufferConfig{
+			CursorUpdateCount: 1,
+			MaxFileSize:       128 * 1024 * 1024,
+			FullAction:        "block",
+		},
+	}
+}
+
+func (o *ObjectStorageOutput) Init(config interface{}) (err error) {
+	o.ObjectStorageOutputConfig = config.(*ObjectStorageOutputConfig)
+
+	endpoint, err := url.Parse(o.Endpoint)
+	if err != nil {
+		return fmt.Errorf("can't parse `endpoint`: %s", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return errors.New("`endpoint` must contain an absolute http or https URL")
+	}
+	if o.Bucket == "" {
+		return errors.New("`bucket` must be specified")
+	}
+	if o.KeyTemplate == "" {
+		return errors.New("`key_template` must be specified")
+	}
+	if o.PartSize < minPartSize {
+		return fmt.Errorf("`part_size` must be at least %d", minPartSize)
+	}
+	if o.MaxObjectSize == 0 {
+		return errors.New("`max_object_size` must be greater than 0")
+	}
+	if o.MaxOpenObjects < 1 {
+		return errors.New("`max_open_objects` must be greater than 0")
+	}
+	switch o.Compression {
+	case "gzip", "none":
+	default:
+		return fmt.Errorf("`compression` must be 'gzip' or 'none', is currently: '%s'",
+			o.Compression)
+	}
+	o.flushInterval = time.Duration(o.FlushInterval) * time.Millisecond
+
+	httpClient := new(http.Client)
+	if o.HttpTimeout > 0 {
+		httpClient.Timeout = time.Duration(o.HttpTimeout) * time.Millisecond
+	}
+	if endpoint.Scheme == "https" {
+		transport := &http.Transport{}
+		if transport.TLSClientConfig, err = tcp.CreateGoTlsConfig(&o.Tls); err != nil {
+			return fmt.Errorf("TLS init error: %s", err)
+		}
+		httpClient.Transport = transport
+	}
+	o.client = &s3Client{
+		endpoint:  endpoint,
+		bucket:    o.Bucket,
+		region:    o.Region,
+		accessKey: o.AccessKey,
+		secretKey: o.SecretKey,
+		client:    httpClient,
+	}
+
+	dir := o.pConfig.Globals.PrependBaseDir(o.StagingDir)
+	o.openDir = filepath.Join(dir, "open")
+	o.readyDir = filepath.Join(dir, "ready")
+	for _, d := range []string{o.openDir, o.readyDir} {
+		if err = os.MkdirAll(d, 0700); err != nil {
+			return fmt.Errorf("can't create staging directory: %s", err)
+		}
+	}
+	if err = plugins.CheckWritePermission(dir); err != nil {
+		return err
+	}
+	if err = o.recoverStaged(); err != nil {
+		return fmt.Errorf("can't recover staged objects: %s", err)
+	}
+
+	o.objects = make(map[string]*stagedObject)
+	o.readyChan = make(chan struct{}, 1)
+	o.stopChan = make(chan struct{})
+	return nil
+}
+
+// Cleans up after a previous run. Partially compressed files are removed.
+// Objects that were still open hold messages past the saved queue cursor, so
+// they're discarded if buffering is in use, since those messages will be
+// replayed, and are otherwise queued for upload.
+func (o *ObjectStorageOutput) recoverStaged() error {
+	stale, _ := filepath.Glob(filepath.Join(o.readyDir, "*"+tmpSuffix))
+	for _, path := range stale {
+		os.Remove(path)
+	}
+	open, err := filepath.Glob(filepath.Join(o.openDir, "*"))
+	if err != nil {
+		return err
+	}
+	replay := o.UseBuffering != nil && *o.UseBuffering
+	for _, path := range open {
+		if strings.HasSuffix(path, keySuffix) {
+			continue
+		}
+		if replay {
+			os.Remove(path + keySuffix)
+			err = os.Remove(path)
+		} else {
+			err = o.moveToReady(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Key files whose object is gone, because we stopped right after
+	// creating or uploading it.
+	orphans, _ := filepath.Glob(filepath.Join(o.openDir, "*"+keySuffix))
+	keys, _ := filepath.Glob(filepath.Join(o.readyDir, "*"+keySuffix))
+	for _, keyPath := range keys {
+		path := strings.TrimSuffix(keyPath, keySuffix)
+		if !exists(path) && !exists(path+gzSuffix) {
+			orphans = append(orphans, keyPath)
+		}
+	}
+	for _, keyPath := range orphans {
+		os.Remove(keyPath)
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Moves a staged object's file, and its key file, into the ready directory.
+// The key file goes first so the uploader never sees an object without one.
+func (o *ObjectStorageOutput) moveToReady(path string) error {
+	name := filepath.Base(path)
+	err := os.Rename(path+keySuffix, filepath.Join(o.readyDir, name+keySuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(path, filepath.Join(o.readyDir, name))
+}
+
+func (o *ObjectStorageOutput) Run(or OutputRunner, h PluginHelper) (err error) {
+	if or.Encoder() == nil {
+		return errors.New("encoder must be specified")
+	}
+	if o.tickChan == nil { // Tests might have set this already.
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		o.tickChan = ticker.C
+	}
+
+	o.uploaderWg.Add(1)
+	go o.uploader(or)
+	o.signalReady()
+
+	var (
+		pack *PipelinePack
+		e    error
+	)
+	inChan := or.InChan()
+	ok := true
+	for ok {
+		select {
+		case pack, ok = <-inChan:
+			if !ok {
+				break
+			}
+			if e = o.stage(or, pack); e != nil {
+				pack.Recycle(e)
+				continue
+			}
+			pack.Recycle(nil)
+		case now := <-o.tickChan:
+			if e = o.sealExpired(or, now); e != nil {
+				or.LogError(e)
+			}
+		}
+	}
+
+	// We're shutting down, seal every open object so it'll be uploaded on
+	// the next start if we don't get to it now.
+	var errMsgs []string
+	for key, obj := range o.objects {
+		if e = o.seal(obj); e != nil {
+			errMsgs = append(errMsgs, e.Error())
+			continue
+		}
+		delete(o.objects, key)
+	}
+	if len(errMsgs) > 0 {
+		or.LogError(fmt.Errorf("error sealing staged objects: %s",
+			strings.Join(errMsgs, ", ")))
+	}
+	if len(o.objects) == 0 && o.lastCursor != "" {
+		or.UpdateCursor(o.lastCursor)
+	}
+	close(o.stopChan)
+	o.uploaderWg.Wait()
+	return nil
+}
+
+// Encodes a message and appends it to the staged object for its key.
+// Returned errors mean the message should be dropped.
+func (o *ObjectStorageOutput) stage(or OutputRunner, pack *PipelinePack) (err error) {
+	msgTime := time.Unix(0, pack.Message.GetTimestamp())
+	if pack.Message.GetTimestamp() == 0 {
+		msgTime = time.Now()
+	}
+	key, err := plugins.InterpolateMessage(o.KeyTemplate, pack.Message, msgTime.UTC(), nil)
+	if err != nil {
+		o.lastCursor = pack.QueueCursor
+		return err
+	}
+	outBytes, err := or.Encode(pack)
+	if err != nil || outBytes == nil {
+		o.lastCursor = pack.QueueCursor
+		if err != nil {
+			return fmt.Errorf("can't encode: %s", err)
+		}
+		return nil
+	}
+
+	obj, ok := o.objects[key]
+	if !ok {
+		if len(o.objects) >= o.MaxOpenObjects {
+			o.sealOldest(or)
+		}
+		if obj, err = o.openObject(key); err != nil {
+			return NewRetryMessageError("can't stage object: %s", err)
+		}
+		o.objects[key] = obj
+	}
+	n, err := obj.file.Write(outBytes)
+	obj.size += uint64(n)
+	if err != nil {
+		return NewRetryMessageError("can't write to '%s': %s", obj.path, err)
+	}
+	o.seq++
+	o.lastCursor = pack.QueueCursor
+
+	if obj.size >= o.MaxObjectSize {
+		if err = o.seal(obj); err != nil {
+			or.LogError(err)
+		} else {
+			delete(o.objects, key)
+			o.advanceCursor(or)
+		}
+	}
+	return nil
+}
+
+// Opens a new staging file for the provided object key. Object keys can be
+// much longer than file names, so the file is named after a hash of the key
+// and the key itself is stored in a key file next to it, so it survives
+// restarts.
+func (o *ObjectStorageOutput) openObject(key string) (*stagedObject, error) {
+	now := time.Now()
+	sum := sha1.Sum([]byte(key))
+	name := fmt.Sprintf("%s.%d", hex.EncodeToString(sum[:]), now.UnixNano())
+	path := filepath.Join(o.openDir, name)
+	if err := writeKeyFile(path+keySuffix, key); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		os.Remove(path + keySuffix)
+		return nil, err
+	}
+	return &stagedObject{
+		key:        key,
+		path:       path,
+		file:       file,
+		opened:     now,
+		firstSeq:   o.seq + 1,
+		prevCursor: o.lastCursor,
+	}, nil
+}
+
+// Syncs and closes a staged object's file and moves it into the ready
+// directory for upload.
+func (o *ObjectStorageOutput) seal(obj *stagedObject) error {
+	if err := obj.file.Sync(); err != nil {
+		return fmt.Errorf("can't sync '%s': %s", obj.path, err)
+	}
+	obj.file.Close()
+	if err := o.moveToReady(obj.path); err != nil {
+		return fmt.Errorf("can't move '%s' to ready: %s", obj.path, err)
+	}
+	o.signalReady()
+	return nil
+}
+
+// Seals the least recently opened object to make room for a new one.
+func (o *ObjectStorageOutput) sealOldest(or OutputRunner) {
+	var oldest *stagedObject
+	for _, obj := range o.objects {
+		if oldest == nil || obj.opened.Before(oldest.opened) {
+			oldest = obj
+		}
+	}
+	if err := o.seal(oldest); err != nil {
+		or.LogError(err)
+		return
+	}
+	delete(o.objects, oldest.key)
+	o.advanceCursor(or)
+}
+
+// Seals every object that has been open for longer than `flush_interval`.
+func (o *ObjectStorageOutput) sealExpired(or OutputRunner, now time.Time) error {
+	var errMsgs []string
+	sealed := false
+	for key, obj := range o.objects {
+		if now.Sub(obj.opened) < o.flushInterval {
+			continue
+		}
+		if err := o.seal(obj); err != nil {
+			errMsgs = append(errMsgs, err.Error())
+			continue
+		}
+		delete(o.objects, key)
+		sealed = true
+	}
+	if sealed {
+		o.advanceCursor(or)
+	}
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("error sealing staged objects: %s",
+			strings.Join(errMsgs, ", "))
+	}
+	return nil
+}
+
+// Advances the queue cursor as far as is safe, i.e. up to the message just
+// before the first one held by an object that's still open.
+func (o *ObjectStorageOutput) advanceCursor(or OutputRunner) {
+	cursor := o.lastCursor
+	var oldest *stagedObject
+	for _, obj := range o.objects {
+		if oldest == nil || obj.firstSeq < oldest.firstSeq {
+			oldest = obj
+		}
+	}
+	if oldest != nil {
+		cursor = oldest.prevCursor
+	}
+	if cursor != "" {
+		or.UpdateCursor(cursor)
+	}
+}
+
+func (o *ObjectStorageOutput) signalReady() {
+	select {
+	case o.readyChan <- struct{}{}:
+	default:
+	}
+}
+
+// Uploads the objects in the ready directory, oldest first, retrying with
+// exponential backoff on failure. Staged files are only deleted once their
+// upload has succeeded.
+func (o *ObjectStorageOutput) uploader(or OutputRunner) {
+	defer o.uploaderWg.Done()
+	backoff := time.Second
+	for {
+		select {
+		case <-o.readyChan:
+		case <-o.stopChan:
+			return
+		}
+		for {
+			err := o.uploadReady()
+			if err == nil {
+				backoff = time.Second
+				break
+			}
+			or.LogError(err)
+			select {
+			case <-time.After(backoff):
+			case <-o.stopChan:
+				return
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// Uploads everything that's currently in the ready directory, stopping at
+// the first failure.
+func (o *ObjectStorageOutput) uploadReady() error {
+	names, err := readyNames(o.readyDir)
+	if err != nil {
+		return fmt.Errorf("can't list staged objects: %s", err)
+	}
+	for _, name := range names {
+		select {
+		case <-o.stopChan:
+			return nil
+		default:
+		}
+		if err = o.upload(name); err != nil {
+			atomic.AddInt64(&o.uploadFailureCount, 1)
+		} else {
+			atomic.AddInt64(&o.uploadCount, 1)
+		}
+		if o.uploaded != nil {
+			o.uploaded(err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compresses (if necessary) and uploads a single staged object, deleting it
+// once the upload is complete.
+func (o *ObjectStorageOutput) upload(name string) (err error) {
+	base := strings.TrimSuffix(name, gzSuffix)
+	keyPath := filepath.Join(o.readyDir, base+keySuffix)
+	key, stamp, err := readStagedKey(keyPath)
+	if err != nil {
+		// Not something we staged, leave it alone.
+		return nil
+	}
+	path := filepath.Join(o.readyDir, name)
+	if o.Compression == "gzip" && !strings.HasSuffix(name, gzSuffix) {
+		gzPath, err := compressStaged(path)
+		if err != nil {
+			return fmt.Errorf("can't compress '%s': %s", path, err)
+		}
+		path = gzPath
+	}
+	objectKey := fmt.Sprintf("%s-%s", key, stamp)
+	if strings.HasSuffix(path, gzSuffix) {
+		objectKey += gzSuffix
+	}
+	if err = o.client.UploadFile(objectKey, path, int64(o.PartSize)); err != nil {
+		return fmt.Errorf("can't upload '%s': %s", objectKey, err)
+	}
+	if err = os.Remove(path); err != nil {
+		return err
+	}
+	return os.Remove(keyPath)
+}
+
+// Returns the names of the files in the ready directory, oldest first.
+func readyNames(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byModTime(infos))
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.Mode().IsRegular() && !strings.HasSuffix(name, tmpSuffix) &&
+			!strings.HasSuffix(name, keySuffix) {
+
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+type byModTime []os.FileInfo
+
+func (fis byModTime) Len() int           { return len(fis) }
+func (fis byModTime) Swap(i, j int)      { fis[i], fis[j] = fis[j], fis[i] }
+func (fis byModTime) Less(i, j int) bool { return fis[i].ModTime().Before(fis[j].ModTime()) }
+
+// Writes a staged object's key file, syncing it so it's there for as long as
+// the object is.
+func writeKeyFile(path, key string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(key); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// Reads the object key of a staged object from its key file, and extracts
+// the creation timestamp from the file name.
+func readStagedKey(keyPath string) (key, stamp string, err error) {
+	name := strings.TrimSuffix(filepath.Base(keyPath), keySuffix)
+	i := strings.LastIndex(name, ".")
+	if i == -1 {
+		return "", "", fmt.Errorf("invalid staged object name: %s", name)
+	}
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return "", "", err
+	}
+	return string(keyBytes), name[i+1:], nil
+}
+
+// Gzips a staged file, removing the original once the compressed version has
+// been synced to disk. Returns the path to the compressed file.
+func compressStaged(path string) (string, error) {
+	gzPath := path + gzSuffix
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(gzPath+tmpSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		if err = gz.Close(); err == nil {
+			err = dst.Sync()
+		}
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(gzPath+tmpSuffix, gzPath)
+	}
+	if err != nil {
+		os.Remove(gzPath + tmpSuffix)
+		return "", err
+	}
+	return gzPath, os.Remove(path)
+}
+
+func (o *ObjectStorageOutput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "UploadCount",
+		atomic.LoadInt64(&o.uploadCount), "count")
+	message.NewInt64Field(msg, "UploadFailureCount",
+		atomic.LoadInt64(&o.uploadFailureCount), "count")
+	return nil
+}
+
+func init() {
+	RegisterPlugin("ObjectStorageOutput", func() interface{} {
+		return new(ObjectStorageOutput)
+	})
+}
@@ -0,0 +1,301 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package objstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/plugins"
+	plugins_ts "github.com/mozilla-services/heka/plugins/testsupport"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+// Bare bones stand-in for an S3 compatible store, supporting just enough of
+// the multipart upload API for our client.
+type fakeS3 struct {
+	sync.Mutex
+	parts   map[string][][]byte
+	objects map[string][]byte
+	auth    []string
+	nextId  int
+	fail    bool
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		parts:   make(map[string][][]byte),
+		objects: make(map[string][]byte),
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	if s.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "<Error><Code>InternalError</Code><Message>oops</Message></Error>")
+		return
+	}
+	query := r.URL.Query()
+	uploadId := query.Get("uploadId")
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "POST" && uploadId == "":
+		s.nextId++
+		uploadId = fmt.Sprintf("upload%d", s.nextId)
+		s.parts[uploadId] = nil
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId>"+
+			"</InitiateMultipartUploadResult>", uploadId)
+	case r.Method == "PUT":
+		s.parts[uploadId] = append(s.parts[uploadId], body)
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(s.parts[uploadId])))
+	case r.Method == "POST":
+		var complete completeUpload
+		xml.Unmarshal(body, &complete)
+		var obj []byte
+		for i, part := range complete.Parts {
+			if part.ETag != fmt.Sprintf(`"%d"`, i+1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			obj = append(obj, s.parts[uploadId][part.PartNumber-1]...)
+		}
+		s.objects[r.URL.Path] = obj
+		delete(s.parts, uploadId)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == "DELETE":
+		delete(s.parts, uploadId)
+	}
+}
+
+func ObjectStorageOutputSpec(c gs.Context) {
+	t := new(pipeline_ts.SimpleT)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "objstore-output-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+
+	pConfig := NewPipelineConfig(nil)
+	pConfig.Globals.BaseDir = tmpDir
+	oth := plugins_ts.NewOutputTestHelper(ctrl)
+
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	c.Specify("An ObjectStorageOutput", func() {
+		output := new(ObjectStorageOutput)
+		output.SetName("archive")
+		output.SetPipelineConfig(pConfig)
+		config := output.ConfigStruct().(*ObjectStorageOutputConfig)
+		config.Endpoint = server.URL
+		config.Bucket = "logs"
+		config.AccessKey = "AKID"
+		config.SecretKey = "secret"
+		config.KeyTemplate = "%{Logger}/%{%Y-%m-%d}"
+		config.FlushInterval = 1000
+		stagingDir := filepath.Join(tmpDir, "object_storage", "archive")
+
+		c.Specify("rejects a part size below the S3 minimum", func() {
+			config.PartSize = 1024
+			err := output.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("discards objects left open by a previous run", func() {
+			openDir := filepath.Join(stagingDir, "open")
+			err := os.MkdirAll(openDir, 0700)
+			c.Assume(err, gs.IsNil)
+			stale := filepath.Join(openDir, "Zm9v.1")
+			err = ioutil.WriteFile(stale, []byte("partial"), 0600)
+			c.Assume(err, gs.IsNil)
+			err = output.Init(config)
+			c.Expect(err, gs.IsNil)
+			_, err = os.Stat(stale)
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+		})
+
+		c.Specify("removes key files of objects that are gone", func() {
+			readyDir := filepath.Join(stagingDir, "ready")
+			err := os.MkdirAll(readyDir, 0700)
+			c.Assume(err, gs.IsNil)
+			orphan := filepath.Join(readyDir, "abc.1"+keySuffix)
+			err = ioutil.WriteFile(orphan, []byte("foo"), 0600)
+			c.Assume(err, gs.IsNil)
+			kept := filepath.Join(readyDir, "abc.2")
+			err = ioutil.WriteFile(kept+gzSuffix, []byte("data"), 0600)
+			c.Assume(err, gs.IsNil)
+			err = ioutil.WriteFile(kept+keySuffix, []byte("foo"), 0600)
+			c.Assume(err, gs.IsNil)
+
+			err = output.Init(config)
+			c.Expect(err, gs.IsNil)
+			_, err = os.Stat(orphan)
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+			_, err = os.Stat(kept + keySuffix)
+			c.Expect(err, gs.IsNil)
+		})
+
+		c.Specify("stages and uploads objects", func() {
+			err := output.Init(config)
+			c.Assume(err, gs.IsNil)
+			tickChan := make(chan time.Time)
+			output.tickChan = tickChan
+			uploaded := make(chan error, 10)
+			output.uploaded = func(err error) { uploaded <- err }
+			inChan := make(chan *PipelinePack)
+			oth.MockOutputRunner.EXPECT().InChan().Return(inChan)
+			oth.MockOutputRunner.EXPECT().Encoder().Return(new(plugins.PayloadEncoder))
+			oth.MockOutputRunner.EXPECT().LogError(gomock.Any()).AnyTimes()
+
+			ts := time.Date(2016, time.May, 1, 10, 0, 0, 0, time.UTC)
+			newPack := func(cursor string) *PipelinePack {
+				pack := NewPipelinePack(pConfig.InputRecycleChan())
+				pack.Message = pipeline_ts.GetTestMessage()
+				pack.Message.SetTimestamp(ts.UnixNano())
+				pack.QueueCursor = cursor
+				return pack
+			}
+
+			done := make(chan struct{})
+			go func() {
+				output.Run(oth.MockOutputRunner, oth.MockHelper)
+				close(done)
+			}()
+
+			c.Specify("after the flush interval", func() {
+				oth.MockOutputRunner.EXPECT().Encode(gomock.Any()).Return(
+					[]byte("line\n"), nil).Times(2)
+				inChan <- newPack("cursor1")
+				inChan <- newPack("cursor2")
+				oth.MockOutputRunner.EXPECT().UpdateCursor("cursor2").Times(2)
+				tickChan <- time.Now().Add(time.Hour)
+				c.Expect(<-uploaded, gs.IsNil)
+
+				s3.Lock()
+				c.Expect(len(s3.objects), gs.Equals, 1)
+				for path, obj := range s3.objects {
+					prefix := "/logs/GoSpec/2016-05-01-"
+					c.Expect(strings.HasPrefix(path, prefix), gs.IsTrue)
+					c.Expect(strings.HasSuffix(path, ".gz"), gs.IsTrue)
+					gz, err := gzip.NewReader(bytes.NewReader(obj))
+					c.Assume(err, gs.IsNil)
+					content, err := ioutil.ReadAll(gz)
+					c.Expect(err, gs.IsNil)
+					c.Expect(string(content), gs.Equals, "line\nline\n")
+				}
+				for _, auth := range s3.auth {
+					c.Expect(strings.HasPrefix(auth,
+						"AWS4-HMAC-SHA256 Credential=AKID/"), gs.IsTrue)
+				}
+				s3.Unlock()
+
+				close(inChan)
+				<-done
+				names, err := readyNames(filepath.Join(stagingDir, "ready"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(names), gs.Equals, 0)
+			})
+
+			c.Specify("when the max object size is reached", func() {
+				output.MaxObjectSize = 10
+				output.Compression = "none"
+				oth.MockOutputRunner.EXPECT().Encode(gomock.Any()).Return(
+					[]byte("0123456789"), nil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("cursor1").Times(2)
+				inChan <- newPack("cursor1")
+				c.Expect(<-uploaded, gs.IsNil)
+
+				s3.Lock()
+				c.Expect(len(s3.objects), gs.Equals, 1)
+				for path, obj := range s3.objects {
+					c.Expect(strings.HasSuffix(path, ".gz"), gs.IsFalse)
+					c.Expect(string(obj), gs.Equals, "0123456789")
+				}
+				s3.Unlock()
+
+				close(inChan)
+				<-done
+			})
+
+			c.Specify("with keys longer than a file name", func() {
+				prefix := strings.Repeat("k", 300)
+				output.KeyTemplate = prefix + "/%{Logger}"
+				output.MaxObjectSize = 10
+				output.Compression = "none"
+				oth.MockOutputRunner.EXPECT().Encode(gomock.Any()).Return(
+					[]byte("0123456789"), nil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("cursor1").Times(2)
+				inChan <- newPack("cursor1")
+				c.Expect(<-uploaded, gs.IsNil)
+
+				s3.Lock()
+				c.Expect(len(s3.objects), gs.Equals, 1)
+				for path := range s3.objects {
+					c.Expect(strings.HasPrefix(path, "/logs/"+prefix+"/GoSpec-"),
+						gs.IsTrue)
+				}
+				s3.Unlock()
+
+				close(inChan)
+				<-done
+				left, err := ioutil.ReadDir(filepath.Join(stagingDir, "ready"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(left), gs.Equals, 0)
+			})
+
+			c.Specify("keeps staged objects if the upload fails", func() {
+				s3.fail = true
+				oth.MockOutputRunner.EXPECT().Encode(gomock.Any()).Return(
+					[]byte("line\n"), nil)
+				oth.MockOutputRunner.EXPECT().UpdateCursor("cursor1").Times(2)
+				inChan <- newPack("cursor1")
+				tickChan <- time.Now().Add(time.Hour)
+				c.Expect(<-uploaded, gs.Not(gs.IsNil))
+
+				close(inChan)
+				<-done
+				names, err := readyNames(filepath.Join(stagingDir, "ready"))
+				c.Expect(err, gs.IsNil)
+				c.Expect(len(names), gs.Equals, 1)
+				c.Expect(strings.HasSuffix(names[0], ".gz"), gs.IsTrue)
+
+				msg := new(message.Message)
+				err = output.ReportMsg(msg)
+				c.Expect(err, gs.IsNil)
+				failures, _ := msg.GetFieldValue("UploadFailureCount")
+				c.Expect(failures.(int64) > 0, gs.IsTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,270 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package objstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	amzShortFormat  = "20060102"
+	emptyPayloadSHA = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Minimal client for the S3 multipart upload API, using path-style bucket
+// addressing so it works with S3 compatible stores. Requests are signed
+// with AWS Signature Version 4 if credentials are provided.
+type s3Client struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type initiateResult struct {
+	UploadId string
+}
+
+type completePart struct {
+	PartNumber int
+	ETag       string
+}
+
+type completeUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// Uploads the contents of the file at the provided path to the given object
+// key, in parts of at most `partSize` bytes. The upload is aborted if any
+// part fails.
+func (c *s3Client) UploadFile(key, path string, partSize int64) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	resp, err := c.do("POST", key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return fmt.Errorf("can't initiate upload: %s", err)
+	}
+	var result initiateResult
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if err != nil || result.UploadId == "" {
+		return fmt.Errorf("can't parse initiate upload response: %v", err)
+	}
+	uploadId := result.UploadId
+	defer func() {
+		if err != nil {
+			resp, e := c.do("DELETE", key, url.Values{"uploadId": {uploadId}}, nil)
+			if e == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	var parts []completePart
+	buf := make([]byte, partSize)
+	for partNum := 1; ; partNum++ {
+		n, e := io.ReadFull(f, buf)
+		if e == io.EOF && partNum > 1 {
+			break
+		}
+		if e != nil && e != io.ErrUnexpectedEOF && e != io.EOF {
+			return e
+		}
+		query := url.Values{
+			"partNumber": {fmt.Sprintf("%d", partNum)},
+			"uploadId":   {uploadId},
+		}
+		if resp, err = c.do("PUT", key, query, buf[:n]); err != nil {
+			return fmt.Errorf("can't upload part %d: %s", partNum, err)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		parts = append(parts, completePart{
+			PartNumber: partNum,
+			ETag:       resp.Header.Get("ETag"),
+		})
+		if n < len(buf) {
+			break
+		}
+	}
+
+	body, err := xml.Marshal(completeUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	if resp, err = c.do("POST", key, url.Values{"uploadId": {uploadId}}, body); err != nil {
+		return fmt.Errorf("can't complete upload: %s", err)
+	}
+	defer resp.Body.Close()
+	// S3 can report a failure in the body of a 200 response to a complete
+	// request.
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		var s3Err s3Error
+		xml.Unmarshal(respBody, &s3Err)
+		return fmt.Errorf("can't complete upload: %s %s", s3Err.Code, s3Err.Message)
+	}
+	return nil
+}
+
+// Makes a request for the given object key, returning an error if the
+// request fails or if a non-2xx status code is returned.
+func (c *s3Client) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	c.sign(req, body, time.Now().UTC())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		var s3Err s3Error
+		if xml.Unmarshal(respBody, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("%s: %s %s", resp.Status, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return resp, nil
+}
+
+// Adds the AWS Signature Version 4 headers to the request. Does nothing if
+// no credentials are configured.
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	if c.accessKey == "" {
+		return
+	}
+	payloadHash := emptyPayloadSHA
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	amzDate := now.Format(amzDateFormat)
+	shortDate := now.Format(amzShortFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := shortDate + "/" + c.region + "/s3/aws4_request"
+	crSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(crSum[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), shortDate)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Encodes query parameters sorted by key, as required for signing.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// URI encodes a string as specified by AWS, i.e. encoding everything except
+// unreserved characters and, optionally, slashes.
+func uriEncode(s string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			buf.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			buf.WriteByte(ch)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", ch)
+		}
+	}
+	return buf.String()
+}
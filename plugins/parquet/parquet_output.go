@@ -0,0 +1,420 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins"
+)
+
+// Suffix of files that are still being written.
+const tmpSuffix = ".tmp"
+
+// Extracts a single column value from a message.
+type extractor func(msg *message.Message) interface{}
+
+var headerColumns = map[string]struct {
+	typ     ColumnType
+	extract extractor
+}{
+	"Uuid": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetUuidString()
+	}},
+	"Timestamp": {ColumnTimestamp, func(m *message.Message) interface{} {
+		return m.GetTimestamp()
+	}},
+	"Type": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetType()
+	}},
+	"Logger": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetLogger()
+	}},
+	"Severity": {ColumnInt32, func(m *message.Message) interface{} {
+		return int64(m.GetSeverity())
+	}},
+	"Payload": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetPayload()
+	}},
+	"EnvVersion": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetEnvVersion()
+	}},
+	"Pid": {ColumnInt32, func(m *message.Message) interface{} {
+		return int64(m.GetPid())
+	}},
+	"Hostname": {ColumnString, func(m *message.Message) interface{} {
+		return m.GetHostname()
+	}},
+}
+
+// An archive file holding the messages for a single time window.
+type archiveWindow struct {
+	start  time.Time
+	path   string
+	file   *os.File
+	writer *Writer
+	// Sequence number of the first message in this window.
+	firstSeq uint64
+	// Queue cursor of the last message received before this window was
+	// opened.
+	prevCursor string
+}
+
+// Output plugin that groups messages by time window and archives them into
+// Parquet files.
+type ParquetOutput struct {
+	*ParquetOutputConfig
+	pConfig    *PipelineConfig
+	dir        string
+	perm       os.FileMode
+	folderPerm os.FileMode
+	window     time.Duration
+	grace      time.Duration
+	columns    []Column
+	extractors []extractor
+	windows    map[int64]*archiveWindow
+	seq        uint64
+	lastCursor string
+	tickChan   <-chan time.Time
+}
+
+type ParquetOutputConfig struct {
+	// Directory into which archive files are written, relative paths are
+	// relative to the base_dir.
+	Path string
+	// Prefix of the archive file names.
+	Prefix string
+	// Size of the time window used to group messages into files, as a
+	// duration string.
+	Window string
+	// How long to wait for late messages after a window has ended before its
+	// file is finalised, as a duration string.
+	WindowGrace string `toml:"window_grace"`
+	// Message header values to write out as columns.
+	HeaderFields []string `toml:"header_fields"`
+	// Message fields to write out as columns, mapped to their column type.
+	Fields map[string]string
+	// Number of rows buffered in memory before a row group is written out.
+	RowGroupSize uint32 `toml:"row_group_size"`
+	// Page compression, either "gzip" or "none".
+	Compression string
+	// Output file permissions.
+	Perm string
+	// Permissions to apply to the output directory if it doesn't exist.
+	FolderPerm string `toml:"folder_perm"`
+	// Defaults to true for ParquetOutput.
+	UseBuffering *bool `toml:"use_buffering"`
+	Buffering    QueueBufferConfig
+}
+
+func (o *ParquetOutput) SetPipelineConfig(pConfig *PipelineConfig) {
+	o.pConfig = pConfig
+}
+
+func (o *ParquetOutput) ConfigStruct() interface{} {
+	b := true
+	return &ParquetOutputConfig{
+		Path:        "parquet",
+		Prefix:      "heka",
+		Window:      "1h",
+		WindowGrace: "5m",
+		HeaderFields: []string{"Uuid", "Timestamp", "Type", "Logger", "Severity",
+			"Payload", "EnvVersion", "Pid", "Hostname"},
+		RowGroupSize: 10000,
+		Compression:  "gzip",
+		Perm:         "644",
+		FolderPerm:   "700",
+		UseBuffering: &b,
+		Buffering: QueueBufferConfig{
+			CursorUpdateCount: 1,
+			MaxFileSize:       128 * 1024 * 1024,
+			FullAction:        "block",
+		},
+	}
+}
+
+func (o *ParquetOutput) Init(config interface{}) (err error) {
+	o.ParquetOutputConfig = config.(*ParquetOutputConfig)
+
+	if o.window, err = time.ParseDuration(o.Window); err != nil {
+		return fmt.Errorf("can't parse `window`: %s", err)
+	}
+	if o.window <= 0 {
+		return fmt.Errorf("`window` must be greater than 0")
+	}
+	if o.grace, err = time.ParseDuration(o.WindowGrace); err != nil {
+		return fmt.Errorf("can't parse `window_grace`: %s", err)
+	}
+	if o.RowGroupSize < 1 {
+		return fmt.Errorf("`row_group_size` must be greater than 0")
+	}
+	switch o.Compression {
+	case "gzip", "none":
+	default:
+		return fmt.Errorf("`compression` must be 'gzip' or 'none', is currently: '%s'",
+			o.Compression)
+	}
+
+	var intPerm int64
+	if intPerm, err = strconv.ParseInt(o.Perm, 8, 32); err != nil {
+		return fmt.Errorf("can't parse `perm`, is it an octal integer string?")
+	}
+	o.perm = os.FileMode(intPerm)
+	if intPerm, err = strconv.ParseInt(o.FolderPerm, 8, 32); err != nil {
+		return fmt.Errorf("can't parse `folder_perm`, is it an octal integer string?")
+	}
+	o.folderPerm = os.FileMode(intPerm)
+
+	if err = o.buildSchema(); err != nil {
+		return err
+	}
+
+	o.dir = o.pConfig.Globals.PrependBaseDir(o.Path)
+	if err = os.MkdirAll(o.dir, o.folderPerm); err != nil {
+		return fmt.Errorf("can't create output directory: %s", err)
+	}
+	if err = plugins.CheckWritePermission(o.dir); err != nil {
+		return err
+	}
+	// Partially written files from a previous run can't be resumed, their
+	// messages will be replayed from the buffer.
+	stale, _ := filepath.Glob(filepath.Join(o.dir, o.Prefix+"-*"+tmpSuffix))
+	for _, path := range stale {
+		os.Remove(path)
+	}
+
+	o.windows = make(map[int64]*archiveWindow)
+	return nil
+}
+
+// Generates the file schema and matching value extractors from the header
+// and field configuration.
+func (o *ParquetOutput) buildSchema() error {
+	o.columns = o.columns[:0]
+	o.extractors = o.extractors[:0]
+	for _, name := range o.HeaderFields {
+		hc, ok := headerColumns[name]
+		if !ok {
+			return fmt.Errorf("unknown header field: '%s'", name)
+		}
+		o.columns = append(o.columns, Column{Name: name, Type: hc.typ, Required: true})
+		o.extractors = append(o.extractors, hc.extract)
+	}
+
+	names := make([]string, 0, len(o.Fields))
+	for name := range o.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ, ok := ColumnTypes[strings.ToLower(o.Fields[name])]
+		if !ok {
+			return fmt.Errorf("unknown column type '%s' for field '%s'",
+				o.Fields[name], name)
+		}
+		o.columns = append(o.columns, Column{Name: name, Type: typ})
+		fieldName := name
+		o.extractors = append(o.extractors, func(m *message.Message) interface{} {
+			val, ok := m.GetFieldValue(fieldName)
+			if !ok {
+				return nil
+			}
+			return val
+		})
+	}
+
+	if len(o.columns) == 0 {
+		return fmt.Errorf("at least one header field or field must be configured")
+	}
+	return nil
+}
+
+func (o *ParquetOutput) Run(or OutputRunner, h PluginHelper) (err error) {
+	if o.tickChan == nil { // Tests might have set this already.
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		o.tickChan = ticker.C
+	}
+
+	var (
+		pack *PipelinePack
+		e    error
+	)
+	inChan := or.InChan()
+	ok := true
+	for ok {
+		select {
+		case pack, ok = <-inChan:
+			if !ok {
+				break
+			}
+			if e = o.append(pack); e != nil {
+				pack.Recycle(NewRetryMessageError("can't archive message: %s", e))
+				continue
+			}
+			pack.Recycle(nil)
+		case now := <-o.tickChan:
+			if e = o.finalizeExpired(or, now); e != nil {
+				or.LogError(e)
+			}
+		}
+	}
+
+	// We're shutting down, close out every open window.
+	for key, w := range o.windows {
+		if e = o.finalize(w); e != nil {
+			or.LogError(e)
+			continue
+		}
+		delete(o.windows, key)
+	}
+	if len(o.windows) == 0 && o.lastCursor != "" {
+		or.UpdateCursor(o.lastCursor)
+	}
+	return nil
+}
+
+// Adds a message to the archive file for its time window, opening the file
+// if necessary.
+func (o *ParquetOutput) append(pack *PipelinePack) (err error) {
+	msg := pack.Message
+	ts := time.Unix(0, msg.GetTimestamp())
+	if msg.GetTimestamp() == 0 {
+		ts = time.Now()
+	}
+	start := ts.Truncate(o.window)
+	key := start.UnixNano()
+
+	w, ok := o.windows[key]
+	if !ok {
+		if w, err = o.openWindow(start); err != nil {
+			return err
+		}
+		o.windows[key] = w
+	}
+
+	row := make([]interface{}, len(o.extractors))
+	for i, extract := range o.extractors {
+		row[i] = extract(msg)
+	}
+	if err = w.writer.Append(row); err != nil {
+		return err
+	}
+	if uint32(w.writer.BufferedRows()) >= o.RowGroupSize {
+		if err = w.writer.FlushRowGroup(); err != nil {
+			return err
+		}
+	}
+	o.seq++
+	o.lastCursor = pack.QueueCursor
+	return nil
+}
+
+func (o *ParquetOutput) openWindow(start time.Time) (*archiveWindow, error) {
+	// The creation time keeps file names unique if messages for a window
+	// that has already been finalised show up late.
+	name := fmt.Sprintf("%s-%s-%d.parquet", o.Prefix,
+		start.UTC().Format("20060102T150405Z"), time.Now().UnixNano())
+	path := filepath.Join(o.dir, name)
+	file, err := os.OpenFile(path+tmpSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, o.perm)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewWriter(file, o.columns, o.Compression == "gzip")
+	if err != nil {
+		file.Close()
+		os.Remove(path + tmpSuffix)
+		return nil, err
+	}
+	return &archiveWindow{
+		start:      start,
+		path:       path,
+		file:       file,
+		writer:     writer,
+		firstSeq:   o.seq + 1,
+		prevCursor: o.lastCursor,
+	}, nil
+}
+
+// Writes the file footer, syncs the file to disk and moves it into its final
+// location.
+func (o *ParquetOutput) finalize(w *archiveWindow) error {
+	if err := w.writer.Close(); err != nil {
+		return fmt.Errorf("can't finalise '%s': %s", w.path, err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("can't sync '%s': %s", w.path, err)
+	}
+	w.file.Close()
+	if err := os.Rename(w.path+tmpSuffix, w.path); err != nil {
+		return fmt.Errorf("can't rename '%s': %s", w.path, err)
+	}
+	return nil
+}
+
+// Finalises the files for every window that ended more than `window_grace`
+// ago and then advances the queue cursor as far as is safe, i.e. up to the
+// message just before the first one held by a window that's still open.
+func (o *ParquetOutput) finalizeExpired(or OutputRunner, now time.Time) error {
+	var errMsgs []string
+	finalized := false
+	for key, w := range o.windows {
+		if now.Before(w.start.Add(o.window + o.grace)) {
+			continue
+		}
+		if err := o.finalize(w); err != nil {
+			errMsgs = append(errMsgs, err.Error())
+			continue
+		}
+		delete(o.windows, key)
+		finalized = true
+	}
+
+	if finalized {
+		cursor := o.lastCursor
+		var oldest *archiveWindow
+		for _, w := range o.windows {
+			if oldest == nil || w.firstSeq < oldest.firstSeq {
+				oldest = w
+			}
+		}
+		if oldest != nil {
+			cursor = oldest.prevCursor
+		}
+		if cursor != "" {
+			or.UpdateCursor(cursor)
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("error finalising archive files: %s",
+			strings.Join(errMsgs, ", "))
+	}
+	return nil
+}
+
+func init() {
+	RegisterPlugin("ParquetOutput", func() interface{} {
+		return new(ParquetOutput)
+	})
+}
@@ -0,0 +1,119 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package parquet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	plugins_ts "github.com/mozilla-services/heka/plugins/testsupport"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func ParquetOutputSpec(c gs.Context) {
+	t := new(pipeline_ts.SimpleT)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "parquet-output-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+
+	pConfig := NewPipelineConfig(nil)
+	pConfig.Globals.BaseDir = tmpDir
+	oth := plugins_ts.NewOutputTestHelper(ctrl)
+
+	c.Specify("A ParquetOutput", func() {
+		output := new(ParquetOutput)
+		output.SetPipelineConfig(pConfig)
+		config := output.ConfigStruct().(*ParquetOutputConfig)
+		config.Fields = map[string]string{"foo": "string"}
+		outDir := filepath.Join(tmpDir, "parquet")
+
+		c.Specify("rejects unknown column types", func() {
+			config.Fields["foo"] = "decimal"
+			err := output.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("cleans up stale partial files", func() {
+			err := os.MkdirAll(outDir, 0700)
+			c.Assume(err, gs.IsNil)
+			stale := filepath.Join(outDir, "heka-20160101T000000Z-1.parquet.tmp")
+			err = ioutil.WriteFile(stale, []byte("PAR1"), 0644)
+			c.Assume(err, gs.IsNil)
+			err = output.Init(config)
+			c.Expect(err, gs.IsNil)
+			_, err = os.Stat(stale)
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+		})
+
+		c.Specify("archives messages by window", func() {
+			err := output.Init(config)
+			c.Assume(err, gs.IsNil)
+			tickChan := make(chan time.Time)
+			output.tickChan = tickChan
+			inChan := make(chan *PipelinePack)
+			oth.MockOutputRunner.EXPECT().InChan().Return(inChan)
+
+			base := time.Date(2016, time.May, 1, 10, 0, 0, 0, time.UTC)
+			newPack := func(ts time.Time, cursor string) *PipelinePack {
+				pack := NewPipelinePack(pConfig.InputRecycleChan())
+				pack.Message = pipeline_ts.GetTestMessage()
+				pack.Message.SetTimestamp(ts.UnixNano())
+				pack.QueueCursor = cursor
+				return pack
+			}
+
+			done := make(chan struct{})
+			go func() {
+				output.Run(oth.MockOutputRunner, oth.MockHelper)
+				close(done)
+			}()
+
+			inChan <- newPack(base.Add(10*time.Minute), "cursor1")
+			inChan <- newPack(base.Add(70*time.Minute), "cursor2")
+			inChan <- newPack(base.Add(20*time.Minute), "cursor3")
+
+			// Only the first window has expired, but the second window holds
+			// the second message, so the cursor can only advance to the
+			// first one.
+			oth.MockOutputRunner.EXPECT().UpdateCursor("cursor1")
+			tickChan <- base.Add(66 * time.Minute)
+			tickChan <- base.Add(66 * time.Minute) // Wait for first tick.
+
+			matches, err := filepath.Glob(filepath.Join(outDir, "*.parquet"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(matches), gs.Equals, 1)
+			c.Expect(filepath.Base(matches[0])[:22], gs.Equals, "heka-20160501T100000Z-")
+
+			oth.MockOutputRunner.EXPECT().UpdateCursor("cursor3")
+			close(inChan)
+			<-done
+
+			matches, err = filepath.Glob(filepath.Join(outDir, "*.parquet"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(matches), gs.Equals, 2)
+			matches, err = filepath.Glob(filepath.Join(outDir, "*.tmp"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(matches), gs.Equals, 0)
+		})
+	})
+}
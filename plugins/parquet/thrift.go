@@ -0,0 +1,120 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type ids.
+const (
+	thriftBoolTrue  byte = 1
+	thriftBoolFalse byte = 2
+	thriftI32       byte = 5
+	thriftI64       byte = 6
+	thriftBinary    byte = 8
+	thriftList      byte = 9
+	thriftStruct    byte = 12
+)
+
+// Minimal Thrift compact protocol encoder, supporting only what's needed to
+// write Parquet file metadata and page headers.
+type thriftWriter struct {
+	buf    []byte
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	t.buf = append(t.buf, tmp[:n]...)
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.zigzag(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) fieldBool(id int16, v bool) {
+	if v {
+		t.fieldHeader(id, thriftBoolTrue)
+	} else {
+		t.fieldHeader(id, thriftBoolFalse)
+	}
+}
+
+func (t *thriftWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) fieldString(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// Starts a nested struct field, must be balanced by a call to structEnd.
+func (t *thriftWriter) fieldStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// Starts a list field, the elements must be written immediately afterward.
+func (t *thriftWriter) fieldList(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xf0|elemType)
+		t.varint(uint64(size))
+	}
+}
+
+// Starts a struct, either the top level one or a list element.
+func (t *thriftWriter) structBegin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf = append(t.buf, 0)
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// List element writers.
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) elemString(v string) {
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
@@ -0,0 +1,539 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+const magic = "PAR1"
+
+// Parquet physical types.
+const (
+	typeBoolean   int32 = 0
+	typeInt32     int32 = 1
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+)
+
+// Parquet converted (logical) types.
+const (
+	convertedUTF8            int32 = 0
+	convertedTimestampMicros int32 = 10
+)
+
+// Parquet encodings and compression codecs.
+const (
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	codecUncompressed int32 = 0
+	codecGzip         int32 = 2
+)
+
+const (
+	repetitionRequired int32 = 0
+	repetitionOptional int32 = 1
+	pageTypeData       int32 = 0
+)
+
+// Type of the data held in a column.
+type ColumnType int
+
+const (
+	ColumnString ColumnType = iota
+	ColumnBytes
+	ColumnInt32
+	ColumnInt64
+	ColumnDouble
+	ColumnBool
+	ColumnTimestamp
+)
+
+// Maps the type names accepted in config files to column types.
+var ColumnTypes = map[string]ColumnType{
+	"string":    ColumnString,
+	"bytes":     ColumnBytes,
+	"int32":     ColumnInt32,
+	"int64":     ColumnInt64,
+	"double":    ColumnDouble,
+	"bool":      ColumnBool,
+	"timestamp": ColumnTimestamp,
+}
+
+func (ct ColumnType) physical() int32 {
+	switch ct {
+	case ColumnInt32:
+		return typeInt32
+	case ColumnInt64, ColumnTimestamp:
+		return typeInt64
+	case ColumnDouble:
+		return typeDouble
+	case ColumnBool:
+		return typeBoolean
+	}
+	return typeByteArray
+}
+
+// Definition of a single column in a Parquet file's schema.
+type Column struct {
+	Name string
+	Type ColumnType
+	// Required columns can't hold null values.
+	Required bool
+}
+
+// Accumulates the values of one column for the current row group.
+type columnChunk struct {
+	col     *Column
+	values  bytes.Buffer
+	defined []bool
+	// Bit packing state for boolean columns.
+	bits    byte
+	numBits uint
+	// Number of non-null values.
+	count int
+}
+
+// Appends a value to the chunk, returning false if the value is nil or
+// can't be converted to the column's type.
+func (cc *columnChunk) append(val interface{}) bool {
+	var tmp [8]byte
+	switch cc.col.Type {
+	case ColumnString, ColumnBytes:
+		var b []byte
+		switch v := val.(type) {
+		case nil:
+			return false
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			b = []byte(fmt.Sprint(v))
+		}
+		binary.LittleEndian.PutUint32(tmp[:4], uint32(len(b)))
+		cc.values.Write(tmp[:4])
+		cc.values.Write(b)
+	case ColumnInt32:
+		i, ok := toInt64(val)
+		if !ok || i < math.MinInt32 || i > math.MaxInt32 {
+			return false
+		}
+		binary.LittleEndian.PutUint32(tmp[:4], uint32(int32(i)))
+		cc.values.Write(tmp[:4])
+	case ColumnInt64:
+		i, ok := toInt64(val)
+		if !ok {
+			return false
+		}
+		binary.LittleEndian.PutUint64(tmp[:], uint64(i))
+		cc.values.Write(tmp[:])
+	case ColumnTimestamp:
+		var micros int64
+		switch v := val.(type) {
+		case time.Time:
+			micros = v.UnixNano() / 1000
+		default:
+			i, ok := toInt64(val)
+			if !ok {
+				return false
+			}
+			// Heka timestamps are in nanoseconds.
+			micros = i / 1000
+		}
+		binary.LittleEndian.PutUint64(tmp[:], uint64(micros))
+		cc.values.Write(tmp[:])
+	case ColumnDouble:
+		var f float64
+		switch v := val.(type) {
+		case float64:
+			f = v
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(v, 64); err != nil {
+				return false
+			}
+		default:
+			i, ok := toInt64(val)
+			if !ok {
+				return false
+			}
+			f = float64(i)
+		}
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+		cc.values.Write(tmp[:])
+	case ColumnBool:
+		var b bool
+		switch v := val.(type) {
+		case bool:
+			b = v
+		case string:
+			var err error
+			if b, err = strconv.ParseBool(v); err != nil {
+				return false
+			}
+		default:
+			return false
+		}
+		if b {
+			cc.bits |= 1 << cc.numBits
+		}
+		cc.numBits++
+		if cc.numBits == 8 {
+			cc.values.WriteByte(cc.bits)
+			cc.bits, cc.numBits = 0, 0
+		}
+	}
+	cc.count++
+	return true
+}
+
+func (cc *columnChunk) reset() {
+	cc.values.Reset()
+	cc.defined = cc.defined[:0]
+	cc.bits, cc.numBits = 0, 0
+	cc.count = 0
+}
+
+// Returns the page body, i.e. the definition levels (for optional columns)
+// followed by the plain encoded values.
+func (cc *columnChunk) pageData() []byte {
+	var page bytes.Buffer
+	if !cc.col.Required {
+		levels := encodeLevels(cc.defined)
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(levels)))
+		page.Write(tmp[:])
+		page.Write(levels)
+	}
+	page.Write(cc.values.Bytes())
+	if cc.numBits > 0 {
+		page.WriteByte(cc.bits)
+	}
+	return page.Bytes()
+}
+
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Encodes definition levels (with a max level of 1) using the RLE / bit
+// packing hybrid encoding, emitting only RLE runs.
+func encodeLevels(defined []bool) []byte {
+	var (
+		buf []byte
+		tmp [binary.MaxVarintLen64]byte
+	)
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		buf = append(buf, tmp[:n]...)
+		if defined[i] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		i = j
+	}
+	return buf
+}
+
+type columnMeta struct {
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+	dataPageOffset   int64
+}
+
+type rowGroupMeta struct {
+	columns   []columnMeta
+	totalSize int64
+	numRows   int64
+}
+
+// Wraps an io.Writer to keep track of the current file offset.
+type countingWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.offset += int64(n)
+	return n, err
+}
+
+// Writer streams rows out to a Parquet file with a flat schema. Rows are
+// accumulated in memory until FlushRowGroup is called (or the file is
+// closed), at which point they're written out as a row group with one PLAIN
+// encoded data page per column.
+type Writer struct {
+	w         *countingWriter
+	columns   []Column
+	chunks    []*columnChunk
+	rowGroups []rowGroupMeta
+	numRows   int
+	codec     int32
+	closed    bool
+}
+
+// Creates a new Writer, writing the Parquet header to the provided
+// io.Writer. If `compress` is true, pages will be gzip compressed.
+func NewWriter(w io.Writer, columns []Column, compress bool) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("at least one column is required")
+	}
+	pw := &Writer{
+		w:       &countingWriter{w: w},
+		columns: columns,
+		chunks:  make([]*columnChunk, len(columns)),
+		codec:   codecUncompressed,
+	}
+	if compress {
+		pw.codec = codecGzip
+	}
+	for i := range columns {
+		pw.chunks[i] = &columnChunk{col: &pw.columns[i]}
+	}
+	if _, err := io.WriteString(pw.w, magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Appends a row, which must hold one value per column. Values that are nil
+// or that can't be converted to the column's type are stored as nulls, which
+// is an error for required columns.
+func (pw *Writer) Append(row []interface{}) error {
+	if pw.closed {
+		return errors.New("writer is closed")
+	}
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(pw.columns), len(row))
+	}
+	for i, val := range row {
+		if !pw.columns[i].Required {
+			continue
+		}
+		if val == nil {
+			return fmt.Errorf("missing value for required column '%s'",
+				pw.columns[i].Name)
+		}
+	}
+	for i, val := range row {
+		cc := pw.chunks[i]
+		ok := val != nil && cc.append(val)
+		if !ok && cc.col.Required {
+			// Keep the row count consistent across columns.
+			cc.append(zeroValue(cc.col.Type))
+			ok = true
+		}
+		cc.defined = append(cc.defined, ok)
+	}
+	pw.numRows++
+	return nil
+}
+
+func zeroValue(ct ColumnType) interface{} {
+	switch ct {
+	case ColumnString, ColumnBytes:
+		return ""
+	case ColumnBool:
+		return false
+	case ColumnDouble:
+		return float64(0)
+	}
+	return int64(0)
+}
+
+// Returns the number of rows buffered for the current row group.
+func (pw *Writer) BufferedRows() int {
+	return pw.numRows
+}
+
+// Writes all buffered rows out as a row group.
+func (pw *Writer) FlushRowGroup() error {
+	if pw.numRows == 0 {
+		return nil
+	}
+	rg := rowGroupMeta{
+		columns: make([]columnMeta, len(pw.chunks)),
+		numRows: int64(pw.numRows),
+	}
+	for i, cc := range pw.chunks {
+		data := cc.pageData()
+		uncompressedLen := len(data)
+		if pw.codec == codecGzip {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write(data)
+			if err := gz.Close(); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		}
+
+		var header thriftWriter
+		header.structBegin()
+		header.fieldI32(1, pageTypeData)
+		header.fieldI32(2, int32(uncompressedLen))
+		header.fieldI32(3, int32(len(data)))
+		header.fieldStruct(5)
+		header.fieldI32(1, int32(pw.numRows))
+		header.fieldI32(2, encodingPlain)
+		header.fieldI32(3, encodingRLE)
+		header.fieldI32(4, encodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		meta := columnMeta{
+			numValues:        int64(pw.numRows),
+			uncompressedSize: int64(len(header.buf) + uncompressedLen),
+			compressedSize:   int64(len(header.buf) + len(data)),
+			dataPageOffset:   pw.w.offset,
+		}
+		if _, err := pw.w.Write(header.buf); err != nil {
+			return err
+		}
+		if _, err := pw.w.Write(data); err != nil {
+			return err
+		}
+		rg.columns[i] = meta
+		rg.totalSize += meta.uncompressedSize
+		cc.reset()
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.numRows = 0
+	return nil
+}
+
+// Flushes any buffered rows and writes the file footer. The underlying
+// io.Writer is not closed.
+func (pw *Writer) Close() error {
+	if pw.closed {
+		return nil
+	}
+	if err := pw.FlushRowGroup(); err != nil {
+		return err
+	}
+	pw.closed = true
+	footer := pw.fileMetaData()
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], uint32(len(footer)))
+	if _, err := pw.w.Write(footer); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write(tmp[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(pw.w, magic)
+	return err
+}
+
+func (pw *Writer) fileMetaData() []byte {
+	var totalRows int64
+	for _, rg := range pw.rowGroups {
+		totalRows += rg.numRows
+	}
+
+	var t thriftWriter
+	t.structBegin()
+	t.fieldI32(1, 1) // version
+	t.fieldList(2, thriftStruct, len(pw.columns)+1)
+	// Root schema element.
+	t.structBegin()
+	t.fieldString(4, "schema")
+	t.fieldI32(5, int32(len(pw.columns)))
+	t.structEnd()
+	for _, col := range pw.columns {
+		t.structBegin()
+		t.fieldI32(1, col.Type.physical())
+		if col.Required {
+			t.fieldI32(3, repetitionRequired)
+		} else {
+			t.fieldI32(3, repetitionOptional)
+		}
+		t.fieldString(4, col.Name)
+		switch col.Type {
+		case ColumnString:
+			t.fieldI32(6, convertedUTF8)
+		case ColumnTimestamp:
+			t.fieldI32(6, convertedTimestampMicros)
+		}
+		t.structEnd()
+	}
+	t.fieldI64(3, totalRows)
+	t.fieldList(4, thriftStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		t.structBegin()
+		t.fieldList(1, thriftStruct, len(rg.columns))
+		for i, cm := range rg.columns {
+			col := pw.columns[i]
+			t.structBegin() // ColumnChunk
+			t.fieldI64(2, cm.dataPageOffset)
+			t.fieldStruct(3) // ColumnMetaData
+			t.fieldI32(1, col.Type.physical())
+			t.fieldList(2, thriftI32, 2)
+			t.elemI32(encodingPlain)
+			t.elemI32(encodingRLE)
+			t.fieldList(3, thriftBinary, 1)
+			t.elemString(col.Name)
+			t.fieldI32(4, pw.codec)
+			t.fieldI64(5, cm.numValues)
+			t.fieldI64(6, cm.uncompressedSize)
+			t.fieldI64(7, cm.compressedSize)
+			t.fieldI64(9, cm.dataPageOffset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.fieldI64(2, rg.totalSize)
+		t.fieldI64(3, rg.numRows)
+		t.structEnd()
+	}
+	t.fieldString(6, "heka")
+	t.structEnd()
+	return t.buf
+}
@@ -0,0 +1,81 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func ParquetWriterSpec(c gs.Context) {
+	c.Specify("A Parquet Writer", func() {
+		var buf bytes.Buffer
+		columns := []Column{
+			{Name: "Timestamp", Type: ColumnTimestamp, Required: true},
+			{Name: "Logger", Type: ColumnString, Required: true},
+			{Name: "status", Type: ColumnInt64},
+			{Name: "ok", Type: ColumnBool},
+		}
+		w, err := NewWriter(&buf, columns, false)
+		c.Assume(err, gs.IsNil)
+
+		c.Specify("writes a well formed file", func() {
+			err = w.Append([]interface{}{int64(1e9), "a", int64(200), true})
+			c.Expect(err, gs.IsNil)
+			err = w.Append([]interface{}{int64(2e9), "b", nil, "false"})
+			c.Expect(err, gs.IsNil)
+			c.Expect(w.BufferedRows(), gs.Equals, 2)
+			err = w.FlushRowGroup()
+			c.Expect(err, gs.IsNil)
+			c.Expect(w.BufferedRows(), gs.Equals, 0)
+			err = w.Append([]interface{}{int64(3e9), "c", "404", nil})
+			c.Expect(err, gs.IsNil)
+			err = w.Close()
+			c.Expect(err, gs.IsNil)
+
+			data := buf.Bytes()
+			c.Expect(string(data[:4]), gs.Equals, magic)
+			c.Expect(string(data[len(data)-4:]), gs.Equals, magic)
+			footerLen := binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4])
+			c.Expect(int(footerLen) < len(data)-12, gs.IsTrue)
+			footer := data[len(data)-8-int(footerLen) : len(data)-8]
+			c.Expect(bytes.Contains(footer, []byte("status")), gs.IsTrue)
+			c.Expect(len(w.rowGroups), gs.Equals, 2)
+			c.Expect(w.rowGroups[0].numRows, gs.Equals, int64(2))
+		})
+
+		c.Specify("rejects bad rows", func() {
+			err = w.Append([]interface{}{int64(1e9), "a"})
+			c.Expect(err, gs.Not(gs.IsNil))
+			err = w.Append([]interface{}{nil, "a", nil, nil})
+			c.Expect(err, gs.Not(gs.IsNil))
+			c.Expect(w.BufferedRows(), gs.Equals, 0)
+		})
+
+		c.Specify("stores unconvertible values as nulls", func() {
+			err = w.Append([]interface{}{int64(1e9), "a", "not a number", true})
+			c.Expect(err, gs.IsNil)
+			c.Expect(w.chunks[2].defined[0], gs.IsFalse)
+			c.Expect(w.chunks[3].defined[0], gs.IsTrue)
+		})
+	})
+
+	c.Specify("Definition levels are run length encoded", func() {
+		levels := encodeLevels([]bool{true, true, true, false, true})
+		c.Expect(bytes.Equal(levels, []byte{6, 1, 2, 0, 2, 1}), gs.IsTrue)
+	})
+}
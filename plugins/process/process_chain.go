@@ -19,6 +19,7 @@ package process
 import (
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -41,6 +42,9 @@ type ManagedCmd struct {
 
 	Stdout_r *io.PipeReader
 	Stderr_r *io.PipeReader
+
+	// Set by Wait() once the process has exited.
+	state *os.ProcessState
 }
 
 func NewManagedCmd(path string, args []string, timeout time.Duration) (mc *ManagedCmd) {
@@ -77,6 +81,7 @@ func (mc *ManagedCmd) Wait() (err error) {
 				mc.Stopchan <- true
 				err = fmt.Errorf("ManagedCmd timedout")
 			case err = <-mc.done:
+				mc.state = mc.ProcessState
 				done = true
 			}
 		}
@@ -85,6 +90,7 @@ func (mc *ManagedCmd) Wait() (err error) {
 		case <-mc.Stopchan:
 			err = fmt.Errorf("ManagedCmd was stopped with error: [%s]", mc.kill())
 		case err = <-mc.done:
+			mc.state = mc.ProcessState
 		}
 	}
 
@@ -110,6 +116,7 @@ func (mc *ManagedCmd) kill() (err error) {
 	}
 	// killing process will make Wait() return
 	<-mc.done
+	mc.state = mc.ProcessState
 	return fmt.Errorf("subprocess was killed: [%s]", strings.Join(mc.Args, " "))
 }
 
@@ -121,6 +128,7 @@ func (mc *ManagedCmd) clone() (clone *ManagedCmd) {
 	clone = NewManagedCmd(mc.Path, mc.Args[1:], mc.timeout_duration)
 	clone.Env = mc.Env
 	clone.Dir = mc.Dir
+	clone.SysProcAttr = mc.SysProcAttr
 	return clone
 }
 
@@ -135,15 +143,21 @@ type CommandChain struct {
 
 	done     chan CommandChainStatus
 	Stopchan chan bool
+	started  time.Time
 }
 
 // A CommandChainStatus records the return execution result of a command chain.
 // ReturnStatus stores the return status of the command chain, which is the
 // return status of the last successfully executed command.
 // SubcmdErrors stores the errors of each subcommand.
+// States stores the state of each subcommand by its index in the chain, nil
+// for subcommands whose state isn't known.
+// WallTime is the time from starting the chain until all commands exited.
 type CommandChainStatus struct {
 	ExitStatus   error
 	SubcmdErrors error
+	States       []*os.ProcessState
+	WallTime     time.Duration
 }
 
 func NewCommandChain(timeout time.Duration) (cc *CommandChain) {
@@ -185,6 +199,7 @@ func (cc *CommandChain) Start() (err error) {
 	/* This is a bit subtle.  You want to spin up all the commands in
 	   order by calling Start().  */
 
+	cc.started = time.Now()
 	for idx, cmd := range cc.Cmds {
 		if idx == (len(cc.Cmds) - 1) {
 			err = cmd.Start(true)
@@ -209,10 +224,12 @@ func (cc *CommandChain) Wait() (cc_status CommandChainStatus) {
 		var subcmd_err error
 		var cc_status CommandChainStatus
 		subcmd_errors := make([]string, 0)
+		cc_status.States = make([]*os.ProcessState, len(cc.Cmds))
 
 		for i, cmd := range cc.Cmds {
 			subcmd_err = cmd.Wait()
 			cc_status.ExitStatus = subcmd_err
+			cc_status.States[i] = cmd.state
 
 			if subcmd_err != nil {
 				subcmd_errors = append(subcmd_errors,
@@ -226,6 +243,7 @@ func (cc *CommandChain) Wait() (cc_status CommandChainStatus) {
 				}
 			}
 		}
+		cc_status.WallTime = time.Since(cc.started)
 		if len(subcmd_errors) > 0 {
 			cc_status.SubcmdErrors = fmt.Errorf(strings.Join(subcmd_errors, "\n"))
 			cc.done <- cc_status
@@ -259,6 +277,7 @@ func (cc *CommandChain) clone() (clone *CommandChain) {
 		cmd := clone.AddStep(orig.Path, orig.Args[1:]...)
 		cmd.Env = orig.Env
 		cmd.Dir = orig.Dir
+		cmd.SysProcAttr = orig.SysProcAttr
 	}
 	return clone
 }
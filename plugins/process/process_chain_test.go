@@ -148,6 +148,9 @@ func ProcessChainSpec(c gs.Context) {
 
 			cc := chain.Wait()
 			c.Expect(cc.SubcmdErrors, gs.IsNil)
+			c.Expect(len(cc.States), gs.Equals, 2)
+			c.Expect(cc.States[0], gs.Not(gs.IsNil))
+			c.Expect(cc.States[1], gs.Not(gs.IsNil))
 
 			c.Expect(<-stderrResult, gs.Equals, "")
 			c.Expect(<-stdoutResult, gs.Equals, PIPE_CMD_OUTPUT)
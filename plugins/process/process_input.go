@@ -24,11 +24,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/pborman/uuid"
 )
 
 type cmdConfig struct {
@@ -41,9 +43,19 @@ type cmdConfig struct {
 	// Environment variables.
 	Env []string
 
+	// Add Env to Heka's environment instead of replacing it.
+	InheritEnv bool `toml:"inherit_env"`
+
 	// Dir specifies the working directory of Command.  Defaults to the
 	// directory where the program resides.
 	Directory string
+
+	// Same as Directory, which is kept for existing configs. Takes
+	// precedence if both are set.
+	WorkingDirectory string `toml:"working_directory"`
+
+	// User name or uid to run the command as.
+	RunAs string `toml:"run_as"`
 }
 
 // Helper function for manually comparing structs since slice attributes mean
@@ -55,6 +67,15 @@ func (c *cmdConfig) Equals(otherC *cmdConfig) bool {
 	if c.Directory != otherC.Directory {
 		return false
 	}
+	if c.WorkingDirectory != otherC.WorkingDirectory {
+		return false
+	}
+	if c.InheritEnv != otherC.InheritEnv {
+		return false
+	}
+	if c.RunAs != otherC.RunAs {
+		return false
+	}
 	if len(c.Args) != len(otherC.Args) {
 		return false
 	}
@@ -91,6 +112,9 @@ type ProcessInputConfig struct {
 
 	ParseStdout bool `toml:"stdout"`
 	ParseStderr bool `toml:"stderr"`
+
+	// Send a message with the exit status and resource usage after each run.
+	SendResults bool `toml:"send_results"`
 }
 
 // Helper function for manually comparing structs since a map attribute means
@@ -108,6 +132,9 @@ func (pic *ProcessInputConfig) Equals(otherPic *ProcessInputConfig) bool {
 	if pic.ParseStderr != otherPic.ParseStderr {
 		return false
 	}
+	if pic.SendResults != otherPic.SendResults {
+		return false
+	}
 	if len(pic.Command) != len(otherPic.Command) {
 		return false
 	}
@@ -134,6 +161,7 @@ type ProcessInput struct {
 
 	parseStdout bool
 	parseStderr bool
+	sendResults bool
 
 	stdoutDeliverer Deliverer
 	stdoutSRunner   SplitterRunner
@@ -148,6 +176,7 @@ type ProcessInput struct {
 	hekaPid        int32
 	tickInterval   uint
 	immediateStart bool
+	skippedCount   int64
 
 	once sync.Once
 }
@@ -171,6 +200,7 @@ func (pi *ProcessInput) Init(config interface{}) (err error) {
 	pi.immediateStart = conf.ImmediateStart
 	pi.parseStdout = conf.ParseStdout
 	pi.parseStderr = conf.ParseStderr
+	pi.sendResults = conf.SendResults
 
 	if len(conf.Command) < 1 {
 		return fmt.Errorf("No Command Configured")
@@ -189,11 +219,23 @@ func (pi *ProcessInput) Init(config interface{}) (err error) {
 
 		cmd := pi.cc.AddStep(cmdCfg.Bin, cmdCfg.Args...)
 
-		if cmdCfg.Directory != "" {
+		if cmdCfg.WorkingDirectory != "" {
+			cmd.Dir = cmdCfg.WorkingDirectory
+		} else if cmdCfg.Directory != "" {
 			cmd.Dir = cmdCfg.Directory
 		}
 		if cmdCfg.Env != nil {
-			cmd.Env = cmdCfg.Env
+			if cmdCfg.InheritEnv {
+				cmd.Env = append(os.Environ(), cmdCfg.Env...)
+			} else {
+				cmd.Env = cmdCfg.Env
+			}
+		}
+		if cmdCfg.RunAs != "" {
+			if err = setRunAs(cmd.Cmd, cmdCfg.RunAs); err != nil {
+				return fmt.Errorf("Command at index [%s][%d]: %s", pi.ProcessName,
+					idx, err)
+			}
 		}
 	}
 
@@ -324,6 +366,13 @@ func (pi *ProcessInput) RunCmd() {
 				pi.stopChan <- true
 				return
 			}
+			// A tick that came while the commands were running is skipped
+			// rather than starting the next run right away.
+			select {
+			case <-tickChan:
+				atomic.AddInt64(&pi.skippedCount, 1)
+			default:
+			}
 		case <-pi.stopChan:
 			return
 		}
@@ -369,6 +418,74 @@ func (pi *ProcessInput) runOnce() {
 		go throwAway(stderrReader)
 	}
 	pi.ccStatus = pi.cc.Wait()
+	if pi.sendResults {
+		pi.sendResult()
+	}
+}
+
+// Sends a message with the exit status of the last command in the chain, or -1
+// if it isn't known, and the time and memory used by all of them. Results are injected directly,
+// since the input's decoder is meant for the commands' output.
+func (pi *ProcessInput) sendResult() {
+	var (
+		userTime, sysTime time.Duration
+		rss               int64
+		hasRSS            bool
+		exitStatus        = -1
+		signal            string
+	)
+	states := pi.ccStatus.States
+	for _, state := range states {
+		if state == nil {
+			continue
+		}
+		userTime += state.UserTime()
+		sysTime += state.SystemTime()
+		if r, ok := maxRSS(state); ok {
+			hasRSS = true
+			if r > rss {
+				rss = r
+			}
+		}
+	}
+	if last := len(states) - 1; last >= 0 && states[last] != nil {
+		if status, ok := states[last].Sys().(syscall.WaitStatus); ok {
+			exitStatus = status.ExitStatus()
+			if status.Signaled() {
+				signal = status.Signal().String()
+			}
+		}
+	}
+
+	pack := <-pi.ir.InChan()
+	msg := pack.Message
+	msg.SetUuid(uuid.NewRandom())
+	msg.SetTimestamp(time.Now().UnixNano())
+	msg.SetType("ProcessInputResult")
+	msg.SetPid(pi.hekaPid)
+	msg.SetHostname(pi.hostname)
+	message.NewStringField(msg, "ProcessInputName", pi.ProcessName)
+	message.NewIntField(msg, "ExitStatus", exitStatus, "")
+	if signal != "" {
+		message.NewStringField(msg, "Signal", signal)
+	}
+	addSeconds := func(name string, d time.Duration) {
+		if f, err := message.NewField(name, d.Seconds(), "s"); err == nil {
+			msg.AddField(f)
+		}
+	}
+	addSeconds("WallTime", pi.ccStatus.WallTime)
+	addSeconds("UserTime", userTime)
+	addSeconds("SystemTime", sysTime)
+	if hasRSS {
+		message.NewInt64Field(msg, "MaxRSS", rss, "B")
+	}
+	if pi.ccStatus.SubcmdErrors != nil {
+		message.NewStringField(msg, "SubcmdErrors", pi.ccStatus.SubcmdErrors.Error())
+	}
+	if err := pi.ir.Inject(pack); err != nil {
+		pi.ir.LogError(fmt.Errorf("can't inject result message: %s", err))
+	}
 }
 
 func (pi *ProcessInput) ParseOutput(r io.Reader, deliverer Deliverer,
@@ -386,6 +503,12 @@ func (pi *ProcessInput) ParseOutput(r io.Reader, deliverer Deliverer,
 	}
 }
 
+func (pi *ProcessInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "SkippedRunCount",
+		atomic.LoadInt64(&pi.skippedCount), "count")
+	return nil
+}
+
 // CleanupForRestart implements the Restarting interface.
 func (pi *ProcessInput) CleanupForRestart() {
 	// Reset the CommandChain (and therefore os.exec status)
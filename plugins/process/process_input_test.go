@@ -18,6 +18,9 @@ package process
 import (
 	"io"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"time"
 
 	. "github.com/mozilla-services/heka/pipeline"
@@ -150,6 +153,77 @@ func ProcessInputSpec(c gs.Context) {
 				err = <-errChan
 				c.Expect(err, gs.IsNil)
 			})
+
+			c.Specify("runs commands in the working directory", func() {
+				pInput.SetName("WorkingDir")
+
+				arg := PROCESSINPUT_TEST1_CMD_ARGS[0]
+				config.Command["0"] = cmdConfig{
+					Bin:              PROCESSINPUT_TEST1_CMD,
+					Args:             []string{filepath.Base(arg)},
+					WorkingDirectory: filepath.Dir(arg),
+					Env:              []string{"HEKA_TEST=1"},
+					InheritEnv:       true,
+				}
+				err := pInput.Init(config)
+				c.Assume(err, gs.IsNil)
+				env := pInput.cc.Cmds[0].Env
+				c.Expect(len(env), gs.Equals, len(os.Environ())+1)
+				c.Expect(env[len(env)-1], gs.Equals, "HEKA_TEST=1")
+
+				go func() {
+					errChan <- pInput.Run(ith.MockInputRunner, ith.MockHelper)
+				}()
+				tickChan <- time.Now()
+
+				actual := <-bytesChan
+				c.Expect(string(actual), gs.Equals, PROCESSINPUT_TEST1_OUTPUT+"\n")
+				<-decChan
+
+				pInput.Stop()
+				err = <-errChan
+				c.Expect(err, gs.IsNil)
+			})
+
+			c.Specify("sends a result message after each run", func() {
+				pInput.SetName("Results")
+				config.SendResults = true
+				config.Command["0"] = cmdConfig{Bin: STDERR_CMD, Args: STDERR_CMD_ARGS}
+				err := pInput.Init(config)
+				c.Assume(err, gs.IsNil)
+
+				inChan := make(chan *PipelinePack, 1)
+				inChan <- ith.Pack
+				ith.MockInputRunner.EXPECT().InChan().Return(inChan)
+				injected := make(chan *PipelinePack, 1)
+				injectCall := ith.MockInputRunner.EXPECT().Inject(ith.Pack).Return(nil)
+				injectCall.Do(func(pack *PipelinePack) {
+					injected <- pack
+				})
+
+				go func() {
+					errChan <- pInput.Run(ith.MockInputRunner, ith.MockHelper)
+				}()
+				tickChan <- time.Now()
+				<-bytesChan
+				<-decChan
+
+				msg := (<-injected).Message
+				c.Expect(msg.GetType(), gs.Equals, "ProcessInputResult")
+				field := msg.FindFirstField("ProcessInputName")
+				c.Expect(field.ValueString[0], gs.Equals, "Results")
+				field = msg.FindFirstField("ExitStatus")
+				c.Expect(field.ValueInteger[0], gs.Not(gs.Equals), int64(0))
+				c.Expect(msg.FindFirstField("Signal"), gs.IsNil)
+				field = msg.FindFirstField("WallTime")
+				c.Expect(field.GetRepresentation(), gs.Equals, "s")
+				c.Expect(field.ValueDouble[0] > 0, gs.IsTrue)
+				c.Expect(msg.FindFirstField("SubcmdErrors"), gs.Not(gs.IsNil))
+
+				pInput.Stop()
+				err = <-errChan
+				c.Expect(err, gs.IsNil)
+			})
 		})
 
 		c.Specify("using stderr", func() {
@@ -184,4 +258,59 @@ func ProcessInputSpec(c gs.Context) {
 			})
 		})
 	})
+
+	c.Specify("A ProcessInput with run_as", func() {
+		pInput := ProcessInput{}
+		config := pInput.ConfigStruct().(*ProcessInputConfig)
+		config.Command = make(map[string]cmdConfig)
+
+		c.Specify("rejects unknown users", func() {
+			config.Command["0"] = cmdConfig{
+				Bin:   PROCESSINPUT_TEST1_CMD,
+				RunAs: "no-such-heka-user",
+			}
+			err := pInput.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+	})
+
+	c.Specify("A ProcessInput's result message", func() {
+		pInput := ProcessInput{ProcessName: "Results"}
+		pInput.ir = ith.MockInputRunner
+
+		// A command that exits with an error.
+		cmd := exec.Command(STDERR_CMD, STDERR_CMD_ARGS...)
+		c.Assume(cmd.Run(), gs.Not(gs.IsNil))
+		state := cmd.ProcessState
+
+		inChan := make(chan *PipelinePack, 1)
+		inChan <- ith.Pack
+		ith.MockInputRunner.EXPECT().InChan().Return(inChan)
+		injected := make(chan *PipelinePack, 1)
+		injectCall := ith.MockInputRunner.EXPECT().Inject(ith.Pack).Return(nil)
+		injectCall.Do(func(pack *PipelinePack) {
+			injected <- pack
+		})
+
+		c.Specify("has the exit status of the last command", func() {
+			pInput.ccStatus.States = []*os.ProcessState{nil, state}
+			pInput.sendResult()
+
+			msg := (<-injected).Message
+			field := msg.FindFirstField("ExitStatus")
+			c.Expect(field.ValueInteger[0], gs.Equals, int64(state.ExitCode()))
+			c.Expect(field.ValueInteger[0] > 0, gs.IsTrue)
+		})
+
+		c.Specify("has an unknown exit status if the last command has none", func() {
+			pInput.ccStatus.States = []*os.ProcessState{state, nil}
+			pInput.sendResult()
+
+			msg := (<-injected).Message
+			field := msg.FindFirstField("ExitStatus")
+			c.Expect(field.ValueInteger[0], gs.Equals, int64(-1))
+			c.Expect(msg.FindFirstField("Signal"), gs.IsNil)
+			c.Expect(msg.FindFirstField("UserTime"), gs.Not(gs.IsNil))
+		})
+	})
 }
@@ -0,0 +1,67 @@
+// +build !windows
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// Sets the command up to run as the user, given as a user name or uid, with
+// the user's primary group.
+func setRunAs(cmd *exec.Cmd, runAs string) error {
+	u, err := user.Lookup(runAs)
+	if err != nil {
+		if u, err = user.LookupId(runAs); err != nil {
+			return fmt.Errorf("unknown run_as user '%s'", runAs)
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("run_as user '%s' has no numeric uid", runAs)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("run_as user '%s' has no numeric gid", runAs)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid: uint32(uid),
+		Gid: uint32(gid),
+	}
+	return nil
+}
+
+// Returns the maximum resident set size of an exited process in bytes.
+func maxRSS(state *os.ProcessState) (int64, bool) {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0, false
+	}
+	// Darwin reports bytes, the others kilobytes.
+	if runtime.GOOS == "darwin" {
+		return int64(rusage.Maxrss), true
+	}
+	return int64(rusage.Maxrss) * 1024, true
+}
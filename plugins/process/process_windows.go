@@ -0,0 +1,30 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setRunAs(cmd *exec.Cmd, runAs string) error {
+	return errors.New("run_as isn't supported on Windows")
+}
+
+// The maximum resident set size isn't available on Windows.
+func maxRSS(state *os.ProcessState) (int64, bool) {
+	return 0, false
+}
@@ -18,10 +18,14 @@ package statsd
 import (
 	"bytes"
 	"fmt"
+	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins/udp"
 	"math"
 	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,11 +33,13 @@ import (
 // aggregated values. It can listen on a UDP address if configured to do so
 // for standard statsd packets of message type Counter, Gauge, or Timer. It
 // also accepts StatPacket objects generated from within Heka itself (usually
-// via a configured StatFilter plugin) over the exposed `Packet` channel. It
-// currently doesn't support Sets or other metric types.
+// via a configured StatFilter plugin) over the exposed `Packet` channel. Sets,
+// histograms, distributions and DogStatsD tags are supported as well.
 type StatsdInput struct {
+	packetCount   int64
 	name          string
-	listener      net.Conn
+	listeners     []net.Conn
+	udpConns      []*net.UDPConn
 	stopChan      chan bool
 	statChan      chan<- Stat
 	statAccumName string
@@ -54,6 +60,12 @@ type StatsdInputConfig struct {
 	// sends a lots in single message of stats it's required to boost this value.
 	// Defaults to 512.
 	MaxMsgSize uint `toml:"max_msg_size"`
+	// Number of sockets bound to the address using SO_REUSEPORT, each read
+	// from by its own goroutine. Defaults to 1.
+	Sockets int `toml:"sockets"`
+	// Size of the sockets' kernel receive buffers in bytes, 0 leaves the
+	// system default in place.
+	ReceiveBufferSize int `toml:"receive_buffer_size"`
 }
 
 func (s *StatsdInput) ConfigStruct() interface{} {
@@ -61,6 +73,7 @@ func (s *StatsdInput) ConfigStruct() interface{} {
 		Address:       "127.0.0.1:8125",
 		StatAccumName: "StatAccumInput",
 		MaxMsgSize:    512,
+		Sockets:       1,
 	}
 }
 
@@ -70,10 +83,17 @@ func (s *StatsdInput) Init(config interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResolveUDPAddr failed: %s\n", err.Error())
 	}
-	s.listener, err = net.ListenUDP("udp", udpAddr)
+	if conf.Sockets < 1 {
+		conf.Sockets = 1
+	}
+	s.udpConns, err = udp.ListenUDPSockets("udp", udpAddr, conf.Sockets,
+		conf.ReceiveBufferSize)
 	if err != nil {
 		return fmt.Errorf("ListenUDP failed: %s\n", err.Error())
 	}
+	for _, conn := range s.udpConns {
+		s.listeners = append(s.listeners, conn)
+	}
 	s.statAccumName = conf.StatAccumName
 	s.maxMsgSize = conf.MaxMsgSize
 	s.stopChan = make(chan bool)
@@ -88,19 +108,31 @@ func (s *StatsdInput) Run(ir InputRunner, h PluginHelper) (err error) {
 		return
 	}
 
-	// Spin up the UDP listener.
+	// Spin up the UDP listeners.
+	var wg sync.WaitGroup
+	for _, listener := range s.listeners {
+		wg.Add(1)
+		go s.readSocket(listener, &wg)
+	}
+	wg.Wait()
+	return
+}
+
+// Handles the packets received on one of the sockets until stopped.
+func (s *StatsdInput) readSocket(listener net.Conn, wg *sync.WaitGroup) {
 	var (
 		n       int
 		e       error
 		stopped bool
 	)
-	defer s.listener.Close()
+	defer wg.Done()
+	defer listener.Close()
 	timeout := time.Duration(time.Millisecond * 100)
 
 	for !stopped {
 		message := make([]byte, s.maxMsgSize)
-		s.listener.SetReadDeadline(time.Now().Add(timeout))
-		n, e = s.listener.Read(message)
+		listener.SetReadDeadline(time.Now().Add(timeout))
+		n, e = listener.Read(message)
 
 		select {
 		case <-s.stopChan:
@@ -112,16 +144,24 @@ func (s *StatsdInput) Run(ir InputRunner, h PluginHelper) (err error) {
 			continue
 		}
 
+		atomic.AddInt64(&s.packetCount, 1)
 		s.handleMessage(message[:n])
 	}
-
-	return
 }
 
 func (s *StatsdInput) Stop() {
 	close(s.stopChan)
 }
 
+func (s *StatsdInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "PacketCount", atomic.LoadInt64(&s.packetCount),
+		"count")
+	if drops, err := udp.UDPDropCount(s.udpConns); err == nil {
+		message.NewInt64Field(msg, "DroppedPacketCount", drops, "count")
+	}
+	return nil
+}
+
 // Parses received raw statsd bytes data and converts it into a StatPacket
 // object that can be passed to the StatMonitor.
 func (s *StatsdInput) handleMessage(message []byte) {
@@ -173,26 +213,40 @@ func parseMessage(message []byte) ([]Stat, [][]byte) {
 
 		bucket := line[:colonPos]
 		value := line[colonPos+1 : pipePos]
-		modifier, sampleMaybe, err := extractModifier(line[pipePos+1:])
-		if err != nil {
+		// The type is followed by optional "|@<sample rate>" and DogStatsD
+		// "|#<tag>,<tag>" sections, other sections are ignored.
+		sections := bytes.Split(line[pipePos+1:], []byte("|"))
+		modifier := sections[0]
+		if !validModifier(modifier) {
 			badLines = append(badLines, line)
 			continue
 		}
 
-		sampleRate := float32(1)
-		if sampleMaybe {
-			sampleRate, err = extractSampleRate(line[pipePos+2:])
-			if err != nil {
-				badLines = append(badLines, line)
-				continue
-			}
-		}
-
 		var stat Stat
 		stat.Bucket = string(bucket)
 		stat.Value = string(value)
 		stat.Modifier = string(modifier)
-		stat.Sampling = sampleRate
+		stat.Sampling = float32(1)
+
+		var err error
+		for _, section := range sections[1:] {
+			if len(section) < 2 {
+				continue
+			}
+			switch section[0] {
+			case '@':
+				stat.Sampling, err = extractSampleRate(section[1:])
+			case '#':
+				stat.Tags = extractTags(section[1:])
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			badLines = append(badLines, line)
+			continue
+		}
 
 		stats = append(stats, stat)
 	}
@@ -200,50 +254,43 @@ func parseMessage(message []byte) ([]Stat, [][]byte) {
 	return stats, badLines
 }
 
-func extractModifier(message []byte) ([]byte, bool, error) {
-	l := len(message)
-	switch {
-	case l == 1:
-		for _, m := range []byte{'g', 'h', 'm', 'c'} {
-			if message[0] == m {
-				return message, false, nil
-			}
-		}
-	case l == 2:
-		if bytes.Equal(message, []byte("ms")) {
-			return []byte("ms"), false, nil
-		}
-	case l > 2:
-		if message[0] == 'c' {
-			return []byte("c"), true, nil
-		}
-		if message[0] == 'g' {
-			return []byte("g"), true, nil
-		}
-		if bytes.HasPrefix(message, []byte("ms")) {
-			return []byte("ms"), true, nil
+// Counters, gauges, timers, histograms, meters, sets and distributions.
+var modifiers = [][]byte{
+	[]byte("c"), []byte("g"), []byte("ms"), []byte("h"), []byte("m"), []byte("s"),
+	[]byte("d"),
+}
+
+func validModifier(modifier []byte) bool {
+	for _, m := range modifiers {
+		if bytes.Equal(modifier, m) {
+			return true
 		}
 	}
-	return []byte{}, false, fmt.Errorf("invalid modifier in message %s", message)
+	return false
 }
 
-func extractSampleRate(message []byte) (float32, error) {
-	l := len(message)
-	if l > 0 && message[0] == 's' {
-		// Leftover "s" from "ms" modifier.
-		l = l - 1
-		message = message[1:]
-	}
-	if l < 3 || !bytes.HasPrefix(message, []byte("|@")) {
-		return 1, nil
-	}
-	sampleRate, err := strconv.ParseFloat(string(message[2:]), 32)
+func extractSampleRate(rate []byte) (float32, error) {
+	sampleRate, err := strconv.ParseFloat(string(rate), 32)
 	if err != nil {
 		return 1, err
 	}
+	if sampleRate <= 0 {
+		return 1, fmt.Errorf("sample rate %f isn't positive", sampleRate)
+	}
 	return float32(sampleRate), nil
 }
 
+func extractTags(tags []byte) []string {
+	parts := bytes.Split(tags, []byte(","))
+	extracted := make([]string, 0, len(parts))
+	for _, tag := range parts {
+		if tag = bytes.TrimSpace(tag); len(tag) > 0 {
+			extracted = append(extracted, string(tag))
+		}
+	}
+	return extracted
+}
+
 func init() {
 	RegisterPlugin("StatsdInput", func() interface{} {
 		return new(StatsdInput)
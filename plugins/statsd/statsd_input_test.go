@@ -22,6 +22,8 @@ import (
 	plugins_ts "github.com/mozilla-services/heka/plugins/testsupport"
 	"github.com/rafrombrc/gomock/gomock"
 	gs "github.com/rafrombrc/gospec/src/gospec"
+	"reflect"
+	"runtime"
 	"strconv"
 	"sync"
 	"testing"
@@ -54,11 +56,11 @@ func StatsdInputSpec(c gs.Context) {
 		config.Address = ith.AddrStr
 		err := statsdInput.Init(config)
 		c.Assume(err, gs.IsNil)
-		realListener := statsdInput.listener
+		realListener := statsdInput.listeners[0]
 		c.Expect(realListener.LocalAddr().String(), gs.Equals, ith.ResolvedAddrStr)
 		realListener.Close()
 		mockListener := pipeline_ts.NewMockConn(ctrl)
-		statsdInput.listener = mockListener
+		statsdInput.listeners[0] = mockListener
 
 		ith.MockHelper.EXPECT().StatAccumulator("StatAccumInput").Return(mockStatAccum, nil)
 		mockListener.EXPECT().Close()
@@ -68,7 +70,7 @@ func StatsdInputSpec(c gs.Context) {
 			statName := "sample.count"
 			statVal := 303
 			msg := fmt.Sprintf("%s:%d|c\n", statName, statVal)
-			expected := Stat{statName, strconv.Itoa(statVal), "c", float32(1)}
+			expected := Stat{statName, strconv.Itoa(statVal), "c", float32(1), nil}
 			mockStatAccum.EXPECT().DropStat(expected).Return(true)
 			readCall := mockListener.EXPECT().Read(make([]byte, 512))
 			readCall.Return(len(msg), nil)
@@ -97,6 +99,7 @@ func TestParseMessage(t *testing.T) {
 			"123",
 			"g",
 			float32(1),
+			nil,
 		}},
 
 		" \tsample.gauge:123|g\n": []Stat{{
@@ -104,6 +107,7 @@ func TestParseMessage(t *testing.T) {
 			"123",
 			"g",
 			float32(1),
+			nil,
 		}},
 
 		"sample.count:303|c": []Stat{{
@@ -111,6 +115,7 @@ func TestParseMessage(t *testing.T) {
 			"303",
 			"c",
 			float32(1),
+			nil,
 		}},
 
 		"sample.timer:1234|ms": []Stat{{
@@ -118,6 +123,7 @@ func TestParseMessage(t *testing.T) {
 			"1234",
 			"ms",
 			float32(1),
+			nil,
 		}},
 
 		"sample.histogram:1234|h": []Stat{{
@@ -125,6 +131,7 @@ func TestParseMessage(t *testing.T) {
 			"1234",
 			"h",
 			float32(1),
+			nil,
 		}},
 
 		"sample.meter:1234|m": []Stat{{
@@ -132,6 +139,7 @@ func TestParseMessage(t *testing.T) {
 			"1234",
 			"m",
 			float32(1),
+			nil,
 		}},
 
 		// with sample rate ----------------------------------
@@ -141,6 +149,7 @@ func TestParseMessage(t *testing.T) {
 			"123",
 			"c",
 			float32(0.9),
+			nil,
 		}},
 
 		"sample.timer.w.rate:1234|ms|@0.5": []Stat{{
@@ -148,6 +157,41 @@ func TestParseMessage(t *testing.T) {
 			"1234",
 			"ms",
 			float32(0.5),
+			nil,
+		}},
+
+		// DogStatsD types and tags ---------------------------
+
+		"sample.set:user42|s": []Stat{{
+			"sample.set",
+			"user42",
+			"s",
+			float32(1),
+			nil,
+		}},
+
+		"sample.distribution:12.5|d|#env:prod": []Stat{{
+			"sample.distribution",
+			"12.5",
+			"d",
+			float32(1),
+			[]string{"env:prod"},
+		}},
+
+		"sample.count.tagged:1|c|@0.5|#env:prod, canary,,region:eu-west-1": []Stat{{
+			"sample.count.tagged",
+			"1",
+			"c",
+			float32(0.5),
+			[]string{"env:prod", "canary", "region:eu-west-1"},
+		}},
+
+		"sample.timer.tagged:7|ms|#env:prod|@0.25|c:container-id": []Stat{{
+			"sample.timer.tagged",
+			"7",
+			"ms",
+			float32(0.25),
+			[]string{"env:prod"},
 		}},
 
 		// with multiple stats -------------------------------
@@ -157,11 +201,13 @@ func TestParseMessage(t *testing.T) {
 			"1234",
 			"c",
 			float32(1),
+			nil,
 		}, Stat{
 			"sample.counter2",
 			"2345",
 			"c",
 			float32(1),
+			nil,
 		}},
 	}
 
@@ -191,6 +237,10 @@ func TestParseMessage(t *testing.T) {
 				t.Fatalf("expected %f at index %d, got %f",
 					expected[index].Sampling, index, stat.Sampling)
 			}
+			if !reflect.DeepEqual(stat.Tags, expected[index].Tags) {
+				t.Fatalf("expected tags %v at index %d, got %v",
+					expected[index].Tags, index, stat.Tags)
+			}
 		}
 	}
 }
@@ -200,6 +250,9 @@ func TestParseMessageInvalid(t *testing.T) {
 		"foo.bar.baz:",
 		"foo.bar.baz|",
 		"foo.bar.baz:1234|x",
+		"foo.bar.baz:1234|cx",
+		"foo.bar.baz:1234|c|@zero",
+		"foo.bar.baz:1234|c|@0",
 	}
 
 	for _, m := range messages {
@@ -210,6 +263,34 @@ func TestParseMessageInvalid(t *testing.T) {
 	}
 }
 
+func TestMultipleSockets(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT sockets are only supported on Linux")
+	}
+	statsdInput := StatsdInput{}
+	config := statsdInput.ConfigStruct().(*StatsdInputConfig)
+	config.Address = "127.0.0.1:0"
+	config.Sockets = 3
+	config.ReceiveBufferSize = 1 << 16
+	if err := statsdInput.Init(config); err != nil {
+		t.Fatalf("can't listen on multiple sockets: %s", err)
+	}
+	defer func() {
+		for _, listener := range statsdInput.listeners {
+			listener.Close()
+		}
+	}()
+	if len(statsdInput.listeners) != 3 {
+		t.Fatalf("expected 3 sockets, got %d", len(statsdInput.listeners))
+	}
+	addr := statsdInput.listeners[0].LocalAddr().String()
+	for _, listener := range statsdInput.listeners[1:] {
+		if listener.LocalAddr().String() != addr {
+			t.Fatalf("socket bound to %s instead of %s", listener.LocalAddr(), addr)
+		}
+	}
+}
+
 func BenchmarkMessageParser(b *testing.B) {
 	msg := []byte("sample.gauge:123|g\n")
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,125 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// Returned when a frame exceeds the maximum message size. The frame is
+// skipped, so reading can continue with the next one.
+var errFrameTooLarge = errors.New("message exceeds max_message_size")
+
+// Extracts syslog messages from a stream as described in RFC 6587. The
+// framing is detected per message: frames starting with a digit use octet
+// counting (`MSG-LEN SP SYSLOG-MSG`), which allows messages to contain
+// newlines, anything else is assumed to be terminated by a newline.
+type frameReader struct {
+	r       *bufio.Reader
+	maxSize int
+}
+
+func newFrameReader(r io.Reader, maxSize int) *frameReader {
+	return &frameReader{
+		r:       bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// Returns the next message in the stream, or io.EOF once the stream has been
+// exhausted.
+func (f *frameReader) ReadFrame() ([]byte, error) {
+	for {
+		b, err := f.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b[0] >= '1' && b[0] <= '9':
+			return f.readOctetCounted()
+		case b[0] == '\n' || b[0] == '\r' || b[0] == 0:
+			// Skip empty lines and trailer bytes some senders add.
+			f.r.ReadByte()
+		default:
+			return f.readLine()
+		}
+	}
+}
+
+func (f *frameReader) readOctetCounted() ([]byte, error) {
+	lenStr, err := f.r.ReadString(' ')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+	if err != nil || length <= 0 {
+		return nil, fmt.Errorf("invalid message length: %q", lenStr)
+	}
+	if length > f.maxSize {
+		if _, err = io.CopyN(ioutil.Discard, f.r, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, errFrameTooLarge
+	}
+	frame := make([]byte, length)
+	if _, err = io.ReadFull(f.r, frame); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return frame, err
+}
+
+func (f *frameReader) readLine() ([]byte, error) {
+	var frame []byte
+	tooLarge := false
+	for {
+		line, err := f.r.ReadSlice('\n')
+		if !tooLarge {
+			if len(frame)+len(line) > f.maxSize+1 {
+				tooLarge = true
+				frame = nil
+			} else {
+				frame = append(frame, line...)
+			}
+		}
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case nil:
+			if tooLarge {
+				return nil, errFrameTooLarge
+			}
+			return frame[:len(frame)-1], nil
+		case io.EOF:
+			// A final message that isn't terminated by a newline.
+			if len(frame) > 0 {
+				return frame, nil
+			}
+			if tooLarge {
+				return nil, errFrameTooLarge
+			}
+			return nil, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"io"
+	"strings"
+
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func FrameReaderSpec(c gs.Context) {
+	c.Specify("A frame reader", func() {
+		readAll := func(data string, maxSize int) (frames []string, errs []error) {
+			fr := newFrameReader(strings.NewReader(data), maxSize)
+			for {
+				frame, err := fr.ReadFrame()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					errs = append(errs, err)
+					if err != errFrameTooLarge {
+						return
+					}
+					continue
+				}
+				frames = append(frames, string(frame))
+			}
+		}
+
+		c.Specify("splits newline delimited messages", func() {
+			frames, errs := readAll("<13>one\r\n\n<13>two\n<13>three", 1024)
+			c.Expect(len(errs), gs.Equals, 0)
+			c.Expect(len(frames), gs.Equals, 3)
+			c.Expect(frames[0], gs.Equals, "<13>one\r")
+			c.Expect(frames[1], gs.Equals, "<13>two")
+			c.Expect(frames[2], gs.Equals, "<13>three")
+		})
+
+		c.Specify("keeps newlines in octet counted messages", func() {
+			frames, errs := readAll("18 <13>multi\nline msg12 <13>next one", 1024)
+			c.Expect(len(errs), gs.Equals, 0)
+			c.Expect(len(frames), gs.Equals, 2)
+			c.Expect(frames[0], gs.Equals, "<13>multi\nline msg")
+			c.Expect(frames[1], gs.Equals, "<13>next one")
+		})
+
+		c.Specify("handles mixed framing", func() {
+			frames, errs := readAll("<13>lf\n7 <13>oct<13>lf again\n", 1024)
+			c.Expect(len(errs), gs.Equals, 0)
+			c.Expect(frames, gs.ContainsExactly, []string{"<13>lf", "<13>oct", "<13>lf again"})
+		})
+
+		c.Specify("skips messages larger than the max size", func() {
+			long := strings.Repeat("x", 40)
+			frames, errs := readAll("40 "+long+"<13>"+long+"\n<13>ok\n", 20)
+			c.Expect(len(errs), gs.Equals, 2)
+			c.Expect(errs[0], gs.Equals, errFrameTooLarge)
+			c.Expect(errs[1], gs.Equals, errFrameTooLarge)
+			c.Expect(frames, gs.ContainsExactly, []string{"<13>ok"})
+		})
+
+		c.Specify("fails on truncated octet counted messages", func() {
+			frames, errs := readAll("30 <13>short", 1024)
+			c.Expect(len(frames), gs.Equals, 0)
+			c.Expect(errs, gs.ContainsExactly, []error{io.ErrUnexpectedEOF})
+		})
+	})
+}
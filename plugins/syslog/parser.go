@@ -0,0 +1,272 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+)
+
+const nilValue = "-"
+
+var (
+	utf8BOM = []byte("\xEF\xBB\xBF")
+
+	errNoPri        = errors.New("missing or invalid PRI")
+	errBadStructure = errors.New("invalid structured data")
+)
+
+// Parses syslog messages in either the RFC 5424 or the RFC 3164 (BSD)
+// format, filling in the corresponding fields of a Heka message.
+type parser struct {
+	// Location used for RFC 3164 timestamps, which carry no time zone.
+	location *time.Location
+	// Returns the current time, used to infer the year of RFC 3164
+	// timestamps.
+	now func() time.Time
+}
+
+func newParser(location *time.Location) *parser {
+	return &parser{
+		location: location,
+		now:      time.Now,
+	}
+}
+
+// Parses a single syslog message. PRI is mapped to Severity and the Facility
+// field, HOSTNAME to Hostname, APP-NAME (or the RFC 3164 TAG) to Logger,
+// PROCID to Pid and the message text to Payload. Structured data parameters
+// are added as fields named `<SD-ID>.<PARAM-NAME>`. Header values that are
+// absent leave the corresponding message attributes untouched.
+func (p *parser) Parse(data []byte, msg *message.Message) error {
+	data = bytes.TrimRight(data, "\r\n\x00")
+	pri, rest, err := parsePri(data)
+	if err != nil {
+		return err
+	}
+	msg.SetSeverity(int32(pri % 8))
+	message.NewInt64Field(msg, "Facility", int64(pri/8), "")
+
+	if isRFC5424(rest) {
+		return p.parseRFC5424(rest[2:], msg)
+	}
+	p.parseRFC3164(rest, msg)
+	return nil
+}
+
+func parsePri(data []byte) (pri int, rest []byte, err error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errNoPri
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, errNoPri
+	}
+	if pri, err = strconv.Atoi(string(data[1:end])); err != nil || pri > 191 {
+		return 0, nil, errNoPri
+	}
+	return pri, data[end+1:], nil
+}
+
+// RFC 5424 messages have a VERSION (currently always 1) directly after the
+// PRI, which can't be the start of an RFC 3164 timestamp.
+func isRFC5424(data []byte) bool {
+	return len(data) >= 2 && data[0] == '1' && data[1] == ' '
+}
+
+func (p *parser) parseRFC5424(data []byte, msg *message.Message) error {
+	var token string
+	token, data = nextToken(data)
+	if token != nilValue {
+		t, err := time.Parse(time.RFC3339Nano, token)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %s", token)
+		}
+		msg.SetTimestamp(t.UnixNano())
+	}
+	if token, data = nextToken(data); token != nilValue && token != "" {
+		msg.SetHostname(token)
+	}
+	if token, data = nextToken(data); token != nilValue && token != "" {
+		msg.SetLogger(token)
+	}
+	if token, data = nextToken(data); token != nilValue && token != "" {
+		setProcId(msg, token)
+	}
+	if token, data = nextToken(data); token != nilValue && token != "" {
+		message.NewStringField(msg, "MsgId", token)
+	}
+
+	if len(data) == 0 {
+		return errBadStructure
+	}
+	if data[0] == '-' {
+		data = data[1:]
+	} else {
+		var err error
+		if data, err = parseStructuredData(data, msg); err != nil {
+			return err
+		}
+	}
+	if len(data) > 0 {
+		if data[0] != ' ' {
+			return errBadStructure
+		}
+		data = bytes.TrimPrefix(data[1:], utf8BOM)
+	}
+	msg.SetPayload(string(data))
+	return nil
+}
+
+// Parses one or more SD-ELEMENTs, returning the remaining data.
+func parseStructuredData(data []byte, msg *message.Message) ([]byte, error) {
+	for len(data) > 0 && data[0] == '[' {
+		end := bytes.IndexAny(data, " ]")
+		if end < 2 {
+			return nil, errBadStructure
+		}
+		id := string(data[1:end])
+		data = data[end:]
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+			eq := bytes.IndexByte(data, '=')
+			if eq < 1 || len(data) < eq+2 || data[eq+1] != '"' {
+				return nil, errBadStructure
+			}
+			name := string(data[:eq])
+			value, rest, err := parseParamValue(data[eq+2:])
+			if err != nil {
+				return nil, err
+			}
+			message.NewStringField(msg, id+"."+name, value)
+			data = rest
+		}
+		if len(data) == 0 || data[0] != ']' {
+			return nil, errBadStructure
+		}
+		data = data[1:]
+	}
+	return data, nil
+}
+
+// Parses a PARAM-VALUE up to the closing quote, handling the `\"`, `\\` and
+// `\]` escapes.
+func parseParamValue(data []byte) (string, []byte, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			return buf.String(), data[i+1:], nil
+		case '\\':
+			if i+1 < len(data) {
+				switch data[i+1] {
+				case '"', '\\', ']':
+					i++
+				}
+			}
+		}
+		buf.WriteByte(data[i])
+	}
+	return "", nil, errBadStructure
+}
+
+// Parses the BSD format, which is loosely defined enough that anything with
+// a valid PRI is accepted; the parts that can't be recognized end up in the
+// payload.
+func (p *parser) parseRFC3164(data []byte, msg *message.Message) {
+	if t, rest, ok := p.parseStamp(data); ok {
+		msg.SetTimestamp(t.UnixNano())
+		data = rest
+		// The HOSTNAME field is frequently left out, e.g. by local senders,
+		// in which case the first token is already the TAG.
+		if token, rest := nextToken(data); token != "" && len(rest) > 0 &&
+			!bytes.ContainsAny([]byte(token), ":[") {
+			msg.SetHostname(token)
+			data = rest
+		}
+	}
+
+	// The TAG is only recognized when terminated by a colon or a PID in
+	// brackets, to avoid mistaking the first word of the content for it.
+	end := bytes.IndexAny(data, " :[")
+	if end > 0 && data[end] != ' ' {
+		tag := string(data[:end])
+		rest := data[end:]
+		if rest[0] == '[' {
+			if pidEnd := bytes.IndexByte(rest, ']'); pidEnd > 0 {
+				setProcId(msg, string(rest[1:pidEnd]))
+				rest = rest[pidEnd+1:]
+			}
+		}
+		if len(rest) == 0 || rest[0] == ':' || rest[0] == ' ' {
+			msg.SetLogger(tag)
+			rest = bytes.TrimPrefix(rest, []byte(":"))
+			data = bytes.TrimPrefix(rest, []byte(" "))
+		}
+	}
+	msg.SetPayload(string(data))
+}
+
+// Parses the `Mmm dd hh:mm:ss` RFC 3164 timestamp, also accepting the
+// RFC 3339 timestamps many modern senders use instead. The year, which the
+// BSD format lacks, is assumed to be the one closest to the current time.
+func (p *parser) parseStamp(data []byte) (t time.Time, rest []byte, ok bool) {
+	if len(data) > len(time.Stamp) && data[len(time.Stamp)] == ' ' {
+		stamp := string(data[:len(time.Stamp)])
+		if t, err := time.ParseInLocation(time.Stamp, stamp, p.location); err == nil {
+			now := p.now().In(p.location)
+			t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+				t.Second(), 0, p.location)
+			if t.After(now.AddDate(0, 1, 0)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, data[len(time.Stamp)+1:], true
+		}
+	}
+	token, rest := nextToken(data)
+	if t, err := time.Parse(time.RFC3339Nano, token); err == nil && len(rest) > 0 {
+		return t, rest, true
+	}
+	return t, data, false
+}
+
+func setProcId(msg *message.Message, procId string) {
+	if pid, err := strconv.ParseInt(procId, 10, 32); err == nil {
+		msg.SetPid(int32(pid))
+	} else {
+		message.NewStringField(msg, "ProcId", procId)
+	}
+}
+
+// Returns the data up to the next space and the data following that space.
+func nextToken(data []byte) (string, []byte) {
+	end := bytes.IndexByte(data, ' ')
+	if end < 0 {
+		return string(data), nil
+	}
+	return string(data[:end]), data[end+1:]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
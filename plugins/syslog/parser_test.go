@@ -0,0 +1,119 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func ParserSpec(c gs.Context) {
+	c.Specify("A syslog parser", func() {
+		p := newParser(time.UTC)
+		p.now = func() time.Time {
+			return time.Date(2016, time.January, 10, 0, 0, 0, 0, time.UTC)
+		}
+		msg := new(message.Message)
+
+		c.Specify("parses RFC 5424 messages", func() {
+			data := "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog " +
+				"1234 ID47 [exampleSDID@32473 iut=\"3\" eventSource=\"App\\\"lication\"]" +
+				"[examplePriority@32473 class=\"high\"] \xEF\xBB\xBFAn application event\n"
+			err := p.Parse([]byte(data), msg)
+			c.Expect(err, gs.IsNil)
+			c.Expect(msg.GetSeverity(), gs.Equals, int32(5))
+			facility, _ := msg.GetFieldValue("Facility")
+			c.Expect(facility, gs.Equals, int64(20))
+			ts := time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC)
+			c.Expect(msg.GetTimestamp(), gs.Equals, ts.UnixNano())
+			c.Expect(msg.GetHostname(), gs.Equals, "mymachine.example.com")
+			c.Expect(msg.GetLogger(), gs.Equals, "evntslog")
+			c.Expect(msg.GetPid(), gs.Equals, int32(1234))
+			msgId, _ := msg.GetFieldValue("MsgId")
+			c.Expect(msgId, gs.Equals, "ID47")
+			iut, _ := msg.GetFieldValue("exampleSDID@32473.iut")
+			c.Expect(iut, gs.Equals, "3")
+			source, _ := msg.GetFieldValue("exampleSDID@32473.eventSource")
+			c.Expect(source, gs.Equals, "App\"lication")
+			class, _ := msg.GetFieldValue("examplePriority@32473.class")
+			c.Expect(class, gs.Equals, "high")
+			c.Expect(msg.GetPayload(), gs.Equals, "An application event")
+		})
+
+		c.Specify("leaves RFC 5424 nil values unset", func() {
+			msg.SetHostname("peer")
+			err := p.Parse([]byte("<34>1 - - su - - - 'su root' failed"), msg)
+			c.Expect(err, gs.IsNil)
+			c.Expect(msg.GetHostname(), gs.Equals, "peer")
+			c.Expect(msg.GetLogger(), gs.Equals, "su")
+			c.Expect(msg.Pid, gs.IsNil)
+			c.Expect(msg.GetPayload(), gs.Equals, "'su root' failed")
+		})
+
+		c.Specify("keeps non-numeric RFC 5424 PROCIDs as a field", func() {
+			err := p.Parse([]byte("<34>1 - host app worker-1 - -"), msg)
+			c.Expect(err, gs.IsNil)
+			procId, _ := msg.GetFieldValue("ProcId")
+			c.Expect(procId, gs.Equals, "worker-1")
+			c.Expect(msg.GetPayload(), gs.Equals, "")
+		})
+
+		c.Specify("rejects malformed RFC 5424 structured data", func() {
+			err := p.Parse([]byte("<34>1 - host app - - [id foo=\"bar] msg"), msg)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("parses RFC 3164 messages", func() {
+			err := p.Parse([]byte("<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed"), msg)
+			c.Expect(err, gs.IsNil)
+			c.Expect(msg.GetSeverity(), gs.Equals, int32(2))
+			facility, _ := msg.GetFieldValue("Facility")
+			c.Expect(facility, gs.Equals, int64(4))
+			// October is in the past year given the January "now".
+			ts := time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC)
+			c.Expect(msg.GetTimestamp(), gs.Equals, ts.UnixNano())
+			c.Expect(msg.GetHostname(), gs.Equals, "mymachine")
+			c.Expect(msg.GetLogger(), gs.Equals, "su")
+			c.Expect(msg.GetPid(), gs.Equals, int32(230))
+			c.Expect(msg.GetPayload(), gs.Equals, "'su root' failed")
+		})
+
+		c.Specify("parses RFC 3164 messages without a hostname", func() {
+			err := p.Parse([]byte("<13>Jan  9 08:00:00 cron: job done"), msg)
+			c.Expect(err, gs.IsNil)
+			c.Expect(msg.GetHostname(), gs.Equals, "")
+			c.Expect(msg.GetLogger(), gs.Equals, "cron")
+			c.Expect(msg.GetPayload(), gs.Equals, "job done")
+			ts := time.Date(2016, time.January, 9, 8, 0, 0, 0, time.UTC)
+			c.Expect(msg.GetTimestamp(), gs.Equals, ts.UnixNano())
+		})
+
+		c.Specify("puts unrecognized RFC 3164 content in the payload", func() {
+			err := p.Parse([]byte("<13>just some text"), msg)
+			c.Expect(err, gs.IsNil)
+			c.Expect(msg.GetLogger(), gs.Equals, "")
+			c.Expect(msg.GetPayload(), gs.Equals, "just some text")
+		})
+
+		c.Specify("rejects messages without a valid PRI", func() {
+			err := p.Parse([]byte("<192>1 - - - - - -"), msg)
+			c.Expect(err, gs.Equals, errNoPri)
+			err = p.Parse([]byte("no pri here"), msg)
+			c.Expect(err, gs.Equals, errNoPri)
+		})
+	})
+}
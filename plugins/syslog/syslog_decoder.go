@@ -0,0 +1,63 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/mozilla-services/heka/pipeline"
+)
+
+// Decoder that parses an RFC 5424 or RFC 3164 syslog message stored in the
+// payload, for use with inputs other than the SyslogInput.
+type SyslogDecoder struct {
+	parser *parser
+}
+
+type SyslogDecoderConfig struct {
+	// Time zone used for RFC 3164 timestamps, which don't specify one.
+	// Defaults to "UTC".
+	Location string
+}
+
+func (d *SyslogDecoder) ConfigStruct() interface{} {
+	return &SyslogDecoderConfig{
+		Location: "UTC",
+	}
+}
+
+func (d *SyslogDecoder) Init(config interface{}) error {
+	conf := config.(*SyslogDecoderConfig)
+	location, err := time.LoadLocation(conf.Location)
+	if err != nil {
+		return fmt.Errorf("invalid location '%s': %s", conf.Location, err)
+	}
+	d.parser = newParser(location)
+	return nil
+}
+
+func (d *SyslogDecoder) Decode(pack *PipelinePack) (packs []*PipelinePack, err error) {
+	if err = d.parser.Parse([]byte(pack.Message.GetPayload()), pack.Message); err != nil {
+		return nil, err
+	}
+	return []*PipelinePack{pack}, nil
+}
+
+func init() {
+	RegisterPlugin("SyslogDecoder", func() interface{} {
+		return new(SyslogDecoder)
+	})
+}
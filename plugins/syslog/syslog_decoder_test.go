@@ -0,0 +1,62 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	. "github.com/mozilla-services/heka/pipeline"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func SyslogDecoderSpec(c gs.Context) {
+	c.Specify("A SyslogDecoder", func() {
+		decoder := new(SyslogDecoder)
+		config := decoder.ConfigStruct().(*SyslogDecoderConfig)
+		pack := NewPipelinePack(make(chan *PipelinePack, 1))
+
+		c.Specify("rejects unknown locations", func() {
+			config.Location = "Nowhere/Special"
+			err := decoder.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("decodes the payload", func() {
+			err := decoder.Init(config)
+			c.Assume(err, gs.IsNil)
+			pack.Message.SetType("TcpInput")
+			pack.Message.SetPayload(`<11>1 2016-05-01T10:00:00+02:00 web nginx - - ` +
+				`[req@1 path="/"] upstream timed out`)
+			packs, err := decoder.Decode(pack)
+			c.Expect(err, gs.IsNil)
+			c.Expect(len(packs), gs.Equals, 1)
+			msg := packs[0].Message
+			c.Expect(msg.GetType(), gs.Equals, "TcpInput")
+			c.Expect(msg.GetSeverity(), gs.Equals, int32(3))
+			c.Expect(msg.GetHostname(), gs.Equals, "web")
+			c.Expect(msg.GetLogger(), gs.Equals, "nginx")
+			path, _ := msg.GetFieldValue("req@1.path")
+			c.Expect(path, gs.Equals, "/")
+			c.Expect(msg.GetPayload(), gs.Equals, "upstream timed out")
+		})
+
+		c.Specify("fails on invalid messages", func() {
+			err := decoder.Init(config)
+			c.Assume(err, gs.IsNil)
+			pack.Message.SetPayload("no syslog here")
+			packs, err := decoder.Decode(pack)
+			c.Expect(err, gs.Not(gs.IsNil))
+			c.Expect(packs, gs.IsNil)
+		})
+	})
+}
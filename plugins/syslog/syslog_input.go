@@ -0,0 +1,331 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins/tcp"
+	"github.com/pborman/uuid"
+)
+
+// Input plugin that receives syslog messages over UDP, TCP (optionally with
+// TLS) or unix sockets and parses them into Heka messages. Stream
+// connections support both octet counted and newline delimited framing.
+type SyslogInput struct {
+	*SyslogInputConfig
+	name          string
+	hostname      string
+	parser        *parser
+	listener      net.Listener
+	packetConn    net.PacketConn
+	conns         map[net.Conn]struct{}
+	connsLock     sync.Mutex
+	wg            sync.WaitGroup
+	stopChan      chan struct{}
+	processCount  int64
+	failureCount  int64
+	tooLargeCount int64
+}
+
+type SyslogInputConfig struct {
+	// Network type, one of "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6",
+	// "unix" or "unixgram".
+	Net string
+	// Address to listen on, i.e. a host:port pair or a socket path, where a
+	// leading "@" selects the abstract namespace.
+	Address string
+	// Octal permissions of the socket file for unix networks. Defaults to
+	// "0666".
+	SocketPerm string `toml:"socket_perm"`
+	// Owner of the socket file for unix networks, as "user[:group]".
+	SocketOwner string `toml:"socket_owner"`
+	// Set to true if TCP connections should be tunneled through TLS.
+	// Requires additional Tls config section.
+	UseTls bool `toml:"use_tls"`
+	// Subsection for TLS configuration.
+	Tls tcp.TlsConfig
+	// Messages larger than this are discarded. Defaults to 64KiB.
+	MaxMessageSize uint32 `toml:"max_message_size"`
+	// Time zone used for RFC 3164 timestamps, which don't specify one.
+	// Defaults to "UTC".
+	Location string
+}
+
+func (s *SyslogInput) SetName(name string) {
+	s.name = name
+}
+
+func (s *SyslogInput) ConfigStruct() interface{} {
+	config := &SyslogInputConfig{
+		Net:            "udp",
+		Address:        "127.0.0.1:514",
+		MaxMessageSize: 64 * 1024,
+		Location:       "UTC",
+	}
+	config.Tls = tcp.TlsConfig{PreferServerCiphers: true}
+	return config
+}
+
+func (s *SyslogInput) Init(config interface{}) (err error) {
+	s.SyslogInputConfig = config.(*SyslogInputConfig)
+	if s.MaxMessageSize == 0 {
+		return errors.New("max_message_size must be greater than zero")
+	}
+	location, err := time.LoadLocation(s.Location)
+	if err != nil {
+		return fmt.Errorf("invalid location '%s': %s", s.Location, err)
+	}
+	s.parser = newParser(location)
+
+	var perms *tcp.UnixSocketPerms
+	if tcp.IsUnixNet(s.Net) {
+		// Socket files are world writable unless configured otherwise.
+		if perms, err = tcp.ParseUnixSocketPerms(s.SocketPerm, s.SocketOwner); err != nil {
+			return err
+		}
+	}
+	switch s.Net {
+	case "udp", "udp4", "udp6":
+		s.packetConn, err = net.ListenPacket(s.Net, s.Address)
+	case "unixgram":
+		var conn *net.UnixConn
+		if conn, err = tcp.ListenUnixgram(s.Address, perms); err == nil {
+			s.packetConn = conn
+		}
+	case "tcp", "tcp4", "tcp6":
+		s.listener, err = net.Listen(s.Net, s.Address)
+	case "unix":
+		s.listener, err = tcp.ListenUnix(s.Net, s.Address, perms)
+	default:
+		return fmt.Errorf("unsupported net: %s", s.Net)
+	}
+	if err != nil {
+		s.closeListeners()
+		return fmt.Errorf("can't listen on %s: %s", s.Address, err)
+	}
+
+	if s.UseTls {
+		if s.listener == nil || s.Net == "unix" {
+			s.closeListeners()
+			return errors.New("TLS is only supported for TCP connections")
+		}
+		if s.Tls.CertFile == "" || s.Tls.KeyFile == "" {
+			s.closeListeners()
+			return errors.New("TLS config requires both cert_file and key_file value.")
+		}
+		goConf, err := tcp.CreateGoTlsConfig(&s.Tls)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.listener = tls.NewListener(s.listener, goConf)
+	}
+
+	s.conns = make(map[net.Conn]struct{})
+	s.stopChan = make(chan struct{})
+	return nil
+}
+
+func (s *SyslogInput) closeListeners() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.packetConn != nil {
+		s.packetConn.Close()
+	}
+}
+
+func (s *SyslogInput) Run(ir InputRunner, h PluginHelper) error {
+	s.hostname = h.Hostname()
+	if s.packetConn != nil {
+		s.receivePackets(ir)
+	} else {
+		s.acceptConnections(ir)
+	}
+	s.wg.Wait()
+
+	// Closing a unix listener removes its socket file, datagram sockets have
+	// to be cleaned up here.
+	if s.Net == "unixgram" && !tcp.IsAbstractAddress(s.Address) {
+		if err := os.Remove(s.Address); err != nil && !os.IsNotExist(err) {
+			ir.LogError(fmt.Errorf("Error cleaning up unix socket: %s", err))
+		}
+	}
+	return nil
+}
+
+// Reads datagrams until the socket is closed, each datagram holding exactly
+// one message.
+func (s *SyslogInput) receivePackets(ir InputRunner) {
+	deliverer := ir.NewDeliverer("")
+	defer deliverer.Done()
+	buf := make([]byte, s.MaxMessageSize+1)
+	for {
+		n, addr, err := s.packetConn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				ir.LogError(fmt.Errorf("Read error: %s", err))
+				continue
+			}
+			ir.LogError(fmt.Errorf("Read error: %s", err))
+			return
+		}
+		if n > int(s.MaxMessageSize) {
+			atomic.AddInt64(&s.tooLargeCount, 1)
+			continue
+		}
+		s.deliver(ir, deliverer, buf[:n], s.remoteHost(addr))
+	}
+}
+
+func (s *SyslogInput) acceptConnections(ir InputRunner) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				ir.LogError(fmt.Errorf("Accept failed: %s", err))
+				continue
+			}
+			break
+		}
+		s.connsLock.Lock()
+		select {
+		case <-s.stopChan:
+			s.connsLock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.connsLock.Unlock()
+		go s.handleConnection(ir, conn)
+	}
+}
+
+// Extracts messages from a stream connection until it's closed by the peer
+// or by Stop.
+func (s *SyslogInput) handleConnection(ir InputRunner, conn net.Conn) {
+	host := s.remoteHost(conn.RemoteAddr())
+	deliverer := ir.NewDeliverer(host)
+	defer func() {
+		s.connsLock.Lock()
+		delete(s.conns, conn)
+		s.connsLock.Unlock()
+		conn.Close()
+		deliverer.Done()
+		s.wg.Done()
+	}()
+
+	frames := newFrameReader(conn, int(s.MaxMessageSize))
+	for {
+		frame, err := frames.ReadFrame()
+		if err == errFrameTooLarge {
+			atomic.AddInt64(&s.tooLargeCount, 1)
+			continue
+		}
+		if err != nil {
+			if err != io.EOF && !s.stopping() {
+				ir.LogError(fmt.Errorf("Error reading from %s: %s", host, err))
+			}
+			return
+		}
+		s.deliver(ir, deliverer, frame, host)
+	}
+}
+
+// Parses the message into a new pack and hands it off. Messages that can't
+// be parsed are delivered as is, with the raw data as the payload.
+func (s *SyslogInput) deliver(ir InputRunner, deliverer Deliverer, data []byte,
+	host string) {
+
+	pack := <-ir.InChan()
+	pack.Message.SetUuid(uuid.NewRandom())
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetType(s.name)
+	pack.Message.SetHostname(host)
+	if err := s.parser.Parse(data, pack.Message); err != nil {
+		atomic.AddInt64(&s.failureCount, 1)
+		pack.Message.SetPayload(string(data))
+	}
+	atomic.AddInt64(&s.processCount, 1)
+	deliverer.Deliver(pack)
+}
+
+// Returns the IP of the sender, or our own hostname for messages received
+// over unix sockets.
+func (s *SyslogInput) remoteHost(addr net.Addr) string {
+	if addr == nil || strings.HasPrefix(s.Net, "unix") {
+		return s.hostname
+	}
+	raddr := addr.String()
+	host, _, err := net.SplitHostPort(raddr)
+	if err != nil {
+		return raddr
+	}
+	return host
+}
+
+func (s *SyslogInput) stopping() bool {
+	select {
+	case <-s.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *SyslogInput) Stop() {
+	s.connsLock.Lock()
+	close(s.stopChan)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsLock.Unlock()
+	s.closeListeners()
+}
+
+func (s *SyslogInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "ProcessMessageCount",
+		atomic.LoadInt64(&s.processCount), "count")
+	message.NewInt64Field(msg, "ParseFailureCount",
+		atomic.LoadInt64(&s.failureCount), "count")
+	message.NewInt64Field(msg, "TooLargeCount",
+		atomic.LoadInt64(&s.tooLargeCount), "count")
+	return nil
+}
+
+func init() {
+	RegisterPlugin("SyslogInput", func() interface{} {
+		return new(SyslogInput)
+	})
+}
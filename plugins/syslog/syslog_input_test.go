@@ -0,0 +1,172 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package syslog
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func SyslogInputSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pConfig := NewPipelineConfig(nil)
+	mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+
+	c.Specify("A SyslogInput", func() {
+		input := new(SyslogInput)
+		input.SetName("syslog")
+		config := input.ConfigStruct().(*SyslogInputConfig)
+		config.Address = "127.0.0.1:0"
+
+		packSupply := make(chan *PipelinePack, 2)
+		for i := 0; i < 2; i++ {
+			packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+		}
+		delivered := make(chan *PipelinePack, 2)
+
+		runErr := make(chan error, 1)
+		startInput := func(token string) {
+			mockHelper.EXPECT().Hostname().Return("localhost.localdomain")
+			mockIR.EXPECT().NewDeliverer(token).Return(mockDeliverer)
+			mockIR.EXPECT().InChan().Return(packSupply).AnyTimes()
+			mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+			mockDeliverer.EXPECT().Deliver(gomock.Any()).Do(func(pack *PipelinePack) {
+				delivered <- pack
+			}).AnyTimes()
+			mockDeliverer.EXPECT().Done()
+			go func() {
+				runErr <- input.Run(mockIR, mockHelper)
+			}()
+		}
+
+		c.Specify("rejects TLS for datagram sockets", func() {
+			config.UseTls = true
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("receives UDP messages", func() {
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput("")
+
+			conn, err := net.Dial("udp", input.packetConn.LocalAddr().String())
+			c.Assume(err, gs.IsNil)
+			defer conn.Close()
+			_, err = conn.Write([]byte("<14>1 - - app 42 - - hello\n"))
+			c.Expect(err, gs.IsNil)
+			_, err = conn.Write([]byte("not syslog"))
+			c.Expect(err, gs.IsNil)
+
+			pack := <-delivered
+			c.Expect(pack.Message.GetType(), gs.Equals, "syslog")
+			c.Expect(pack.Message.GetHostname(), gs.Equals, "127.0.0.1")
+			c.Expect(pack.Message.GetLogger(), gs.Equals, "app")
+			c.Expect(pack.Message.GetPid(), gs.Equals, int32(42))
+			c.Expect(pack.Message.GetSeverity(), gs.Equals, int32(6))
+			c.Expect(pack.Message.GetPayload(), gs.Equals, "hello")
+			pack = <-delivered
+			c.Expect(pack.Message.GetPayload(), gs.Equals, "not syslog")
+
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+
+			msg := new(message.Message)
+			input.ReportMsg(msg)
+			count, _ := msg.GetFieldValue("ProcessMessageCount")
+			c.Expect(count, gs.Equals, int64(2))
+			failures, _ := msg.GetFieldValue("ParseFailureCount")
+			c.Expect(failures, gs.Equals, int64(1))
+		})
+
+		c.Specify("receives framed TCP messages", func() {
+			config.Net = "tcp"
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			startInput("127.0.0.1")
+
+			conn, err := net.Dial("tcp", input.listener.Addr().String())
+			c.Assume(err, gs.IsNil)
+			defer conn.Close()
+			_, err = conn.Write([]byte("33 <13>1 - host - - - - first\nsecond" +
+				"<13>1 - host - - - - third\n"))
+			c.Expect(err, gs.IsNil)
+
+			pack := <-delivered
+			c.Expect(pack.Message.GetHostname(), gs.Equals, "host")
+			c.Expect(pack.Message.GetPayload(), gs.Equals, "first\nsecond")
+			pack = <-delivered
+			c.Expect(pack.Message.GetPayload(), gs.Equals, "third")
+
+			// Stop has to close the open connection for Run to return.
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+		})
+
+		c.Specify("listens on unix sockets", func() {
+			tmpDir, err := ioutil.TempDir("", "syslog-input-tests")
+			c.Assume(err, gs.IsNil)
+			defer os.RemoveAll(tmpDir)
+			config.Net = "unixgram"
+			config.Address = filepath.Join(tmpDir, "log")
+			config.SocketPerm = "0620"
+
+			// Left behind by a previous run that didn't shut down cleanly.
+			unixAddr, err := net.ResolveUnixAddr("unixgram", config.Address)
+			c.Assume(err, gs.IsNil)
+			stale, err := net.ListenUnixgram("unixgram", unixAddr)
+			c.Assume(err, gs.IsNil)
+			stale.Close()
+
+			err = input.Init(config)
+			c.Assume(err, gs.IsNil)
+			info, err := os.Stat(config.Address)
+			c.Assume(err, gs.IsNil)
+			c.Expect(info.Mode().Perm(), gs.Equals, os.FileMode(0620))
+			startInput("")
+
+			conn, err := net.Dial("unixgram", config.Address)
+			c.Assume(err, gs.IsNil)
+			defer conn.Close()
+			_, err = conn.Write([]byte("<30>Jan  2 03:04:05 sshd[99]: accepted"))
+			c.Expect(err, gs.IsNil)
+
+			pack := <-delivered
+			c.Expect(pack.Message.GetHostname(), gs.Equals, "localhost.localdomain")
+			c.Expect(pack.Message.GetLogger(), gs.Equals, "sshd")
+			c.Expect(pack.Message.GetPid(), gs.Equals, int32(99))
+			c.Expect(pack.Message.GetPayload(), gs.Equals, "accepted")
+
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+			_, err = os.Stat(config.Address)
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+		})
+	})
+}
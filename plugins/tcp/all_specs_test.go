@@ -27,6 +27,9 @@ func TestAllSpecs(t *testing.T) {
 	r.AddSpec(TcpOutputSpec)
 	r.AddSpec(TlsSpec)
 	r.AddSpec(TcpInputSpecFailure)
+	r.AddSpec(TcpInputLimitsSpec)
+	r.AddSpec(TcpInputDrainSpec)
+	r.AddSpec(UnixSocketSpec)
 
 	gospec.MainGoTest(r, t)
 }
@@ -0,0 +1,135 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package tcp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+)
+
+// Wraps an accepted connection, keeping track of when data was last received
+// and throttling reads to the byte rate allowed for the remote host.
+type connReader struct {
+	stats connStats
+	net.Conn
+	raddr    string
+	limiter  *rateLimiter
+	stopChan chan bool
+	lastRead time.Time
+}
+
+func (r *connReader) Read(p []byte) (n int, err error) {
+	n, err = r.Conn.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&r.stats.bytes, int64(n))
+		r.lastRead = time.Now()
+		if r.limiter != nil {
+			// Not reading any further for a while is enough to push back on
+			// the sender through TCP flow control.
+			if wait := r.limiter.take(n); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-r.stopChan:
+				}
+			}
+		}
+	}
+	return n, err
+}
+
+// Sorts connections by the number of bytes read, most first.
+type byBytesRead []*connReader
+
+func (b byBytesRead) Len() int      { return len(b) }
+func (b byBytesRead) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byBytesRead) Less(i, j int) bool {
+	bi, bj := atomic.LoadInt64(&b[i].stats.bytes), atomic.LoadInt64(&b[j].stats.bytes)
+	if bi != bj {
+		return bi > bj
+	}
+	return b[i].raddr < b[j].raddr
+}
+
+// Bytes read, records delivered and errors encountered on a connection.
+type connStats struct {
+	bytes   int64
+	records int64
+	errors  int64
+}
+
+func (s *connStats) add(other *connStats) {
+	s.bytes += atomic.LoadInt64(&other.bytes)
+	s.records += atomic.LoadInt64(&other.records)
+	s.errors += atomic.LoadInt64(&other.errors)
+}
+
+func (s *connStats) report(msg *message.Message, prefix string) {
+	message.NewInt64Field(msg, prefix+"ByteCount", atomic.LoadInt64(&s.bytes), "B")
+	message.NewInt64Field(msg, prefix+"RecordCount", atomic.LoadInt64(&s.records),
+		"count")
+	message.NewInt64Field(msg, prefix+"ErrorCount", atomic.LoadInt64(&s.errors),
+		"count")
+}
+
+// Counts the records the splitter delivers for a connection.
+type countingDeliverer struct {
+	Deliverer
+	stats *connStats
+}
+
+func (d *countingDeliverer) Deliver(pack *PipelinePack) {
+	atomic.AddInt64(&d.stats.records, 1)
+	d.Deliverer.Deliver(pack)
+}
+
+// Token bucket allowing a sustained rate of `rate` bytes per second, with
+// bursts of up to one second's worth of data.
+type rateLimiter struct {
+	sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// Consumes `n` bytes worth of tokens, returning how long the caller needs to
+// wait for the bucket to no longer be in debt.
+func (l *rateLimiter) take(n int) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
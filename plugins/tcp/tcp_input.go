@@ -16,25 +16,58 @@
 package tcp
 
 import (
+	"bytes"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
 )
 
+const (
+	// How often blocked reads wake up to check if the input is being stopped.
+	stopCheckInterval = 5 * time.Second
+	// How often draining connections check if their current record is done.
+	drainCheckInterval = 100 * time.Millisecond
+)
+
 // Input plugin implementation that listens for Heka protocol messages on a
 // specified TCP socket. Creates a separate goroutine for each TCP connection.
 type TcpInput struct {
+	// Connection counts, first to be 64-bit aligned for atomic access.
+	activeConns       int64
+	rejectedConns     int64
+	idleClosedConns   int64
 	keepAliveDuration time.Duration
+	idleTimeout       time.Duration
+	drainTimeout      time.Duration
 	listener          net.Listener
 	wg                sync.WaitGroup
 	stopChan          chan bool
 	ir                InputRunner
 	config            *TcpInputConfig
+	// Set for unix sockets, whose peers are on the local host.
+	hostname string
+	// Connection and rate limiting state for each remote host.
+	sources     map[string]*tcpSource
+	sourcesLock sync.Mutex
+	// Open connections, and the totals of the ones that have been closed.
+	conns       map[*connReader]struct{}
+	closedStats connStats
+	connsLock   sync.Mutex
+}
+
+// Tracks the open connections and the byte rate allowance of a remote host.
+type tcpSource struct {
+	conns   int
+	limiter *rateLimiter
 }
 
 type TcpInputConfig struct {
@@ -42,8 +75,15 @@ type TcpInputConfig struct {
 	// Needs to match the input type.
 	Net string
 	// String representation of the address of the network connection on which
-	// the listener should be listening (e.g. "127.0.0.1:5565").
+	// the listener should be listening (e.g. "127.0.0.1:5565"), or the path
+	// of the socket for unix networks, where a leading "@" selects the
+	// abstract namespace.
 	Address string
+	// Octal permissions of the socket file for unix networks. Defaults to
+	// "0666".
+	SocketPerm string `toml:"socket_perm"`
+	// Owner of the socket file for unix networks, as "user[:group]".
+	SocketOwner string `toml:"socket_owner"`
 	// Set to true if the TCP connection should be tunneled through TLS.
 	// Requires additional Tls config section.
 	UseTls bool `toml:"use_tls"`
@@ -53,6 +93,24 @@ type TcpInputConfig struct {
 	KeepAlive bool `toml:"keep_alive"`
 	// Integer indicating seconds between keep alives.
 	KeepAlivePeriod int `toml:"keep_alive_period"`
+	// Maximum number of concurrent connections, further connections are
+	// closed right after being accepted. 0 means no limit.
+	MaxConnections int `toml:"max_connections"`
+	// Maximum number of concurrent connections from a single remote host.
+	// 0 means no limit.
+	MaxConnectionsPerIp int `toml:"max_connections_per_ip"`
+	// Maximum rate in bytes per second at which data is read from a single
+	// remote host, shared between all of its connections. 0 means no limit.
+	MaxBytesPerSecPerIp int `toml:"max_bytes_per_sec_per_ip"`
+	// Seconds after which a connection that hasn't sent any data is closed.
+	// 0 means connections are never closed for being idle.
+	IdleTimeout int `toml:"idle_timeout"`
+	// Seconds that connections are given on shutdown to finish sending a
+	// partially received record.
+	DrainTimeout int `toml:"drain_timeout"`
+	// Number of open connections whose stats are reported individually, the
+	// ones that read the most bytes. 0 means only totals are reported.
+	ReportConnections int `toml:"report_connections"`
 	// So we can default to using ProtobufDecoder.
 	Decoder string
 	// So we can default to using HekaFramingSplitter.
@@ -61,9 +119,10 @@ type TcpInputConfig struct {
 
 func (t *TcpInput) ConfigStruct() interface{} {
 	config := &TcpInputConfig{
-		Net:      "tcp",
-		Decoder:  "ProtobufDecoder",
-		Splitter: "HekaFramingSplitter",
+		Net:          "tcp",
+		Decoder:      "ProtobufDecoder",
+		Splitter:     "HekaFramingSplitter",
+		DrainTimeout: 5,
 	}
 	config.Tls = TlsConfig{PreferServerCiphers: true}
 	return config
@@ -72,13 +131,19 @@ func (t *TcpInput) ConfigStruct() interface{} {
 func (t *TcpInput) Init(config interface{}) error {
 	var err error
 	t.config = config.(*TcpInputConfig)
-	address, err := net.ResolveTCPAddr(t.config.Net, t.config.Address)
-	if err != nil {
-		return fmt.Errorf("ResolveTCPAddress failed: %s\n", err.Error())
-	}
-	t.listener, err = net.ListenTCP(t.config.Net, address)
-	if err != nil {
-		return fmt.Errorf("ListenTCP failed: %s\n", err.Error())
+	if IsUnixNet(t.config.Net) {
+		if err = t.listenUnix(); err != nil {
+			return err
+		}
+	} else {
+		address, err := net.ResolveTCPAddr(t.config.Net, t.config.Address)
+		if err != nil {
+			return fmt.Errorf("ResolveTCPAddress failed: %s\n", err.Error())
+		}
+		t.listener, err = net.ListenTCP(t.config.Net, address)
+		if err != nil {
+			return fmt.Errorf("ListenTCP failed: %s\n", err.Error())
+		}
 	}
 	// We're already listening, make sure we clean up if init fails later on.
 	closeIt := true
@@ -95,11 +160,37 @@ func (t *TcpInput) Init(config interface{}) error {
 	if t.config.KeepAlivePeriod != 0 {
 		t.keepAliveDuration = time.Duration(t.config.KeepAlivePeriod) * time.Second
 	}
+	if t.config.MaxConnections < 0 || t.config.MaxConnectionsPerIp < 0 ||
+		t.config.MaxBytesPerSecPerIp < 0 || t.config.IdleTimeout < 0 ||
+		t.config.DrainTimeout < 0 || t.config.ReportConnections < 0 {
+		return errors.New("Connection limits and timeouts can't be negative.")
+	}
+	t.idleTimeout = time.Duration(t.config.IdleTimeout) * time.Second
+	t.drainTimeout = time.Duration(t.config.DrainTimeout) * time.Second
+	t.sources = make(map[string]*tcpSource)
+	t.conns = make(map[*connReader]struct{})
 	t.stopChan = make(chan bool)
 	closeIt = false
 	return nil
 }
 
+func (t *TcpInput) listenUnix() (err error) {
+	if t.config.Net == "unixgram" {
+		return errors.New("TcpInput can't use unixgram sockets, use a UdpInput.")
+	}
+	if t.config.KeepAlive {
+		return errors.New("KeepAlive only supported for TCP Connections.")
+	}
+	perms, err := ParseUnixSocketPerms(t.config.SocketPerm, t.config.SocketOwner)
+	if err != nil {
+		return err
+	}
+	if t.listener, err = ListenUnix(t.config.Net, t.config.Address, perms); err != nil {
+		return fmt.Errorf("Listen failed: %s\n", err.Error())
+	}
+	return nil
+}
+
 func (t *TcpInput) setupTls(tomlConf *TlsConfig) (err error) {
 	if tomlConf.CertFile == "" || tomlConf.KeyFile == "" {
 		return errors.New("TLS config requires both cert_file and key_file value.")
@@ -113,18 +204,38 @@ func (t *TcpInput) setupTls(tomlConf *TlsConfig) (err error) {
 
 // Listen on the provided TCP connection, extracting messages from the incoming
 // data until the connection is closed or Stop is called on the input.
-func (t *TcpInput) handleConnection(conn net.Conn) {
-	raddr := conn.RemoteAddr().String()
-	host, _, err := net.SplitHostPort(raddr)
-	if err != nil {
-		host = raddr
-	}
+func (t *TcpInput) handleConnection(conn net.Conn, raddr, host string,
+	source *tcpSource) {
 
-	deliverer := t.ir.NewDeliverer(host)
+	hostname := raddr
+	if t.hostname != "" {
+		hostname = t.hostname
+	}
+	reader := &connReader{
+		Conn:     conn,
+		raddr:    raddr,
+		limiter:  source.limiter,
+		stopChan: t.stopChan,
+		lastRead: time.Now(),
+	}
+	deliverer := &countingDeliverer{
+		Deliverer: t.ir.NewDeliverer(host),
+		stats:     &reader.stats,
+	}
 	sr := t.ir.NewSplitterRunner(host)
 
+	t.connsLock.Lock()
+	t.conns[reader] = struct{}{}
+	t.connsLock.Unlock()
+
 	defer func() {
 		conn.Close()
+		t.connsLock.Lock()
+		delete(t.conns, reader)
+		t.closedStats.add(&reader.stats)
+		t.connsLock.Unlock()
+		t.releaseSource(host)
+		atomic.AddInt64(&t.activeConns, -1)
 		t.wg.Done()
 		deliverer.Done()
 		sr.Done()
@@ -132,29 +243,133 @@ func (t *TcpInput) handleConnection(conn net.Conn) {
 
 	if !sr.UseMsgBytes() {
 		name := t.ir.Name()
+		tlsConn, _ := conn.(*tls.Conn)
+		var clientCN string
 		packDec := func(pack *PipelinePack) {
-			pack.Message.SetHostname(raddr)
+			pack.Message.SetHostname(hostname)
 			pack.Message.SetType(name)
+			message.NewStringField(pack.Message, "RemoteAddr", raddr)
+			// The handshake is done by the time the first record arrives.
+			if tlsConn != nil && clientCN == "" {
+				certs := tlsConn.ConnectionState().PeerCertificates
+				if len(certs) > 0 {
+					clientCN = certs[0].Subject.CommonName
+				}
+			}
+			if clientCN != "" {
+				message.NewStringField(pack.Message, "TlsClientCN", clientCN)
+			}
 		}
 		sr.SetPackDecorator(packDec)
 	}
 
-	stopped := false
-	for !stopped {
-		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readTimeout := stopCheckInterval
+	if t.idleTimeout > 0 && t.idleTimeout < readTimeout {
+		readTimeout = t.idleTimeout
+	}
+
+	var err error
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
 		select {
 		case <-t.stopChan:
-			stopped = true
+			t.drain(reader, sr, deliverer)
+			return
 		default:
-			err = sr.SplitStream(conn, deliverer)
-			if err != nil {
-				if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
-					// keep the connection open, we are just checking to see if
-					// we are shutting down: Issue #354
-				} else {
-					stopped = true
-				}
+		}
+		err = sr.SplitStream(reader, deliverer)
+		if err == nil {
+			continue
+		}
+		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+			// keep the connection open, we are just checking to see if
+			// we are shutting down: Issue #354
+			if t.idleTimeout > 0 && time.Since(reader.lastRead) >= t.idleTimeout {
+				atomic.AddInt64(&t.idleClosedConns, 1)
+				return
 			}
+			continue
+		}
+		if err != io.EOF {
+			atomic.AddInt64(&reader.stats.errors, 1)
+		}
+		return
+	}
+}
+
+// Gives a connection that has a partially received record buffered in the
+// splitter up to `drain_timeout` to send the rest of it. Data following that
+// record, or the record itself if it still isn't complete by then, is
+// delivered as is if the splitter is configured to deliver incomplete final
+// records, and dropped otherwise.
+func (t *TcpInput) drain(reader *connReader, sr SplitterRunner, del Deliverer) {
+	deadline := time.Now().Add(t.drainTimeout)
+	records := atomic.LoadInt64(&reader.stats.records)
+	for {
+		remaining := sr.GetRemainingData()
+		if len(remaining) == 0 {
+			return
+		}
+		if !time.Now().Before(deadline) || atomic.LoadInt64(&reader.stats.records) > records {
+			if sr.IncompleteFinal() {
+				sr.DeliverRecord(remaining, del)
+			} else {
+				atomic.AddInt64(&reader.stats.errors, 1)
+				t.ir.LogError(fmt.Errorf("Dropped incomplete record from %s on shutdown",
+					reader.raddr))
+			}
+			return
+		}
+
+		// Hand the buffered data back to the splitter, followed by whatever
+		// else arrives before the next check.
+		pending := append([]byte(nil), remaining...)
+		readDeadline := time.Now().Add(drainCheckInterval)
+		if readDeadline.After(deadline) {
+			readDeadline = deadline
+		}
+		reader.SetReadDeadline(readDeadline)
+		err := sr.SplitStream(io.MultiReader(bytes.NewReader(pending), reader), del)
+		if neterr, ok := err.(net.Error); !ok || !neterr.Timeout() {
+			// The peer is gone, any incomplete record was already handled by
+			// the splitter.
+			return
+		}
+	}
+}
+
+// Registers a new connection from the given host, returning nil if that
+// would exceed the connection limits.
+func (t *TcpInput) acquireSource(host string) *tcpSource {
+	t.sourcesLock.Lock()
+	defer t.sourcesLock.Unlock()
+	if t.config.MaxConnections > 0 &&
+		atomic.LoadInt64(&t.activeConns) >= int64(t.config.MaxConnections) {
+		return nil
+	}
+	source, ok := t.sources[host]
+	if !ok {
+		source = new(tcpSource)
+		if t.config.MaxBytesPerSecPerIp > 0 {
+			source.limiter = newRateLimiter(t.config.MaxBytesPerSecPerIp)
+		}
+		t.sources[host] = source
+	}
+	if t.config.MaxConnectionsPerIp > 0 && source.conns >= t.config.MaxConnectionsPerIp {
+		return nil
+	}
+	source.conns++
+	atomic.AddInt64(&t.activeConns, 1)
+	return source
+}
+
+func (t *TcpInput) releaseSource(host string) {
+	t.sourcesLock.Lock()
+	defer t.sourcesLock.Unlock()
+	if source, ok := t.sources[host]; ok {
+		source.conns--
+		if source.conns <= 0 {
+			delete(t.sources, host)
 		}
 	}
 }
@@ -163,6 +378,13 @@ func (t *TcpInput) Run(ir InputRunner, h PluginHelper) error {
 	t.ir = ir
 	var conn net.Conn
 	var e error
+	unixNet := IsUnixNet(t.config.Net)
+	if unixNet {
+		t.hostname = h.Hostname()
+	}
+	// Unix socket peers are usually unnamed, so their connections are
+	// numbered to tell them apart.
+	var unixConnSeq int
 	for {
 		if conn, e = t.listener.Accept(); e != nil {
 			if netErr, ok := e.(net.Error); ok && netErr.Temporary() {
@@ -182,18 +404,76 @@ func (t *TcpInput) Run(ir InputRunner, h PluginHelper) error {
 				tcpConn.SetKeepAlivePeriod(t.keepAliveDuration)
 			}
 		}
+		var raddr, host string
+		if unixNet {
+			unixConnSeq++
+			raddr = fmt.Sprintf("%s#%d", t.config.Address, unixConnSeq)
+			host = t.config.Address
+		} else {
+			raddr = conn.RemoteAddr().String()
+			var err error
+			if host, _, err = net.SplitHostPort(raddr); err != nil {
+				host = raddr
+			}
+		}
+		source := t.acquireSource(host)
+		if source == nil {
+			atomic.AddInt64(&t.rejectedConns, 1)
+			conn.Close()
+			continue
+		}
 		t.wg.Add(1)
-		go t.handleConnection(conn)
+		go t.handleConnection(conn, raddr, host, source)
 	}
 	t.wg.Wait()
 	return nil
 }
 
+// Stops accepting connections and makes the open ones drain, see `drain`.
 func (t *TcpInput) Stop() {
 	if err := t.listener.Close(); err != nil {
 		t.ir.LogError(fmt.Errorf("Error closing listener: %s", err))
 	}
+	t.connsLock.Lock()
 	close(t.stopChan)
+	// Wake up blocked reads so the connections notice right away.
+	for reader := range t.conns {
+		reader.SetReadDeadline(time.Now())
+	}
+	t.connsLock.Unlock()
+}
+
+func (t *TcpInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "ActiveConnectionCount",
+		atomic.LoadInt64(&t.activeConns), "count")
+	message.NewInt64Field(msg, "RejectedConnectionCount",
+		atomic.LoadInt64(&t.rejectedConns), "count")
+	message.NewInt64Field(msg, "IdleClosedConnectionCount",
+		atomic.LoadInt64(&t.idleClosedConns), "count")
+
+	t.connsLock.Lock()
+	readers := make([]*connReader, 0, len(t.conns))
+	for reader := range t.conns {
+		readers = append(readers, reader)
+	}
+	totals := t.closedStats
+	t.connsLock.Unlock()
+
+	for _, reader := range readers {
+		totals.add(&reader.stats)
+	}
+	totals.report(msg, "")
+
+	// Reporting every connection would make the report grow with the number
+	// of clients.
+	sort.Sort(byBytesRead(readers))
+	for i, reader := range readers {
+		if i >= t.config.ReportConnections {
+			break
+		}
+		reader.stats.report(msg, "Connection."+reader.raddr+".")
+	}
+	return nil
 }
 
 func init() {
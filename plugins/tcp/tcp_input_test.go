@@ -23,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
 	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
 	"github.com/mozilla-services/heka/pipelinemock"
@@ -89,8 +90,9 @@ func TcpInputSpec(c gs.Context) {
 
 			// splitCall gets called twice. The first time it returns nil, the
 			// second time it returns io.EOF.
+			// The deliverer is wrapped to count records per connection.
 			splitCall := ith.MockSplitterRunner.EXPECT().SplitStream(gomock.Any(),
-				ith.MockDeliverer).AnyTimes()
+				gomock.Any()).AnyTimes()
 			splitCall.Do(func(conn net.Conn, del Deliverer) {
 				recd, _ := ioutil.ReadAll(conn)
 				bytesChan <- recd
@@ -206,3 +208,289 @@ func TcpInputSpecFailure(c gs.Context) {
 	c.Assume(err.Error(), gs.Equals, "ResolveTCPAddress failed: unknown network udp\n")
 
 }
+
+func TcpInputLimitsSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pConfig := NewPipelineConfig(nil)
+	mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+	mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+
+	c.Specify("A TcpInput with limits", func() {
+		tcpInput := new(TcpInput)
+		config := tcpInput.ConfigStruct().(*TcpInputConfig)
+		config.Address = "127.0.0.1:0"
+
+		// Each accepted connection blocks in the splitter until the client
+		// closes it, making its packs decorator available.
+		decorators := make(chan func(*PipelinePack), 2)
+		closed := make(chan struct{}, 2)
+		expectConnection := func() {
+			mockIR.EXPECT().Name().Return("tcp_in")
+			mockIR.EXPECT().NewDeliverer(gomock.Any()).Return(mockDeliverer)
+			mockIR.EXPECT().NewSplitterRunner(gomock.Any()).Return(mockSR)
+			mockSR.EXPECT().UseMsgBytes().Return(false)
+			mockSR.EXPECT().SetPackDecorator(gomock.Any()).Do(func(dec func(*PipelinePack)) {
+				decorators <- dec
+			})
+			mockSR.EXPECT().SplitStream(gomock.Any(), gomock.Any()).Do(
+				func(conn net.Conn, del Deliverer) {
+					ioutil.ReadAll(conn)
+				}).Return(io.EOF)
+			mockDeliverer.EXPECT().Done()
+			mockSR.EXPECT().Done().Do(func() {
+				closed <- struct{}{}
+			})
+		}
+
+		errChan := make(chan error, 1)
+		start := func() string {
+			err := tcpInput.Init(config)
+			c.Assume(err, gs.IsNil)
+			go func() {
+				errChan <- tcpInput.Run(mockIR, mockHelper)
+			}()
+			return tcpInput.listener.Addr().String()
+		}
+		counts := func() (active, rejected, idle int64) {
+			msg := new(message.Message)
+			tcpInput.ReportMsg(msg)
+			value, _ := msg.GetFieldValue("ActiveConnectionCount")
+			active = value.(int64)
+			value, _ = msg.GetFieldValue("RejectedConnectionCount")
+			rejected = value.(int64)
+			value, _ = msg.GetFieldValue("IdleClosedConnectionCount")
+			idle = value.(int64)
+			return
+		}
+
+		c.Specify("rejects connections beyond max_connections_per_ip", func() {
+			config.MaxConnectionsPerIp = 1
+			expectConnection()
+			addr := start()
+
+			first, err := net.Dial("tcp", addr)
+			c.Assume(err, gs.IsNil)
+			dec := <-decorators
+			second, err := net.Dial("tcp", addr)
+			c.Assume(err, gs.IsNil)
+			// The second connection is closed without being read from.
+			second.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err = second.Read(make([]byte, 1))
+			c.Expect(err, gs.Equals, io.EOF)
+			second.Close()
+
+			active, rejected, _ := counts()
+			c.Expect(active, gs.Equals, int64(1))
+			c.Expect(rejected, gs.Equals, int64(1))
+
+			pack := NewPipelinePack(pConfig.InputRecycleChan())
+			dec(pack)
+			c.Expect(pack.Message.GetType(), gs.Equals, "tcp_in")
+			remoteAddr, _ := pack.Message.GetFieldValue("RemoteAddr")
+			c.Expect(remoteAddr, gs.Equals, first.LocalAddr().String())
+
+			first.Close()
+			<-closed
+			tcpInput.Stop()
+			c.Expect(<-errChan, gs.IsNil)
+			active, _, _ = counts()
+			c.Expect(active, gs.Equals, int64(0))
+		})
+
+		c.Specify("closes idle connections", func() {
+			config.IdleTimeout = 1
+			mockIR.EXPECT().Name().Return("tcp_in")
+			mockIR.EXPECT().NewDeliverer(gomock.Any()).Return(mockDeliverer)
+			mockIR.EXPECT().NewSplitterRunner(gomock.Any()).Return(mockSR)
+			mockSR.EXPECT().UseMsgBytes().Return(false)
+			mockSR.EXPECT().SetPackDecorator(gomock.Any())
+			mockSR.EXPECT().SplitStream(gomock.Any(), gomock.Any()).Do(
+				func(conn net.Conn, del Deliverer) {
+					conn.Read(make([]byte, 10))
+				}).Return(timeoutError{}).AnyTimes()
+			mockDeliverer.EXPECT().Done()
+			mockSR.EXPECT().Done()
+			addr := start()
+
+			conn, err := net.Dial("tcp", addr)
+			c.Assume(err, gs.IsNil)
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err = conn.Read(make([]byte, 1))
+			c.Expect(err, gs.Equals, io.EOF)
+
+			tcpInput.Stop()
+			c.Expect(<-errChan, gs.IsNil)
+			_, _, idle := counts()
+			c.Expect(idle, gs.Equals, int64(1))
+		})
+	})
+
+	c.Specify("A rate limiter", func() {
+		limiter := newRateLimiter(1000)
+
+		c.Specify("allows bursts of up to a second's worth of data", func() {
+			c.Expect(limiter.take(1000), gs.Equals, time.Duration(0))
+		})
+
+		c.Specify("makes readers wait when exceeding the rate", func() {
+			wait := limiter.take(1500)
+			c.Expect(wait > 400*time.Millisecond, gs.IsTrue)
+			c.Expect(wait <= 500*time.Millisecond, gs.IsTrue)
+		})
+	})
+}
+
+func TcpInputDrainSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pConfig := NewPipelineConfig(nil)
+	mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+	mockIR := pipelinemock.NewMockInputRunner(ctrl)
+	mockDeliverer := pipelinemock.NewMockDeliverer(ctrl)
+
+	c.Specify("A stopping TcpInput", func() {
+		tcpInput := new(TcpInput)
+		config := tcpInput.ConfigStruct().(*TcpInputConfig)
+		config.Address = "127.0.0.1:0"
+		config.DrainTimeout = 1
+		config.ReportConnections = 1
+
+		splitter := new(TokenSplitter)
+		err := splitter.Init(splitter.ConfigStruct())
+		c.Assume(err, gs.IsNil)
+		srConfig := CommonSplitterConfig{}
+
+		packSupply := make(chan *PipelinePack, 2)
+		for i := 0; i < 2; i++ {
+			packSupply <- NewPipelinePack(pConfig.InputRecycleChan())
+		}
+		payloads := make(chan string, 2)
+		mockIR.EXPECT().Name().Return("tcp_in").AnyTimes()
+		mockIR.EXPECT().InChan().Return(packSupply).AnyTimes()
+		mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+		mockIR.EXPECT().NewDeliverer(gomock.Any()).Return(mockDeliverer)
+		mockDeliverer.EXPECT().Deliver(gomock.Any()).Do(func(pack *PipelinePack) {
+			payloads <- pack.Message.GetPayload()
+		}).AnyTimes()
+		mockDeliverer.EXPECT().Done()
+
+		start := func() net.Conn {
+			sr := NewSplitterRunner("TokenSplitter", splitter, srConfig)
+			sr.SetInputRunner(mockIR)
+			mockIR.EXPECT().NewSplitterRunner(gomock.Any()).Return(
+				unregisteredSplitterRunner{sr})
+			err := tcpInput.Init(config)
+			c.Assume(err, gs.IsNil)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- tcpInput.Run(mockIR, mockHelper)
+			}()
+			conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+			c.Assume(err, gs.IsNil)
+			_, err = conn.Write([]byte("one\ntw"))
+			c.Assume(err, gs.IsNil)
+			c.Expect(<-payloads, gs.Equals, "one\n")
+
+			msg := new(message.Message)
+			tcpInput.ReportMsg(msg)
+			prefix := "Connection." + conn.LocalAddr().String() + "."
+			count, _ := msg.GetFieldValue(prefix + "RecordCount")
+			c.Expect(count, gs.Equals, int64(1))
+			count, _ = msg.GetFieldValue(prefix + "ByteCount")
+			c.Expect(count, gs.Equals, int64(6))
+
+			// Only the totals are reported by default.
+			tcpInput.config.ReportConnections = 0
+			msg = new(message.Message)
+			tcpInput.ReportMsg(msg)
+			_, ok := msg.GetFieldValue(prefix + "ByteCount")
+			c.Expect(ok, gs.IsFalse)
+			count, _ = msg.GetFieldValue("ByteCount")
+			c.Expect(count, gs.Equals, int64(6))
+
+			go func() {
+				tcpInput.Stop()
+				c.Expect(<-errChan, gs.IsNil)
+				close(payloads)
+			}()
+			return conn
+		}
+
+		c.Specify("lets connections finish their current record", func() {
+			conn := start()
+			defer conn.Close()
+			stopped := time.Now()
+			time.Sleep(200 * time.Millisecond)
+			_, err := conn.Write([]byte("o\n"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(<-payloads, gs.Equals, "two\n")
+			// There's no need to wait for the drain timeout once the record
+			// is complete.
+			_, ok := <-payloads
+			c.Expect(ok, gs.IsFalse)
+			c.Expect(time.Since(stopped) < time.Second, gs.IsTrue)
+
+			msg := new(message.Message)
+			tcpInput.ReportMsg(msg)
+			count, _ := msg.GetFieldValue("RecordCount")
+			c.Expect(count, gs.Equals, int64(2))
+		})
+
+		c.Specify("doesn't wait for records following the current one", func() {
+			conn := start()
+			defer conn.Close()
+			_, err := conn.Write([]byte("o\nthr"))
+			c.Expect(err, gs.IsNil)
+			c.Expect(<-payloads, gs.Equals, "two\n")
+			_, ok := <-payloads
+			c.Expect(ok, gs.IsFalse)
+
+			msg := new(message.Message)
+			tcpInput.ReportMsg(msg)
+			count, _ := msg.GetFieldValue("ErrorCount")
+			c.Expect(count, gs.Equals, int64(1))
+		})
+
+		c.Specify("drops incomplete records after the drain timeout", func() {
+			conn := start()
+			defer conn.Close()
+			_, ok := <-payloads
+			c.Expect(ok, gs.IsFalse)
+
+			msg := new(message.Message)
+			tcpInput.ReportMsg(msg)
+			count, _ := msg.GetFieldValue("ErrorCount")
+			c.Expect(count, gs.Equals, int64(1))
+		})
+
+		c.Specify("delivers incomplete records if configured to", func() {
+			incompleteFinal := true
+			srConfig.IncompleteFinal = &incompleteFinal
+			conn := start()
+			defer conn.Close()
+			c.Expect(<-payloads, gs.Equals, "tw")
+		})
+	})
+}
+
+// Splitter runner that wasn't registered with a pipeline config, so there's
+// nothing to clean up when it's done.
+type unregisteredSplitterRunner struct {
+	SplitterRunner
+}
+
+func (sr unregisteredSplitterRunner) Done() {}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
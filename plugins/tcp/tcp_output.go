@@ -46,8 +46,11 @@ type TcpOutput struct {
 
 // ConfigStruct for TcpOutput plugin.
 type TcpOutputConfig struct {
+	// Network type ("tcp", "tcp4", "tcp6", "unix" or "unixpacket"). Defaults
+	// to "tcp".
+	Net string
 	// String representation of the TCP address to which this output should be
-	// sending data.
+	// sending data, or the path of the socket for unix networks.
 	Address      string
 	LocalAddress string `toml:"local_address"`
 	UseTls       bool   `toml:"use_tls"`
@@ -82,6 +85,7 @@ func (t *TcpOutput) ConfigStruct() interface{} {
 		FullAction:        "shutdown",
 	}
 	return &TcpOutputConfig{
+		Net:          "tcp",
 		Address:      "localhost:9125",
 		Encoder:      "ProtobufEncoder",
 		UseBuffering: &b,
@@ -98,13 +102,27 @@ func (t *TcpOutput) Init(config interface{}) (err error) {
 	t.conf = config.(*TcpOutputConfig)
 	t.address = t.conf.Address
 
+	switch t.conf.Net {
+	case "tcp", "tcp4", "tcp6":
+	case "unix", "unixpacket":
+		if err = CheckUnixAddress(t.address); err != nil {
+			return err
+		}
+		if t.conf.LocalAddress != "" || t.conf.KeepAlive {
+			return fmt.Errorf("local_address and keep_alive can't be used with net '%s'",
+				t.conf.Net)
+		}
+	default:
+		return fmt.Errorf("Unsupported net '%s'", t.conf.Net)
+	}
+
 	if t.conf.LocalAddress != "" {
 		// Error out if use_tls and local_address options are both set for now.
 		if t.conf.UseTls {
 			return fmt.Errorf("Cannot combine local_address %s and use_tls config options",
 				t.localAddress)
 		}
-		t.localAddress, err = net.ResolveTCPAddr("tcp", t.conf.LocalAddress)
+		t.localAddress, err = net.ResolveTCPAddr(t.conf.Net, t.conf.LocalAddress)
 	}
 
 	if t.conf.KeepAlivePeriod != 0 {
@@ -190,9 +208,9 @@ func (t *TcpOutput) connect() (err error) {
 		// We should use DialWithDialer but its not in GOLANG release yet.
 		// https://code.google.com/p/go/source/detail?r=3d37606fb79393f22a69573afe31f0b0cd4866e3&name=default
 		// t.connection, err = tls.DialWithDialer(dialer, "tcp", t.address, goTlsConf)
-		t.connection, err = tls.Dial("tcp", t.address, goTlsConf)
+		t.connection, err = tls.Dial(t.conf.Net, t.address, goTlsConf)
 	} else {
-		t.connection, err = dialer.Dial("tcp", t.address)
+		t.connection, err = dialer.Dial(t.conf.Net, t.address)
 	}
 	if err == nil && t.conf.KeepAlive {
 		tcpConn, ok := t.connection.(*net.TCPConn)
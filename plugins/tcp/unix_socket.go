@@ -0,0 +1,221 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Returns true for the unix domain socket network types, i.e. "unix",
+// "unixgram" and "unixpacket".
+func IsUnixNet(network string) bool {
+	return strings.HasPrefix(network, "unix")
+}
+
+// Returns true if the address is in the linux abstract socket namespace,
+// which is denoted by a leading "@".
+func IsAbstractAddress(address string) bool {
+	return strings.HasPrefix(address, "@")
+}
+
+// Checks that a unix domain socket address can be used on this platform.
+func CheckUnixAddress(address string) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("Can't use Unix domain sockets on Windows.")
+	}
+	if address == "" {
+		return errors.New("Unix domain sockets require a socket path.")
+	}
+	if IsAbstractAddress(address) && runtime.GOOS != "linux" {
+		return errors.New("Abstract sockets are linux-specific.")
+	}
+	return nil
+}
+
+// Mode and ownership given to the files of the unix domain sockets that are
+// created, a UID or GID of -1 leaves it unchanged.
+type UnixSocketPerms struct {
+	Mode os.FileMode
+	Uid  int
+	Gid  int
+}
+
+// Parses the octal `socket_perm` (e.g. "0660") and the "user[:group]"
+// `socket_owner` config options, where the user and group may be given as
+// names or numeric ids.
+func ParseUnixSocketPerms(perm, owner string) (perms *UnixSocketPerms, err error) {
+	perms = &UnixSocketPerms{Mode: 0666, Uid: -1, Gid: -1}
+	if perm != "" {
+		mode, err := strconv.ParseUint(perm, 8, 32)
+		if err != nil || mode > 0777 {
+			return nil, fmt.Errorf("Invalid socket_perm '%s', must be octal, e.g. \"0660\"",
+				perm)
+		}
+		perms.Mode = os.FileMode(mode)
+	}
+	if owner == "" {
+		return perms, nil
+	}
+	userName, groupName := owner, ""
+	if i := strings.Index(owner, ":"); i >= 0 {
+		userName, groupName = owner[:i], owner[i+1:]
+	}
+	if userName != "" {
+		if perms.Uid, err = lookupId(userName, false); err != nil {
+			return nil, fmt.Errorf("Invalid socket_owner user '%s': %s", userName, err)
+		}
+	}
+	if groupName != "" {
+		if perms.Gid, err = lookupId(groupName, true); err != nil {
+			return nil, fmt.Errorf("Invalid socket_owner group '%s': %s", groupName,
+				err)
+		}
+	}
+	return perms, nil
+}
+
+func lookupId(name string, group bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	var id string
+	if group {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		id = g.Gid
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		id = u.Uid
+	}
+	return strconv.Atoi(id)
+}
+
+// Applies the permissions to the socket file, abstract sockets don't have
+// one.
+func (p *UnixSocketPerms) apply(address string) error {
+	if IsAbstractAddress(address) {
+		return nil
+	}
+	if err := os.Chmod(address, p.Mode); err != nil {
+		return fmt.Errorf("Error changing socket permissions: %s", err)
+	}
+	if p.Uid != -1 || p.Gid != -1 {
+		if err := os.Chown(address, p.Uid, p.Gid); err != nil {
+			return fmt.Errorf("Error changing socket owner: %s", err)
+		}
+	}
+	return nil
+}
+
+// Removes a socket file left behind by a process that didn't shut down
+// cleanly. A socket that still accepts connections is in use and is left
+// alone, as is anything at the path that isn't a socket.
+func RemoveStaleUnixSocket(network, address string) error {
+	if IsAbstractAddress(address) {
+		// The kernel removes abstract sockets along with their last fd.
+		return nil
+	}
+	info, err := os.Lstat(address)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("'%s' exists and is not a socket", address)
+	}
+	conn, err := net.Dial(network, address)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("Socket '%s' is in use", address)
+	}
+	if !isConnRefused(err) {
+		return fmt.Errorf("Can't tell if socket '%s' is stale: %s", address, err)
+	}
+	return os.Remove(address)
+}
+
+func isConnRefused(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.ECONNREFUSED
+}
+
+// Listens on a "unix" or "unixpacket" socket, replacing a stale socket file
+// if there is one. The socket file is removed when the listener is closed.
+func ListenUnix(network, address string, perms *UnixSocketPerms) (net.Listener,
+	error) {
+
+	if err := CheckUnixAddress(address); err != nil {
+		return nil, err
+	}
+	if err := RemoveStaleUnixSocket(network, address); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err = perms.apply(address); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Listens on a "unixgram" socket, replacing a stale socket file if there is
+// one. Unlike with ListenUnix the caller has to remove the socket file when
+// done with it.
+func ListenUnixgram(address string, perms *UnixSocketPerms) (*net.UnixConn,
+	error) {
+
+	if err := CheckUnixAddress(address); err != nil {
+		return nil, err
+	}
+	if err := RemoveStaleUnixSocket("unixgram", address); err != nil {
+		return nil, err
+	}
+	unixAddr, err := net.ResolveUnixAddr("unixgram", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", unixAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err = perms.apply(address); err != nil {
+		conn.Close()
+		os.Remove(address)
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,265 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package tcp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func UnixSocketSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tmpDir, err := ioutil.TempDir("", "unix-socket-tests")
+	c.Assume(err, gs.IsNil)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "heka.sock")
+
+	c.Specify("Unix socket permissions", func() {
+		c.Specify("default to world writable", func() {
+			perms, err := ParseUnixSocketPerms("", "")
+			c.Expect(err, gs.IsNil)
+			c.Expect(perms.Mode, gs.Equals, os.FileMode(0666))
+			c.Expect(perms.Uid, gs.Equals, -1)
+			c.Expect(perms.Gid, gs.Equals, -1)
+		})
+
+		c.Specify("accept numeric owners", func() {
+			perms, err := ParseUnixSocketPerms("0640", ":42")
+			c.Expect(err, gs.IsNil)
+			c.Expect(perms.Mode, gs.Equals, os.FileMode(0640))
+			c.Expect(perms.Uid, gs.Equals, -1)
+			c.Expect(perms.Gid, gs.Equals, 42)
+		})
+
+		c.Specify("reject invalid values", func() {
+			_, err := ParseUnixSocketPerms("rw-rw----", "")
+			c.Expect(err, gs.Not(gs.IsNil))
+			_, err = ParseUnixSocketPerms("0660", "no-such-user-hopefully")
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+	})
+
+	c.Specify("ListenUnix", func() {
+		perms := &UnixSocketPerms{Mode: 0660, Uid: -1, Gid: -1}
+
+		c.Specify("sets the socket permissions", func() {
+			listener, err := ListenUnix("unix", path, perms)
+			c.Assume(err, gs.IsNil)
+			defer listener.Close()
+			info, err := os.Stat(path)
+			c.Assume(err, gs.IsNil)
+			c.Expect(info.Mode().Perm(), gs.Equals, os.FileMode(0660))
+		})
+
+		c.Specify("replaces stale sockets", func() {
+			stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+			c.Assume(err, gs.IsNil)
+			// Leave the socket file behind as a crashed process would.
+			stale.SetUnlinkOnClose(false)
+			stale.Close()
+			_, err = os.Stat(path)
+			c.Assume(err, gs.IsNil)
+
+			listener, err := ListenUnix("unix", path, perms)
+			c.Expect(err, gs.IsNil)
+			listener.Close()
+		})
+
+		c.Specify("refuses sockets that are in use", func() {
+			other, err := net.Listen("unix", path)
+			c.Assume(err, gs.IsNil)
+			defer other.Close()
+			go func() {
+				if conn, err := other.Accept(); err == nil {
+					conn.Close()
+				}
+			}()
+
+			_, err = ListenUnix("unix", path, perms)
+			c.Expect(err, gs.Not(gs.IsNil))
+			_, err = os.Stat(path)
+			c.Expect(err, gs.IsNil)
+		})
+
+		c.Specify("leaves other files alone", func() {
+			err := ioutil.WriteFile(path, []byte("data"), 0644)
+			c.Assume(err, gs.IsNil)
+			_, err = ListenUnix("unix", path, perms)
+			c.Expect(err, gs.Not(gs.IsNil))
+			data, err := ioutil.ReadFile(path)
+			c.Expect(err, gs.IsNil)
+			c.Expect(string(data), gs.Equals, "data")
+		})
+
+		c.Specify("supports abstract sockets on linux", func() {
+			if runtime.GOOS != "linux" {
+				return
+			}
+			address := fmt.Sprintf("@heka-test-%d", os.Getpid())
+			listener, err := ListenUnix("unix", address, perms)
+			c.Assume(err, gs.IsNil)
+			defer listener.Close()
+			conn, err := net.Dial("unix", address)
+			c.Expect(err, gs.IsNil)
+			conn.Close()
+		})
+	})
+
+	c.Specify("A TcpInput on a unix socket", func() {
+		input := &TcpInput{}
+		config := input.ConfigStruct().(*TcpInputConfig)
+		config.Net = "unix"
+		config.Address = path
+		config.SocketPerm = "0600"
+
+		c.Specify("refuses keep_alive", func() {
+			config.KeepAlive = true
+			err := input.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("accepts connections", func() {
+			err := input.Init(config)
+			c.Assume(err, gs.IsNil)
+			info, err := os.Stat(path)
+			c.Assume(err, gs.IsNil)
+			c.Expect(info.Mode().Perm(), gs.Equals, os.FileMode(0600))
+
+			mockIR := pipelinemock.NewMockInputRunner(ctrl)
+			mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+			mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+			mockDel := pipelinemock.NewMockDeliverer(ctrl)
+			mockHelper.EXPECT().Hostname().Return("hekatests.example.com")
+			mockIR.EXPECT().Name().Return("UnixInput")
+			mockIR.EXPECT().NewDeliverer(path).Return(mockDel)
+			mockIR.EXPECT().NewSplitterRunner(path).Return(mockSR)
+			mockDel.EXPECT().Done()
+			mockSR.EXPECT().UseMsgBytes().Return(false)
+			var packDec func(*PipelinePack)
+			mockSR.EXPECT().SetPackDecorator(gomock.Any()).Do(
+				func(dec func(*PipelinePack)) {
+					packDec = dec
+				})
+			var srDone sync.WaitGroup
+			srDone.Add(1)
+			mockSR.EXPECT().Done().Do(func() {
+				srDone.Done()
+			})
+			received := make(chan string, 1)
+			mockSR.EXPECT().SplitStream(gomock.Any(), gomock.Any()).Do(
+				func(r io.Reader, del Deliverer) {
+					data, _ := ioutil.ReadAll(r)
+					received <- string(data)
+				}).Return(io.EOF)
+
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- input.Run(mockIR, mockHelper)
+			}()
+
+			conn, err := net.Dial("unix", path)
+			c.Assume(err, gs.IsNil)
+			_, err = conn.Write([]byte("local data"))
+			c.Expect(err, gs.IsNil)
+			conn.Close()
+			c.Expect(<-received, gs.Equals, "local data")
+
+			pack := NewPipelinePack(nil)
+			packDec(pack)
+			c.Expect(pack.Message.GetHostname(), gs.Equals, "hekatests.example.com")
+			raddr, _ := pack.Message.GetFieldValue("RemoteAddr")
+			c.Expect(raddr, gs.Equals, path+"#1")
+
+			input.Stop()
+			c.Expect(<-runErr, gs.IsNil)
+			srDone.Wait()
+			_, err = os.Stat(path)
+			c.Expect(os.IsNotExist(err), gs.IsTrue)
+		})
+	})
+
+	c.Specify("A TcpOutput on a unix socket", func() {
+		output := new(TcpOutput)
+		output.SetName("unix")
+		config := output.ConfigStruct().(*TcpOutputConfig)
+		config.Net = "unix"
+		config.Address = path
+
+		c.Specify("refuses a local_address", func() {
+			config.LocalAddress = "127.0.0.1:0"
+			err := output.Init(config)
+			c.Expect(err, gs.Not(gs.IsNil))
+		})
+
+		c.Specify("writes to the socket", func() {
+			listener, err := net.Listen("unix", path)
+			c.Assume(err, gs.IsNil)
+			defer listener.Close()
+			received := make(chan string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					received <- err.Error()
+					return
+				}
+				defer conn.Close()
+				b := make([]byte, 100)
+				n, _ := conn.Read(b)
+				received <- string(b[:n])
+			}()
+
+			useFraming := false
+			config.UseFraming = &useFraming
+			err = output.Init(config)
+			c.Assume(err, gs.IsNil)
+			mockOR := pipelinemock.NewMockOutputRunner(ctrl)
+			mockHelper := pipelinemock.NewMockPluginHelper(ctrl)
+			mockHelper.EXPECT().PipelineConfig().Return(NewPipelineConfig(nil))
+			err = output.Prepare(mockOR, mockHelper)
+			c.Assume(err, gs.IsNil)
+
+			pack := NewPipelinePack(nil)
+			pack.QueueCursor = "queuecursor"
+			mockOR.EXPECT().Encode(pack).Return([]byte("to the socket"), nil)
+			mockOR.EXPECT().UpdateCursor(pack.QueueCursor)
+			err = output.ProcessMessage(pack)
+			c.Expect(err, gs.IsNil)
+
+			select {
+			case data := <-received:
+				c.Expect(data, gs.Equals, "to the socket")
+			case <-time.After(5 * time.Second):
+				c.Expect("timed out", gs.Equals, "")
+			}
+			output.CleanUp()
+		})
+	})
+}
@@ -25,6 +25,7 @@ func TestAllSpecs(t *testing.T) {
 
 	r.AddSpec(UdpInputSpec)
 	r.AddSpec(UdpInputSpecFailure)
+	r.AddSpec(UdpInputSocketsSpec)
 	r.AddSpec(UdpOutputSpec)
 
 	gs.MainGoTest(r, t)
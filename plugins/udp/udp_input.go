@@ -17,24 +17,28 @@ package udp
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/mozilla-services/heka/message"
 	. "github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins/tcp"
 )
 
 // Input plugin implementation that listens for Heka protocol messages on a
 // specified UDP socket.
 type UdpInput struct {
-	listener    net.Conn
-	reader      UdpInputReader
+	packetCount int64
+	listeners   []net.Conn
+	udpConns    []*net.UDPConn
 	name        string
 	stopChan    chan struct{}
 	config      *UdpInputConfig
-	remote_addr string
 }
 
 // ConfigStruct for NetworkInput plugins.
@@ -43,55 +47,61 @@ type UdpInputConfig struct {
 	// input type.
 	Net string
 	// String representation of the address of the network connection on which
-	// the listener should be listening (e.g. "127.0.0.1:5565").
+	// the listener should be listening (e.g. "127.0.0.1:5565"), or the path
+	// of the socket for "unixgram", where a leading "@" selects the abstract
+	// namespace.
 	Address string
+	// Octal permissions of the "unixgram" socket file. Defaults to "0666".
+	SocketPerm string `toml:"socket_perm"`
+	// Owner of the "unixgram" socket file, as "user[:group]".
+	SocketOwner string `toml:"socket_owner"`
 	// Set Hostname field from remote address
 	SetHostname bool `toml:"set_hostname"`
+	// Number of sockets bound to the address using SO_REUSEPORT, each read
+	// from by its own goroutine. Defaults to 1.
+	Sockets int `toml:"sockets"`
+	// Size of the sockets' kernel receive buffers in bytes, 0 leaves the
+	// system default in place.
+	ReceiveBufferSize int `toml:"receive_buffer_size"`
 }
 
-// Wrap ReadFrom into Read and set Hostname
+// Wrap ReadFrom into Read, keeping track of the remote address and of the
+// number of packets received.
 type UdpInputReader struct {
-	listener *net.UDPConn
-	input *UdpInput
+	listener   *net.UDPConn
+	input      *UdpInput
+	remoteAddr string
 }
 
 func (u *UdpInput) ConfigStruct() interface{} {
 	return &UdpInputConfig{
-		Net: "udp",
+		Net:     "udp",
+		Sockets: 1,
 	}
 }
 
 func (u *UdpInput) Init(config interface{}) (err error) {
 	u.config = config.(*UdpInputConfig)
+	if u.config.Sockets < 1 {
+		u.config.Sockets = 1
+	}
+	var listener net.Conn
 
 	if u.config.Net == "unixgram" {
-		if runtime.GOOS == "windows" {
-			return errors.New(
-				"Can't use Unix datagram sockets on Windows.")
-		}
-		if runtime.GOOS != "linux" && strings.HasPrefix(u.config.Address, "@") {
-			return errors.New(
-				"Abstract sockets are linux-specific.")
-		}
 		if u.config.SetHostname {
 			return errors.New(
 				"Can't set Hostname from Unix datagram.")
 		}
-		unixAddr, err := net.ResolveUnixAddr(u.config.Net, u.config.Address)
+		// Socket files are world writable unless configured otherwise.
+		perms, err := tcp.ParseUnixSocketPerms(u.config.SocketPerm,
+			u.config.SocketOwner)
 		if err != nil {
-			return fmt.Errorf("Error resolving unixgram address: %s", err)
+			return err
 		}
-		u.listener, err = net.ListenUnixgram(u.config.Net, unixAddr)
+		listener, err = tcp.ListenUnixgram(u.config.Address, perms)
 		if err != nil {
 			return fmt.Errorf("Error listening on unixgram: %s", err)
 		}
-		// Ensure socket file is world writable, unless socket is abstract.
-		if !strings.HasPrefix(u.config.Address, "@") {
-			if err = os.Chmod(u.config.Address, 0666); err != nil {
-				return fmt.Errorf(
-					"Error changing unixgram socket permissions: %s", err)
-			}
-		}
 
 	} else if len(u.config.Address) > 3 && u.config.Address[:3] == "fd:" {
 		// File descriptor
@@ -107,7 +117,7 @@ func (u *UdpInput) Init(config interface{}) (err error) {
 		}
 		fd := uintptr(fdInt)
 		udpFile := os.NewFile(fd, "udpFile")
-		u.listener, err = net.FileConn(udpFile)
+		listener, err = net.FileConn(udpFile)
 		if err != nil {
 			return fmt.Errorf("Error accessing UDP fd: %s\n", err.Error())
 		}
@@ -117,23 +127,63 @@ func (u *UdpInput) Init(config interface{}) (err error) {
 		if err != nil {
 			return fmt.Errorf("ResolveUDPAddr failed: %s\n", err.Error())
 		}
-		u.listener, err = net.ListenUDP(u.config.Net, udpAddr)
+		u.udpConns, err = ListenUDPSockets(u.config.Net, udpAddr, u.config.Sockets,
+			u.config.ReceiveBufferSize)
 		if err != nil {
 			return fmt.Errorf("ListenUDP failed: %s\n", err.Error())
 		}
-		if u.config.SetHostname {
-			u.reader = UdpInputReader {
-				u.listener.(*net.UDPConn),
-				u,
-			}
+		for _, conn := range u.udpConns {
+			u.listeners = append(u.listeners, conn)
+		}
+	}
+	if listener != nil {
+		if u.config.Sockets > 1 || u.config.ReceiveBufferSize > 0 {
+			listener.Close()
+			return errors.New(
+				"sockets and receive_buffer_size are only supported for UDP addresses")
 		}
+		u.listeners = []net.Conn{listener}
 	}
 	u.stopChan = make(chan struct{})
 	return
 }
 
 func (u *UdpInput) Run(ir InputRunner, h PluginHelper) error {
-	sr := ir.NewSplitterRunner("")
+	var wg sync.WaitGroup
+	for i, listener := range u.listeners {
+		token := ""
+		if len(u.listeners) > 1 {
+			token = strconv.Itoa(i)
+		}
+		var reader io.Reader = &countingReader{listener, &u.packetCount}
+		if u.config.SetHostname {
+			reader = &UdpInputReader{
+				listener: listener.(*net.UDPConn),
+				input:    u,
+			}
+		}
+		wg.Add(1)
+		go u.readSocket(ir, ir.NewSplitterRunner(token), reader, &wg)
+	}
+	wg.Wait()
+
+	if u.config.Net == "unixgram" {
+		if !tcp.IsAbstractAddress(u.config.Address) {
+			err := os.Remove(u.config.Address)
+			if err != nil {
+				ir.LogError(errors.New("Error cleaning up unix datagram socket"))
+			}
+		}
+	}
+	return nil
+}
+
+// Feeds the datagrams read from one of the sockets to its splitter until the
+// input is stopped.
+func (u *UdpInput) readSocket(ir InputRunner, sr SplitterRunner, reader io.Reader,
+	wg *sync.WaitGroup) {
+
+	defer wg.Done()
 	defer sr.Done()
 	ok := true
 	var err error
@@ -142,8 +192,8 @@ func (u *UdpInput) Run(ir InputRunner, h PluginHelper) error {
 		name := ir.Name()
 		packDec := func(pack *PipelinePack) {
 			pack.Message.SetType(name)
-			if u.config.SetHostname {
-				pack.Message.SetHostname(u.remote_addr)
+			if hostReader, isHostReader := reader.(*UdpInputReader); isHostReader {
+				pack.Message.SetHostname(hostReader.remoteAddr)
 			}
 		}
 		sr.SetPackDecorator(packDec)
@@ -154,11 +204,7 @@ func (u *UdpInput) Run(ir InputRunner, h PluginHelper) error {
 		case _, ok = <-u.stopChan:
 			break
 		default:
-			if u.config.SetHostname {
-				err = sr.SplitStream(u.reader, nil)
-			} else {
-				err = sr.SplitStream(u.listener, nil)
-			}
+			err = sr.SplitStream(reader, nil)
 			// "use of closed" -> we're stopping.
 			if err != nil && !strings.Contains(err.Error(), "use of closed") {
 				ir.LogError(fmt.Errorf("Read error: %s", err))
@@ -166,28 +212,49 @@ func (u *UdpInput) Run(ir InputRunner, h PluginHelper) error {
 			sr.GetRemainingData() // reset the receiving buffer
 		}
 	}
-	if u.config.Net == "unixgram" {
-		if !strings.HasPrefix(u.config.Address, "@") {
-			err = os.Remove(u.config.Address)
-			if err != nil {
-				ir.LogError(errors.New("Error cleaning up unix datagram socket"))
-			}
+}
+
+func (u *UdpInput) Stop() {
+	close(u.stopChan)
+	for _, listener := range u.listeners {
+		listener.Close()
+	}
+}
+
+func (u *UdpInput) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "PacketCount", atomic.LoadInt64(&u.packetCount),
+		"count")
+	if len(u.udpConns) > 0 {
+		if drops, err := UDPDropCount(u.udpConns); err == nil {
+			message.NewInt64Field(msg, "DroppedPacketCount", drops, "count")
 		}
 	}
 	return nil
 }
 
-func (u *UdpInput) Stop() {
-	close(u.stopChan)
-	u.listener.Close()
+// Counts the datagrams read from a socket.
+type countingReader struct {
+	net.Conn
+	count *int64
 }
 
-func (r UdpInputReader) Read(p []byte) (n int, err error) {
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.Conn.Read(p)
+	if n > 0 {
+		atomic.AddInt64(r.count, 1)
+	}
+	return n, err
+}
+
+func (r *UdpInputReader) Read(p []byte) (n int, err error) {
 	n, addr, err := r.listener.ReadFromUDP(p)
 	if addr != nil {
-		r.input.remote_addr = addr.IP.String()
+		r.remoteAddr = addr.IP.String()
 	} else {
-		r.input.remote_addr = ""
+		r.remoteAddr = ""
+	}
+	if n > 0 {
+		atomic.AddInt64(&r.input.packetCount, 1)
 	}
 	return n, err
 }
@@ -18,6 +18,7 @@ package udp
 import (
 	"io/ioutil"
 	"net"
+	"os"
 	"path/filepath"
 	"runtime"
 
@@ -93,7 +94,7 @@ func UdpInputSpec(c gs.Context) {
 
 			err := udpInput.Init(config)
 			c.Assume(err, gs.IsNil)
-			realListener := (udpInput.listener).(*net.UDPConn)
+			realListener := (udpInput.listeners[0]).(*net.UDPConn)
 			c.Expect(realListener.LocalAddr().String(), gs.Equals, ith.ResolvedAddrStr)
 
 			c.Specify("passes the connection to SplitStream", func() {
@@ -122,7 +123,7 @@ func UdpInputSpec(c gs.Context) {
 
 				err = udpInput.Init(config)
 				c.Assume(err, gs.IsNil)
-				realListener := (udpInput.listener).(*net.UnixConn)
+				realListener := (udpInput.listeners[0]).(*net.UnixConn)
 				c.Expect(realListener.LocalAddr().String(), gs.Equals, unixPath)
 
 				c.Specify("passes the socket to SplitStream", func() {
@@ -141,6 +142,37 @@ func UdpInputSpec(c gs.Context) {
 					udpInput.Stop()
 				})
 			})
+
+			c.Specify("creating a unix datagram socket file", func() {
+				tmpDir, err := ioutil.TempDir("", "heka-socket")
+				c.Assume(err, gs.IsNil)
+				defer os.RemoveAll(tmpDir)
+				unixPath := filepath.Join(tmpDir, "unixgram-socket")
+				config.Net = "unixgram"
+				config.Address = unixPath
+
+				c.Specify("applies the socket permissions", func() {
+					config.SocketPerm = "0620"
+					err := udpInput.Init(config)
+					c.Assume(err, gs.IsNil)
+					defer udpInput.listeners[0].Close()
+					info, err := os.Stat(unixPath)
+					c.Assume(err, gs.IsNil)
+					c.Expect(info.Mode().Perm(), gs.Equals, os.FileMode(0620))
+				})
+
+				c.Specify("replaces a stale socket file", func() {
+					unixAddr, err := net.ResolveUnixAddr("unixgram", unixPath)
+					c.Assume(err, gs.IsNil)
+					stale, err := net.ListenUnixgram("unixgram", unixAddr)
+					c.Assume(err, gs.IsNil)
+					stale.Close()
+
+					err = udpInput.Init(config)
+					c.Expect(err, gs.IsNil)
+					udpInput.listeners[0].Close()
+				})
+			})
 		}
 
 		if runtime.GOOS == "linux" {
@@ -152,7 +184,7 @@ func UdpInputSpec(c gs.Context) {
 
 				err := udpInput.Init(config)
 				c.Assume(err, gs.IsNil)
-				realListener := (udpInput.listener).(*net.UnixConn)
+				realListener := (udpInput.listeners[0]).(*net.UnixConn)
 				c.Expect(realListener.LocalAddr().String(), gs.Equals, unixPath)
 
 				c.Specify("passes the socket to SplitStream", func() {
@@ -18,9 +18,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"runtime"
 
 	"github.com/mozilla-services/heka/pipeline"
+	"github.com/mozilla-services/heka/plugins/tcp"
 )
 
 // This is our plugin struct.
@@ -63,8 +63,8 @@ func (o *UdpOutput) Init(config interface{}) (err error) {
 	}
 
 	if o.Net == "unixgram" {
-		if runtime.GOOS == "windows" {
-			return errors.New("Can't use Unix datagram sockets on Windows.")
+		if err = tcp.CheckUnixAddress(o.Address); err != nil {
+			return err
 		}
 		var unixAddr, lAddr *net.UnixAddr
 		unixAddr, err = net.ResolveUnixAddr(o.Net, o.Address)
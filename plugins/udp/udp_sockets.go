@@ -0,0 +1,59 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package udp
+
+import (
+	"fmt"
+	"net"
+)
+
+// Listens on `count` UDP sockets bound to the same address, so they can be
+// read from in parallel with the kernel spreading the incoming datagrams
+// between them. More than one socket requires SO_REUSEPORT support. The
+// sockets' receive buffers are set to `rcvBufSize` bytes unless it is 0.
+func ListenUDPSockets(network string, addr *net.UDPAddr, count,
+	rcvBufSize int) (conns []*net.UDPConn, err error) {
+
+	defer func() {
+		if err != nil {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			conns = nil
+		}
+	}()
+
+	var conn *net.UDPConn
+	for i := 0; i < count; i++ {
+		if count == 1 {
+			conn, err = net.ListenUDP(network, addr)
+		} else {
+			conn, err = listenReusePort(network, addr)
+		}
+		if err != nil {
+			return conns, err
+		}
+		conns = append(conns, conn)
+		if rcvBufSize > 0 {
+			if err = conn.SetReadBuffer(rcvBufSize); err != nil {
+				return conns, fmt.Errorf("setting receive buffer size: %s", err)
+			}
+		}
+		// The remaining sockets have to bind to the port the first one got
+		// if an ephemeral port was requested.
+		addr = conn.LocalAddr().(*net.UDPAddr)
+	}
+	return conns, nil
+}
@@ -0,0 +1,136 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package udp
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func listenReusePort(network string, addr *net.UDPAddr) (*net.UDPConn, error) {
+	// Like the net package, listen on both IPv4 and IPv6 when no specific
+	// address is given.
+	dualStack := network == "udp" &&
+		(addr.IP == nil || addr.IP.Equal(net.IPv6unspecified))
+	ip4 := addr.IP.To4()
+	family := syscall.AF_INET
+	if network == "udp6" || dualStack || (ip4 == nil && addr.IP != nil) {
+		family = syscall.AF_INET6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err == syscall.EAFNOSUPPORT && dualStack {
+		// No IPv6 on this host, IPv4 will have to do.
+		dualStack = false
+		family = syscall.AF_INET
+		fd, err = syscall.Socket(family, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(fd)
+	if err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	var sa syscall.Sockaddr
+	if family == syscall.AF_INET6 {
+		if dualStack {
+			err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 0)
+			if err != nil {
+				syscall.Close(fd)
+				return nil, os.NewSyscallError("setsockopt", err)
+			}
+		}
+		sa6 := &syscall.SockaddrInet6{Port: addr.Port}
+		copy(sa6.Addr[:], addr.IP.To16())
+		sa = sa6
+	} else {
+		sa4 := &syscall.SockaddrInet4{Port: addr.Port}
+		copy(sa4.Addr[:], ip4)
+		sa = sa4
+	}
+	if err = syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	// FilePacketConn dups the descriptor, so the file can be closed after.
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("udp:%s", addr))
+	defer file.Close()
+	conn, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// Formats the address the way it is shown in /proc/net/udp{,6}, the IP
+// being made of native byte order 32 bit words.
+func procNetAddr(addr *net.UDPAddr) string {
+	ip := addr.IP.To4()
+	if ip == nil {
+		ip = addr.IP.To16()
+	}
+	if ip == nil {
+		ip = net.IPv4zero.To4()
+	}
+	words := make([]byte, len(ip))
+	for i := 0; i < len(ip); i += 4 {
+		words[i], words[i+1], words[i+2], words[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+	return fmt.Sprintf("%s:%04X", strings.ToUpper(hex.EncodeToString(words)), addr.Port)
+}
+
+// Sums up the datagrams the kernel dropped on the given sockets because
+// their receive buffers were full, as reported in /proc/net/udp{,6}.
+func UDPDropCount(conns []*net.UDPConn) (drops int64, err error) {
+	addrs := make(map[string]bool)
+	for _, conn := range conns {
+		addrs[procNetAddr(conn.LocalAddr().(*net.UDPAddr))] = true
+	}
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // Skip the header.
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 13 || !addrs[fields[1]] {
+				continue
+			}
+			count, err := strconv.ParseInt(fields[12], 10, 64)
+			if err == nil {
+				drops += count
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return drops, nil
+}
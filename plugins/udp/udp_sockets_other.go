@@ -0,0 +1,33 @@
+// +build !linux
+
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package udp
+
+import (
+	"errors"
+	"net"
+)
+
+var errNoReusePort = errors.New("listening on multiple sockets is only supported on Linux")
+
+func listenReusePort(network string, addr *net.UDPAddr) (*net.UDPConn, error) {
+	return nil, errNoReusePort
+}
+
+// Drop counters are only available on Linux.
+func UDPDropCount(conns []*net.UDPConn) (int64, error) {
+	return 0, errors.New("UDP drop counts are only available on Linux")
+}
@@ -0,0 +1,169 @@
+/***** BEGIN LICENSE BLOCK *****
+# This Source Code Form is subject to the terms of the Mozilla Public
+# License, v. 2.0. If a copy of the MPL was not distributed with this file,
+# You can obtain one at http://mozilla.org/MPL/2.0/.
+#
+# The Initial Developer of the Original Code is the Mozilla Foundation.
+# Portions created by the Initial Developer are Copyright (C) 2026
+# the Initial Developer. All Rights Reserved.
+#
+# Contributor(s):
+#   agent (agent@local)
+#
+# ***** END LICENSE BLOCK *****/
+
+package udp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/mozilla-services/heka/message"
+	. "github.com/mozilla-services/heka/pipeline"
+	pipeline_ts "github.com/mozilla-services/heka/pipeline/testsupport"
+	"github.com/mozilla-services/heka/pipelinemock"
+	"github.com/rafrombrc/gomock/gomock"
+	gs "github.com/rafrombrc/gospec/src/gospec"
+)
+
+func UdpInputSocketsSpec(c gs.Context) {
+	t := &pipeline_ts.SimpleT{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c.Specify("A UdpInput", func() {
+		udpInput := UdpInput{}
+		config := udpInput.ConfigStruct().(*UdpInputConfig)
+		config.Address = "127.0.0.1:0"
+
+		if runtime.GOOS != "windows" {
+			c.Specify("only tunes UDP sockets", func() {
+				tmpDir, err := ioutil.TempDir("", "heka-socket")
+				c.Assume(err, gs.IsNil)
+				defer os.RemoveAll(tmpDir)
+				config.Net = "unixgram"
+				config.Address = filepath.Join(tmpDir, "unixgram-socket")
+				config.ReceiveBufferSize = 1 << 20
+				err = udpInput.Init(config)
+				c.Expect(err, gs.Not(gs.IsNil))
+			})
+		}
+
+		c.Specify("sets the receive buffer size", func() {
+			config.ReceiveBufferSize = 1 << 16
+			err := udpInput.Init(config)
+			c.Assume(err, gs.IsNil)
+			c.Expect(len(udpInput.listeners), gs.Equals, 1)
+			udpInput.Stop()
+		})
+
+		if runtime.GOOS != "linux" {
+			return
+		}
+
+		c.Specify("reads from multiple sockets", func() {
+			config.Sockets = 2
+			err := udpInput.Init(config)
+			c.Assume(err, gs.IsNil)
+			c.Assume(len(udpInput.listeners), gs.Equals, 2)
+			addr := udpInput.listeners[0].LocalAddr().String()
+			c.Expect(udpInput.listeners[1].LocalAddr().String(), gs.Equals, addr)
+
+			mockIR := pipelinemock.NewMockInputRunner(ctrl)
+			mockIR.EXPECT().LogError(gomock.Any()).AnyTimes()
+			received := make(chan string, 100)
+			for _, token := range []string{"0", "1"} {
+				mockSR := pipelinemock.NewMockSplitterRunner(ctrl)
+				mockIR.EXPECT().NewSplitterRunner(token).Return(mockSR)
+				mockSR.EXPECT().UseMsgBytes().Return(true)
+				mockSR.EXPECT().GetRemainingData().AnyTimes()
+				mockSR.EXPECT().Done()
+				mockSR.EXPECT().SplitStream(gomock.Any(), nil).Do(
+					func(conn net.Conn, del Deliverer) {
+						recd := make([]byte, 100)
+						if n, _ := conn.Read(recd); n > 0 {
+							received <- string(recd[:n])
+						}
+					}).AnyTimes()
+			}
+			done := make(chan error)
+			go func() {
+				done <- udpInput.Run(mockIR, nil)
+			}()
+
+			// Each sender gets its own source port, which the kernel hashes to
+			// pick the socket.
+			for i := 0; i < 20; i++ {
+				conn, err := net.Dial("udp", addr)
+				c.Assume(err, gs.IsNil)
+				_, err = conn.Write([]byte(fmt.Sprintf("packet %d", i)))
+				c.Expect(err, gs.IsNil)
+				conn.Close()
+			}
+			packets := make(map[string]bool)
+			for i := 0; i < 20; i++ {
+				packets[<-received] = true
+			}
+			c.Expect(len(packets), gs.Equals, 20)
+
+			udpInput.Stop()
+			c.Expect(<-done, gs.IsNil)
+
+			msg := new(message.Message)
+			udpInput.ReportMsg(msg)
+			count, _ := msg.GetFieldValue("PacketCount")
+			c.Expect(count, gs.Equals, int64(20))
+			drops, ok := msg.GetFieldValue("DroppedPacketCount")
+			c.Expect(ok, gs.IsTrue)
+			c.Expect(drops, gs.Equals, int64(0))
+		})
+
+		c.Specify("listens on IPv4 and IPv6 without a specific address", func() {
+			conns, err := ListenUDPSockets("udp", &net.UDPAddr{}, 2, 0)
+			c.Assume(err, gs.IsNil)
+			defer func() {
+				for _, conn := range conns {
+					conn.Close()
+				}
+			}()
+			port := conns[0].LocalAddr().(*net.UDPAddr).Port
+			c.Expect(conns[1].LocalAddr().(*net.UDPAddr).Port, gs.Equals, port)
+			for _, host := range []string{"127.0.0.1", "::1"} {
+				conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
+					IP: net.ParseIP(host), Port: port})
+				c.Assume(err, gs.IsNil)
+				_, err = conn.Write([]byte("packet"))
+				c.Expect(err, gs.IsNil)
+				conn.Close()
+			}
+			packets := make(chan string, 2)
+			for _, conn := range conns {
+				go func(conn *net.UDPConn) {
+					recd := make([]byte, 100)
+					for {
+						n, from, err := conn.ReadFromUDP(recd)
+						if err != nil {
+							return
+						}
+						packets <- fmt.Sprintf("%s %s", from.IP, recd[:n])
+					}
+				}(conn)
+			}
+			received := []string{<-packets, <-packets}
+			c.Expect(received, gs.ContainsExactly, []string{
+				"127.0.0.1 packet", "::1 packet"})
+		})
+
+		c.Specify("formats addresses like /proc/net/udp", func() {
+			addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8125}
+			c.Expect(procNetAddr(addr), gs.Equals, "0100007F:1FBD")
+			addr = &net.UDPAddr{IP: net.ParseIP("::1"), Port: 53}
+			c.Expect(procNetAddr(addr), gs.Equals,
+				"00000000000000000000000001000000:0035")
+		})
+	})
+}